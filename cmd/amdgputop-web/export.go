@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/config"
+	"github.com/skobkin/amdgputop-web/internal/export"
+	"github.com/skobkin/amdgputop-web/internal/tsdb"
+)
+
+// runExport implements `amdgputop-web export`, dumping samples persisted in
+// the data directory without going through a running server.
+func runExport(cfg config.Config, args []string, stdout io.Writer) (err error) {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	dataDir := fs.String("data-dir", cfg.DataDir, "Data directory of the history store (APP_DATA_DIR)")
+	gpuID := fs.String("gpu", "", "GPU id to export (all GPUs when empty)")
+	fromFlag := fs.String("from", "", "Start of range: RFC 3339, unix seconds or duration ago")
+	toFlag := fs.String("to", "", "End of range: RFC 3339, unix seconds or duration ago")
+	formatFlag := fs.String("format", string(export.CSV), "Output format: csv or ndjson")
+	output := fs.String("o", "", "Output file (stdout when empty)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+
+		return err
+	}
+
+	if *dataDir == "" {
+		return errors.New("data directory not set; pass -data-dir or APP_DATA_DIR")
+	}
+	format, err := export.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	from, err := export.ParseTime(*fromFlag, now)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	to, err := export.ParseTime(*toFlag, now)
+	if err != nil {
+		return fmt.Errorf("invalid -to: %w", err)
+	}
+
+	store, err := tsdb.Open(tsdb.Options{Dir: *dataDir, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	out := stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer func() {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		out = file
+	}
+
+	return export.Write(out, store, format, *gpuID, from, to)
+}
@@ -1,8 +1,10 @@
-// Command amdgputop-web runs the HTTP/WebSocket server.
+// Command amdgputop-web runs the HTTP/WebSocket server. The export subcommand
+// dumps persisted samples as CSV or NDJSON.
 package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -33,6 +35,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(cfg, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "export:", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: cfg.LogLevel})
 	logger := slog.New(handler)
 
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/skobkin/amdgputop-web/internal/api"
+	"github.com/skobkin/amdgputop-web/internal/capture"
 	"github.com/skobkin/amdgputop-web/internal/config"
 	"github.com/skobkin/amdgputop-web/internal/gpu"
 	"github.com/skobkin/amdgputop-web/internal/procscan"
@@ -27,6 +28,8 @@ type options struct {
 	collectProcs bool
 	gpuFilter    string
 	jsonOutput   bool
+	capturePath  string
+	redact       bool
 	procCfg      config.ProcConfig
 }
 
@@ -51,6 +54,8 @@ func parseFlags(base config.Config) options {
 	flag.BoolVar(&opts.collectProcs, "procs", opts.collectProcs, "Collect process snapshots when sampling")
 	flag.StringVar(&opts.gpuFilter, "gpu", defaultGPU, "Limit sampling to specific GPU id")
 	flag.BoolVar(&opts.jsonOutput, "json", false, "Emit discovery result as JSON")
+	flag.StringVar(&opts.capturePath, "capture", "", "Archive the sysfs/debugfs/procfs files read by the app into a .tar.gz fixture and exit")
+	flag.BoolVar(&opts.redact, "redact", true, "Keep only argv[0] of captured process command lines")
 	flag.Parse()
 
 	opts.procCfg.Enable = opts.collectProcs
@@ -69,6 +74,15 @@ func main() {
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
 
+	if opts.capturePath != "" {
+		if err := runCapture(opts, logger); err != nil {
+			logger.Error("capture failed", "err", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	infos, err := gpu.Discover(opts.sysfsRoot, logger.With("component", "gpu_discovery"))
 	if err != nil {
 		logger.Error("gpu discovery failed", "err", err)
@@ -277,6 +291,36 @@ func main() {
 	}
 }
 
+func runCapture(opts options, logger *slog.Logger) error {
+	file, err := os.Create(opts.capturePath)
+	if err != nil {
+		return err
+	}
+
+	summary, err := capture.Write(file, capture.Options{
+		SysfsRoot:      opts.sysfsRoot,
+		DebugfsRoot:    opts.debugfsRoot,
+		ProcRoot:       opts.procRoot,
+		RedactCmdlines: opts.redact,
+		Logger:         logger.With("component", "capture"),
+	})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Captured %d GPU(s), %d process(es), %d file(s) into %s", summary.GPUs, summary.Processes, summary.Files, opts.capturePath)
+	if summary.Skipped > 0 {
+		fmt.Printf(" (%d unreadable, run as root for debugfs and other users' processes)", summary.Skipped)
+	}
+	fmt.Println()
+	fmt.Printf("Extract and point APP_SYSFS_ROOT, APP_DEBUGFS_ROOT and APP_PROC_ROOT at the %s/, %s/ and %s/ directories.\n", capture.SysfsDir, capture.DebugfsDir, capture.ProcDir)
+
+	return nil
+}
+
 func waitUntil(timeout time.Duration, condition func() bool) bool {
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
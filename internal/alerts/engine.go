@@ -0,0 +1,435 @@
+// Package alerts evaluates threshold rules against sampler samples and tracks
+// the resulting alerts through pending, firing and resolved states.
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+// State is the lifecycle stage of an alert.
+type State string
+
+// Alert states. Pending alerts wait for their rule's For duration; firing
+// alerts stay active until the clear condition holds.
+const (
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// DefaultSeverity is assigned to rules without a severity.
+const DefaultSeverity = "warning"
+
+// ErrUnknownRule is returned when silencing a rule that does not exist.
+var ErrUnknownRule = errors.New("unknown alert rule")
+
+// Rule describes a threshold condition. Expr starts the alert; Clear, when
+// set, must hold to resolve it, providing hysteresis (e.g. fire above 95°C,
+// resolve below 90°C). Without Clear the alert resolves once Expr is false.
+type Rule struct {
+	Name     string `json:"name"`
+	Expr     string `json:"expr"`
+	For      string `json:"for,omitempty"`
+	Clear    string `json:"clear,omitempty"`
+	GPU      string `json:"gpu,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	// Silenced mutes the rule permanently; it is still evaluated and listed.
+	Silenced bool `json:"silenced,omitempty"`
+}
+
+// LoadRules reads a JSON array of rules from path.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read alert rules file: %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("decode alert rules file: %w", err)
+	}
+
+	return rules, nil
+}
+
+// Alert is the state of one rule on one GPU.
+type Alert struct {
+	Rule        string     `json:"rule"`
+	GPUId       string     `json:"gpu_id"`
+	State       State      `json:"state"`
+	Severity    string     `json:"severity"`
+	Expr        string     `json:"expr"`
+	Value       *float64   `json:"value"`
+	ActiveSince time.Time  `json:"active_since"`
+	FiredAt     *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	Silenced    bool       `json:"silenced"`
+}
+
+// Silence mutes notifications of a rule, on one GPU or on all when GPUId is
+// empty, until the given time.
+type Silence struct {
+	Rule  string    `json:"rule"`
+	GPUId string    `json:"gpu_id,omitempty"`
+	Until time.Time `json:"until"`
+}
+
+type compiledRule struct {
+	Rule
+	expr  *condition
+	clear *condition
+	hold  time.Duration
+}
+
+type alertKey struct {
+	rule  string
+	gpuID string
+}
+
+// Engine evaluates rules against every observed sample. Observe is meant to
+// be registered as a sampler listener.
+type Engine struct {
+	rules  []compiledRule
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu          sync.Mutex
+	active      map[alertKey]*Alert
+	silences    map[alertKey]time.Time
+	subscribers map[*subscriber]struct{}
+}
+
+// NewEngine compiles rules. Rule names must be unique.
+func NewEngine(rules []Rule, logger *slog.Logger) (*Engine, error) {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	compiled := make([]compiledRule, 0, len(rules))
+	seen := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i)
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("rule %q: duplicate name", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		cr := compiledRule{Rule: rule}
+		if cr.Severity == "" {
+			cr.Severity = DefaultSeverity
+		}
+		var err error
+		if cr.expr, err = parseCondition(rule.Expr); err != nil {
+			return nil, fmt.Errorf("rule %q: expr: %w", rule.Name, err)
+		}
+		if rule.Clear != "" {
+			if cr.clear, err = parseCondition(rule.Clear); err != nil {
+				return nil, fmt.Errorf("rule %q: clear: %w", rule.Name, err)
+			}
+		}
+		if rule.For != "" {
+			if cr.hold, err = time.ParseDuration(rule.For); err != nil || cr.hold < 0 {
+				return nil, fmt.Errorf("rule %q: invalid for %q", rule.Name, rule.For)
+			}
+		}
+		compiled = append(compiled, cr)
+	}
+
+	return &Engine{
+		rules:       compiled,
+		logger:      logger.With("component", "alerts"),
+		now:         time.Now,
+		active:      make(map[alertKey]*Alert),
+		silences:    make(map[alertKey]time.Time),
+		subscribers: make(map[*subscriber]struct{}),
+	}, nil
+}
+
+// Observe evaluates all rules against a sample.
+func (e *Engine) Observe(sample sampler.Sample) {
+	if sample.Suspended() || sample.Quarantined {
+		// A sleeping GPU is not read and a hung reader returns nothing;
+		// their alerts keep their state until readings come back.
+		return
+	}
+	v := metricValues(sample.Metrics)
+	ts := sample.Timestamp
+
+	e.mu.Lock()
+	var changed []Alert
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if rule.GPU != "" && rule.GPU != sample.GPUId {
+			continue
+		}
+		if alert, ok := e.evaluate(rule, sample.GPUId, v, ts); ok {
+			changed = append(changed, alert)
+		}
+	}
+	subs := make([]*subscriber, 0, len(e.subscribers))
+	for sub := range e.subscribers {
+		subs = append(subs, sub)
+	}
+	e.mu.Unlock()
+
+	for _, alert := range changed {
+		e.logger.Info("alert state changed", "rule", alert.Rule, "gpu_id", alert.GPUId, "state", alert.State, "silenced", alert.Silenced)
+		if alert.Silenced {
+			continue
+		}
+		for _, sub := range subs {
+			sub.send(alert)
+		}
+	}
+}
+
+// evaluate advances the state machine of one rule on one GPU and reports
+// the alert when its state changed. A condition that cannot be evaluated
+// because a metric is missing neither raises nor resolves an alert. Callers
+// hold e.mu.
+func (e *Engine) evaluate(rule *compiledRule, gpuID string, v values, ts time.Time) (Alert, bool) {
+	key := alertKey{rule: rule.Name, gpuID: gpuID}
+	alert := e.active[key]
+
+	holds, known := rule.expr.holds(v)
+	if alert == nil {
+		if !holds {
+			return Alert{}, false
+		}
+		alert = &Alert{
+			Rule:        rule.Name,
+			GPUId:       gpuID,
+			State:       StatePending,
+			Severity:    rule.Severity,
+			Expr:        rule.Expr,
+			ActiveSince: ts,
+		}
+		e.active[key] = alert
+		e.update(rule, alert, v, ts)
+		if rule.hold == 0 {
+			e.fire(alert, ts)
+		}
+
+		return *alert, true
+	}
+
+	e.update(rule, alert, v, ts)
+	switch alert.State {
+	case StatePending:
+		if !known {
+			return Alert{}, false
+		}
+		if !holds {
+			// Cleared before For elapsed; report it so clients drop the pending entry.
+			delete(e.active, key)
+			alert.State = StateResolved
+			alert.ResolvedAt = &ts
+
+			return *alert, true
+		}
+		if ts.Sub(alert.ActiveSince) >= rule.hold {
+			e.fire(alert, ts)
+
+			return *alert, true
+		}
+	case StateFiring:
+		resolved := known && !holds
+		if rule.clear != nil {
+			resolved, _ = rule.clear.holds(v)
+		}
+		if resolved {
+			delete(e.active, key)
+			alert.State = StateResolved
+			alert.ResolvedAt = &ts
+
+			return *alert, true
+		}
+	}
+
+	return Alert{}, false
+}
+
+func (e *Engine) fire(alert *Alert, ts time.Time) {
+	alert.State = StateFiring
+	alert.FiredAt = &ts
+}
+
+func (e *Engine) update(rule *compiledRule, alert *Alert, v values, ts time.Time) {
+	alert.Value = nil
+	if value, ok := rule.expr.value(v); ok {
+		alert.Value = &value
+	}
+	alert.Silenced = rule.Silenced || e.silencedLocked(rule.Name, alert.GPUId, ts)
+}
+
+func (e *Engine) silencedLocked(rule, gpuID string, now time.Time) bool {
+	for _, key := range []alertKey{{rule: rule, gpuID: gpuID}, {rule: rule}} {
+		if until, ok := e.silences[key]; ok && now.Before(until) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Active lists pending and firing alerts ordered by rule and GPU.
+func (e *Engine) Active() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	alerts := make([]Alert, 0, len(e.active))
+	for _, alert := range e.active {
+		copied := *alert
+		copied.Silenced = copied.Silenced || e.silencedLocked(alert.Rule, alert.GPUId, now)
+		alerts = append(alerts, copied)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+
+		return alerts[i].GPUId < alerts[j].GPUId
+	})
+
+	return alerts
+}
+
+// Rules returns the configured rules.
+func (e *Engine) Rules() []Rule {
+	rules := make([]Rule, 0, len(e.rules))
+	for _, rule := range e.rules {
+		rules = append(rules, rule.Rule)
+	}
+
+	return rules
+}
+
+// Silence mutes rule on gpuID (all GPUs when empty) until the given time. A
+// time in the past lifts the silence.
+func (e *Engine) Silence(rule, gpuID string, until time.Time) error {
+	known := false
+	for _, r := range e.rules {
+		if r.Name == rule {
+			known = true
+
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("%w %q", ErrUnknownRule, rule)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := alertKey{rule: rule, gpuID: gpuID}
+	if !until.After(e.now()) {
+		delete(e.silences, key)
+
+		return nil
+	}
+	e.silences[key] = until
+
+	return nil
+}
+
+// Silences lists silences that have not expired yet.
+func (e *Engine) Silences() []Silence {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	silences := make([]Silence, 0, len(e.silences))
+	for key, until := range e.silences {
+		if !now.Before(until) {
+			delete(e.silences, key)
+
+			continue
+		}
+		silences = append(silences, Silence{Rule: key.rule, GPUId: key.gpuID, Until: until})
+	}
+	sort.Slice(silences, func(i, j int) bool {
+		if silences[i].Rule != silences[j].Rule {
+			return silences[i].Rule < silences[j].Rule
+		}
+
+		return silences[i].GPUId < silences[j].GPUId
+	})
+
+	return silences
+}
+
+// Subscribe streams alert state changes of unsilenced alerts. Slow
+// subscribers lose the oldest pending changes; each loss is logged.
+func (e *Engine) Subscribe() (<-chan Alert, func()) {
+	sub := &subscriber{ch: make(chan Alert, 16), logger: e.logger}
+
+	e.mu.Lock()
+	e.subscribers[sub] = struct{}{}
+	e.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			e.mu.Lock()
+			delete(e.subscribers, sub)
+			e.mu.Unlock()
+			sub.close()
+		})
+	}
+
+	return sub.ch, cancel
+}
+
+type subscriber struct {
+	ch     chan Alert
+	logger *slog.Logger
+	mu     sync.Mutex
+	closed bool
+}
+
+func (s *subscriber) send(alert Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- alert:
+		return
+	default:
+		select {
+		case dropped := <-s.ch:
+			s.logger.Warn("alert subscriber lagging, dropped state change",
+				"rule", dropped.Rule, "gpu_id", dropped.GPUId, "state", dropped.State)
+		default:
+		}
+		select {
+		case s.ch <- alert:
+		default:
+		}
+	}
+}
+
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.ch)
+}
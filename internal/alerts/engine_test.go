@@ -0,0 +1,190 @@
+package alerts
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+func tempSample(gpuID string, ts time.Time, temp float64) sampler.Sample {
+	return sampler.Sample{GPUId: gpuID, Timestamp: ts, Metrics: sampler.Metrics{TempC: &temp}}
+}
+
+func TestEngineLifecycleWithHysteresis(t *testing.T) {
+	t.Parallel()
+
+	engine, err := NewEngine([]Rule{{Name: "gpu_hot", Expr: "temp_c > 95", For: "30s", Clear: "temp_c < 90", Severity: "critical"}}, nil)
+	if err != nil {
+		t.Fatalf("NewEngine returned error: %v", err)
+	}
+	updates, cancel := engine.Subscribe()
+	defer cancel()
+
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	expectState := func(want State) Alert {
+		t.Helper()
+		select {
+		case alert := <-updates:
+			if alert.State != want {
+				t.Fatalf("expected %s, got %+v", want, alert)
+			}
+
+			return alert
+		default:
+			t.Fatalf("expected %s update", want)
+		}
+
+		return Alert{}
+	}
+	expectQuiet := func() {
+		t.Helper()
+		select {
+		case alert := <-updates:
+			t.Fatalf("unexpected update %+v", alert)
+		default:
+		}
+	}
+
+	engine.Observe(tempSample("card0", base, 96))
+	expectState(StatePending)
+
+	engine.Observe(tempSample("card0", base.Add(20*time.Second), 97))
+	expectQuiet()
+
+	engine.Observe(tempSample("card0", base.Add(30*time.Second), 97))
+	fired := expectState(StateFiring)
+	if fired.Value == nil || *fired.Value != 97 || fired.Severity != "critical" || fired.FiredAt == nil {
+		t.Fatalf("unexpected firing alert %+v", fired)
+	}
+	if active := engine.Active(); len(active) != 1 || active[0].State != StateFiring {
+		t.Fatalf("unexpected active alerts %+v", active)
+	}
+
+	// Between the clear and fire thresholds the alert keeps firing.
+	engine.Observe(tempSample("card0", base.Add(40*time.Second), 92))
+	expectQuiet()
+
+	engine.Observe(tempSample("card0", base.Add(50*time.Second), 89))
+	expectState(StateResolved)
+	if active := engine.Active(); len(active) != 0 {
+		t.Fatalf("expected no active alerts, got %+v", active)
+	}
+
+	// A pending alert that clears before For elapses never fires.
+	engine.Observe(tempSample("card0", base.Add(60*time.Second), 96))
+	expectState(StatePending)
+	engine.Observe(tempSample("card0", base.Add(70*time.Second), 80))
+	if resolved := expectState(StateResolved); resolved.FiredAt != nil {
+		t.Fatalf("expected pending alert to resolve without firing, got %+v", resolved)
+	}
+}
+
+func TestEngineSilencesAndGPUFilter(t *testing.T) {
+	t.Parallel()
+
+	engine, err := NewEngine([]Rule{
+		{Name: "fan_stopped", Expr: "fan_rpm == 0 while temp_c > 70"},
+		{Name: "card1_hot", Expr: "temp_c > 80", GPU: "card1"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewEngine returned error: %v", err)
+	}
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return base }
+
+	if err := engine.Silence("missing", "", base.Add(time.Hour)); !errors.Is(err, ErrUnknownRule) {
+		t.Fatalf("expected ErrUnknownRule, got %v", err)
+	}
+	if err := engine.Silence("fan_stopped", "card0", base.Add(time.Hour)); err != nil {
+		t.Fatalf("Silence returned error: %v", err)
+	}
+	updates, cancel := engine.Subscribe()
+	defer cancel()
+
+	fan, temp := 0.0, 85.0
+	for _, gpuID := range []string{"card0", "card1"} {
+		engine.Observe(sampler.Sample{GPUId: gpuID, Timestamp: base, Metrics: sampler.Metrics{FanRPM: &fan, TempC: &temp}})
+	}
+
+	active := engine.Active()
+	if len(active) != 3 {
+		t.Fatalf("expected three active alerts, got %+v", active)
+	}
+	if active[1].Rule != "fan_stopped" || active[1].GPUId != "card0" || !active[1].Silenced || active[2].Silenced {
+		t.Fatalf("unexpected silencing %+v", active)
+	}
+
+	// Only unsilenced changes are pushed: card1_hot and fan_stopped on card1.
+	if got := len(updates); got != 2 {
+		t.Fatalf("expected 2 pushed updates, got %d", got)
+	}
+	if silences := engine.Silences(); len(silences) != 1 || silences[0].GPUId != "card0" {
+		t.Fatalf("unexpected silences %+v", silences)
+	}
+	if err := engine.Silence("fan_stopped", "card0", time.Time{}); err != nil || len(engine.Silences()) != 0 {
+		t.Fatalf("expected silence to be lifted, err %v", err)
+	}
+}
+
+func TestNewEngineValidatesRules(t *testing.T) {
+	t.Parallel()
+
+	invalid := [][]Rule{
+		{{Expr: "temp_c > 1"}},
+		{{Name: "a", Expr: "temp_c > 1"}, {Name: "a", Expr: "temp_c > 2"}},
+		{{Name: "a", Expr: "temp > 1"}},
+		{{Name: "a", Expr: "temp_c > 1", Clear: "temp_c <"}},
+		{{Name: "a", Expr: "temp_c > 1", For: "soon"}},
+	}
+	for i, rules := range invalid {
+		if _, err := NewEngine(rules, nil); err == nil {
+			t.Fatalf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestEngineIgnoresSuspendedGPUs(t *testing.T) {
+	t.Parallel()
+
+	engine, err := NewEngine([]Rule{{Name: "no_readings", Expr: "readings == 0"}}, nil)
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	engine.Observe(sampler.Sample{GPUId: "card0", Timestamp: time.Now(), PowerState: sampler.PowerStateSuspended})
+	if active := engine.Active(); len(active) != 0 {
+		t.Fatalf("expected suspended GPU not to be evaluated, got %+v", active)
+	}
+	engine.Observe(sampler.Sample{GPUId: "card0", Timestamp: time.Now()})
+	if active := engine.Active(); len(active) != 1 {
+		t.Fatalf("expected reader failure to fire, got %+v", active)
+	}
+}
+
+func TestEngineKeepsFiringAlertsWithoutReadings(t *testing.T) {
+	t.Parallel()
+
+	engine, err := NewEngine([]Rule{{Name: "hot", Expr: "temp_c > 90"}}, nil)
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	hot, cool := 95.0, 50.0
+	base := time.Now()
+	engine.Observe(sampler.Sample{GPUId: "card0", Timestamp: base, Metrics: sampler.Metrics{TempC: &hot}})
+	if active := engine.Active(); len(active) != 1 || active[0].State != StateFiring {
+		t.Fatalf("expected firing alert, got %+v", active)
+	}
+
+	// Neither a failed read nor a hung reader says the GPU cooled down.
+	engine.Observe(sampler.Sample{GPUId: "card0", Timestamp: base.Add(time.Second)})
+	engine.Observe(sampler.Sample{GPUId: "card0", Timestamp: base.Add(2 * time.Second), Quarantined: true})
+	if active := engine.Active(); len(active) != 1 || active[0].State != StateFiring {
+		t.Fatalf("expected alert to keep firing without readings, got %+v", active)
+	}
+
+	engine.Observe(sampler.Sample{GPUId: "card0", Timestamp: base.Add(3 * time.Second), Metrics: sampler.Metrics{TempC: &cool}})
+	if active := engine.Active(); len(active) != 0 {
+		t.Fatalf("expected alert to resolve once the reading is back, got %+v", active)
+	}
+}
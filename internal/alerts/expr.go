@@ -0,0 +1,412 @@
+package alerts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+// Expressions compare sampler metrics, e.g. "temp_c > 95",
+// "vram_used / vram_total > 0.95" or "fan_rpm == 0 while temp_c > 70".
+//
+//	expr   = and { ("or" | "||") and }
+//	and    = cmp { ("and" | "while" | "&&") cmp }
+//	cmp    = sum [ (">" | ">=" | "<" | "<=" | "==" | "!=") sum ]
+//	sum    = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/") factor }
+//	factor = number | metric | "(" expr ")" | "-" factor
+//
+// Metric names are the JSON names of sampler.Metrics; the "_bytes" suffix
+// may be omitted. "readings" counts the metrics present in the sample, so
+// "readings == 0" matches a reader that failed altogether. A comparison
+// involving a missing reading is false.
+
+type values map[string]float64
+
+const readingsName = "readings"
+
+// metricValues collects the readings present in a sample.
+func metricValues(m sampler.Metrics) values {
+	out := make(values, len(sampler.MetricFields)+1)
+	for _, field := range sampler.MetricFields {
+		if value, ok := field.Value(m); ok {
+			out[field.Name] = value
+		}
+	}
+	out[readingsName] = float64(len(out))
+
+	return out
+}
+
+type node interface {
+	eval(v values) (float64, bool)
+}
+
+type number float64
+
+func (n number) eval(values) (float64, bool) { return float64(n), true }
+
+type metric string
+
+func (m metric) eval(v values) (float64, bool) {
+	value, ok := v[string(m)]
+
+	return value, ok
+}
+
+type negate struct{ x node }
+
+func (n negate) eval(v values) (float64, bool) {
+	x, ok := n.x.eval(v)
+
+	return -x, ok
+}
+
+type binary struct {
+	op   string
+	l, r node
+}
+
+func (b binary) eval(v values) (float64, bool) {
+	// and/or follow three-valued logic: a side that cannot be evaluated
+	// only matters when the other side does not decide the result.
+	switch b.op {
+	case "and":
+		l, lok := b.l.eval(v)
+		r, rok := b.r.eval(v)
+		if (lok && l == 0) || (rok && r == 0) {
+			return 0, true
+		}
+
+		return 1, lok && rok
+	case "or":
+		l, lok := b.l.eval(v)
+		r, rok := b.r.eval(v)
+		if (lok && l != 0) || (rok && r != 0) {
+			return 1, true
+		}
+
+		return 0, lok && rok
+	}
+
+	l, lok := b.l.eval(v)
+	r, rok := b.r.eval(v)
+	if !lok || !rok {
+		return 0, false
+	}
+	switch b.op {
+	case "+":
+		return l + r, true
+	case "-":
+		return l - r, true
+	case "*":
+		return l * r, true
+	case "/":
+		if r == 0 {
+			return 0, false
+		}
+
+		return l / r, true
+	case ">":
+		return truth(l > r), true
+	case ">=":
+		return truth(l >= r), true
+	case "<":
+		return truth(l < r), true
+	case "<=":
+		return truth(l <= r), true
+	case "==":
+		return truth(l == r), true
+	case "!=":
+		return truth(l != r), true
+	}
+
+	return 0, false
+}
+
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func isComparison(op string) bool {
+	switch op {
+	case ">", ">=", "<", "<=", "==", "!=":
+		return true
+	}
+
+	return false
+}
+
+// condition is a compiled expression. subject is the left-hand side of the
+// first comparison, reported as the alert value.
+type condition struct {
+	text    string
+	root    node
+	subject node
+}
+
+// holds reports whether the condition is true. ok is false when it cannot
+// be evaluated, e.g. because a metric it compares was not read.
+func (c *condition) holds(v values) (holds, ok bool) {
+	value, ok := c.root.eval(v)
+
+	return ok && value != 0, ok
+}
+
+func (c *condition) value(v values) (float64, bool) {
+	if c.subject == nil {
+		return 0, false
+	}
+
+	return c.subject.eval(v)
+}
+
+func parseCondition(text string) (*condition, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek())
+	}
+	if !isBoolean(root) {
+		return nil, fmt.Errorf("expression %q is not a comparison", text)
+	}
+
+	return &condition{text: text, root: root, subject: p.subject}, nil
+}
+
+func isBoolean(n node) bool {
+	b, ok := n.(binary)
+
+	return ok && (b.op == "and" || b.op == "or" || isComparison(b.op))
+}
+
+type parser struct {
+	tokens  []string
+	pos     int
+	subject node
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *parser) peek() string {
+	if p.done() {
+		return ""
+	}
+
+	return p.tokens[p.pos]
+}
+
+func (p *parser) accept(options ...string) (string, bool) {
+	tok := p.peek()
+	for _, option := range options {
+		if tok == option {
+			p.pos++
+
+			return tok, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *parser) expr() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("or", "||"); !ok {
+			return left, nil
+		}
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: "or", l: left, r: right}
+	}
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.cmp()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("and", "while", "&&"); !ok {
+			return left, nil
+		}
+		right, err := p.cmp()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: "and", l: left, r: right}
+	}
+}
+
+func (p *parser) cmp() (node, error) {
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept(">", ">=", "<", "<=", "==", "!=")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.subject == nil {
+		p.subject = left
+	}
+
+	return binary{op: op, l: left, r: right}, nil
+}
+
+func (p *parser) sum() (node, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, l: left, r: right}
+	}
+}
+
+func (p *parser) term() (node, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, l: left, r: right}
+	}
+}
+
+func (p *parser) factor() (node, error) {
+	if p.done() {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch {
+	case tok == "(":
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+
+		return inner, nil
+	case tok == "-":
+		inner, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+
+		return negate{x: inner}, nil
+	case unicode.IsDigit(rune(tok[0])) || tok[0] == '.':
+		value, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok)
+		}
+
+		return number(value), nil
+	case unicode.IsLetter(rune(tok[0])) || tok[0] == '_':
+		return resolveMetric(tok)
+	}
+
+	return nil, fmt.Errorf("unexpected %q", tok)
+}
+
+func resolveMetric(name string) (metric, error) {
+	if name == readingsName {
+		return metric(name), nil
+	}
+	for _, candidate := range []string{name, name + "_bytes"} {
+		for _, field := range sampler.MetricFields {
+			if field.Name == candidate {
+				return metric(candidate), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("unknown metric %q", name)
+}
+
+func tokenize(text string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(text); {
+		c := rune(text[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(text) && (unicode.IsLetter(rune(text[i])) || unicode.IsDigit(rune(text[i])) || text[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, strings.ToLower(text[start:i]))
+		case unicode.IsDigit(c) || c == '.':
+			start := i
+			for i < len(text) && (unicode.IsDigit(rune(text[i])) || text[i] == '.' || text[i] == 'e' ||
+				((text[i] == '+' || text[i] == '-') && i > start && text[i-1] == 'e')) {
+				i++
+			}
+			tokens = append(tokens, text[start:i])
+		default:
+			if i+1 < len(text) {
+				switch two := text[i : i+2]; two {
+				case ">=", "<=", "==", "!=", "&&", "||":
+					tokens = append(tokens, two)
+					i += 2
+
+					continue
+				}
+			}
+			if !strings.ContainsRune("<>+-*/()", c) {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,74 @@
+package alerts
+
+import (
+	"testing"
+
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+func TestConditionEvaluation(t *testing.T) {
+	t.Parallel()
+
+	v := values{
+		"temp_c":           96,
+		"fan_rpm":          0,
+		"vram_used_bytes":  97,
+		"vram_total_bytes": 100,
+		readingsName:       4,
+	}
+
+	testCases := []struct {
+		expr  string
+		holds bool
+		known bool
+		value float64
+	}{
+		{"temp_c > 95", true, true, 96},
+		{"temp_c >= 97", false, true, 96},
+		{"vram_used / vram_total > 0.95", true, true, 0.97},
+		{"fan_rpm == 0 while temp_c > 70", true, true, 0},
+		{"fan_rpm > 0 || temp_c < 50", false, true, 0},
+		{"(temp_c - 6) * 2 <= 180", true, true, 180},
+		{"power_w > 100", false, false, 0},
+		{"power_w > 100 or temp_c > 90", true, true, 0},
+		{"power_w > 100 or temp_c > 99", false, false, 0},
+		{"power_w > 100 and temp_c > 99", false, true, 0},
+		{"-fan_rpm < 1e3", true, true, 0},
+		{"readings == 0", false, true, 0},
+	}
+
+	for _, tc := range testCases {
+		cond, err := parseCondition(tc.expr)
+		if err != nil {
+			t.Fatalf("parseCondition(%q) returned error: %v", tc.expr, err)
+		}
+		if got, known := cond.holds(v); got != tc.holds || known != tc.known {
+			t.Fatalf("%q: expected holds=%v known=%v, got %v %v", tc.expr, tc.holds, tc.known, got, known)
+		}
+		if value, ok := cond.value(v); ok && tc.value != 0 && value != tc.value {
+			t.Fatalf("%q: expected value %v, got %v", tc.expr, tc.value, value)
+		}
+	}
+}
+
+func TestMetricValuesCountsReadings(t *testing.T) {
+	t.Parallel()
+
+	temp := 60.0
+	if got := metricValues(sampler.Metrics{TempC: &temp})[readingsName]; got != 1 {
+		t.Fatalf("expected 1 reading, got %v", got)
+	}
+	if got := metricValues(sampler.Metrics{})[readingsName]; got != 0 {
+		t.Fatalf("expected 0 readings, got %v", got)
+	}
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{"", "temp_c", "temp_c >", "bogus > 1", "temp_c > 1 )", "(temp_c > 1", "temp_c ? 1"} {
+		if _, err := parseCondition(expr); err == nil {
+			t.Fatalf("expected error for %q", expr)
+		}
+	}
+}
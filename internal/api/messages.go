@@ -4,6 +4,8 @@
 package api
 
 import (
+	"github.com/skobkin/amdgputop-web/internal/alerts"
+	"github.com/skobkin/amdgputop-web/internal/events"
 	"github.com/skobkin/amdgputop-web/internal/gpu"
 	"github.com/skobkin/amdgputop-web/internal/procscan"
 	"github.com/skobkin/amdgputop-web/internal/sampler"
@@ -16,6 +18,9 @@ type HelloMessage struct {
 	GPUs            []gpu.Info      `json:"gpus"`
 	Features        map[string]bool `json:"features"`
 	ChartsMaxPoints int             `json:"charts_max_points,omitempty"`
+	// Capabilities summarises per GPU which fields are available and why
+	// the others are missing; see /api/gpus/<id>/capabilities.
+	Capabilities map[string]sampler.CapabilitySummary `json:"capabilities,omitempty"`
 }
 
 // NewHelloMessage constructs a hello payload.
@@ -57,6 +62,90 @@ func NewProcsMessage(snapshot procscan.Snapshot) ProcsMessage {
 	}
 }
 
+// ProcHistoryMessage backfills per-process history for a GPU on request. It is
+// sent once, right after a subscribe with proc_history set, and never updated:
+// clients extend each series themselves by appending a point built from the
+// ts, vram_bytes, gtt_bytes and gpu_time_ms_per_s of every later procs message.
+type ProcHistoryMessage struct {
+	Type      string                    `json:"type"`
+	GPUId     string                    `json:"gpu_id"`
+	Processes []procscan.ProcessHistory `json:"processes"`
+}
+
+// NewProcHistoryMessage constructs a proc_history payload.
+func NewProcHistoryMessage(gpuID string, histories []procscan.ProcessHistory) ProcHistoryMessage {
+	return ProcHistoryMessage{
+		Type:      "proc_history",
+		GPUId:     gpuID,
+		Processes: histories,
+	}
+}
+
+// HistoryMessage backfills recent samples of a GPU in columnar form. Each
+// metric array is aligned with TimestampsMS; missing readings are null.
+type HistoryMessage struct {
+	Type         string                `json:"type"`
+	GPUId        string                `json:"gpu_id"`
+	TimestampsMS []int64               `json:"ts_ms"`
+	Metrics      map[string][]*float64 `json:"metrics"`
+}
+
+// NewHistoryMessage constructs a history payload from samples ordered oldest first.
+func NewHistoryMessage(gpuID string, samples []sampler.Sample) HistoryMessage {
+	msg := HistoryMessage{
+		Type:         "history",
+		GPUId:        gpuID,
+		TimestampsMS: make([]int64, len(samples)),
+		Metrics:      make(map[string][]*float64),
+	}
+	for i, sample := range samples {
+		msg.TimestampsMS[i] = sample.Timestamp.UnixMilli()
+		for _, field := range sampler.MetricFields {
+			value, ok := field.Value(sample.Metrics)
+			if !ok {
+				continue
+			}
+			column, exists := msg.Metrics[field.Name]
+			if !exists {
+				column = make([]*float64, len(samples))
+				msg.Metrics[field.Name] = column
+			}
+			column[i] = &value
+		}
+	}
+
+	return msg
+}
+
+// AlertMessage reports an alert state change, or an active alert right after
+// connecting.
+type AlertMessage struct {
+	Type string `json:"type"`
+	alerts.Alert
+}
+
+// NewAlertMessage constructs an alert payload.
+func NewAlertMessage(alert alerts.Alert) AlertMessage {
+	return AlertMessage{
+		Type:  "alert",
+		Alert: alert,
+	}
+}
+
+// EventMessage pushes a GPU timeline event.
+type EventMessage struct {
+	Type string `json:"type"`
+	events.Event
+}
+
+// NewEventMessage constructs an event payload.
+func NewEventMessage(event events.Event) EventMessage {
+	return EventMessage{
+		Type:  "event",
+		Event: event,
+	}
+}
+
 // ErrorMessage communicates an error condition to the client.
 type ErrorMessage struct {
 	Type    string `json:"type"`
@@ -70,8 +159,12 @@ type ClientMessage struct {
 
 // SubscribeMessage requests subscription to GPU telemetry.
 type SubscribeMessage struct {
-	Type  string `json:"type"`
-	GPUId string `json:"gpu_id"`
+	Type        string `json:"type"`
+	GPUId       string `json:"gpu_id"`
+	ProcHistory bool   `json:"proc_history,omitempty"`
+	// SubStats keeps the sub-interval stats and sub_samples in stats
+	// messages; they are left out by default.
+	SubStats bool `json:"sub_stats,omitempty"`
 }
 
 // PongMessage is the response to a ping.
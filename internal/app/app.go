@@ -7,13 +7,21 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/skobkin/amdgputop-web/internal/alerts"
 	"github.com/skobkin/amdgputop-web/internal/config"
-	"github.com/skobkin/amdgputop-web/internal/gpu"
+	"github.com/skobkin/amdgputop-web/internal/events"
 	"github.com/skobkin/amdgputop-web/internal/httpserver"
+	"github.com/skobkin/amdgputop-web/internal/kmsg"
+	"github.com/skobkin/amdgputop-web/internal/notify"
 	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/replay"
 	"github.com/skobkin/amdgputop-web/internal/sampler"
+	"github.com/skobkin/amdgputop-web/internal/tsdb"
+	"github.com/skobkin/amdgputop-web/internal/usage"
 )
 
 const shutdownTimeout = 10 * time.Second
@@ -22,50 +30,113 @@ const shutdownTimeout = 10 * time.Second
 func Run(ctx context.Context, baseLogger *slog.Logger, cfg config.Config) error {
 	appLogger := baseLogger.With("component", "app")
 
-	gpus, err := gpu.Discover(cfg.SysfsRoot, baseLogger.With("component", "gpu_discovery"))
+	inputs, err := openBackends(cfg, baseLogger)
 	if err != nil {
-		return fmt.Errorf("discover gpus: %w", err)
+		return err
 	}
-	appLogger.Info("discovered GPUs", "count", len(gpus))
+	gpus := inputs.gpus
 
-	readers := make(map[string]*sampler.Reader, len(gpus))
-	for _, info := range gpus {
-		readerLogger := baseLogger.With("component", "sampler_reader", "gpu_id", info.ID)
-		reader, err := sampler.NewReader(info.ID, cfg.SysfsRoot, cfg.DebugfsRoot, readerLogger)
+	samplerManager, err := sampler.NewManagerFromSources(cfg.SampleInterval, inputs.samplers, baseLogger.With("component", "sampler"))
+	if err != nil {
+		return fmt.Errorf("init sampler manager: %w", err)
+	}
+	if err := samplerManager.SetReadTimeout(cfg.SampleReadTimeout); err != nil {
+		return fmt.Errorf("init sampler manager: %w", err)
+	}
+	if err := samplerManager.SetGroupIntervals(cfg.SampleMediumInterval, cfg.SampleSlowInterval); err != nil {
+		return fmt.Errorf("init sampler manager: %w", err)
+	}
+	if cfg.SampleSubInterval > 0 {
+		if err := samplerManager.SetSubInterval(cfg.SampleSubInterval); err != nil {
+			return fmt.Errorf("init sampler manager: %w", err)
+		}
+	}
+	var alertEngine *alerts.Engine
+	rules := notify.LimitRules(cfg.Notify)
+	if cfg.AlertRulesFile != "" {
+		fileRules, err := alerts.LoadRules(cfg.AlertRulesFile)
 		if err != nil {
-			appLogger.Warn("failed to initialise metrics reader", "gpu_id", info.ID, "err", err)
-
-			continue
+			return fmt.Errorf("init alerts: %w", err)
 		}
-		readers[info.ID] = reader
+		rules = append(fileRules, rules...)
 	}
-
-	if len(gpus) > 0 && len(readers) == 0 {
-		appLogger.Warn("no metrics readers initialised", "reason", "sysfs access failed")
+	if cfg.AlertRulesFile != "" || len(rules) > 0 {
+		alertEngine, err = alerts.NewEngine(rules, baseLogger)
+		if err != nil {
+			return fmt.Errorf("init alerts: %w", err)
+		}
+		samplerManager.AddListener(alertEngine.Observe)
+		appLogger.Info("alerting enabled", "rules", len(rules), "path", cfg.AlertRulesFile)
 	}
 
-	samplerManager, err := sampler.NewManager(cfg.SampleInterval, readers, baseLogger.With("component", "sampler"))
+	notifiers, err := notify.FromConfig(cfg.Notify)
 	if err != nil {
-		return fmt.Errorf("init sampler manager: %w", err)
+		return fmt.Errorf("init notifications: %w", err)
 	}
-	if cfg.LazySampler {
+	switch {
+	case len(notifiers) > 0 && alertEngine == nil:
+		appLogger.Warn("notification targets configured without alert rules or limits")
+	case len(notifiers) > 0:
+		dispatcher := notify.NewDispatcher(notifiers, notify.Options{
+			Retries: cfg.Notify.Retries,
+			Backoff: cfg.Notify.Backoff,
+			Dedupe:  cfg.Notify.Dedupe,
+		}, baseLogger.With("component", "notify"))
+		updates, unsubscribe := alertEngine.Subscribe()
+		defer unsubscribe()
+		go func() { _ = dispatcher.Run(ctx, updates) }()
+		appLogger.Info("notifications enabled", "targets", len(notifiers))
+	}
+	switch {
+	case cfg.LazySampler && alertEngine != nil:
+		// Rules must be evaluated without connected clients.
+		appLogger.Info("lazy sampler disabled because alert rules are configured")
+	case cfg.LazySampler:
 		if err := samplerManager.EnableLazy(cfg.LazySamplerIdleTTL); err != nil {
 			return fmt.Errorf("enable lazy sampler: %w", err)
 		}
 	}
+	if cfg.Charts.Enable {
+		if err := samplerManager.EnableHistory(cfg.Charts.MaxPoints); err != nil {
+			return fmt.Errorf("enable sampler history: %w", err)
+		}
+	}
 	defer func() {
 		if err := samplerManager.Close(); err != nil {
 			appLogger.Warn("sampler manager close", "err", err)
 		}
 	}()
 
+	var historyStore *tsdb.Store
+	if cfg.DataDir != "" {
+		historyStore, err = tsdb.Open(tsdb.Options{
+			Dir:          cfg.DataDir,
+			Logger:       baseLogger.With("component", "tsdb"),
+			RawRetention: cfg.DataRawRetention,
+		})
+		if err != nil {
+			return fmt.Errorf("open history store: %w", err)
+		}
+		defer func() {
+			if err := historyStore.Close(); err != nil {
+				appLogger.Warn("history store close", "err", err)
+			}
+		}()
+		samplerManager.SetHistoryStore(historyStore)
+	}
+
 	var (
-		procManager *procscan.Manager
+		procManager  *procscan.Manager
+		usageTracker *usage.Tracker
 	)
 
-	if cfg.Proc.Enable {
+	if cfg.Proc.Enable && !inputs.procsDisabled {
 		procLogger := baseLogger.With("component", "procscan")
-		procManager, err = procscan.NewManager(cfg.Proc, cfg.ProcRoot, gpus, procLogger)
+		if inputs.procs != nil {
+			procManager, err = procscan.NewManagerWithSource(cfg.Proc, inputs.procs, gpus, procLogger)
+		} else {
+			procManager, err = procscan.NewManager(cfg.Proc, cfg.ProcRoot, gpus, procLogger)
+		}
 		if err != nil {
 			return fmt.Errorf("init proc scanner: %w", err)
 		}
@@ -74,6 +145,22 @@ func Run(ctx context.Context, baseLogger *slog.Logger, cfg config.Config) error
 				return fmt.Errorf("enable lazy proc scanner: %w", err)
 			}
 		}
+		if inputs.live && cfg.DebugfsRoot != "" {
+			if err := procManager.EnableGEMInfo(cfg.DebugfsRoot); err != nil {
+				appLogger.Info("amdgpu_gem_info fallback unavailable", "err", err)
+			}
+		}
+		maxPowerAge := 2*cfg.SampleInterval + cfg.Proc.ScanInterval
+		procManager.SetPowerSource(func(gpuID string) (float64, bool) {
+			sample, ok := samplerManager.Latest(gpuID)
+			if !ok || sample.Metrics.PowerW == nil || time.Since(sample.Timestamp) > maxPowerAge {
+				return 0, false
+			}
+
+			return *sample.Metrics.PowerW, true
+		})
+		usageTracker = usage.NewTracker(3 * cfg.Proc.ScanInterval)
+		procManager.AddListener(usageTracker.Observe)
 		defer func() {
 			if err := procManager.Close(); err != nil {
 				appLogger.Warn("proc manager close", "err", err)
@@ -81,6 +168,84 @@ func Run(ctx context.Context, baseLogger *slog.Logger, cfg config.Config) error
 		}()
 	}
 
+	if cfg.RecordFile != "" {
+		recorder, err := replay.NewRecorder(cfg.RecordFile, gpus, cfg.SampleInterval)
+		if err != nil {
+			return fmt.Errorf("open recording: %w", err)
+		}
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				appLogger.Warn("recording close", "err", err)
+			}
+		}()
+		samplerManager.AddListener(recorder.RecordSample)
+		if procManager != nil {
+			procManager.AddListener(recorder.RecordSnapshot)
+		}
+		appLogger.Info("recording telemetry", "path", cfg.RecordFile)
+	}
+
+	eventsPath := ""
+	if cfg.DataDir != "" {
+		eventsPath = filepath.Join(cfg.DataDir, "events.ndjson")
+	}
+	eventLog, err := events.Open(events.Options{
+		Path:     eventsPath,
+		Capacity: cfg.EventsMax,
+		Logger:   baseLogger.With("component", "events"),
+	})
+	if err != nil {
+		return fmt.Errorf("open event log: %w", err)
+	}
+	defer func() {
+		if err := eventLog.Close(); err != nil {
+			appLogger.Warn("event log close", "err", err)
+		}
+	}()
+	for id, source := range inputs.samplers {
+		if reader, ok := source.(*sampler.Reader); ok {
+			reader.OnReopen(reopenRecorder(eventLog, id))
+		}
+	}
+	detector := events.NewDetector(eventLog)
+	samplerManager.AddListener(detector.ObserveSample)
+	samplerManager.AddActivityListener(detector.ObserveSampler)
+	if procManager != nil {
+		procManager.AddListener(detector.ObserveSnapshot)
+	}
+	if len(inputs.failed) > 0 {
+		for _, info := range gpus {
+			if err, ok := inputs.failed[info.ID]; ok {
+				eventLog.Append(events.Event{
+					GPUId:   info.ID,
+					Kind:    events.KindReaderError,
+					Message: "GPU reader could not be opened",
+					Attrs:   map[string]string{"error": err.Error()},
+				})
+			}
+		}
+		go retryReaders(ctx, cfg, gpus, inputs.failed, samplerManager, eventLog, baseLogger)
+	}
+
+	var kmsgWatcher *kmsg.Watcher
+	if cfg.KmsgEnable {
+		gpuByPCI := make(map[string]string, len(gpus))
+		for _, info := range gpus {
+			gpuByPCI[strings.ToLower(info.PCI)] = info.ID
+		}
+		kmsgWatcher = kmsg.NewWatcher(cfg.KmsgPath, func(record kmsg.Record) {
+			eventLog.Append(record.Event(gpuByPCI[record.PCI]))
+		}, baseLogger.With("component", "kmsg"))
+		go func() {
+			// The kernel log is optional; e.g. dmesg_restrict hides it from
+			// unprivileged users.
+			if err := kmsgWatcher.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				appLogger.Warn("kernel log watcher stopped", "path", cfg.KmsgPath, "err", err)
+			}
+		}()
+		appLogger.Info("watching kernel log", "path", cfg.KmsgPath)
+	}
+
 	samplerCtx, samplerCancel := context.WithCancel(ctx)
 	defer samplerCancel()
 
@@ -105,6 +270,19 @@ func Run(ctx context.Context, baseLogger *slog.Logger, cfg config.Config) error
 	}
 
 	srv := httpserver.New(cfg, baseLogger.With("component", "http"), gpus, samplerManager, procManager)
+	if usageTracker != nil {
+		srv.EnableUsage(usageTracker)
+	}
+	if historyStore != nil {
+		srv.EnableExport(historyStore)
+	}
+	if alertEngine != nil {
+		srv.EnableAlerts(alertEngine)
+	}
+	srv.EnableEvents(eventLog)
+	if kmsgWatcher != nil {
+		srv.EnableKernelLog(kmsgWatcher)
+	}
 
 	appLogger.Info("starting HTTP server", "listen_addr", cfg.ListenAddr)
 
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/config"
+	"github.com/skobkin/amdgputop-web/internal/events"
+	"github.com/skobkin/amdgputop-web/internal/gpu"
+	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/replay"
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+	"github.com/skobkin/amdgputop-web/internal/simulate"
+)
+
+// backends holds the telemetry inputs the managers are built from.
+type backends struct {
+	gpus     []gpu.Info
+	samplers map[string]sampler.Source
+	// procs replaces the procfs scanner when set; procsDisabled means
+	// process data is unavailable altogether (e.g. a recording without it).
+	procs         procscan.Source
+	procsDisabled bool
+	live          bool
+	// failed lists GPUs whose reader could not be opened, with the error.
+	failed map[string]error
+}
+
+// openBackends discovers GPUs and opens their readers, loads a recording
+// when APP_REPLAY_FILE is set, or creates virtual GPUs for APP_SIMULATE.
+func openBackends(cfg config.Config, baseLogger *slog.Logger) (backends, error) {
+	appLogger := baseLogger.With("component", "app")
+
+	if cfg.ReplayFile != "" && cfg.Simulate > 0 {
+		return backends{}, fmt.Errorf("APP_REPLAY_FILE and APP_SIMULATE are mutually exclusive")
+	}
+
+	if cfg.Simulate > 0 {
+		sim, err := simulate.New(cfg.Simulate, uint64(time.Now().UnixNano()))
+		if err != nil {
+			return backends{}, fmt.Errorf("start simulator: %w", err)
+		}
+		appLogger.Info("simulating GPUs", "count", cfg.Simulate)
+
+		return backends{gpus: sim.GPUs(), samplers: sim.SamplerSources(), procs: sim.ProcSource()}, nil
+	}
+
+	if cfg.ReplayFile != "" {
+		rec, err := replay.Load(cfg.ReplayFile)
+		if err != nil {
+			return backends{}, fmt.Errorf("load replay: %w", err)
+		}
+		player, err := replay.NewPlayer(rec, cfg.ReplaySpeed)
+		if err != nil {
+			return backends{}, fmt.Errorf("start replay: %w", err)
+		}
+		appLogger.Info("replaying recording", "path", cfg.ReplayFile, "gpus", len(rec.GPUs), "speed", cfg.ReplaySpeed)
+
+		result := backends{gpus: rec.GPUs, samplers: player.SamplerSources()}
+		if rec.HasProcesses() {
+			result.procs = player.ProcSource()
+		} else {
+			result.procsDisabled = true
+		}
+
+		return result, nil
+	}
+
+	gpus, err := gpu.Discover(cfg.SysfsRoot, baseLogger.With("component", "gpu_discovery"))
+	if err != nil {
+		return backends{}, fmt.Errorf("discover gpus: %w", err)
+	}
+	appLogger.Info("discovered GPUs", "count", len(gpus))
+
+	readers := make(map[string]sampler.Source, len(gpus))
+	failed := make(map[string]error)
+	for _, info := range gpus {
+		reader, err := openReader(cfg, info, baseLogger)
+		if err != nil {
+			appLogger.Warn("failed to initialise metrics reader", "gpu_id", info.ID, "err", err)
+			failed[info.ID] = err
+
+			continue
+		}
+		readers[info.ID] = reader
+	}
+
+	if len(gpus) > 0 && len(readers) == 0 {
+		appLogger.Warn("no metrics readers initialised", "reason", "sysfs access failed")
+	}
+
+	return backends{gpus: gpus, samplers: readers, live: true, failed: failed}, nil
+}
+
+func openReader(cfg config.Config, info gpu.Info, baseLogger *slog.Logger) (*sampler.Reader, error) {
+	readerLogger := baseLogger.With("component", "sampler_reader", "gpu_id", info.ID)
+
+	return sampler.NewReader(info.ID, cfg.SysfsRoot, cfg.DebugfsRoot, readerLogger)
+}
+
+// readerRetryInterval is how often readers that failed at startup are
+// opened again.
+const readerRetryInterval = 30 * time.Second
+
+// retryReaders keeps opening the readers that failed at startup (e.g. while
+// amdgpu was still initialising) and hands them to the sampler, until all
+// of them are open or ctx is done.
+func retryReaders(ctx context.Context, cfg config.Config, gpus []gpu.Info, failed map[string]error, manager *sampler.Manager, eventLog *events.Log, baseLogger *slog.Logger) {
+	appLogger := baseLogger.With("component", "app")
+	pending := make([]gpu.Info, 0, len(failed))
+	for _, info := range gpus {
+		if _, ok := failed[info.ID]; ok {
+			pending = append(pending, info)
+		}
+	}
+
+	ticker := time.NewTicker(readerRetryInterval)
+	defer ticker.Stop()
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		remaining := pending[:0]
+		for _, info := range pending {
+			reader, err := openReader(cfg, info, baseLogger)
+			if err != nil {
+				appLogger.Debug("metrics reader still unavailable", "gpu_id", info.ID, "err", err)
+				remaining = append(remaining, info)
+
+				continue
+			}
+			reader.OnReopen(reopenRecorder(eventLog, info.ID))
+			if err := manager.AddSource(info.ID, reader); err != nil {
+				_ = reader.Close()
+				appLogger.Warn("failed to add metrics reader", "gpu_id", info.ID, "err", err)
+
+				continue
+			}
+			appLogger.Info("metrics reader initialised after retry", "gpu_id", info.ID)
+			eventLog.Append(events.Event{GPUId: info.ID, Kind: events.KindReaderRecovered, Message: "GPU reader opened after failing at startup"})
+		}
+		pending = remaining
+	}
+}
+
+// reopenRecorder returns a Reader.OnReopen callback recording that a reader
+// reopened its stale handles. Whether readings came back is the detector's
+// reader_recovered event.
+func reopenRecorder(eventLog *events.Log, gpuID string) func(int) {
+	return func(failedSamples int) {
+		eventLog.Append(events.Event{
+			GPUId:   gpuID,
+			Kind:    events.KindReaderReopened,
+			Message: "GPU reader reopened its sysfs/debugfs handles",
+			Attrs:   map[string]string{"failed_samples": strconv.Itoa(failedSamples)},
+		})
+	}
+}
@@ -0,0 +1,454 @@
+// Package capture archives the sysfs, debugfs and procfs files read by GPU
+// discovery, the sampler and the process scanner, so that a machine can be
+// reproduced from fixtures by pointing APP_SYSFS_ROOT, APP_DEBUGFS_ROOT and
+// APP_PROC_ROOT at the extracted directories.
+package capture
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Top-level directories of a capture archive.
+const (
+	SysfsDir   = "sysfs"
+	DebugfsDir = "debugfs"
+	ProcDir    = "proc"
+)
+
+// maxLinkDepth bounds symlink chains followed inside sysfs.
+const maxLinkDepth = 8
+
+// deviceFiles are the PCI device attributes read from class/drm/cardN/device.
+var deviceFiles = []string{
+	"uevent",
+	"vendor",
+	"device",
+	"subsystem_vendor",
+	"subsystem_device",
+	"product_name",
+	"gpu_busy_percent",
+	"mem_busy_percent",
+	"pp_dpm_sclk",
+	"pp_dpm_mclk",
+	"mem_info_vram_used",
+	"mem_info_vram_total",
+	"mem_info_gtt_used",
+	"mem_info_gtt_total",
+	"gpu_metrics",
+	"power_dpm_force_performance_level",
+	"pp_power_profile_mode",
+	"current_link_speed",
+	"current_link_width",
+	"max_link_speed",
+	"max_link_width",
+	"power/runtime_status",
+}
+
+// hwmonFiles are read from each device/hwmon/hwmonN directory.
+var hwmonFiles = []string{
+	"name",
+	"temp1_input",
+	"temp1_label",
+	"fan1_input",
+	"power1_average",
+	"power1_input",
+}
+
+// debugfsFiles are read from dri/N in debugfs.
+var debugfsFiles = []string{
+	"amdgpu_pm_info",
+	"amdgpu_gem_info",
+}
+
+// procFiles are copied for every process holding a DRM file descriptor.
+// stat carries the start time the scanner's fd cache is keyed on. environ
+// and exe are left out on purpose.
+var procFiles = []string{
+	"comm",
+	"stat",
+	"status",
+	"cgroup",
+}
+
+// Options selects the roots to capture.
+type Options struct {
+	SysfsRoot   string
+	DebugfsRoot string
+	ProcRoot    string
+	// RedactCmdlines keeps only argv[0] of captured command lines.
+	RedactCmdlines bool
+	Logger         *slog.Logger
+}
+
+// Summary reports what was captured.
+type Summary struct {
+	GPUs      int
+	Processes int
+	Files     int
+	// Skipped counts files that existed but could not be read, typically
+	// debugfs or other users' fdinfo without root.
+	Skipped int
+}
+
+// Write captures the configured roots into a gzip-compressed tar archive.
+// Missing roots are skipped; only write errors abort the capture.
+func Write(w io.Writer, opts Options) (Summary, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	gz := gzip.NewWriter(w)
+	a := &archive{
+		tw:     tar.NewWriter(gz),
+		logger: logger,
+		dirs:   make(map[string]bool),
+		files:  make(map[string]bool),
+		mtime:  time.Now(),
+	}
+
+	cards, err := a.captureSysfs(opts.SysfsRoot)
+	if err == nil {
+		err = a.captureDebugfs(opts.DebugfsRoot, cards)
+	}
+	if err == nil {
+		err = a.captureProc(opts.ProcRoot, opts.RedactCmdlines)
+	}
+	if err != nil {
+		return a.summary, err
+	}
+
+	if err := a.tw.Close(); err != nil {
+		return a.summary, fmt.Errorf("close archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return a.summary, fmt.Errorf("close archive: %w", err)
+	}
+
+	return a.summary, nil
+}
+
+type archive struct {
+	tw      *tar.Writer
+	logger  *slog.Logger
+	dirs    map[string]bool
+	files   map[string]bool
+	mtime   time.Time
+	summary Summary
+}
+
+func (a *archive) dir(name string) error {
+	if name == "." || name == "/" || a.dirs[name] {
+		return nil
+	}
+	if err := a.dir(path.Dir(name)); err != nil {
+		return err
+	}
+	a.dirs[name] = true
+
+	return a.write(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0o755, ModTime: a.mtime}, nil)
+}
+
+func (a *archive) file(name string, data []byte) error {
+	if a.files[name] {
+		return nil
+	}
+	if err := a.dir(path.Dir(name)); err != nil {
+		return err
+	}
+	a.files[name] = true
+	a.summary.Files++
+
+	return a.write(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: a.mtime}, data)
+}
+
+func (a *archive) symlink(name, target string) error {
+	if a.files[name] {
+		return nil
+	}
+	if err := a.dir(path.Dir(name)); err != nil {
+		return err
+	}
+	a.files[name] = true
+
+	return a.write(&tar.Header{Typeflag: tar.TypeSymlink, Name: name, Linkname: target, Mode: 0o777, ModTime: a.mtime}, nil)
+}
+
+func (a *archive) write(hdr *tar.Header, data []byte) error {
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write %s: %w", hdr.Name, err)
+	}
+	if len(data) > 0 {
+		if _, err := a.tw.Write(data); err != nil {
+			return fmt.Errorf("write %s: %w", hdr.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// copy archives root/rel as prefix/rel. Unreadable files are counted and
+// skipped; absent ones are ignored.
+func (a *archive) copy(root *os.Root, prefix, rel string) error {
+	data, err := root.ReadFile(rel)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			a.summary.Skipped++
+			a.logger.Debug("skip unreadable file", "path", path.Join(prefix, rel), "err", err)
+		}
+
+		return nil
+	}
+
+	return a.file(path.Join(prefix, rel), data)
+}
+
+// resolve archives the symlink chain at rel (if any) and returns the path it
+// finally points at, relative to root.
+func (a *archive) resolve(root *os.Root, prefix, rel string) (string, error) {
+	for range maxLinkDepth {
+		info, err := root.Lstat(rel)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			return rel, nil
+		}
+		target, err := root.Readlink(rel)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			return "", fmt.Errorf("absolute symlink %s -> %s", rel, target)
+		}
+		if err := a.symlink(path.Join(prefix, rel), target); err != nil {
+			return "", err
+		}
+		rel = path.Clean(path.Join(path.Dir(rel), target))
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			return "", fmt.Errorf("symlink %s escapes root", rel)
+		}
+	}
+
+	return "", fmt.Errorf("too many levels of symlinks at %s", rel)
+}
+
+func (a *archive) captureSysfs(rootPath string) ([]int, error) {
+	if rootPath == "" {
+		return nil, nil
+	}
+	root, err := os.OpenRoot(rootPath)
+	if err != nil {
+		a.logger.Warn("skip sysfs", "err", err)
+
+		return nil, nil
+	}
+	defer root.Close()
+
+	entries, err := fs.ReadDir(root.FS(), "class/drm")
+	if err != nil {
+		a.logger.Warn("skip sysfs", "err", err)
+
+		return nil, nil
+	}
+
+	var cards []int
+	for _, entry := range entries {
+		name := entry.Name()
+		index, err := strconv.Atoi(strings.TrimPrefix(name, "card"))
+		if !strings.HasPrefix(name, "card") || err != nil || index < 0 {
+			continue
+		}
+
+		cardRel, err := a.resolve(root, SysfsDir, path.Join("class/drm", name))
+		if err != nil {
+			a.logger.Warn("skip card", "card", name, "err", err)
+
+			continue
+		}
+		if err := a.dir(path.Join(SysfsDir, cardRel)); err != nil {
+			return nil, err
+		}
+		deviceRel, err := a.resolve(root, SysfsDir, path.Join(cardRel, "device"))
+		if err != nil {
+			a.logger.Warn("skip card", "card", name, "err", err)
+
+			continue
+		}
+		if err := a.captureDevice(root, deviceRel); err != nil {
+			return nil, err
+		}
+		cards = append(cards, index)
+		a.summary.GPUs++
+	}
+
+	return cards, nil
+}
+
+func (a *archive) captureDevice(root *os.Root, deviceRel string) error {
+	if err := a.dir(path.Join(SysfsDir, deviceRel)); err != nil {
+		return err
+	}
+	for _, name := range deviceFiles {
+		if err := a.copy(root, SysfsDir, path.Join(deviceRel, name)); err != nil {
+			return err
+		}
+	}
+
+	// Discovery only lists device/drm to find the render node.
+	if entries, err := fs.ReadDir(root.FS(), path.Join(deviceRel, "drm")); err == nil {
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), "renderD") || strings.HasPrefix(entry.Name(), "card") {
+				if err := a.dir(path.Join(SysfsDir, deviceRel, "drm", entry.Name())); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	entries, err := fs.ReadDir(root.FS(), path.Join(deviceRel, "hwmon"))
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		hwmonRel, err := a.resolve(root, SysfsDir, path.Join(deviceRel, "hwmon", entry.Name()))
+		if err != nil {
+			continue
+		}
+		if err := a.dir(path.Join(SysfsDir, hwmonRel)); err != nil {
+			return err
+		}
+		for _, name := range hwmonFiles {
+			if err := a.copy(root, SysfsDir, path.Join(hwmonRel, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *archive) captureDebugfs(rootPath string, cards []int) error {
+	if rootPath == "" || len(cards) == 0 {
+		return nil
+	}
+	root, err := os.OpenRoot(rootPath)
+	if err != nil {
+		a.logger.Info("skip debugfs", "err", err)
+
+		return nil
+	}
+	defer root.Close()
+
+	for _, index := range cards {
+		for _, name := range debugfsFiles {
+			if err := a.copy(root, DebugfsDir, path.Join("dri", strconv.Itoa(index), name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *archive) captureProc(rootPath string, redact bool) error {
+	if rootPath == "" {
+		return nil
+	}
+	root, err := os.OpenRoot(rootPath)
+	if err != nil {
+		a.logger.Warn("skip procfs", "err", err)
+
+		return nil
+	}
+	defer root.Close()
+
+	entries, err := fs.ReadDir(root.FS(), ".")
+	if err != nil {
+		a.logger.Warn("skip procfs", "err", err)
+
+		return nil
+	}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		if err := a.captureProcess(root, entry.Name(), redact); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *archive) captureProcess(root *os.Root, pid string, redact bool) error {
+	fds, err := fs.ReadDir(root.FS(), path.Join(pid, "fd"))
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			a.summary.Skipped++
+		}
+
+		return nil
+	}
+
+	drm := make(map[string]string)
+	for _, fd := range fds {
+		target, err := root.Readlink(path.Join(pid, "fd", fd.Name()))
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSuffix(target, " (deleted)"), "/dev/dri/") {
+			drm[fd.Name()] = target
+		}
+	}
+	if len(drm) == 0 {
+		return nil
+	}
+
+	prefix := path.Join(ProcDir, pid)
+	for _, name := range procFiles {
+		if err := a.copy(root, ProcDir, path.Join(pid, name)); err != nil {
+			return err
+		}
+	}
+	if cmdline, err := root.ReadFile(path.Join(pid, "cmdline")); err == nil {
+		if redact {
+			cmdline = redactCmdline(cmdline)
+		}
+		if err := a.file(path.Join(prefix, "cmdline"), cmdline); err != nil {
+			return err
+		}
+	}
+	for fd, target := range drm {
+		if err := a.symlink(path.Join(prefix, "fd", fd), target); err != nil {
+			return err
+		}
+		if err := a.copy(root, ProcDir, path.Join(pid, "fdinfo", fd)); err != nil {
+			return err
+		}
+	}
+	a.summary.Processes++
+
+	return nil
+}
+
+// redactCmdline keeps argv[0] of a NUL-separated command line.
+func redactCmdline(cmdline []byte) []byte {
+	if i := bytes.IndexByte(cmdline, 0); i >= 0 {
+		return cmdline[:i+1]
+	}
+
+	return cmdline
+}
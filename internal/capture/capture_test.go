@@ -0,0 +1,153 @@
+package capture
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skobkin/amdgputop-web/internal/gpu"
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+func TestCaptureRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	src := t.TempDir()
+	sysfs := filepath.Join(src, "sys")
+	deviceDir := filepath.Join(sysfs, "devices", "pci0000:00", "0000:03:00.0")
+	writeFile(t, filepath.Join(deviceDir, "uevent"), "PCI_SLOT_NAME=0000:03:00.0\nPCI_ID=1002:744C\n")
+	writeFile(t, filepath.Join(deviceDir, "gpu_busy_percent"), "42\n")
+	writeFile(t, filepath.Join(deviceDir, "hwmon", "hwmon3", "temp1_input"), "51000\n")
+	writeFile(t, filepath.Join(deviceDir, "unrelated"), "not captured\n")
+	mkdir(t, filepath.Join(deviceDir, "drm", "renderD128"))
+	cardDir := filepath.Join(deviceDir, "drm", "card0")
+	mkdir(t, cardDir)
+	symlink(t, "../../../0000:03:00.0", filepath.Join(cardDir, "device"))
+	mkdir(t, filepath.Join(sysfs, "class", "drm"))
+	symlink(t, "../../devices/pci0000:00/0000:03:00.0/drm/card0", filepath.Join(sysfs, "class", "drm", "card0"))
+
+	debugfs := filepath.Join(src, "debug")
+	writeFile(t, filepath.Join(debugfs, "dri", "0", "amdgpu_pm_info"), "GFX Clocks and Power:\n")
+
+	proc := filepath.Join(src, "proc")
+	writeFile(t, filepath.Join(proc, "4242", "comm"), "game\n")
+	writeFile(t, filepath.Join(proc, "4242", "stat"), "4242 (game) S 1 4242 4242 0 -1 4194560 0 0 0 0 0 0 0 0 20 0 1 0 123456 0 0\n")
+	writeFile(t, filepath.Join(proc, "4242", "cmdline"), "/opt/game\x00--token=secret\x00")
+	writeFile(t, filepath.Join(proc, "4242", "environ"), "SECRET=1\x00")
+	writeFile(t, filepath.Join(proc, "4242", "fdinfo", "5"), "drm-driver:\tamdgpu\n")
+	mkdir(t, filepath.Join(proc, "4242", "fd"))
+	symlink(t, "/dev/dri/renderD128", filepath.Join(proc, "4242", "fd", "5"))
+	symlink(t, "/dev/null", filepath.Join(proc, "4242", "fd", "0"))
+	mkdir(t, filepath.Join(proc, "99", "fd"))
+	symlink(t, "/dev/null", filepath.Join(proc, "99", "fd", "0"))
+
+	var buf bytes.Buffer
+	summary, err := Write(&buf, Options{SysfsRoot: sysfs, DebugfsRoot: debugfs, ProcRoot: proc, RedactCmdlines: true})
+	if err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if summary.GPUs != 1 || summary.Processes != 1 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	out := t.TempDir()
+	extract(t, &buf, out)
+
+	infos, err := gpu.Discover(filepath.Join(out, SysfsDir), nil)
+	if err != nil || len(infos) != 1 || infos[0].PCI != "0000:03:00.0" || infos[0].RenderNode != "/dev/dri/renderD128" {
+		t.Fatalf("unexpected discovery from capture: %+v (err %v)", infos, err)
+	}
+
+	reader, err := sampler.NewReader("card0", filepath.Join(out, SysfsDir), filepath.Join(out, DebugfsDir), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewReader returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = reader.Close() })
+	metrics := reader.Sample().Metrics
+	if metrics.GPUBusyPct == nil || *metrics.GPUBusyPct != 42 || metrics.TempC == nil || *metrics.TempC != 51 {
+		t.Fatalf("unexpected metrics from capture: %+v", metrics)
+	}
+
+	if _, err := os.Stat(filepath.Join(out, DebugfsDir, "dri", "0", "amdgpu_pm_info")); err != nil {
+		t.Fatalf("expected debugfs pm info: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(out, SysfsDir, "devices", "pci0000:00", "0000:03:00.0", "unrelated")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected unrelated attribute to be skipped, got %v", err)
+	}
+
+	procOut := filepath.Join(out, ProcDir, "4242")
+	if target, err := os.Readlink(filepath.Join(procOut, "fd", "5")); err != nil || target != "/dev/dri/renderD128" {
+		t.Fatalf("unexpected fd link %q (err %v)", target, err)
+	}
+	if _, err := os.Stat(filepath.Join(procOut, "stat")); err != nil {
+		t.Fatalf("expected process stat: %v", err)
+	}
+	if cmdline, err := os.ReadFile(filepath.Join(procOut, "cmdline")); err != nil || string(cmdline) != "/opt/game\x00" {
+		t.Fatalf("expected redacted cmdline, got %q (err %v)", cmdline, err)
+	}
+	for _, skipped := range []string{filepath.Join(procOut, "environ"), filepath.Join(procOut, "fd", "0"), filepath.Join(out, ProcDir, "99")} {
+		if _, err := os.Lstat(skipped); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected %s to be skipped, got %v", skipped, err)
+		}
+	}
+}
+
+func extract(t *testing.T, r io.Reader, dir string) {
+	t.Helper()
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			t.Fatalf("read archive: %v", err)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			mkdir(t, target)
+		case tar.TypeSymlink:
+			symlink(t, hdr.Linkname, target)
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("read %s: %v", hdr.Name, err)
+			}
+			writeFile(t, target, string(data))
+		}
+	}
+}
+
+func mkdir(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0o750); err != nil {
+		t.Fatalf("mkdir %s: %v", path, err)
+	}
+}
+
+func symlink(t *testing.T, target, path string) {
+	t.Helper()
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatalf("symlink %s: %v", path, err)
+	}
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	mkdir(t, filepath.Dir(path))
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
@@ -10,23 +10,41 @@ import (
 	"time"
 )
 
+// MaxSimulatedGPUs bounds APP_SIMULATE.
+const MaxSimulatedGPUs = 16
+
 // Config represents runtime configuration sourced from environment variables.
 type Config struct {
-	ListenAddr         string
-	SampleInterval     time.Duration
-	LazySampler        bool
-	LazySamplerIdleTTL time.Duration
-	AllowedOrigins     []string
-	DefaultGPU         string
-	EnablePrometheus   bool
-	EnablePprof        bool
-	LogLevel           slog.Level
-	SysfsRoot          string
-	DebugfsRoot        string
-	ProcRoot           string
-	WS                 WebsocketConfig
-	Proc               ProcConfig
-	Charts             ChartsConfig
+	ListenAddr           string
+	SampleInterval       time.Duration
+	LazySampler          bool
+	LazySamplerIdleTTL   time.Duration
+	SampleReadTimeout    time.Duration
+	SampleSubInterval    time.Duration
+	SampleMediumInterval time.Duration
+	SampleSlowInterval   time.Duration
+	AllowedOrigins       []string
+	DefaultGPU           string
+	EnablePrometheus     bool
+	EnablePprof          bool
+	LogLevel             slog.Level
+	SysfsRoot            string
+	DebugfsRoot          string
+	ProcRoot             string
+	DataDir              string
+	DataRawRetention     time.Duration
+	RecordFile           string
+	ReplayFile           string
+	ReplaySpeed          float64
+	Simulate             int
+	AlertRulesFile       string
+	EventsMax            int
+	KmsgEnable           bool
+	KmsgPath             string
+	WS                   WebsocketConfig
+	Proc                 ProcConfig
+	Charts               ChartsConfig
+	Notify               NotifyConfig
 }
 
 // WebsocketConfig captures tunables for WebSocket handling.
@@ -38,10 +56,16 @@ type WebsocketConfig struct {
 
 // ProcConfig contains settings for the process scanner feature.
 type ProcConfig struct {
-	Enable       bool
-	ScanInterval time.Duration
-	MaxPIDs      int
-	MaxFDsPerPID int
+	Enable         bool
+	ScanInterval   time.Duration
+	MaxPIDs        int
+	MaxFDsPerPID   int
+	MaxExited      int
+	NetlinkEvents  bool
+	HistoryMaxPIDs int
+	HistoryPoints  int
+	Enrich         bool
+	RulesFile      string
 }
 
 // ChartsConfig contains settings for front-end charts.
@@ -50,36 +74,78 @@ type ChartsConfig struct {
 	MaxPoints int
 }
 
+// NotifyConfig contains GPU condition limits and the notification targets
+// alerts are delivered to. A zero limit disables it.
+type NotifyConfig struct {
+	TempC           float64
+	FanStoppedTempC float64
+	VRAMPct         float64
+	ReaderFailures  bool
+	For             time.Duration
+	WebhookURL      string
+	WebhookFormat   string
+	WebhookBody     string
+	WebhookHeaders  []string
+	SMTPAddr        string
+	SMTPFrom        string
+	SMTPTo          []string
+	SMTPUsername    string
+	SMTPPassword    string
+	Exec            string
+	Retries         int
+	Backoff         time.Duration
+	Dedupe          time.Duration
+}
+
 // Load parses configuration from environment variables, applying defaults.
 func Load() (Config, error) {
 	cfg := Config{
-		ListenAddr:         ":8080",
-		SampleInterval:     2 * time.Second,
-		LazySampler:        true,
-		LazySamplerIdleTTL: 10 * time.Second,
-		AllowedOrigins:     []string{"*"},
-		DefaultGPU:         "auto",
-		EnablePrometheus:   false,
-		EnablePprof:        false,
-		LogLevel:           slog.LevelInfo,
-		SysfsRoot:          "/sys",
-		DebugfsRoot:        "/sys/kernel/debug",
-		ProcRoot:           "/proc",
+		ListenAddr:           ":8080",
+		SampleInterval:       2 * time.Second,
+		LazySampler:          true,
+		LazySamplerIdleTTL:   10 * time.Second,
+		SampleReadTimeout:    time.Second,
+		SampleMediumInterval: 5 * time.Second,
+		SampleSlowInterval:   30 * time.Second,
+		AllowedOrigins:       []string{"*"},
+		DefaultGPU:           "auto",
+		EnablePrometheus:     false,
+		EnablePprof:          false,
+		LogLevel:             slog.LevelInfo,
+		SysfsRoot:            "/sys",
+		DebugfsRoot:          "/sys/kernel/debug",
+		ProcRoot:             "/proc",
+		DataRawRetention:     24 * time.Hour,
+		ReplaySpeed:          1,
+		EventsMax:            10000,
+		KmsgPath:             "/dev/kmsg",
 		WS: WebsocketConfig{
 			MaxClients:   1024,
 			WriteTimeout: 3 * time.Second,
 			ReadTimeout:  30 * time.Second,
 		},
 		Proc: ProcConfig{
-			Enable:       true,
-			ScanInterval: 2 * time.Second,
-			MaxPIDs:      5000,
-			MaxFDsPerPID: 64,
+			Enable:         true,
+			ScanInterval:   2 * time.Second,
+			MaxPIDs:        5000,
+			MaxFDsPerPID:   64,
+			MaxExited:      256,
+			NetlinkEvents:  false,
+			HistoryMaxPIDs: 64,
+			HistoryPoints:  150,
+			Enrich:         false,
 		},
 		Charts: ChartsConfig{
 			Enable:    true,
 			MaxPoints: 7200,
 		},
+		Notify: NotifyConfig{
+			For:           30 * time.Second,
+			WebhookFormat: "json",
+			Retries:       3,
+			Backoff:       2 * time.Second,
+			Dedupe:        10 * time.Minute,
+		},
 	}
 
 	if value := strings.TrimSpace(os.Getenv("APP_LISTEN_ADDR")); value != "" {
@@ -116,6 +182,53 @@ func Load() (Config, error) {
 		cfg.LazySamplerIdleTTL = duration
 	}
 
+	if value := strings.TrimSpace(os.Getenv("APP_SAMPLE_READ_TIMEOUT")); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_SAMPLE_READ_TIMEOUT: %w", err)
+		}
+		if timeout <= 0 {
+			return Config{}, fmt.Errorf("APP_SAMPLE_READ_TIMEOUT must be > 0")
+		}
+		cfg.SampleReadTimeout = timeout
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_SAMPLE_SUBINTERVAL")); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_SAMPLE_SUBINTERVAL: %w", err)
+		}
+		if duration < 0 {
+			return Config{}, fmt.Errorf("APP_SAMPLE_SUBINTERVAL must be >= 0")
+		}
+		if duration >= cfg.SampleInterval {
+			return Config{}, fmt.Errorf("APP_SAMPLE_SUBINTERVAL must be < APP_SAMPLE_INTERVAL")
+		}
+		cfg.SampleSubInterval = duration
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_SAMPLE_MEDIUM_INTERVAL")); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_SAMPLE_MEDIUM_INTERVAL: %w", err)
+		}
+		if duration < 0 {
+			return Config{}, fmt.Errorf("APP_SAMPLE_MEDIUM_INTERVAL must be >= 0")
+		}
+		cfg.SampleMediumInterval = duration
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_SAMPLE_SLOW_INTERVAL")); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_SAMPLE_SLOW_INTERVAL: %w", err)
+		}
+		if duration < 0 {
+			return Config{}, fmt.Errorf("APP_SAMPLE_SLOW_INTERVAL must be >= 0")
+		}
+		cfg.SampleSlowInterval = duration
+	}
+
 	if value := strings.TrimSpace(os.Getenv("APP_ALLOWED_ORIGINS")); value != "" {
 		origins := splitAndTrim(value, ",")
 		if len(origins) == 0 {
@@ -164,6 +277,81 @@ func Load() (Config, error) {
 		cfg.ProcRoot = value
 	}
 
+	if value := strings.TrimSpace(os.Getenv("APP_DATA_DIR")); value != "" {
+		cfg.DataDir = value
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_DATA_RAW_RETENTION")); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_DATA_RAW_RETENTION: %w", err)
+		}
+		if duration <= 0 {
+			return Config{}, fmt.Errorf("APP_DATA_RAW_RETENTION must be > 0")
+		}
+		cfg.DataRawRetention = duration
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_RECORD_FILE")); value != "" {
+		cfg.RecordFile = value
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_REPLAY_FILE")); value != "" {
+		cfg.ReplayFile = value
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_REPLAY_SPEED")); value != "" {
+		speed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_REPLAY_SPEED: %w", err)
+		}
+		if speed <= 0 {
+			return Config{}, fmt.Errorf("APP_REPLAY_SPEED must be > 0")
+		}
+		cfg.ReplaySpeed = speed
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_SIMULATE")); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_SIMULATE: %w", err)
+		}
+		if count < 0 {
+			return Config{}, fmt.Errorf("APP_SIMULATE must be >= 0")
+		}
+		if count > MaxSimulatedGPUs {
+			return Config{}, fmt.Errorf("APP_SIMULATE must be <= %d", MaxSimulatedGPUs)
+		}
+		cfg.Simulate = count
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_ALERT_RULES_FILE")); value != "" {
+		cfg.AlertRulesFile = value
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_EVENTS_MAX")); value != "" {
+		maxEvents, err := strconv.Atoi(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_EVENTS_MAX: %w", err)
+		}
+		if maxEvents <= 0 {
+			return Config{}, fmt.Errorf("APP_EVENTS_MAX must be > 0")
+		}
+		cfg.EventsMax = maxEvents
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_KMSG_ENABLE")); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_KMSG_ENABLE: %w", err)
+		}
+		cfg.KmsgEnable = enabled
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_KMSG_PATH")); value != "" {
+		cfg.KmsgPath = value
+	}
+
 	if value := strings.TrimSpace(os.Getenv("APP_WS_MAX_CLIENTS")); value != "" {
 		maxClients, err := strconv.Atoi(value)
 		if err != nil {
@@ -238,6 +426,59 @@ func Load() (Config, error) {
 		cfg.Proc.MaxFDsPerPID = maxFDs
 	}
 
+	if value := strings.TrimSpace(os.Getenv("APP_PROC_EXITED_MAX")); value != "" {
+		maxExited, err := strconv.Atoi(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_PROC_EXITED_MAX: %w", err)
+		}
+		if maxExited <= 0 {
+			return Config{}, fmt.Errorf("APP_PROC_EXITED_MAX must be > 0")
+		}
+		cfg.Proc.MaxExited = maxExited
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_PROC_NETLINK_EVENTS")); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_PROC_NETLINK_EVENTS: %w", err)
+		}
+		cfg.Proc.NetlinkEvents = enabled
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_PROC_HISTORY_MAX_PIDS")); value != "" {
+		maxPIDs, err := strconv.Atoi(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_PROC_HISTORY_MAX_PIDS: %w", err)
+		}
+		if maxPIDs <= 0 {
+			return Config{}, fmt.Errorf("APP_PROC_HISTORY_MAX_PIDS must be > 0")
+		}
+		cfg.Proc.HistoryMaxPIDs = maxPIDs
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_PROC_HISTORY_POINTS")); value != "" {
+		points, err := strconv.Atoi(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_PROC_HISTORY_POINTS: %w", err)
+		}
+		if points <= 0 {
+			return Config{}, fmt.Errorf("APP_PROC_HISTORY_POINTS must be > 0")
+		}
+		cfg.Proc.HistoryPoints = points
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_PROC_ENRICH")); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_PROC_ENRICH: %w", err)
+		}
+		cfg.Proc.Enrich = enabled
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_PROC_RULES_FILE")); value != "" {
+		cfg.Proc.RulesFile = value
+	}
+
 	if value := strings.TrimSpace(os.Getenv("APP_CHARTS_ENABLE")); value != "" {
 		enabled, err := strconv.ParseBool(value)
 		if err != nil {
@@ -257,6 +498,150 @@ func Load() (Config, error) {
 		cfg.Charts.MaxPoints = maxPoints
 	}
 
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_TEMP_C")); value != "" {
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_NOTIFY_TEMP_C: %w", err)
+		}
+		if limit < 0 {
+			return Config{}, fmt.Errorf("APP_NOTIFY_TEMP_C must be >= 0")
+		}
+		cfg.Notify.TempC = limit
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_FAN_STOPPED_TEMP_C")); value != "" {
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_NOTIFY_FAN_STOPPED_TEMP_C: %w", err)
+		}
+		if limit < 0 {
+			return Config{}, fmt.Errorf("APP_NOTIFY_FAN_STOPPED_TEMP_C must be >= 0")
+		}
+		cfg.Notify.FanStoppedTempC = limit
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_VRAM_PCT")); value != "" {
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_NOTIFY_VRAM_PCT: %w", err)
+		}
+		if limit < 0 {
+			return Config{}, fmt.Errorf("APP_NOTIFY_VRAM_PCT must be >= 0")
+		}
+		if limit > 100 {
+			return Config{}, fmt.Errorf("APP_NOTIFY_VRAM_PCT must be <= 100")
+		}
+		cfg.Notify.VRAMPct = limit
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_READER_FAILURES")); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_NOTIFY_READER_FAILURES: %w", err)
+		}
+		cfg.Notify.ReaderFailures = enabled
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_FOR")); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_NOTIFY_FOR: %w", err)
+		}
+		if duration < 0 {
+			return Config{}, fmt.Errorf("APP_NOTIFY_FOR must be >= 0")
+		}
+		cfg.Notify.For = duration
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_WEBHOOK_URL")); value != "" {
+		cfg.Notify.WebhookURL = value
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_WEBHOOK_FORMAT")); value != "" {
+		format := strings.ToLower(value)
+		switch format {
+		case "json", "ntfy", "gotify", "discord", "slack":
+		default:
+			return Config{}, fmt.Errorf("APP_NOTIFY_WEBHOOK_FORMAT must be one of json, ntfy, gotify, discord, slack")
+		}
+		cfg.Notify.WebhookFormat = format
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_WEBHOOK_BODY")); value != "" {
+		cfg.Notify.WebhookBody = value
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_WEBHOOK_HEADERS")); value != "" {
+		headers := splitAndTrim(value, ";")
+		for _, header := range headers {
+			if name, _, ok := strings.Cut(header, ":"); !ok || strings.TrimSpace(name) == "" {
+				return Config{}, fmt.Errorf("APP_NOTIFY_WEBHOOK_HEADERS: invalid header %q", header)
+			}
+		}
+		cfg.Notify.WebhookHeaders = headers
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_SMTP_ADDR")); value != "" {
+		cfg.Notify.SMTPAddr = value
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_SMTP_FROM")); value != "" {
+		cfg.Notify.SMTPFrom = value
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_SMTP_TO")); value != "" {
+		cfg.Notify.SMTPTo = splitAndTrim(value, ",")
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_SMTP_USERNAME")); value != "" {
+		cfg.Notify.SMTPUsername = value
+	}
+
+	if value := os.Getenv("APP_NOTIFY_SMTP_PASSWORD"); value != "" {
+		cfg.Notify.SMTPPassword = value
+	}
+
+	if cfg.Notify.SMTPAddr != "" && (cfg.Notify.SMTPFrom == "" || len(cfg.Notify.SMTPTo) == 0) {
+		return Config{}, fmt.Errorf("APP_NOTIFY_SMTP_ADDR requires APP_NOTIFY_SMTP_FROM and APP_NOTIFY_SMTP_TO")
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_EXEC")); value != "" {
+		cfg.Notify.Exec = value
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_RETRIES")); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_NOTIFY_RETRIES: %w", err)
+		}
+		if retries < 0 {
+			return Config{}, fmt.Errorf("APP_NOTIFY_RETRIES must be >= 0")
+		}
+		cfg.Notify.Retries = retries
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_BACKOFF")); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_NOTIFY_BACKOFF: %w", err)
+		}
+		if duration <= 0 {
+			return Config{}, fmt.Errorf("APP_NOTIFY_BACKOFF must be > 0")
+		}
+		cfg.Notify.Backoff = duration
+	}
+
+	if value := strings.TrimSpace(os.Getenv("APP_NOTIFY_DEDUPE")); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse APP_NOTIFY_DEDUPE: %w", err)
+		}
+		if duration < 0 {
+			return Config{}, fmt.Errorf("APP_NOTIFY_DEDUPE must be >= 0")
+		}
+		cfg.Notify.Dedupe = duration
+	}
+
 	return cfg, nil
 }
 
@@ -25,6 +25,15 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.LazySamplerIdleTTL != 10*time.Second {
 		t.Fatalf("unexpected LazySamplerIdleTTL %s", cfg.LazySamplerIdleTTL)
 	}
+	if cfg.SampleReadTimeout != time.Second {
+		t.Fatalf("unexpected SampleReadTimeout %s", cfg.SampleReadTimeout)
+	}
+	if cfg.SampleSubInterval != 0 {
+		t.Fatalf("expected sub-interval sampling disabled by default, got %s", cfg.SampleSubInterval)
+	}
+	if cfg.SampleMediumInterval != 5*time.Second || cfg.SampleSlowInterval != 30*time.Second {
+		t.Fatalf("unexpected group intervals %s/%s", cfg.SampleMediumInterval, cfg.SampleSlowInterval)
+	}
 	if cfg.LogLevel != slog.LevelInfo {
 		t.Fatalf("unexpected LogLevel %v", cfg.LogLevel)
 	}
@@ -37,6 +46,34 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.ProcRoot != "/proc" {
 		t.Fatalf("unexpected ProcRoot %q", cfg.ProcRoot)
 	}
+	if cfg.DataDir != "" {
+		t.Fatalf("unexpected DataDir %q", cfg.DataDir)
+	}
+	if cfg.DataRawRetention != 24*time.Hour {
+		t.Fatalf("unexpected DataRawRetention %s", cfg.DataRawRetention)
+	}
+	if cfg.RecordFile != "" || cfg.ReplayFile != "" {
+		t.Fatalf("unexpected record/replay files %q/%q", cfg.RecordFile, cfg.ReplayFile)
+	}
+	if cfg.ReplaySpeed != 1 {
+		t.Fatalf("unexpected ReplaySpeed %v", cfg.ReplaySpeed)
+	}
+	if cfg.Simulate != 0 {
+		t.Fatalf("unexpected Simulate %d", cfg.Simulate)
+	}
+	if cfg.AlertRulesFile != "" {
+		t.Fatalf("unexpected AlertRulesFile %q", cfg.AlertRulesFile)
+	}
+	if cfg.EventsMax != 10000 {
+		t.Fatalf("unexpected EventsMax %d", cfg.EventsMax)
+	}
+	if cfg.KmsgEnable || cfg.KmsgPath != "/dev/kmsg" {
+		t.Fatalf("unexpected kmsg defaults %v/%q", cfg.KmsgEnable, cfg.KmsgPath)
+	}
+	wantNotify := NotifyConfig{For: 30 * time.Second, WebhookFormat: "json", Retries: 3, Backoff: 2 * time.Second, Dedupe: 10 * time.Minute}
+	if !reflect.DeepEqual(cfg.Notify, wantNotify) {
+		t.Fatalf("unexpected Notify defaults %+v", cfg.Notify)
+	}
 	if !cfg.Proc.Enable {
 		t.Fatalf("expected process scanner enabled by default")
 	}
@@ -46,6 +83,21 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.Charts.MaxPoints != 7200 {
 		t.Fatalf("unexpected charts max points %d", cfg.Charts.MaxPoints)
 	}
+	if cfg.Proc.MaxExited != 256 {
+		t.Fatalf("unexpected proc exited max %d", cfg.Proc.MaxExited)
+	}
+	if cfg.Proc.HistoryMaxPIDs != 64 {
+		t.Fatalf("unexpected proc history max pids %d", cfg.Proc.HistoryMaxPIDs)
+	}
+	if cfg.Proc.HistoryPoints != 150 {
+		t.Fatalf("unexpected proc history points %d", cfg.Proc.HistoryPoints)
+	}
+	if cfg.Proc.Enrich || cfg.Proc.RulesFile != "" {
+		t.Fatalf("expected proc enrichment disabled by default")
+	}
+	if cfg.Proc.NetlinkEvents {
+		t.Fatalf("expected netlink proc events disabled by default")
+	}
 }
 
 func TestLoadEnvOverrides(t *testing.T) {
@@ -53,6 +105,10 @@ func TestLoadEnvOverrides(t *testing.T) {
 	t.Setenv("APP_SAMPLE_INTERVAL", "500ms")
 	t.Setenv("APP_LAZY_SAMPLER", "false")
 	t.Setenv("APP_LAZY_SAMPLER_IDLE_TTL", "45s")
+	t.Setenv("APP_SAMPLE_READ_TIMEOUT", "250ms")
+	t.Setenv("APP_SAMPLE_SUBINTERVAL", "100ms")
+	t.Setenv("APP_SAMPLE_MEDIUM_INTERVAL", "0")
+	t.Setenv("APP_SAMPLE_SLOW_INTERVAL", "1m")
 	t.Setenv("APP_ALLOWED_ORIGINS", "https://example.com, https://other.test")
 	t.Setenv("APP_DEFAULT_GPU", "card42")
 	t.Setenv("APP_ENABLE_PROMETHEUS", "true")
@@ -61,6 +117,34 @@ func TestLoadEnvOverrides(t *testing.T) {
 	t.Setenv("APP_SYSFS_ROOT", "/tmp/sys")
 	t.Setenv("APP_DEBUGFS_ROOT", "/tmp/debug")
 	t.Setenv("APP_PROC_ROOT", "/tmp/proc")
+	t.Setenv("APP_DATA_DIR", "/var/lib/amdgputop")
+	t.Setenv("APP_DATA_RAW_RETENTION", "72h")
+	t.Setenv("APP_RECORD_FILE", "/tmp/session.ndjson.gz")
+	t.Setenv("APP_REPLAY_FILE", "/tmp/replay.ndjson.gz")
+	t.Setenv("APP_REPLAY_SPEED", "2.5")
+	t.Setenv("APP_SIMULATE", "3")
+	t.Setenv("APP_ALERT_RULES_FILE", "/etc/amdgputop/alerts.json")
+	t.Setenv("APP_EVENTS_MAX", "500")
+	t.Setenv("APP_KMSG_ENABLE", "true")
+	t.Setenv("APP_KMSG_PATH", "/tmp/kmsg.log")
+	t.Setenv("APP_NOTIFY_TEMP_C", "95")
+	t.Setenv("APP_NOTIFY_FAN_STOPPED_TEMP_C", "70")
+	t.Setenv("APP_NOTIFY_VRAM_PCT", "97.5")
+	t.Setenv("APP_NOTIFY_READER_FAILURES", "true")
+	t.Setenv("APP_NOTIFY_FOR", "1m")
+	t.Setenv("APP_NOTIFY_WEBHOOK_URL", "https://ntfy.sh/gpu")
+	t.Setenv("APP_NOTIFY_WEBHOOK_FORMAT", "NTFY")
+	t.Setenv("APP_NOTIFY_WEBHOOK_BODY", "{{.Message}}")
+	t.Setenv("APP_NOTIFY_WEBHOOK_HEADERS", "Authorization: Bearer tk; Tags: fire,gpu")
+	t.Setenv("APP_NOTIFY_SMTP_ADDR", "mail.example.com:587")
+	t.Setenv("APP_NOTIFY_SMTP_FROM", "gpu@example.com")
+	t.Setenv("APP_NOTIFY_SMTP_TO", "ops@example.com, oncall@example.com")
+	t.Setenv("APP_NOTIFY_SMTP_USERNAME", "gpu")
+	t.Setenv("APP_NOTIFY_SMTP_PASSWORD", "secret")
+	t.Setenv("APP_NOTIFY_EXEC", "/usr/local/bin/page-me --gpu")
+	t.Setenv("APP_NOTIFY_RETRIES", "5")
+	t.Setenv("APP_NOTIFY_BACKOFF", "500ms")
+	t.Setenv("APP_NOTIFY_DEDUPE", "0")
 	t.Setenv("APP_WS_MAX_CLIENTS", "2048")
 	t.Setenv("APP_WS_WRITE_TIMEOUT", "10s")
 	t.Setenv("APP_WS_READ_TIMEOUT", "45s")
@@ -68,6 +152,12 @@ func TestLoadEnvOverrides(t *testing.T) {
 	t.Setenv("APP_PROC_SCAN_INTERVAL", "5s")
 	t.Setenv("APP_PROC_MAX_PIDS", "128")
 	t.Setenv("APP_PROC_MAX_FDS_PER_PID", "32")
+	t.Setenv("APP_PROC_EXITED_MAX", "64")
+	t.Setenv("APP_PROC_NETLINK_EVENTS", "true")
+	t.Setenv("APP_PROC_HISTORY_MAX_PIDS", "8")
+	t.Setenv("APP_PROC_HISTORY_POINTS", "30")
+	t.Setenv("APP_PROC_ENRICH", "true")
+	t.Setenv("APP_PROC_RULES_FILE", "/etc/amdgputop/rules.json")
 	t.Setenv("APP_CHARTS_ENABLE", "false")
 	t.Setenv("APP_CHARTS_MAX_POINTS", "12000")
 
@@ -88,6 +178,15 @@ func TestLoadEnvOverrides(t *testing.T) {
 	if cfg.LazySamplerIdleTTL != 45*time.Second {
 		t.Fatalf("LazySamplerIdleTTL override failed, got %s", cfg.LazySamplerIdleTTL)
 	}
+	if cfg.SampleReadTimeout != 250*time.Millisecond {
+		t.Fatalf("SampleReadTimeout override failed, got %s", cfg.SampleReadTimeout)
+	}
+	if cfg.SampleSubInterval != 100*time.Millisecond {
+		t.Fatalf("SampleSubInterval override failed, got %s", cfg.SampleSubInterval)
+	}
+	if cfg.SampleMediumInterval != 0 || cfg.SampleSlowInterval != time.Minute {
+		t.Fatalf("group interval overrides failed, got %s/%s", cfg.SampleMediumInterval, cfg.SampleSlowInterval)
+	}
 	wantOrigins := []string{"https://example.com", "https://other.test"}
 	if !reflect.DeepEqual(cfg.AllowedOrigins, wantOrigins) {
 		t.Fatalf("AllowedOrigins mismatch: %+v", cfg.AllowedOrigins)
@@ -113,6 +212,56 @@ func TestLoadEnvOverrides(t *testing.T) {
 	if cfg.ProcRoot != "/tmp/proc" {
 		t.Fatalf("ProcRoot override failed, got %q", cfg.ProcRoot)
 	}
+	if cfg.DataDir != "/var/lib/amdgputop" {
+		t.Fatalf("DataDir override failed, got %q", cfg.DataDir)
+	}
+	if cfg.DataRawRetention != 72*time.Hour {
+		t.Fatalf("DataRawRetention override failed, got %s", cfg.DataRawRetention)
+	}
+	if cfg.RecordFile != "/tmp/session.ndjson.gz" {
+		t.Fatalf("RecordFile override failed, got %q", cfg.RecordFile)
+	}
+	if cfg.ReplayFile != "/tmp/replay.ndjson.gz" {
+		t.Fatalf("ReplayFile override failed, got %q", cfg.ReplayFile)
+	}
+	if cfg.ReplaySpeed != 2.5 {
+		t.Fatalf("ReplaySpeed override failed, got %v", cfg.ReplaySpeed)
+	}
+	if cfg.Simulate != 3 {
+		t.Fatalf("Simulate override failed, got %d", cfg.Simulate)
+	}
+	if cfg.AlertRulesFile != "/etc/amdgputop/alerts.json" {
+		t.Fatalf("AlertRulesFile override failed, got %q", cfg.AlertRulesFile)
+	}
+	if cfg.EventsMax != 500 {
+		t.Fatalf("EventsMax override failed, got %d", cfg.EventsMax)
+	}
+	if !cfg.KmsgEnable || cfg.KmsgPath != "/tmp/kmsg.log" {
+		t.Fatalf("kmsg override failed, got %v/%q", cfg.KmsgEnable, cfg.KmsgPath)
+	}
+	wantNotify := NotifyConfig{
+		TempC:           95,
+		FanStoppedTempC: 70,
+		VRAMPct:         97.5,
+		ReaderFailures:  true,
+		For:             time.Minute,
+		WebhookURL:      "https://ntfy.sh/gpu",
+		WebhookFormat:   "ntfy",
+		WebhookBody:     "{{.Message}}",
+		WebhookHeaders:  []string{"Authorization: Bearer tk", "Tags: fire,gpu"},
+		SMTPAddr:        "mail.example.com:587",
+		SMTPFrom:        "gpu@example.com",
+		SMTPTo:          []string{"ops@example.com", "oncall@example.com"},
+		SMTPUsername:    "gpu",
+		SMTPPassword:    "secret",
+		Exec:            "/usr/local/bin/page-me --gpu",
+		Retries:         5,
+		Backoff:         500 * time.Millisecond,
+		Dedupe:          0,
+	}
+	if !reflect.DeepEqual(cfg.Notify, wantNotify) {
+		t.Fatalf("Notify override failed, got %+v", cfg.Notify)
+	}
 	if cfg.WS.MaxClients != 2048 {
 		t.Fatalf("WS.MaxClients override failed, got %d", cfg.WS.MaxClients)
 	}
@@ -134,6 +283,24 @@ func TestLoadEnvOverrides(t *testing.T) {
 	if cfg.Proc.MaxFDsPerPID != 32 {
 		t.Fatalf("Proc.MaxFDsPerPID override failed, got %d", cfg.Proc.MaxFDsPerPID)
 	}
+	if cfg.Proc.MaxExited != 64 {
+		t.Fatalf("Proc.MaxExited override failed, got %d", cfg.Proc.MaxExited)
+	}
+	if cfg.Proc.HistoryMaxPIDs != 8 {
+		t.Fatalf("Proc.HistoryMaxPIDs override failed, got %d", cfg.Proc.HistoryMaxPIDs)
+	}
+	if cfg.Proc.HistoryPoints != 30 {
+		t.Fatalf("Proc.HistoryPoints override failed, got %d", cfg.Proc.HistoryPoints)
+	}
+	if !cfg.Proc.Enrich {
+		t.Fatalf("Proc.Enrich override failed")
+	}
+	if cfg.Proc.RulesFile != "/etc/amdgputop/rules.json" {
+		t.Fatalf("Proc.RulesFile override failed, got %q", cfg.Proc.RulesFile)
+	}
+	if !cfg.Proc.NetlinkEvents {
+		t.Fatalf("Proc.NetlinkEvents override failed, expected true")
+	}
 	if cfg.Charts.Enable {
 		t.Fatalf("Charts.Enable override failed, expected false")
 	}
@@ -152,6 +319,15 @@ func TestLoadInvalidEnv(t *testing.T) {
 		{"InvalidLazySamplerBool", "APP_LAZY_SAMPLER", "maybe"},
 		{"InvalidLazySamplerTTL", "APP_LAZY_SAMPLER_IDLE_TTL", "slow"},
 		{"NonPositiveLazySamplerTTL", "APP_LAZY_SAMPLER_IDLE_TTL", "0"},
+		{"InvalidSampleReadTimeout", "APP_SAMPLE_READ_TIMEOUT", "soon"},
+		{"NonPositiveSampleReadTimeout", "APP_SAMPLE_READ_TIMEOUT", "0s"},
+		{"InvalidSampleSubInterval", "APP_SAMPLE_SUBINTERVAL", "fast"},
+		{"NegativeSampleSubInterval", "APP_SAMPLE_SUBINTERVAL", "-100ms"},
+		{"SampleSubIntervalNotBelowInterval", "APP_SAMPLE_SUBINTERVAL", "1h"},
+		{"InvalidSampleMediumInterval", "APP_SAMPLE_MEDIUM_INTERVAL", "often"},
+		{"NegativeSampleMediumInterval", "APP_SAMPLE_MEDIUM_INTERVAL", "-5s"},
+		{"InvalidSampleSlowInterval", "APP_SAMPLE_SLOW_INTERVAL", "rarely"},
+		{"NegativeSampleSlowInterval", "APP_SAMPLE_SLOW_INTERVAL", "-30s"},
 		{"InvalidOrigins", "APP_ALLOWED_ORIGINS", ","},
 		{"InvalidPrometheusBool", "APP_ENABLE_PROMETHEUS", "maybe"},
 		{"InvalidLogLevel", "APP_LOG_LEVEL", "loud"},
@@ -166,9 +342,40 @@ func TestLoadInvalidEnv(t *testing.T) {
 		{"NonPositiveProcMaxPIDs", "APP_PROC_MAX_PIDS", "0"},
 		{"InvalidProcMaxFDs", "APP_PROC_MAX_FDS_PER_PID", "lots"},
 		{"NonPositiveProcMaxFDs", "APP_PROC_MAX_FDS_PER_PID", "-1"},
+		{"InvalidProcExitedMax", "APP_PROC_EXITED_MAX", "all"},
+		{"NonPositiveProcExitedMax", "APP_PROC_EXITED_MAX", "0"},
+		{"InvalidProcNetlinkEvents", "APP_PROC_NETLINK_EVENTS", "maybe"},
+		{"InvalidProcHistoryMaxPIDs", "APP_PROC_HISTORY_MAX_PIDS", "many"},
+		{"NonPositiveProcHistoryMaxPIDs", "APP_PROC_HISTORY_MAX_PIDS", "0"},
+		{"InvalidProcHistoryPoints", "APP_PROC_HISTORY_POINTS", "lots"},
+		{"NonPositiveProcHistoryPoints", "APP_PROC_HISTORY_POINTS", "-5"},
+		{"InvalidProcEnrich", "APP_PROC_ENRICH", "sometimes"},
 		{"InvalidChartsEnable", "APP_CHARTS_ENABLE", "maybe"},
 		{"InvalidChartsMaxPoints", "APP_CHARTS_MAX_POINTS", "huge"},
 		{"NonPositiveChartsMaxPoints", "APP_CHARTS_MAX_POINTS", "0"},
+		{"InvalidDataRawRetention", "APP_DATA_RAW_RETENTION", "forever"},
+		{"NonPositiveDataRawRetention", "APP_DATA_RAW_RETENTION", "0s"},
+		{"InvalidReplaySpeed", "APP_REPLAY_SPEED", "fast"},
+		{"NonPositiveReplaySpeed", "APP_REPLAY_SPEED", "0"},
+		{"InvalidSimulate", "APP_SIMULATE", "two"},
+		{"NegativeSimulate", "APP_SIMULATE", "-1"},
+		{"TooManySimulated", "APP_SIMULATE", "17"},
+		{"InvalidEventsMax", "APP_EVENTS_MAX", "lots"},
+		{"NonPositiveEventsMax", "APP_EVENTS_MAX", "0"},
+		{"InvalidKmsgEnable", "APP_KMSG_ENABLE", "maybe"},
+		{"InvalidNotifyTemp", "APP_NOTIFY_TEMP_C", "hot"},
+		{"NegativeNotifyTemp", "APP_NOTIFY_TEMP_C", "-1"},
+		{"InvalidNotifyFanStoppedTemp", "APP_NOTIFY_FAN_STOPPED_TEMP_C", "warm"},
+		{"NotifyVRAMPctOverHundred", "APP_NOTIFY_VRAM_PCT", "101"},
+		{"InvalidNotifyReaderFailures", "APP_NOTIFY_READER_FAILURES", "maybe"},
+		{"InvalidNotifyFor", "APP_NOTIFY_FOR", "long"},
+		{"InvalidNotifyWebhookFormat", "APP_NOTIFY_WEBHOOK_FORMAT", "teams"},
+		{"InvalidNotifyWebhookHeaders", "APP_NOTIFY_WEBHOOK_HEADERS", "NoColon"},
+		{"NotifySMTPWithoutRecipients", "APP_NOTIFY_SMTP_ADDR", "mail.example.com:25"},
+		{"InvalidNotifyRetries", "APP_NOTIFY_RETRIES", "few"},
+		{"NegativeNotifyRetries", "APP_NOTIFY_RETRIES", "-1"},
+		{"NonPositiveNotifyBackoff", "APP_NOTIFY_BACKOFF", "0s"},
+		{"NegativeNotifyDedupe", "APP_NOTIFY_DEDUPE", "-1m"},
 	}
 
 	for _, tc := range testCases {
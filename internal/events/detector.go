@@ -0,0 +1,256 @@
+package events
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+type gpuState struct {
+	state     sampler.State
+	failing   bool
+	seen      bool
+	suspended bool
+	powerSeen bool
+	procs     map[int]procscan.Process
+	procSeen  bool
+}
+
+// Detector derives events by diffing consecutive samples and process
+// snapshots of each GPU. The first sample and snapshot of a GPU only set
+// the baseline, except that a reader failing from the start is reported.
+type Detector struct {
+	log *Log
+
+	mu   sync.Mutex
+	gpus map[string]*gpuState
+}
+
+// NewDetector creates a detector appending to log.
+func NewDetector(log *Log) *Detector {
+	return &Detector{log: log, gpus: make(map[string]*gpuState)}
+}
+
+func (d *Detector) gpu(id string) *gpuState {
+	g, ok := d.gpus[id]
+	if !ok {
+		g = &gpuState{}
+		d.gpus[id] = g
+	}
+
+	return g
+}
+
+// ObserveSample is meant to be registered as a sampler listener.
+func (d *Detector) ObserveSample(sample sampler.Sample) {
+	d.mu.Lock()
+	g := d.gpu(sample.GPUId)
+	events := diffPower(g, sample)
+	if !sample.Suspended() {
+		events = append(events, diffSample(g, sample)...)
+	}
+	// Failed and suspended samples carry no usable state; keep diffing
+	// against the last good one.
+	if !g.failing && !sample.Suspended() {
+		g.state = sample.State
+		g.seen = true
+	}
+	d.mu.Unlock()
+
+	for _, event := range events {
+		d.log.Append(event)
+	}
+}
+
+// diffPower reports runtime suspend and resume. Samples of a suspended GPU
+// carry no readings, which is not a reader error.
+func diffPower(g *gpuState, sample sampler.Sample) []Event {
+	suspended := sample.Suspended()
+	defer func() {
+		g.suspended = suspended
+		g.powerSeen = true
+	}()
+	if !g.powerSeen || suspended == g.suspended {
+		return nil
+	}
+
+	event := Event{GPUId: sample.GPUId, Time: sample.Timestamp, Kind: KindGPUResumed, Message: "GPU resumed from runtime suspend"}
+	if suspended {
+		event.Kind, event.Message = KindGPUSuspended, "GPU entered runtime suspend"
+	}
+
+	return []Event{event}
+}
+
+func diffSample(g *gpuState, sample sampler.Sample) []Event {
+	var out []Event
+	add := func(kind Kind, message string, attrs map[string]string) {
+		out = append(out, Event{GPUId: sample.GPUId, Time: sample.Timestamp, Kind: kind, Message: message, Attrs: attrs})
+	}
+
+	failing := !hasReadings(sample.Metrics)
+	switch {
+	case failing && !g.failing:
+		add(KindReaderError, "GPU reader returned no readings", nil)
+	case !failing && g.failing:
+		add(KindReaderRecovered, "GPU reader recovered", nil)
+	}
+	g.failing = failing
+	if !g.seen || failing {
+		return out
+	}
+
+	prev, cur := g.state, sample.State
+	prevThrottled, prevOK := prev.Throttled()
+	curThrottled, curOK := cur.Throttled()
+	if prevOK && curOK && prevThrottled != curThrottled {
+		if curThrottled {
+			add(KindThrottleStarted, "GPU started throttling", map[string]string{"throttle_status": fmt.Sprintf("0x%x", *cur.ThrottleStatus)})
+		} else {
+			add(KindThrottleEnded, "GPU stopped throttling", nil)
+		}
+	}
+	if changed(prev.PerfLevel, cur.PerfLevel) {
+		add(KindPerfLevelChanged, fmt.Sprintf("Performance level changed from %s to %s", prev.PerfLevel, cur.PerfLevel),
+			map[string]string{"from": prev.PerfLevel, "to": cur.PerfLevel})
+	}
+	if changed(prev.PowerProfile, cur.PowerProfile) {
+		add(KindPowerProfileChanged, fmt.Sprintf("Power profile changed from %s to %s", prev.PowerProfile, cur.PowerProfile),
+			map[string]string{"from": prev.PowerProfile, "to": cur.PowerProfile})
+	}
+	if order, ok := compareLink(prev, cur); ok && order != 0 {
+		kind, verb := KindLinkUpgraded, "upgraded"
+		if order < 0 {
+			kind, verb = KindLinkDowngraded, "downgraded"
+		}
+		add(kind, fmt.Sprintf("PCIe link %s from %s x%s to %s x%s", verb, prev.LinkSpeed, prev.LinkWidth, cur.LinkSpeed, cur.LinkWidth),
+			map[string]string{
+				"from_speed": prev.LinkSpeed, "from_width": prev.LinkWidth,
+				"to_speed": cur.LinkSpeed, "to_width": cur.LinkWidth,
+				"max_speed": cur.MaxLinkSpeed, "max_width": cur.MaxLinkWidth,
+			})
+	}
+
+	return out
+}
+
+func hasReadings(m sampler.Metrics) bool {
+	for _, field := range sampler.MetricFields {
+		if _, ok := field.Value(m); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// changed reports a change between two known values; appearing or
+// disappearing values are not events.
+func changed(prev, cur string) bool {
+	return prev != "" && cur != "" && prev != cur
+}
+
+// compareLink compares link bandwidth (speed × width) of two states.
+func compareLink(prev, cur sampler.State) (int, bool) {
+	prevSpeed, ok1 := sampler.LinkSpeedGTs(prev.LinkSpeed)
+	curSpeed, ok2 := sampler.LinkSpeedGTs(cur.LinkSpeed)
+	prevWidth, err1 := strconv.Atoi(prev.LinkWidth)
+	curWidth, err2 := strconv.Atoi(cur.LinkWidth)
+	if !ok1 || !ok2 || err1 != nil || err2 != nil {
+		return 0, false
+	}
+
+	prevBW, curBW := prevSpeed*float64(prevWidth), curSpeed*float64(curWidth)
+	switch {
+	case curBW < prevBW:
+		return -1, true
+	case curBW > prevBW:
+		return 1, true
+	}
+
+	return 0, true
+}
+
+// ObserveSnapshot is meant to be registered as a process scanner listener.
+func (d *Detector) ObserveSnapshot(snapshot procscan.Snapshot) {
+	d.mu.Lock()
+	g := d.gpu(snapshot.GPUId)
+	current := make(map[int]procscan.Process, len(snapshot.Processes))
+	for _, proc := range snapshot.Processes {
+		current[proc.PID] = proc
+	}
+
+	var out []Event
+	if g.procSeen {
+		for pid, proc := range current {
+			if _, ok := g.procs[pid]; !ok {
+				out = append(out, processEvent(snapshot, KindProcessStarted, "started", proc))
+			}
+		}
+		for pid, proc := range g.procs {
+			if _, ok := current[pid]; !ok {
+				out = append(out, processEvent(snapshot, KindProcessStopped, "stopped", proc))
+			}
+		}
+	}
+	g.procs = current
+	g.procSeen = true
+	d.mu.Unlock()
+
+	sortByPID(out)
+	for _, event := range out {
+		d.log.Append(event)
+	}
+}
+
+func processEvent(snapshot procscan.Snapshot, kind Kind, verb string, proc procscan.Process) Event {
+	name := proc.Name
+	if proc.DisplayName != "" {
+		name = proc.DisplayName
+	}
+	attrs := map[string]string{"pid": strconv.Itoa(proc.PID), "name": proc.Name}
+	if proc.User != "" {
+		attrs["user"] = proc.User
+	}
+
+	return Event{
+		GPUId:   snapshot.GPUId,
+		Time:    snapshot.Timestamp,
+		Kind:    kind,
+		Message: fmt.Sprintf("%s (pid %d) %s using the GPU", name, proc.PID, verb),
+		Attrs:   attrs,
+	}
+}
+
+// sortByPID orders process events deterministically: stops before starts,
+// then by PID.
+func sortByPID(events []Event) {
+	pid := func(e Event) int {
+		n, _ := strconv.Atoi(e.Attrs["pid"])
+
+		return n
+	}
+	slices.SortFunc(events, func(a, b Event) int {
+		if a.Kind != b.Kind {
+			return strings.Compare(string(b.Kind), string(a.Kind))
+		}
+
+		return cmp.Compare(pid(a), pid(b))
+	})
+}
+
+// ObserveSampler is meant to be registered as a sampler activity listener.
+func (d *Detector) ObserveSampler(active bool) {
+	if active {
+		d.log.Append(Event{Kind: KindSamplerActive, Message: "Sampler resumed on demand"})
+
+		return
+	}
+	d.log.Append(Event{Kind: KindSamplerIdle, Message: "Sampler went idle without clients"})
+}
@@ -0,0 +1,231 @@
+package events
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+func TestLogPersistsAndQueries(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	log, err := Open(Options{Path: path, Capacity: 3})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, gpu := range []string{"card0", "card1", "", "card0"} {
+		log.Append(Event{GPUId: gpu, Time: base.Add(time.Duration(i) * time.Minute), Kind: KindReaderError})
+	}
+
+	got := log.Query("card0", time.Time{}, 0)
+	if len(got) != 2 || got[0].GPUId != "" || got[1].ID != 4 {
+		t.Fatalf("expected global and newest card0 event, got %+v", got)
+	}
+	if got := log.Query("", base.Add(3*time.Minute), 0); len(got) != 1 || got[0].ID != 4 {
+		t.Fatalf("unexpected since filter result %+v", got)
+	}
+	if got := log.Query("", time.Time{}, 1); len(got) != 1 || got[0].ID != 4 {
+		t.Fatalf("unexpected limit result %+v", got)
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	// A torn last line is skipped on reload.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("open log file: %v", err)
+	}
+	_, _ = file.WriteString(`{"id":9,"kind":"read`)
+	_ = file.Close()
+
+	reopened, err := Open(Options{Path: path, Capacity: 3})
+	if err != nil {
+		t.Fatalf("reopen returned error: %v", err)
+	}
+	defer reopened.Close()
+	if got := reopened.Query("", time.Time{}, 0); len(got) != 3 || got[0].ID != 2 {
+		t.Fatalf("expected the 3 newest events after reload, got %+v", got)
+	}
+	if event := reopened.Append(Event{Kind: KindSamplerIdle}); event.ID != 5 {
+		t.Fatalf("expected IDs to continue after reload, got %d", event.ID)
+	}
+	// Events are written in the background; Close waits for them.
+	if err := reopened.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log file: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 4 {
+		t.Fatalf("expected compacted file with 4 lines, got %d:\n%s", lines, data)
+	}
+}
+
+func TestLogRingKeepsNewestAcrossCompactions(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	log, err := Open(Options{Path: path, Capacity: 4})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	for range 11 {
+		log.Append(Event{Kind: KindReaderError})
+	}
+	got := log.Query("", time.Time{}, 0)
+	if len(got) != 4 || got[0].ID != 8 || got[3].ID != 11 {
+		t.Fatalf("expected events 8-11 oldest first, got %+v", got)
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if event := log.Append(Event{Kind: KindReaderError}); event.ID != 12 {
+		t.Fatalf("expected appends after Close to stay in memory, got %+v", event)
+	}
+
+	reopened, err := Open(Options{Path: path, Capacity: 4})
+	if err != nil {
+		t.Fatalf("reopen returned error: %v", err)
+	}
+	defer reopened.Close()
+	got = reopened.Query("", time.Time{}, 0)
+	if len(got) != 4 || got[0].ID != 8 || got[3].ID != 11 {
+		t.Fatalf("expected persisted events 8-11 without duplicates, got %+v", got)
+	}
+}
+
+func TestLogSubscribe(t *testing.T) {
+	t.Parallel()
+
+	log, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	ch, cancel := log.Subscribe()
+	log.Append(Event{GPUId: "card0", Kind: KindThrottleStarted})
+	select {
+	case event := <-ch:
+		if event.Kind != KindThrottleStarted || event.ID != 1 || event.Time.IsZero() {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for event")
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatalf("expected channel to be closed after cancel")
+	}
+}
+
+func TestDetectorDiffsSamples(t *testing.T) {
+	t.Parallel()
+
+	log, _ := Open(Options{})
+	d := NewDetector(log)
+	busy := 10.0
+	ts := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	sample := func(state sampler.State, metrics sampler.Metrics) sampler.Sample {
+		ts = ts.Add(time.Second)
+
+		return sampler.Sample{GPUId: "card0", Timestamp: ts, Metrics: metrics, State: state}
+	}
+	status := func(v uint32) *uint32 { return &v }
+	ok := sampler.Metrics{GPUBusyPct: &busy}
+
+	base := sampler.State{PerfLevel: "auto", PowerProfile: "BOOTUP_DEFAULT", LinkSpeed: "16.0 GT/s PCIe", LinkWidth: "16", ThrottleStatus: status(0)}
+	d.ObserveSample(sample(base, ok))
+	if got := log.Query("", time.Time{}, 0); len(got) != 0 {
+		t.Fatalf("expected baseline sample to emit nothing, got %+v", got)
+	}
+
+	changed := base
+	changed.PerfLevel = "manual"
+	changed.PowerProfile = "COMPUTE"
+	changed.LinkSpeed = "2.5 GT/s PCIe"
+	changed.ThrottleStatus = status(0x20)
+	d.ObserveSample(sample(changed, ok))
+	d.ObserveSample(sample(sampler.State{}, sampler.Metrics{}))
+	d.ObserveSample(sample(changed, ok))
+
+	var kinds []Kind
+	for _, event := range log.Query("card0", time.Time{}, 0) {
+		kinds = append(kinds, event.Kind)
+	}
+	want := []Kind{
+		KindThrottleStarted, KindPerfLevelChanged, KindPowerProfileChanged, KindLinkDowngraded,
+		KindReaderError,
+		KindReaderRecovered,
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("expected %v, got %v", want, kinds)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, kinds)
+		}
+	}
+
+	d.ObserveSample(sample(base, ok))
+	got := log.Query("", ts, 0)
+	if len(got) != 4 || got[0].Kind != KindThrottleEnded || got[3].Kind != KindLinkUpgraded {
+		t.Fatalf("expected changes back to the baseline, got %+v", got)
+	}
+
+	// A suspended GPU has no readings, which is not a reader error, and
+	// its state is compared with the one before it went to sleep.
+	asleep := sample(sampler.State{}, sampler.Metrics{})
+	asleep.PowerState = sampler.PowerStateSuspended
+	d.ObserveSample(asleep)
+	awake := sample(base, ok)
+	awake.PowerState = sampler.PowerStateActive
+	d.ObserveSample(awake)
+	got = log.Query("", asleep.Timestamp, 0)
+	if len(got) != 2 || got[0].Kind != KindGPUSuspended || got[1].Kind != KindGPUResumed {
+		t.Fatalf("expected only suspend and resume events, got %+v", got)
+	}
+}
+
+func TestDetectorDiffsProcesses(t *testing.T) {
+	t.Parallel()
+
+	log, _ := Open(Options{})
+	d := NewDetector(log)
+	snapshot := func(pids ...int) procscan.Snapshot {
+		s := procscan.Snapshot{GPUId: "card0", Timestamp: time.Now()}
+		for _, pid := range pids {
+			s.Processes = append(s.Processes, procscan.Process{PID: pid, Name: "proc", User: "demo"})
+		}
+
+		return s
+	}
+
+	d.ObserveSnapshot(snapshot(1, 2))
+	d.ObserveSnapshot(snapshot(2, 4, 3))
+	got := log.Query("", time.Time{}, 0)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 process events, got %+v", got)
+	}
+	if got[0].Kind != KindProcessStopped || got[0].Attrs["pid"] != "1" {
+		t.Fatalf("expected stop of pid 1 first, got %+v", got[0])
+	}
+	if got[1].Kind != KindProcessStarted || got[1].Attrs["pid"] != "3" || got[2].Attrs["pid"] != "4" {
+		t.Fatalf("unexpected start events %+v", got[1:])
+	}
+	if got[1].Message != "proc (pid 3) started using the GPU" {
+		t.Fatalf("unexpected message %q", got[1].Message)
+	}
+
+	d.ObserveSampler(false)
+	if latest := log.Query("card0", time.Time{}, 1); latest[0].Kind != KindSamplerIdle || latest[0].GPUId != "" {
+		t.Fatalf("expected global sampler idle event, got %+v", latest)
+	}
+}
@@ -0,0 +1,431 @@
+// Package events keeps a per-GPU timeline of notable changes (throttling,
+// power state, PCIe link, GPU clients, reader health) derived from samples
+// and process snapshots, plus driver errors reported in the kernel log.
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Kind identifies the type of an event.
+type Kind string
+
+// Event kinds.
+const (
+	KindThrottleStarted     Kind = "throttle_started"
+	KindThrottleEnded       Kind = "throttle_ended"
+	KindPerfLevelChanged    Kind = "perf_level_changed"
+	KindPowerProfileChanged Kind = "power_profile_changed"
+	KindLinkDowngraded      Kind = "link_downgraded"
+	KindLinkUpgraded        Kind = "link_upgraded"
+	KindProcessStarted      Kind = "process_started"
+	KindProcessStopped      Kind = "process_stopped"
+	KindReaderError         Kind = "reader_error"
+	KindReaderRecovered     Kind = "reader_recovered"
+	KindReaderReopened      Kind = "reader_reopened"
+	KindSamplerIdle         Kind = "sampler_idle"
+	KindSamplerActive       Kind = "sampler_active"
+	KindGPUSuspended        Kind = "gpu_suspended"
+	KindGPUResumed          Kind = "gpu_resumed"
+	KindGPUReset            Kind = "gpu_reset"
+	KindGPUResetSucceeded   Kind = "gpu_reset_succeeded"
+	KindGPUResetFailed      Kind = "gpu_reset_failed"
+	KindRingTimeout         Kind = "ring_timeout"
+	KindPageFault           Kind = "page_fault"
+)
+
+// DefaultCapacity is the number of events kept when Options.Capacity is 0.
+const DefaultCapacity = 10000
+
+// Event is one entry of the timeline. GPUId is empty for events that apply
+// to all GPUs, such as the sampler going idle.
+type Event struct {
+	ID      uint64            `json:"id"`
+	GPUId   string            `json:"gpu_id,omitempty"`
+	Time    time.Time         `json:"ts"`
+	Kind    Kind              `json:"kind"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// Options configure a Log.
+type Options struct {
+	// Path of the NDJSON file events are appended to; empty keeps them in
+	// memory only.
+	Path     string
+	Capacity int
+	Logger   *slog.Logger
+}
+
+// writeQueueSize is how many events Append buffers for the file writer.
+const writeQueueSize = 256
+
+// Log stores the most recent events, optionally persisted to disk.
+type Log struct {
+	capacity int
+	path     string
+	logger   *slog.Logger
+
+	mu sync.Mutex
+	// events is a ring of up to capacity events; once it is full, head is
+	// the index of the oldest one.
+	events      []Event
+	head        int
+	nextID      uint64
+	subscribers map[*subscriber]struct{}
+	// writes feeds Append to the writer goroutine, which owns the file. It
+	// is nil for in-memory logs and after Close.
+	writes   chan Event
+	dropped  int
+	done     chan struct{}
+	closeErr error
+
+	file       *os.File
+	fileEvents int
+	// written is the ID of the newest event in the file.
+	written uint64
+}
+
+// Open creates a log, loading previously persisted events when a path is
+// set. Malformed lines, e.g. a tail cut short by a crash, are skipped.
+func Open(opts Options) (*Log, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	capacity := opts.Capacity
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+
+	l := &Log{
+		capacity:    capacity,
+		path:        opts.Path,
+		logger:      logger,
+		nextID:      1,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+	if l.path == "" {
+		return l, nil
+	}
+
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	// Rewrite the file so it only holds what was kept in memory.
+	if err := l.compact(l.ordered()); err != nil {
+		return nil, err
+	}
+
+	l.writes = make(chan Event, writeQueueSize)
+	l.done = make(chan struct{})
+	go l.runWriter(l.writes)
+
+	return l, nil
+}
+
+func (l *Log) load() error {
+	file, err := os.Open(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open event log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	skipped := 0
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.ID == 0 {
+			skipped++
+
+			continue
+		}
+		l.push(event)
+		l.nextID = max(l.nextID, event.ID+1)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read event log: %w", err)
+	}
+	if skipped > 0 {
+		l.logger.Warn("skipped malformed event log lines", "count", skipped)
+	}
+
+	return nil
+}
+
+// compact rewrites the file with events and reopens it for appending. Only
+// Open and the writer goroutine touch the file.
+func (l *Log) compact(events []Event) error {
+	if l.file != nil {
+		_ = l.file.Close()
+		l.file = nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), ".events-*")
+	if err != nil {
+		return fmt.Errorf("compact event log: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+
+			return fmt.Errorf("compact event log: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("compact event log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("compact event log: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("compact event log: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open event log: %w", err)
+	}
+	l.file = file
+	l.fileEvents = len(events)
+	if len(events) > 0 {
+		l.written = max(l.written, events[len(events)-1].ID)
+	}
+
+	return nil
+}
+
+// push stores an event, overwriting the oldest one once the ring is full.
+func (l *Log) push(event Event) {
+	if len(l.events) < l.capacity {
+		l.events = append(l.events, event)
+
+		return
+	}
+	l.events[l.head] = event
+	l.head = (l.head + 1) % len(l.events)
+}
+
+// at returns the i-th oldest event.
+func (l *Log) at(i int) Event {
+	return l.events[(l.head+i)%len(l.events)]
+}
+
+// ordered returns a copy of the stored events, oldest first.
+func (l *Log) ordered() []Event {
+	out := make([]Event, len(l.events))
+	for i := range out {
+		out[i] = l.at(i)
+	}
+
+	return out
+}
+
+// Append assigns the event an ID (and a timestamp when unset), stores it
+// and notifies subscribers. Persisting happens on the writer goroutine; when
+// it falls behind, events stay in memory but are not written to disk.
+func (l *Log) Append(event Event) Event {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+
+	l.mu.Lock()
+	event.ID = l.nextID
+	l.nextID++
+	l.push(event)
+	if l.writes != nil {
+		// Queued under the lock so the file keeps ID order.
+		select {
+		case l.writes <- event:
+		default:
+			l.dropped++
+		}
+	}
+	subs := make([]*subscriber, 0, len(l.subscribers))
+	for sub := range l.subscribers {
+		subs = append(subs, sub)
+	}
+	l.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.send(event)
+	}
+
+	return event
+}
+
+// runWriter appends queued events to the file until writes is closed,
+// compacting it once it holds twice the capacity.
+func (l *Log) runWriter(writes <-chan Event) {
+	defer close(l.done)
+	for event := range writes {
+		l.mu.Lock()
+		dropped := l.dropped
+		l.dropped = 0
+		l.mu.Unlock()
+		if dropped > 0 {
+			l.logger.Warn("event log writer lagging, events not persisted", "count", dropped)
+		}
+		// Events already rewritten by a compaction are not written again.
+		if event.ID <= l.written {
+			continue
+		}
+		l.persist(event)
+	}
+	if l.file != nil {
+		l.closeErr = l.file.Close()
+		l.file = nil
+	}
+}
+
+func (l *Log) persist(event Event) {
+	if l.file == nil {
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		l.logger.Warn("failed to encode event", "err", err)
+
+		return
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		l.logger.Warn("failed to persist event", "err", err)
+
+		return
+	}
+	l.written = event.ID
+	l.fileEvents++
+	if l.fileEvents >= 2*l.capacity {
+		l.mu.Lock()
+		events := l.ordered()
+		l.mu.Unlock()
+		if err := l.compact(events); err != nil {
+			l.logger.Warn("failed to compact event log", "err", err)
+		}
+	}
+}
+
+// Query returns events at or after since, oldest first. A non-empty gpuID
+// selects that GPU's events plus the ones applying to all GPUs; limit > 0
+// keeps only the newest limit events.
+func (l *Log) Query(gpuID string, since time.Time, limit int) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]Event, 0)
+	for i := range l.events {
+		event := l.at(i)
+		if event.Time.Before(since) {
+			continue
+		}
+		if gpuID != "" && event.GPUId != "" && event.GPUId != gpuID {
+			continue
+		}
+		out = append(out, event)
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+
+	return out
+}
+
+// Subscribe streams new events. Slow subscribers lose the oldest pending
+// events.
+func (l *Log) Subscribe() (<-chan Event, func()) {
+	sub := &subscriber{ch: make(chan Event, 32)}
+
+	l.mu.Lock()
+	l.subscribers[sub] = struct{}{}
+	l.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.subscribers, sub)
+			l.mu.Unlock()
+			sub.close()
+		})
+	}
+
+	return sub.ch, cancel
+}
+
+// Close writes the queued events and closes the backing file. Events
+// appended afterwards are kept in memory only.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	writes := l.writes
+	l.writes = nil
+	l.mu.Unlock()
+	if writes == nil {
+		return nil
+	}
+	close(writes)
+	<-l.done
+
+	return l.closeErr
+}
+
+type subscriber struct {
+	ch     chan Event
+	mu     sync.Mutex
+	closed bool
+}
+
+func (s *subscriber) send(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- event:
+		return
+	default:
+		select {
+		case <-s.ch:
+		default:
+		}
+		select {
+		case s.ch <- event:
+		default:
+		}
+	}
+}
+
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.ch)
+}
@@ -0,0 +1,162 @@
+// Package export streams persisted GPU samples as CSV or NDJSON.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+// Format selects the export encoding.
+type Format string
+
+// Supported formats.
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+)
+
+// ParseFormat validates a format name; empty selects CSV.
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(value))) {
+	case "", CSV:
+		return CSV, nil
+	case NDJSON:
+		return NDJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q", value)
+	}
+}
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	if f == NDJSON {
+		return "application/x-ndjson"
+	}
+
+	return "text/csv; charset=utf-8"
+}
+
+// Source yields raw samples, as implemented by tsdb.Store.
+type Source interface {
+	Samples(gpuID string, from, to time.Time, fn func(gpuID string, sample sampler.HistoryBucket) error) error
+}
+
+// Columns lists the exported columns: gpu_id, ts and the JSON names of
+// sampler.Metrics in declaration order.
+func Columns() []string {
+	columns := []string{"gpu_id", "ts"}
+	for _, field := range sampler.MetricFields {
+		columns = append(columns, field.Name)
+	}
+
+	return columns
+}
+
+// Write streams samples of gpuID (all GPUs when empty) between from and to.
+// Missing readings are written as empty CSV cells or JSON nulls.
+func Write(w io.Writer, src Source, format Format, gpuID string, from, to time.Time) error {
+	switch format {
+	case CSV:
+		return writeCSV(w, src, gpuID, from, to)
+	case NDJSON:
+		return writeNDJSON(w, src, gpuID, from, to)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+func writeCSV(w io.Writer, src Source, gpuID string, from, to time.Time) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Columns()); err != nil {
+		return err
+	}
+
+	row := make([]string, 2+len(sampler.MetricFields))
+	err := src.Samples(gpuID, from, to, func(id string, sample sampler.HistoryBucket) error {
+		row[0] = id
+		row[1] = formatTime(sample.Timestamp)
+		for i, field := range sampler.MetricFields {
+			row[2+i] = ""
+			if agg, ok := sample.Metrics[field.Name]; ok {
+				row[2+i] = strconv.FormatFloat(agg.Avg, 'f', -1, 64)
+			}
+		}
+
+		return cw.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func writeNDJSON(w io.Writer, src Source, gpuID string, from, to time.Time) error {
+	bw := bufio.NewWriter(w)
+	var line []byte
+	err := src.Samples(gpuID, from, to, func(id string, sample sampler.HistoryBucket) error {
+		// Keys are written by hand to keep the column order stable.
+		line = append(line[:0], `{"gpu_id":`...)
+		quoted, err := json.Marshal(id)
+		if err != nil {
+			return err
+		}
+		line = append(line, quoted...)
+		line = append(line, `,"ts":"`...)
+		line = append(line, formatTime(sample.Timestamp)...)
+		line = append(line, '"')
+		for _, field := range sampler.MetricFields {
+			line = append(line, `,"`...)
+			line = append(line, field.Name...)
+			line = append(line, `":`...)
+			if agg, ok := sample.Metrics[field.Name]; ok {
+				line = strconv.AppendFloat(line, agg.Avg, 'f', -1, 64)
+			} else {
+				line = append(line, "null"...)
+			}
+		}
+		line = append(line, "}\n"...)
+		_, err = bw.Write(line)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func formatTime(ts time.Time) string {
+	return ts.UTC().Format(time.RFC3339Nano)
+}
+
+// ParseTime accepts RFC 3339 timestamps, unix seconds, or a duration
+// relative to now (e.g. "15m" for fifteen minutes ago). Empty means unbounded.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return ts, nil
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(secs*float64(time.Second))), nil
+	}
+	ago, err := time.ParseDuration(strings.TrimPrefix(value, "-"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 time, unix seconds or duration, got %q", value)
+	}
+
+	return now.Add(-ago), nil
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+type fakeSource []struct {
+	gpuID  string
+	sample sampler.Sample
+}
+
+func (f fakeSource) Samples(gpuID string, _, _ time.Time, fn func(string, sampler.HistoryBucket) error) error {
+	for _, entry := range f {
+		if gpuID != "" && entry.gpuID != gpuID {
+			continue
+		}
+		if err := fn(entry.gpuID, sampler.SampleBucket(entry.sample)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func testSource() fakeSource {
+	busy := 42.5
+	temp := 61.0
+	vram := uint64(1 << 30)
+	ts := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	return fakeSource{
+		{"card0", sampler.Sample{Timestamp: ts, Metrics: sampler.Metrics{GPUBusyPct: &busy, VRAMUsedBytes: &vram}}},
+		{"card1", sampler.Sample{Timestamp: ts.Add(time.Second), Metrics: sampler.Metrics{TempC: &temp}}},
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testSource(), CSV, "", time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"gpu_id,ts,gpu_busy_pct,mem_busy_pct,sclk_mhz,mclk_mhz,temp_c,fan_rpm,power_w,vram_used_bytes,vram_total_bytes,gtt_used_bytes,gtt_total_bytes",
+		"card0,2025-03-01T12:00:00Z,42.5,,,,,,,1073741824,,,",
+		"card1,2025-03-01T12:00:01Z,,,,,61,,,,,,",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("unexpected CSV output:\n%s", buf.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Fatalf("line %d:\n got %s\nwant %s", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testSource(), NDJSON, "card0", time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	line := strings.TrimSpace(buf.String())
+	if strings.Count(line, "\n") != 0 {
+		t.Fatalf("expected a single line for card0, got:\n%s", buf.String())
+	}
+	if !strings.HasPrefix(line, `{"gpu_id":"card0","ts":"2025-03-01T12:00:00Z","gpu_busy_pct":42.5,"mem_busy_pct":null`) {
+		t.Fatalf("unexpected column order: %s", line)
+	}
+
+	var row map[string]any
+	if err := json.Unmarshal([]byte(line), &row); err != nil {
+		t.Fatalf("invalid JSON line: %v", err)
+	}
+	if len(row) != len(Columns()) || row["temp_c"] != nil || row["vram_used_bytes"] != float64(1<<30) {
+		t.Fatalf("unexpected row %v", row)
+	}
+}
+
+func TestParseFormatAndTime(t *testing.T) {
+	t.Parallel()
+
+	if format, err := ParseFormat(""); err != nil || format != CSV {
+		t.Fatalf("expected csv default, got %q (%v)", format, err)
+	}
+	if _, err := ParseFormat("parquet"); err == nil {
+		t.Fatalf("expected unsupported format error")
+	}
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"":                     {},
+		"2025-03-01T11:00:00Z": now.Add(-time.Hour),
+		"1740826800":           now.Add(-time.Hour),
+		"1h":                   now.Add(-time.Hour),
+		"-90m":                 now.Add(-90 * time.Minute),
+	}
+	for input, want := range cases {
+		got, err := ParseTime(input, now)
+		if err != nil || !got.Equal(want) {
+			t.Fatalf("ParseTime(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseTime("yesterday", now); err == nil {
+		t.Fatalf("expected error for invalid time")
+	}
+}
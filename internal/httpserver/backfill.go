@@ -0,0 +1,62 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/skobkin/amdgputop-web/internal/api"
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+// historyBackfill caches the encoded history message of each GPU so that many
+// clients (re)connecting at once share a single encoding per new sample.
+type historyBackfill struct {
+	sampler   *sampler.Manager
+	maxPoints int
+
+	mu      sync.Mutex
+	entries map[string]backfillEntry
+}
+
+type backfillEntry struct {
+	version uint64
+	data    []byte
+}
+
+func newHistoryBackfill(manager *sampler.Manager, maxPoints int) *historyBackfill {
+	return &historyBackfill{
+		sampler:   manager,
+		maxPoints: maxPoints,
+		entries:   make(map[string]backfillEntry),
+	}
+}
+
+// encoded returns the shared history payload for the GPU. The returned slice
+// must not be modified. ok is false when there is nothing to backfill.
+func (b *historyBackfill) encoded(gpuID string) ([]byte, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	version := b.sampler.HistoryVersion(gpuID)
+	if version == 0 {
+		return nil, false, nil
+	}
+	if entry, ok := b.entries[gpuID]; ok && entry.version == version {
+		return entry.data, true, nil
+	}
+
+	samples, version, err := b.sampler.Recent(gpuID, b.maxPoints)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(samples) == 0 {
+		return nil, false, nil
+	}
+	data, err := json.Marshal(api.NewHistoryMessage(gpuID, samples))
+	if err != nil {
+		return nil, false, err
+	}
+	b.entries[gpuID] = backfillEntry{version: version, data: data}
+
+	return data, true, nil
+}
@@ -5,7 +5,10 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/skobkin/amdgputop-web/internal/alerts"
+	"github.com/skobkin/amdgputop-web/internal/events"
 	"github.com/skobkin/amdgputop-web/internal/gpu"
+	"github.com/skobkin/amdgputop-web/internal/kmsg"
 	"github.com/skobkin/amdgputop-web/internal/sampler"
 )
 
@@ -162,6 +165,20 @@ func newGPUMetricsCollector(gpus []gpu.Info, samplerManager *sampler.Manager) pr
 				return float64(*sample.Metrics.GTTTotalBytes), true
 			},
 		},
+		{
+			desc:      desc("runtime_suspended", "1 while the GPU is runtime-suspended and not read, 0 when awake."),
+			valueType: prometheus.GaugeValue,
+			extract: func(sample sampler.Sample) (float64, bool) {
+				if sample.PowerState == "" {
+					return 0, false
+				}
+				if sample.Suspended() {
+					return 1, true
+				}
+
+				return 0, true
+			},
+		},
 		{
 			desc:      desc("sample_timestamp_seconds", "Unix timestamp of the latest GPU sample."),
 			valueType: prometheus.GaugeValue,
@@ -218,3 +235,102 @@ func (c *gpuMetricsCollector) Collect(ch chan<- prometheus.Metric) {
 		}
 	}
 }
+
+// alertsCollector exports pending and firing alerts in the style of the
+// Prometheus ALERTS series.
+type alertsCollector struct {
+	engine func() *alerts.Engine
+	desc   *prometheus.Desc
+}
+
+func newAlertsCollector(engine func() *alerts.Engine) prometheus.Collector {
+	return &alertsCollector{
+		engine: engine,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName("amdgputop", "", "alerts"),
+			"Active GPU alerts; 1 for every pending or firing alert.",
+			[]string{"alertname", "alertstate", "gpu_id", "severity"},
+			nil,
+		),
+	}
+}
+
+func (c *alertsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *alertsCollector) Collect(ch chan<- prometheus.Metric) {
+	engine := c.engine()
+	if engine == nil {
+		return
+	}
+	for _, alert := range engine.Active() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1, alert.Rule, string(alert.State), alert.GPUId, alert.Severity)
+	}
+}
+
+// kernelLogCollector exports counters of amdgpu driver errors seen in the
+// kernel log. Errors of GPUs that are not monitored keep an empty gpu_id.
+type kernelLogCollector struct {
+	watcher func() *kmsg.Watcher
+	gpus    []gpu.Info
+	descs   map[events.Kind]*prometheus.Desc
+}
+
+func newKernelLogCollector(gpus []gpu.Info, watcher func() *kmsg.Watcher) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(
+			prometheus.BuildFQName("amdgputop", "gpu", name),
+			help,
+			[]string{"gpu_id", "pci"},
+			nil,
+		)
+	}
+
+	return &kernelLogCollector{
+		watcher: watcher,
+		gpus:    append([]gpu.Info(nil), gpus...),
+		descs: map[events.Kind]*prometheus.Desc{
+			events.KindGPUReset:       desc("resets_total", "GPU resets started by the driver since start."),
+			events.KindGPUResetFailed: desc("reset_failures_total", "Failed GPU resets since start."),
+			events.KindRingTimeout:    desc("ring_timeouts_total", "Ring (job) timeouts reported by the driver since start."),
+			events.KindPageFault:      desc("page_faults_total", "GPU VM page faults reported by the driver since start."),
+		},
+	}
+}
+
+func (c *kernelLogCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c.descs {
+		ch <- desc
+	}
+}
+
+func (c *kernelLogCollector) Collect(ch chan<- prometheus.Metric) {
+	watcher := c.watcher()
+	if watcher == nil {
+		return
+	}
+
+	type key struct {
+		pci  string
+		kind events.Kind
+	}
+	totals := make(map[key]uint64)
+	for _, count := range watcher.Counts() {
+		totals[key{count.PCI, count.Kind}] = count.Total
+	}
+
+	// Monitored GPUs always report their counters so rates start at zero.
+	for _, info := range c.gpus {
+		for kind, desc := range c.descs {
+			k := key{info.PCI, kind}
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(totals[k]), info.ID, info.PCI)
+			delete(totals, k)
+		}
+	}
+	for k, total := range totals {
+		if desc, ok := c.descs[k.kind]; ok {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(total), "", k.pci)
+		}
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/pprof"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -16,11 +19,17 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/skobkin/amdgputop-web/internal/alerts"
 	"github.com/skobkin/amdgputop-web/internal/api"
 	"github.com/skobkin/amdgputop-web/internal/config"
+	"github.com/skobkin/amdgputop-web/internal/events"
+	"github.com/skobkin/amdgputop-web/internal/export"
 	"github.com/skobkin/amdgputop-web/internal/gpu"
+	"github.com/skobkin/amdgputop-web/internal/kmsg"
 	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/reconcile"
 	"github.com/skobkin/amdgputop-web/internal/sampler"
+	"github.com/skobkin/amdgputop-web/internal/usage"
 	"github.com/skobkin/amdgputop-web/internal/version"
 )
 
@@ -38,6 +47,12 @@ type Server struct {
 	gpuIndex   map[string]gpu.Info
 	sampler    *sampler.Manager
 	proc       *procscan.Manager
+	usage      *usage.Tracker
+	backfill   *historyBackfill
+	export     export.Source
+	alerts     *alerts.Engine
+	events     *events.Log
+	kmsg       *kmsg.Watcher
 
 	maxWSClients int64
 	wsActive     atomic.Int64
@@ -67,6 +82,9 @@ func New(cfg config.Config, logger *slog.Logger, gpus []gpu.Info, samplerManager
 	for _, info := range gpus {
 		s.gpuIndex[info.ID] = info
 	}
+	if cfg.Charts.Enable && samplerManager != nil {
+		s.backfill = newHistoryBackfill(samplerManager, cfg.Charts.MaxPoints)
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", s.handleHealthz)
@@ -79,6 +97,10 @@ func New(cfg config.Config, logger *slog.Logger, gpus []gpu.Info, samplerManager
 	mux.HandleFunc("/api/", s.handleAPIDocs)
 	mux.HandleFunc("/api/gpus", s.handleAPIGPUs)
 	mux.HandleFunc("/api/gpus/", s.handleAPIGPUSubresource)
+	mux.HandleFunc("/api/export", s.handleExport)
+	mux.HandleFunc("/api/alerts", s.handleAlerts)
+	mux.HandleFunc("/api/events", s.handleEvents)
+	mux.HandleFunc("/api/alerts/silences", s.handleAlertSilences)
 	mux.HandleFunc("/ws", s.handleWS)
 	mux.Handle("/", s.staticHandler())
 
@@ -100,6 +122,36 @@ func New(cfg config.Config, logger *slog.Logger, gpus []gpu.Info, samplerManager
 	return s
 }
 
+// EnableUsage serves usage leaderboards from the supplied tracker. It must be
+// called before Start.
+func (s *Server) EnableUsage(tracker *usage.Tracker) {
+	s.usage = tracker
+}
+
+// EnableExport serves /api/export from the supplied sample store. It must be
+// called before Start.
+func (s *Server) EnableExport(source export.Source) {
+	s.export = source
+}
+
+// EnableAlerts serves /api/alerts and pushes alert changes to WebSocket
+// clients. It must be called before Start.
+func (s *Server) EnableAlerts(engine *alerts.Engine) {
+	s.alerts = engine
+}
+
+// EnableEvents serves /api/events and pushes new events to WebSocket
+// clients. It must be called before Start.
+func (s *Server) EnableEvents(log *events.Log) {
+	s.events = log
+}
+
+// EnableKernelLog exports the driver error counters of the supplied kernel
+// log watcher. It must be called before Start.
+func (s *Server) EnableKernelLog(watcher *kmsg.Watcher) {
+	s.kmsg = watcher
+}
+
 // Start begins serving HTTP until shutdown is requested.
 func (s *Server) Start() error {
 	s.logger.Info("listening", "addr", s.httpServer.Addr)
@@ -235,7 +287,7 @@ func (s *Server) handleAPIGPUSubresource(w http.ResponseWriter, r *http.Request)
 	}
 	rest := strings.TrimPrefix(r.URL.Path, prefix)
 	segments := strings.Split(rest, "/")
-	if len(segments) != 2 || segments[0] == "" {
+	if len(segments) < 2 || segments[0] == "" {
 		http.NotFound(w, r)
 
 		return
@@ -248,12 +300,35 @@ func (s *Server) handleAPIGPUSubresource(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	switch segments[1] {
+	switch strings.Join(segments[1:], "/") {
 	case "metrics":
 		s.serveGPUMetrics(w, r, gpuID)
+	case "capabilities":
+		s.serveGPUCapabilities(w, r, gpuID)
 	case "procs":
 		s.serveGPUProcs(w, r, gpuID)
+	case "procs/exited":
+		s.serveGPUExitedProcs(w, r, gpuID)
+	case "usage":
+		s.serveGPUUsage(w, r, gpuID)
+	case "energy":
+		s.serveGPUEnergy(w, r, gpuID)
+	case "memory":
+		s.serveGPUMemory(w, r, gpuID)
+	case "history":
+		s.serveGPUHistory(w, r, gpuID)
 	default:
+		if len(segments) == 4 && segments[1] == "procs" && segments[3] == "history" {
+			pid, err := strconv.Atoi(segments[2])
+			if err != nil || pid <= 0 {
+				http.NotFound(w, r)
+
+				return
+			}
+			s.serveGPUProcHistory(w, r, gpuID, pid)
+
+			return
+		}
 		http.NotFound(w, r)
 	}
 }
@@ -287,6 +362,57 @@ func (s *Server) serveGPUMetrics(w http.ResponseWriter, r *http.Request, gpuID s
 	}
 }
 
+func (s *Server) serveGPUCapabilities(w http.ResponseWriter, r *http.Request, gpuID string) {
+	if s.sampler == nil {
+		http.Error(w, "metrics sampler unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	caps, ok, err := s.sampler.Capabilities(gpuID)
+	if err == nil && !ok {
+		// Nothing sampled yet, e.g. in lazy mode before the first client.
+		if _, _, err = s.sampler.Current(gpuID); err == nil {
+			caps, ok, err = s.sampler.Capabilities(gpuID)
+		}
+	}
+	if err != nil {
+		http.Error(w, "metrics sampler unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+	if !ok {
+		http.Error(w, "capabilities not available", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	logger := s.loggerFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(caps); err != nil {
+		logger.Error("failed to encode gpu capabilities", "gpu_id", gpuID, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+}
+
+// capabilitySummaries summarises the known capabilities of every GPU for
+// the hello message. GPUs not sampled yet are left out.
+func (s *Server) capabilitySummaries() map[string]sampler.CapabilitySummary {
+	if s.sampler == nil {
+		return nil
+	}
+	out := make(map[string]sampler.CapabilitySummary, len(s.gpus))
+	for _, info := range s.gpus {
+		if caps, ok, err := s.sampler.Capabilities(info.ID); err == nil && ok {
+			out[info.ID] = caps.Summary()
+		}
+	}
+
+	return out
+}
+
 func (s *Server) serveGPUProcs(w http.ResponseWriter, r *http.Request, gpuID string) {
 	if s.proc == nil {
 		http.Error(w, "process scanner unavailable", http.StatusServiceUnavailable)
@@ -316,6 +442,404 @@ func (s *Server) serveGPUProcs(w http.ResponseWriter, r *http.Request, gpuID str
 	}
 }
 
+func (s *Server) serveGPUExitedProcs(w http.ResponseWriter, r *http.Request, gpuID string) {
+	if s.proc == nil {
+		http.Error(w, "process scanner unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	exited, err := s.proc.Exited(gpuID)
+	if err != nil {
+		http.Error(w, "process scanner unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	logger := s.loggerFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(exited); err != nil {
+		logger.Error("failed to encode exited process data", "gpu_id", gpuID, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+}
+
+func (s *Server) serveGPUMemory(w http.ResponseWriter, r *http.Request, gpuID string) {
+	if s.sampler == nil || s.proc == nil {
+		http.Error(w, "memory reconciliation unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	sample, ok, err := s.sampler.Current(gpuID)
+	if err != nil || !ok {
+		http.Error(w, "no sample available", http.StatusServiceUnavailable)
+
+		return
+	}
+	snapshot, ok, err := s.proc.Current(gpuID)
+	if err != nil || !ok {
+		http.Error(w, "no process snapshot available", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	logger := s.loggerFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reconcile.Memory(sample, snapshot, s.proc.PermissionDenied())); err != nil {
+		logger.Error("failed to encode memory report", "gpu_id", gpuID, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+}
+
+func (s *Server) serveGPUEnergy(w http.ResponseWriter, r *http.Request, gpuID string) {
+	if s.proc == nil {
+		http.Error(w, "process scanner unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	energy, err := s.proc.Energy(gpuID)
+	if err != nil {
+		http.Error(w, "process scanner unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	logger := s.loggerFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(energy); err != nil {
+		logger.Error("failed to encode energy data", "gpu_id", gpuID, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+}
+
+func (s *Server) serveGPUProcHistory(w http.ResponseWriter, r *http.Request, gpuID string, pid int) {
+	if s.proc == nil {
+		http.Error(w, "process scanner unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	history, ok, err := s.proc.History(gpuID, pid)
+	if err != nil {
+		http.Error(w, "process scanner unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+	if !ok {
+		http.Error(w, "no history for process", http.StatusNotFound)
+
+		return
+	}
+
+	logger := s.loggerFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		logger.Error("failed to encode process history", "gpu_id", gpuID, "pid", pid, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+}
+
+func (s *Server) serveGPUUsage(w http.ResponseWriter, r *http.Request, gpuID string) {
+	if s.usage == nil {
+		http.Error(w, "usage tracking unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	query := r.URL.Query()
+	window, err := usage.ParseWindow(query.Get("window"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+	by, err := usage.ParseGrouping(query.Get("by"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	board := s.usage.Leaderboard(gpuID, by, window, time.Now())
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+
+			return
+		}
+		if len(board.Entries) > limit {
+			board.Entries = board.Entries[:limit]
+		}
+	}
+
+	logger := s.loggerFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(board); err != nil {
+		logger.Error("failed to encode usage leaderboard", "gpu_id", gpuID, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+}
+
+func (s *Server) serveGPUHistory(w http.ResponseWriter, r *http.Request, gpuID string) {
+	if s.sampler == nil {
+		http.Error(w, "metrics history unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	query := r.URL.Query()
+	now := time.Now()
+	since, err := export.ParseTime(query.Get("since"), now)
+	if err != nil {
+		http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+	until, err := export.ParseTime(query.Get("until"), now)
+	if err != nil {
+		http.Error(w, "invalid until: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		http.Error(w, "until must not be before since", http.StatusBadRequest)
+
+		return
+	}
+	var step time.Duration
+	if raw := query.Get("step"); raw != "" {
+		step, err = time.ParseDuration(raw)
+		if err != nil || step <= 0 {
+			http.Error(w, "step must be a positive duration", http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	history, err := s.sampler.History(gpuID, since, until, step)
+	if err != nil {
+		http.Error(w, "metrics history unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	logger := s.loggerFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		logger.Error("failed to encode gpu history", "gpu_id", gpuID, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+	if s.export == nil {
+		http.Error(w, "export unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	query := r.URL.Query()
+	gpuID := query.Get("gpu")
+	if gpuID != "" {
+		if _, ok := s.gpuIndex[gpuID]; !ok {
+			http.Error(w, fmt.Sprintf("unknown gpu %q", gpuID), http.StatusNotFound)
+
+			return
+		}
+	}
+	format, err := export.ParseFormat(query.Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+	now := time.Now()
+	from, err := export.ParseTime(query.Get("from"), now)
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+	to, err := export.ParseTime(query.Get("to"), now)
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	name := "amdgputop"
+	if gpuID != "" {
+		name += "-" + gpuID
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+string(format)))
+
+	// Headers are already sent once streaming starts, so failures can only
+	// be logged.
+	if err := export.Write(w, s.export, format, gpuID, from, to); err != nil {
+		s.loggerFromContext(r.Context()).Warn("export failed", "gpu_id", gpuID, "err", err)
+	}
+}
+
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+	if s.alerts == nil {
+		http.Error(w, "alerts unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	logger := s.loggerFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.alerts.Active()); err != nil {
+		logger.Error("failed to encode alerts", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+	if s.events == nil {
+		http.Error(w, "events unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	query := r.URL.Query()
+	gpuID := query.Get("gpu")
+	if gpuID != "" {
+		if _, ok := s.gpuIndex[gpuID]; !ok {
+			http.Error(w, fmt.Sprintf("unknown gpu %q", gpuID), http.StatusNotFound)
+
+			return
+		}
+	}
+	since, err := export.ParseTime(query.Get("since"), time.Now())
+	if err != nil {
+		http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+	limit := 0
+	if raw := query.Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	logger := s.loggerFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.events.Query(gpuID, since, limit)); err != nil {
+		logger.Error("failed to encode events", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+}
+
+type silenceRequest struct {
+	Rule     string `json:"rule"`
+	GPUId    string `json:"gpu_id"`
+	Duration string `json:"duration"`
+}
+
+func (s *Server) handleAlertSilences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+	if s.alerts == nil {
+		http.Error(w, "alerts unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !s.mutationOriginAllowed(r) {
+			s.loggerFromContext(r.Context()).Warn("silence rejected", "reason", "origin", "origin", r.Header.Get("Origin"))
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+
+			return
+		}
+		var req silenceRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "invalid silence payload", http.StatusBadRequest)
+
+			return
+		}
+		if req.GPUId != "" {
+			if _, ok := s.gpuIndex[req.GPUId]; !ok {
+				http.Error(w, fmt.Sprintf("unknown gpu %q", req.GPUId), http.StatusNotFound)
+
+				return
+			}
+		}
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil || duration < 0 {
+			http.Error(w, "invalid duration", http.StatusBadRequest)
+
+			return
+		}
+		if err := s.alerts.Silence(req.Rule, req.GPUId, time.Now().Add(duration)); err != nil {
+			if errors.Is(err, alerts.ErrUnknownRule) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	logger := s.loggerFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.alerts.Silences()); err != nil {
+		logger.Error("failed to encode alert silences", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+
+		return
+	}
+}
+
 func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 	reqLogger := s.loggerFromContext(r.Context())
 	if r.Method != http.MethodGet {
@@ -352,8 +876,12 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 	outbound := newWSOutbound(wsSendQueueSize, &s.wsDropped)
 
 	features := map[string]bool{
-		"procs":  s.proc != nil,
-		"charts": s.cfg.Charts.Enable,
+		"procs":        s.proc != nil,
+		"proc_history": s.proc != nil,
+		"charts":       s.cfg.Charts.Enable,
+		"history":      s.backfill != nil,
+		"alerts":       s.alerts != nil,
+		"events":       s.events != nil,
 	}
 	chartsMaxPoints := 0
 	if s.cfg.Charts.Enable {
@@ -365,6 +893,7 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 		features,
 		chartsMaxPoints,
 	)
+	hello.Capabilities = s.capabilitySummaries()
 
 	ctx, cancel := context.WithCancel(r.Context())
 
@@ -376,7 +905,10 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 		unsubscribe     func()
 		procCh          <-chan procscan.Snapshot
 		procUnsubscribe func()
+		alertCh         <-chan alerts.Alert
+		eventCh         <-chan events.Event
 		currentGPU      string
+		subStats        bool
 	)
 
 	defer func() {
@@ -395,13 +927,31 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.alerts != nil {
+		// Subscribe before listing so no change between the two is lost.
+		ch, alertUnsubscribe := s.alerts.Subscribe()
+		defer alertUnsubscribe()
+		alertCh = ch
+		for _, alert := range s.alerts.Active() {
+			if !alert.Silenced && !s.enqueueMessage(outbound, api.NewAlertMessage(alert), logger) {
+				return
+			}
+		}
+	}
+
+	if s.events != nil {
+		ch, eventUnsubscribe := s.events.Subscribe()
+		defer eventUnsubscribe()
+		eventCh = ch
+	}
+
 	messageCh := make(chan []byte, 8)
 	readErrCh := make(chan error, 1)
 	go s.readMessages(ctx, conn, messageCh, readErrCh)
 
 	defaultGPU := s.defaultGPU()
 
-	switchSubscription := func(target string) error {
+	switchSubscription := func(target string, withSubStats bool) error {
 		if target == "" {
 			return fmt.Errorf("empty gpu id")
 		}
@@ -411,6 +961,7 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 		if s.sampler == nil {
 			return fmt.Errorf("sampler unavailable")
 		}
+		subStats = withSubStats
 		if target == currentGPU {
 			return nil
 		}
@@ -430,6 +981,7 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 		}
 		subCh = ch
 		unsubscribe = cancel
+		s.enqueueHistory(outbound, target, logger)
 		if s.proc != nil {
 			procStream, procCancel, err := s.proc.Subscribe(target)
 			if err != nil {
@@ -446,7 +998,7 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if defaultGPU != "" {
-		if err := switchSubscription(defaultGPU); err != nil {
+		if err := switchSubscription(defaultGPU, false); err != nil {
 			logger.Warn("failed to subscribe default gpu", "gpu_id", defaultGPU, "err", err)
 			_ = s.enqueueError(outbound, fmt.Sprintf("failed to subscribe default gpu: %v", err), logger)
 		}
@@ -463,6 +1015,11 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 
 				continue
 			}
+			if !subStats {
+				// Sub-interval stats are opt-in to keep pushes small; REST
+				// always returns them.
+				sample.Stats, sample.SubSamples = nil, 0
+			}
 			if !s.enqueueMessage(outbound, api.NewStatsMessage(sample), logger) {
 				return
 			}
@@ -475,6 +1032,28 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 			if !s.enqueueMessage(outbound, api.NewProcsMessage(snapshot), logger) {
 				return
 			}
+		case alert, ok := <-alertCh:
+			if !ok {
+				alertCh = nil
+
+				continue
+			}
+			if !s.enqueueMessage(outbound, api.NewAlertMessage(alert), logger) {
+				return
+			}
+		case event, ok := <-eventCh:
+			if !ok {
+				eventCh = nil
+
+				continue
+			}
+			// Only events of the subscribed GPU and global ones are pushed.
+			if event.GPUId != "" && event.GPUId != currentGPU {
+				continue
+			}
+			if !s.enqueueMessage(outbound, api.NewEventMessage(event), logger) {
+				return
+			}
 		case data, ok := <-messageCh:
 			if !ok {
 				messageCh = nil
@@ -546,7 +1125,7 @@ func (s *Server) readMessages(ctx context.Context, conn *websocket.Conn, out cha
 	}
 }
 
-func (s *Server) handleClientMessage(outbound *wsOutbound, data []byte, switchSubscription func(string) error, defaultGPU string, logger *slog.Logger) error {
+func (s *Server) handleClientMessage(outbound *wsOutbound, data []byte, switchSubscription func(string, bool) error, defaultGPU string, logger *slog.Logger) error {
 	var envelope api.ClientMessage
 	if err := json.Unmarshal(data, &envelope); err != nil {
 		logger.Debug("invalid client message", "err", err)
@@ -575,13 +1154,24 @@ func (s *Server) handleClientMessage(outbound *wsOutbound, data []byte, switchSu
 
 			return nil
 		}
-		if err := switchSubscription(target); err != nil {
+		if err := switchSubscription(target, msg.SubStats); err != nil {
 			if !s.enqueueError(outbound, err.Error(), logger) {
 				return fmt.Errorf("failed to enqueue subscription error")
 			}
 
 			return nil
 		}
+		if msg.ProcHistory && s.proc != nil {
+			histories, err := s.proc.HistoryAll(target)
+			if err != nil {
+				logger.Warn("failed to load process history", "gpu_id", target, "err", err)
+
+				return nil
+			}
+			if !s.enqueueMessage(outbound, api.NewProcHistoryMessage(target, histories), logger) {
+				return fmt.Errorf("failed to enqueue process history")
+			}
+		}
 	case "ping":
 		if !s.enqueueMessage(outbound, api.PongMessage{Type: "pong"}, logger) {
 			return fmt.Errorf("failed to enqueue pong response")
@@ -645,6 +1235,23 @@ func (s *Server) enqueueMessage(outbound *wsOutbound, payload any, logger *slog.
 	return true
 }
 
+// enqueueHistory sends the shared history backfill for the GPU, if any. It is
+// called right after subscribing so that it precedes live stats messages.
+func (s *Server) enqueueHistory(outbound *wsOutbound, gpuID string, logger *slog.Logger) {
+	if s.backfill == nil {
+		return
+	}
+	data, ok, err := s.backfill.encoded(gpuID)
+	if err != nil {
+		logger.Warn("failed to build history backfill", "gpu_id", gpuID, "err", err)
+
+		return
+	}
+	if ok && !outbound.enqueue(data) {
+		logger.Warn("websocket outbound queue unavailable")
+	}
+}
+
 func (s *Server) enqueueError(outbound *wsOutbound, msg string, logger *slog.Logger) bool {
 	return s.enqueueMessage(outbound, api.ErrorMessage{Type: "error", Message: msg}, logger)
 }
@@ -721,6 +1328,10 @@ func (s *Server) registerPrometheus(mux *http.ServeMux) {
 	if gpuCollector := newGPUMetricsCollector(s.gpus, s.sampler); gpuCollector != nil {
 		collectors = append(collectors, gpuCollector)
 	}
+	// The alert engine is attached after New, so the collector resolves it
+	// on every scrape.
+	collectors = append(collectors, newAlertsCollector(func() *alerts.Engine { return s.alerts }))
+	collectors = append(collectors, newKernelLogCollector(s.gpus, func() *kmsg.Watcher { return s.kmsg }))
 
 	for _, collector := range collectors {
 		registry.MustRegister(collector)
@@ -737,6 +1348,40 @@ func registerPprof(mux *http.ServeMux) {
 	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 }
 
+// mutationOriginAllowed reports whether a state-changing request may be
+// served. Requests without an Origin header (curl, scripts) and same-origin
+// requests are; other origins must be listed in AllowedOrigins explicitly,
+// as "*" alone would let any page the operator visits act on their behalf.
+func (s *Server) mutationOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, pattern := range s.cfg.AllowedOrigins {
+		if pattern == "*" {
+			continue
+		}
+		// Patterns match the host like the WebSocket check, or the whole
+		// origin when they carry a scheme.
+		target := u.Host
+		if strings.Contains(pattern, "://") {
+			target = origin
+		}
+		if ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(target)); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
 func originPatterns(origins []string) []string {
 	for _, origin := range origins {
 		if origin == "*" {
@@ -753,6 +1398,10 @@ func (s *Server) readiness() readyResponse {
 	resp := readyResponse{
 		GPUs: len(s.gpus),
 	}
+	if s.proc != nil {
+		denied := s.proc.PermissionDenied()
+		resp.ProcsPermissionDenied = &denied
+	}
 
 	if len(s.gpus) == 0 {
 		resp.Status = "ok"
@@ -776,6 +1425,16 @@ func (s *Server) readiness() readyResponse {
 		return resp
 	}
 
+	// A hung reader only degrades its own GPU; the service stays ready
+	// while any other GPU can still be read.
+	resp.Degraded = s.sampler.Quarantined()
+	if len(resp.Degraded) == len(readers) {
+		resp.Status = "degraded"
+		resp.Reason = "readers_unresponsive"
+
+		return resp
+	}
+
 	if s.cfg.LazySampler && !s.sampler.Ready() && !s.sampler.HasDemand() {
 		resp.Status = "ok"
 
@@ -795,10 +1454,14 @@ func (s *Server) readiness() readyResponse {
 }
 
 type readyResponse struct {
-	Status  string `json:"status"`
-	GPUs    int    `json:"gpus"`
-	Readers int    `json:"metrics_readers"`
-	Reason  string `json:"reason,omitempty"`
+	Status   string               `json:"status"`
+	GPUs     int                  `json:"gpus"`
+	Readers  int                  `json:"metrics_readers"`
+	Reason   string               `json:"reason,omitempty"`
+	Degraded []sampler.Quarantine `json:"degraded,omitempty"`
+	// ProcsPermissionDenied counts processes the last process scan could
+	// not inspect. It spans all GPUs and is absent without a scanner.
+	ProcsPermissionDenied *int `json:"procs_permission_denied,omitempty"`
 }
 
 type wsOutbound struct {
@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -21,10 +22,17 @@ import (
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/model"
+	"github.com/skobkin/amdgputop-web/internal/alerts"
+	"github.com/skobkin/amdgputop-web/internal/api"
 	"github.com/skobkin/amdgputop-web/internal/config"
+	"github.com/skobkin/amdgputop-web/internal/events"
 	"github.com/skobkin/amdgputop-web/internal/gpu"
+	"github.com/skobkin/amdgputop-web/internal/kmsg"
 	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/reconcile"
 	"github.com/skobkin/amdgputop-web/internal/sampler"
+	"github.com/skobkin/amdgputop-web/internal/simulate"
+	"github.com/skobkin/amdgputop-web/internal/usage"
 	"github.com/skobkin/amdgputop-web/internal/version"
 )
 
@@ -163,6 +171,78 @@ func TestReadyzStates(t *testing.T) {
 
 }
 
+// hangingSource blocks in Sample until release is closed.
+type hangingSource struct {
+	id      string
+	release chan struct{}
+}
+
+func (s hangingSource) Sample() sampler.Sample {
+	<-s.release
+
+	return sampler.Sample{GPUId: s.id, Timestamp: time.Now()}
+}
+
+func (s hangingSource) Close() error { return nil }
+
+type instantSource struct{ id string }
+
+func (s instantSource) Sample() sampler.Sample {
+	return sampler.Sample{GPUId: s.id, Timestamp: time.Now()}
+}
+
+func (s instantSource) Close() error { return nil }
+
+func TestReadyzQuarantinedReader(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	cfg := defaultTestConfig()
+	cfg.LazySampler = false
+	newManager := func(sources map[string]sampler.Source) *sampler.Manager {
+		manager, err := sampler.NewManagerFromSources(time.Second, sources, logger)
+		if err != nil {
+			t.Fatalf("NewManagerFromSources error: %v", err)
+		}
+		if err := manager.SetReadTimeout(10 * time.Millisecond); err != nil {
+			t.Fatalf("SetReadTimeout error: %v", err)
+		}
+		manager.CurrentAll()
+
+		return manager
+	}
+
+	partial := newManager(map[string]sampler.Source{
+		"card0": instantSource{id: "card0"},
+		"card1": hangingSource{id: "card1", release: release},
+	})
+	ts := newTestHTTPServer(t, cfg, []gpu.Info{{ID: "card0"}, {ID: "card1"}}, partial, nil)
+	defer ts.Close()
+	assertReadyz(t, ts.URL+"/readyz", http.StatusOK, "ok", "")
+
+	resp, err := http.Get(ts.URL + "/readyz")
+	if err != nil {
+		t.Fatalf("GET readyz failed: %v", err)
+	}
+	var payload readyResponse
+	err = json.NewDecoder(resp.Body).Decode(&payload)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode readyz: %v", err)
+	}
+	if len(payload.Degraded) != 1 || payload.Degraded[0].GPUId != "card1" {
+		t.Fatalf("expected card1 to be reported degraded, got %+v", payload.Degraded)
+	}
+
+	all := newManager(map[string]sampler.Source{"card1": hangingSource{id: "card1", release: release}})
+	tsAll := newTestHTTPServer(t, cfg, []gpu.Info{{ID: "card1"}}, all, nil)
+	defer tsAll.Close()
+	assertReadyz(t, tsAll.URL+"/readyz", http.StatusServiceUnavailable, "degraded", "readers_unresponsive")
+}
+
 func TestVersionEndpoint(t *testing.T) {
 	t.Parallel()
 
@@ -637,121 +717,130 @@ func TestAPIGPUMetrics(t *testing.T) {
 	}
 }
 
-func TestAPIGPUProcs(t *testing.T) {
+func TestAPIGPUCapabilities(t *testing.T) {
 	t.Parallel()
 
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	sysfsRoot := t.TempDir()
-	debugRoot := t.TempDir()
 	devicePath := createDeviceTree(t, sysfsRoot)
 	writeFile(t, filepath.Join(devicePath, "gpu_busy_percent"), "9\n")
 
-	reader, err := sampler.NewReader("card0", sysfsRoot, debugRoot, logger)
+	reader, err := sampler.NewReader("card0", sysfsRoot, "", logger)
 	if err != nil {
 		t.Fatalf("NewReader error: %v", err)
 	}
-
-	samplerManager, err := sampler.NewManager(5*time.Millisecond, map[string]*sampler.Reader{"card0": reader}, logger)
+	manager, err := sampler.NewManagerFromSources(time.Second, map[string]sampler.Source{
+		"card0": reader,
+		"card1": instantSource{id: "card1"},
+	}, logger)
 	if err != nil {
-		t.Fatalf("NewManager error: %v", err)
+		t.Fatalf("NewManagerFromSources error: %v", err)
 	}
-	t.Cleanup(func() { _ = samplerManager.Close() })
-
-	samplerCtx, samplerCancel := context.WithCancel(context.Background())
-	t.Cleanup(samplerCancel)
-	go func() { _ = samplerManager.Run(samplerCtx) }()
+	t.Cleanup(func() { _ = manager.Close() })
 
-	waitFor(t, 2*time.Second, samplerManager.Ready)
+	ts := newTestHTTPServer(t, defaultTestConfig(), []gpu.Info{{ID: "card0"}, {ID: "card1"}}, manager, nil)
+	defer ts.Close()
 
-	procRoot := t.TempDir()
-	pidDir := filepath.Join(procRoot, "3100")
-	if err := os.MkdirAll(filepath.Join(pidDir, "fdinfo"), 0o750); err != nil {
-		t.Fatalf("mkdir fdinfo: %v", err)
+	// The manager is not running: the handler samples on demand.
+	resp, err := http.Get(ts.URL + "/api/gpus/card0/capabilities")
+	if err != nil {
+		t.Fatalf("GET capabilities failed: %v", err)
 	}
-	if err := os.MkdirAll(filepath.Join(pidDir, "fd"), 0o750); err != nil {
-		t.Fatalf("mkdir fd: %v", err)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
 	}
-	writeFile(t, filepath.Join(pidDir, "comm"), "proc\n")
-	writeFile(t, filepath.Join(pidDir, "cmdline"), "proc\x00--gpu\x00")
-	writeFile(t, filepath.Join(pidDir, "status"), "Name:\tproc\nUid:\t0\t0\t0\t0\n")
-	fdinfoData, err := os.ReadFile(filepath.Join("..", "procscan", "testdata", "fdinfo_mem_engine.txt"))
+	var caps sampler.Capabilities
+	if err := json.NewDecoder(resp.Body).Decode(&caps); err != nil {
+		t.Fatalf("decode capabilities: %v", err)
+	}
+	summary := caps.Summary()
+	if caps.GPUId != "card0" || len(summary.Available) == 0 || summary.Available[0] != "gpu_busy_pct" {
+		t.Fatalf("unexpected capabilities %+v", caps)
+	}
+	if summary.Missing["fan_rpm"] != sampler.ReasonNoHwmon {
+		t.Fatalf("expected fan_rpm to be missing without hwmon, got %+v", summary.Missing)
+	}
+
+	// Sources without provenance tracking have no capabilities.
+	resp2, err := http.Get(ts.URL + "/api/gpus/card1/capabilities")
 	if err != nil {
-		t.Fatalf("read fdinfo fixture: %v", err)
+		t.Fatalf("GET capabilities failed: %v", err)
 	}
-	writeFile(t, filepath.Join(pidDir, "fdinfo", "5"), string(fdinfoData))
-	if err := os.Symlink("/dev/dri/renderD128", filepath.Join(pidDir, "fd", "5")); err != nil {
-		t.Fatalf("symlink fd: %v", err)
+	_ = resp2.Body.Close()
+	if resp2.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for source without capabilities, got %d", resp2.StatusCode)
 	}
+}
 
-	procCfg := config.ProcConfig{
-		Enable:       true,
-		ScanInterval: 25 * time.Millisecond,
-		MaxPIDs:      16,
-		MaxFDsPerPID: 16,
+func TestAPISimulatedBackend(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	sim, err := simulate.New(2, 1)
+	if err != nil {
+		t.Fatalf("simulate.New error: %v", err)
 	}
+	gpus := sim.GPUs()
 
-	gpus := []gpu.Info{{ID: "card0", RenderNode: "/dev/dri/renderD128"}}
+	samplerManager, err := sampler.NewManagerFromSources(5*time.Millisecond, sim.SamplerSources(), logger)
+	if err != nil {
+		t.Fatalf("NewManagerFromSources error: %v", err)
+	}
+	t.Cleanup(func() { _ = samplerManager.Close() })
 
-	procManager, err := procscan.NewManager(procCfg, procRoot, gpus, logger)
+	procCfg := config.ProcConfig{Enable: true, ScanInterval: 25 * time.Millisecond, MaxPIDs: 16}
+	procManager, err := procscan.NewManagerWithSource(procCfg, sim.ProcSource(), gpus, logger)
 	if err != nil {
-		t.Fatalf("NewProcManager error: %v", err)
+		t.Fatalf("NewManagerWithSource error: %v", err)
 	}
 	t.Cleanup(func() { _ = procManager.Close() })
 
-	procCtx, procCancel := context.WithCancel(context.Background())
-	t.Cleanup(procCancel)
-	go func() { _ = procManager.Run(procCtx) }()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = samplerManager.Run(ctx) }()
+	go func() { _ = procManager.Run(ctx) }()
 
+	waitFor(t, 2*time.Second, samplerManager.Ready)
 	waitFor(t, 2*time.Second, procManager.Ready)
 
 	cfg := defaultTestConfig()
 	cfg.Proc = procCfg
-	cfg.ProcRoot = procRoot
 
 	ts := newTestHTTPServer(t, cfg, gpus, samplerManager, procManager)
-	defer ts.Close()
 
-	resp, err := http.Get(ts.URL + "/api/gpus/card0/procs")
+	resp, err := http.Get(ts.URL + "/api/gpus/card1/metrics")
 	if err != nil {
-		t.Fatalf("GET procs failed: %v", err)
+		t.Fatalf("GET metrics failed: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	var sample sampler.Sample
+	err = json.NewDecoder(resp.Body).Decode(&sample)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode metrics: %v", err)
 	}
-
-	var payload procscan.Snapshot
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		t.Fatalf("decode procs: %v", err)
+	if sample.Metrics.PowerW == nil || sample.Metrics.TempC == nil || sample.Metrics.FanRPM == nil {
+		t.Fatalf("expected simulated readings, got %+v", sample.Metrics)
 	}
 
-	if payload.GPUId != "card0" {
-		t.Fatalf("unexpected gpu id %q", payload.GPUId)
-	}
-	if !payload.Capabilities.VRAMGTTFromFDInfo {
-		t.Fatalf("expected memory capability")
-	}
-	if len(payload.Processes) == 0 {
-		t.Fatalf("expected processes in snapshot")
+	resp, err = http.Get(ts.URL + "/api/gpus/card1/procs")
+	if err != nil {
+		t.Fatalf("GET procs failed: %v", err)
 	}
-
-	// Requesting procs when manager is nil should yield 503.
-	tsNoProc := newTestHTTPServer(t, cfg, gpus, samplerManager, nil)
-	defer tsNoProc.Close()
-
-	resp2, err := http.Get(tsNoProc.URL + "/api/gpus/card0/procs")
+	var snapshot procscan.Snapshot
+	err = json.NewDecoder(resp.Body).Decode(&snapshot)
+	_ = resp.Body.Close()
 	if err != nil {
-		t.Fatalf("GET procs without manager failed: %v", err)
+		t.Fatalf("decode procs: %v", err)
 	}
-	_ = resp2.Body.Close()
-	if resp2.StatusCode != http.StatusServiceUnavailable {
-		t.Fatalf("expected 503 when proc manager unavailable, got %d", resp2.StatusCode)
+	if len(snapshot.Processes) == 0 || snapshot.Processes[0].VRAMBytes == nil {
+		t.Fatalf("expected simulated processes, got %+v", snapshot)
 	}
 }
 
-func TestWebSocketHelloAndStats(t *testing.T) {
+func TestAPIGPUHistory(t *testing.T) {
 	t.Parallel()
 
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
@@ -759,8 +848,7 @@ func TestWebSocketHelloAndStats(t *testing.T) {
 	sysfsRoot := t.TempDir()
 	debugRoot := t.TempDir()
 	devicePath := createDeviceTree(t, sysfsRoot)
-	busyPath := filepath.Join(devicePath, "gpu_busy_percent")
-	writeFile(t, busyPath, "5\n")
+	writeFile(t, filepath.Join(devicePath, "gpu_busy_percent"), "9\n")
 
 	reader, err := sampler.NewReader("card0", sysfsRoot, debugRoot, logger)
 	if err != nil {
@@ -771,76 +859,68 @@ func TestWebSocketHelloAndStats(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewManager error: %v", err)
 	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	t.Cleanup(cancel)
-	go func() { _ = manager.Run(ctx) }()
-
-	waitFor(t, 2*time.Second, manager.Ready)
+	t.Cleanup(func() { _ = manager.Close() })
 
 	cfg := defaultTestConfig()
-	cfg.SampleInterval = 5 * time.Millisecond
 	gpus := []gpu.Info{{ID: "card0"}}
-
 	ts := newTestHTTPServer(t, cfg, gpus, manager, nil)
 	defer ts.Close()
 
-	wsURL := toWebsocketURL(ts.URL + "/ws")
-	cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer ccancel()
-
-	conn, resp, err := websocket.Dial(cctx, wsURL, nil)
+	respDisabled, err := http.Get(ts.URL + "/api/gpus/card0/history")
 	if err != nil {
-		t.Fatalf("websocket dial: %v", err)
+		t.Fatalf("GET history failed: %v", err)
 	}
-	if resp != nil && resp.Body != nil {
-		defer resp.Body.Close()
+	_ = respDisabled.Body.Close()
+	if respDisabled.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with history disabled, got %d", respDisabled.StatusCode)
 	}
-	defer closeWebsocket(nil, conn)
 
-	helloType, helloData, err := conn.Read(cctx)
-	if err != nil {
-		t.Fatalf("read hello: %v", err)
-	}
-	if helloType != websocket.MessageText {
-		t.Fatalf("unexpected hello type %v", helloType)
+	if err := manager.EnableHistory(100); err != nil {
+		t.Fatalf("EnableHistory error: %v", err)
 	}
 
-	var helloMsg map[string]interface{}
-	if err := json.Unmarshal(helloData, &helloMsg); err != nil {
-		t.Fatalf("decode hello: %v", err)
-	}
-	if helloMsg["type"] != "hello" {
-		t.Fatalf("expected hello message, got %q", helloMsg["type"])
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = manager.Run(ctx) }()
 
-	// Next message should be stats broadcast.
-	statsType, statsData, err := conn.Read(cctx)
+	waitFor(t, 2*time.Second, manager.Ready)
+
+	resp, err := http.Get(ts.URL + "/api/gpus/card0/history?since=1h&step=1m")
 	if err != nil {
-		t.Fatalf("read stats: %v", err)
+		t.Fatalf("GET history failed: %v", err)
 	}
-	if statsType != websocket.MessageText {
-		t.Fatalf("unexpected stats type %v", statsType)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
 	}
 
-	var statsMsg map[string]interface{}
-	if err := json.Unmarshal(statsData, &statsMsg); err != nil {
-		t.Fatalf("decode stats: %v", err)
+	var history sampler.History
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatalf("decode history: %v", err)
 	}
-	if statsMsg["type"] != "stats" {
-		t.Fatalf("expected stats message, got %q", statsMsg["type"])
+	if history.GPUId != "card0" || history.StepMS != time.Minute.Milliseconds() {
+		t.Fatalf("unexpected history header %+v", history)
 	}
-
-	metrics, ok := statsMsg["metrics"].(map[string]interface{})
-	if !ok {
-		t.Fatalf("metrics payload missing or wrong type")
+	if len(history.Buckets) == 0 {
+		t.Fatalf("expected at least one bucket")
 	}
-	if _, ok := metrics["gpu_busy_pct"]; !ok {
-		t.Fatalf("expected gpu_busy_pct value in stats")
+	if got := history.Buckets[0].Metrics["gpu_busy_pct"]; got.Min != 9 || got.Max != 9 {
+		t.Fatalf("unexpected gpu_busy_pct aggregate %+v", got)
+	}
+
+	for _, query := range []string{"since=yesterday", "step=-1s", "step=0s", "since=2024-01-02T00:00:00Z&until=2024-01-01T00:00:00Z"} {
+		badResp, err := http.Get(ts.URL + "/api/gpus/card0/history?" + query)
+		if err != nil {
+			t.Fatalf("GET history %q failed: %v", query, err)
+		}
+		_ = badResp.Body.Close()
+		if badResp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %q, got %d", query, badResp.StatusCode)
+		}
 	}
 }
 
-func TestWebSocketStatsAndProcs(t *testing.T) {
+func TestAPIGPUProcs(t *testing.T) {
 	t.Parallel()
 
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
@@ -848,8 +928,7 @@ func TestWebSocketStatsAndProcs(t *testing.T) {
 	sysfsRoot := t.TempDir()
 	debugRoot := t.TempDir()
 	devicePath := createDeviceTree(t, sysfsRoot)
-	busyPath := filepath.Join(devicePath, "gpu_busy_percent")
-	writeFile(t, busyPath, "7\n")
+	writeFile(t, filepath.Join(devicePath, "gpu_busy_percent"), "9\n")
 
 	reader, err := sampler.NewReader("card0", sysfsRoot, debugRoot, logger)
 	if err != nil {
@@ -860,6 +939,7 @@ func TestWebSocketStatsAndProcs(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewManager error: %v", err)
 	}
+	t.Cleanup(func() { _ = samplerManager.Close() })
 
 	samplerCtx, samplerCancel := context.WithCancel(context.Background())
 	t.Cleanup(samplerCancel)
@@ -868,12 +948,12 @@ func TestWebSocketStatsAndProcs(t *testing.T) {
 	waitFor(t, 2*time.Second, samplerManager.Ready)
 
 	procRoot := t.TempDir()
-	pidDir := filepath.Join(procRoot, "2200")
+	pidDir := filepath.Join(procRoot, "3100")
 	if err := os.MkdirAll(filepath.Join(pidDir, "fdinfo"), 0o750); err != nil {
-		t.Fatalf("mkdir proc fdinfo: %v", err)
+		t.Fatalf("mkdir fdinfo: %v", err)
 	}
 	if err := os.MkdirAll(filepath.Join(pidDir, "fd"), 0o750); err != nil {
-		t.Fatalf("mkdir proc fd: %v", err)
+		t.Fatalf("mkdir fd: %v", err)
 	}
 	writeFile(t, filepath.Join(pidDir, "comm"), "proc\n")
 	writeFile(t, filepath.Join(pidDir, "cmdline"), "proc\x00--gpu\x00")
@@ -900,6 +980,7 @@ func TestWebSocketStatsAndProcs(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewProcManager error: %v", err)
 	}
+	t.Cleanup(func() { _ = procManager.Close() })
 
 	procCtx, procCancel := context.WithCancel(context.Background())
 	t.Cleanup(procCancel)
@@ -908,42 +989,893 @@ func TestWebSocketStatsAndProcs(t *testing.T) {
 	waitFor(t, 2*time.Second, procManager.Ready)
 
 	cfg := defaultTestConfig()
-	cfg.SampleInterval = 5 * time.Millisecond
 	cfg.Proc = procCfg
 	cfg.ProcRoot = procRoot
 
 	ts := newTestHTTPServer(t, cfg, gpus, samplerManager, procManager)
 	defer ts.Close()
 
-	wsURL := toWebsocketURL(ts.URL + "/ws")
-	cctx, ccancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer ccancel()
-
-	conn, resp, err := websocket.Dial(cctx, wsURL, nil)
+	resp, err := http.Get(ts.URL + "/api/gpus/card0/procs")
 	if err != nil {
-		t.Fatalf("websocket dial: %v", err)
+		t.Fatalf("GET procs failed: %v", err)
 	}
-	if resp != nil && resp.Body != nil {
-		defer resp.Body.Close()
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
 	}
-	defer closeWebsocket(nil, conn)
 
-	helloMsg, err := expectHelloMessage(cctx, conn)
-	if err != nil {
-		t.Fatalf("hello message error: %v", err)
+	var payload procscan.Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode procs: %v", err)
 	}
-	features, ok := helloMsg["features"].(map[string]any)
-	if !ok {
-		t.Fatalf("hello features missing")
+
+	if payload.GPUId != "card0" {
+		t.Fatalf("unexpected gpu id %q", payload.GPUId)
 	}
-	if procs, ok := features["procs"].(bool); !ok || !procs {
-		t.Fatalf("expected procs feature true")
+	if !payload.Capabilities.VRAMGTTFromFDInfo {
+		t.Fatalf("expected memory capability")
 	}
-	if charts, ok := features["charts"].(bool); !ok || !charts {
-		t.Fatalf("expected charts feature true")
+	if len(payload.Processes) == 0 {
+		t.Fatalf("expected processes in snapshot")
 	}
-	if maxPoints, ok := helloMsg["charts_max_points"].(float64); !ok || int(maxPoints) != cfg.Charts.MaxPoints {
-		t.Fatalf("expected charts max points %d", cfg.Charts.MaxPoints)
+
+	respExited, err := http.Get(ts.URL + "/api/gpus/card0/procs/exited")
+	if err != nil {
+		t.Fatalf("GET exited procs failed: %v", err)
+	}
+	defer respExited.Body.Close()
+	if respExited.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 for exited procs, got %d", respExited.StatusCode)
+	}
+	var exited procscan.ExitedSnapshot
+	if err := json.NewDecoder(respExited.Body).Decode(&exited); err != nil {
+		t.Fatalf("decode exited procs: %v", err)
+	}
+	if exited.GPUId != "card0" || exited.Processes == nil {
+		t.Fatalf("unexpected exited payload %+v", exited)
+	}
+
+	respHistory, err := http.Get(ts.URL + "/api/gpus/card0/procs/3100/history")
+	if err != nil {
+		t.Fatalf("GET proc history failed: %v", err)
+	}
+	defer respHistory.Body.Close()
+	if respHistory.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 for proc history, got %d", respHistory.StatusCode)
+	}
+	var history procscan.ProcessHistory
+	if err := json.NewDecoder(respHistory.Body).Decode(&history); err != nil {
+		t.Fatalf("decode proc history: %v", err)
+	}
+	if history.PID != 3100 || history.Name != "proc" || len(history.Points) == 0 {
+		t.Fatalf("unexpected proc history %+v", history)
+	}
+
+	respMemory, err := http.Get(ts.URL + "/api/gpus/card0/memory")
+	if err != nil {
+		t.Fatalf("GET memory failed: %v", err)
+	}
+	defer respMemory.Body.Close()
+	if respMemory.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 for memory, got %d", respMemory.StatusCode)
+	}
+	var memory reconcile.MemoryReport
+	if err := json.NewDecoder(respMemory.Body).Decode(&memory); err != nil {
+		t.Fatalf("decode memory: %v", err)
+	}
+	if memory.GPUId != "card0" || memory.Processes != 1 || memory.VRAM.AttributedBytes != 268435456 || memory.ProcsPermissionDenied != 0 {
+		t.Fatalf("unexpected memory report %+v", memory)
+	}
+
+	respReady, err := http.Get(ts.URL + "/readyz")
+	if err != nil {
+		t.Fatalf("GET readyz failed: %v", err)
+	}
+	defer respReady.Body.Close()
+	var ready readyResponse
+	if err := json.NewDecoder(respReady.Body).Decode(&ready); err != nil {
+		t.Fatalf("decode readyz: %v", err)
+	}
+	if ready.ProcsPermissionDenied == nil || *ready.ProcsPermissionDenied != 0 {
+		t.Fatalf("expected scan-wide denied count in readyz, got %+v", ready.ProcsPermissionDenied)
+	}
+
+	respEnergy, err := http.Get(ts.URL + "/api/gpus/card0/energy")
+	if err != nil {
+		t.Fatalf("GET energy failed: %v", err)
+	}
+	defer respEnergy.Body.Close()
+	if respEnergy.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 for energy, got %d", respEnergy.StatusCode)
+	}
+	var energy procscan.EnergySnapshot
+	if err := json.NewDecoder(respEnergy.Body).Decode(&energy); err != nil {
+		t.Fatalf("decode energy: %v", err)
+	}
+	if energy.GPUId != "card0" || energy.Users == nil || energy.Since.IsZero() {
+		t.Fatalf("unexpected energy payload %+v", energy)
+	}
+
+	respMissing, err := http.Get(ts.URL + "/api/gpus/card0/procs/9999/history")
+	if err != nil {
+		t.Fatalf("GET missing proc history failed: %v", err)
+	}
+	_ = respMissing.Body.Close()
+	if respMissing.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown pid history, got %d", respMissing.StatusCode)
+	}
+
+	// Requesting procs when manager is nil should yield 503.
+	tsNoProc := newTestHTTPServer(t, cfg, gpus, samplerManager, nil)
+	defer tsNoProc.Close()
+
+	resp2, err := http.Get(tsNoProc.URL + "/api/gpus/card0/procs")
+	if err != nil {
+		t.Fatalf("GET procs without manager failed: %v", err)
+	}
+	_ = resp2.Body.Close()
+	if resp2.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when proc manager unavailable, got %d", resp2.StatusCode)
+	}
+}
+
+func TestAPIGPUUsage(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	gpus := []gpu.Info{{ID: "card0"}}
+
+	tracker := usage.NewTracker(time.Minute)
+	busy := 50.0
+	now := time.Now()
+	for _, ts := range []time.Time{now.Add(-10 * time.Second), now} {
+		tracker.Observe(procscan.Snapshot{
+			GPUId:     "card0",
+			Timestamp: ts,
+			Processes: []procscan.Process{
+				{PID: 1, User: "alice", Name: "blender", GPUTimeMSPerS: &busy},
+				{PID: 2, User: "bob", Name: "game", GPUTimeMSPerS: &busy},
+			},
+		})
+	}
+
+	srv := New(defaultTestConfig(), logger, gpus, nil, nil)
+	srv.EnableUsage(tracker)
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/gpus/card0/usage?window=1h&by=user&limit=1")
+	if err != nil {
+		t.Fatalf("GET usage failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	var board usage.Leaderboard
+	if err := json.NewDecoder(resp.Body).Decode(&board); err != nil {
+		t.Fatalf("decode usage: %v", err)
+	}
+	if board.GPUId != "card0" || board.By != usage.ByUser {
+		t.Fatalf("unexpected leaderboard header %+v", board)
+	}
+	if len(board.Entries) != 1 || board.Entries[0].GPUTimeMS != 500 {
+		t.Fatalf("unexpected leaderboard entries %+v", board.Entries)
+	}
+
+	for _, query := range []string{"window=30d", "by=pid", "limit=0"} {
+		badResp, err := http.Get(ts.URL + "/api/gpus/card0/usage?" + query)
+		if err != nil {
+			t.Fatalf("GET usage %q failed: %v", query, err)
+		}
+		_ = badResp.Body.Close()
+		if badResp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %q, got %d", query, badResp.StatusCode)
+		}
+	}
+
+	tsNoUsage := newTestHTTPServer(t, defaultTestConfig(), gpus, nil, nil)
+	respNoUsage, err := http.Get(tsNoUsage.URL + "/api/gpus/card0/usage")
+	if err != nil {
+		t.Fatalf("GET usage without tracker failed: %v", err)
+	}
+	_ = respNoUsage.Body.Close()
+	if respNoUsage.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without tracker, got %d", respNoUsage.StatusCode)
+	}
+}
+
+type exportSource []sampler.Sample
+
+func (e exportSource) Samples(gpuID string, _, _ time.Time, fn func(string, sampler.HistoryBucket) error) error {
+	for _, sample := range e {
+		if gpuID != "" && sample.GPUId != gpuID {
+			continue
+		}
+		if err := fn(sample.GPUId, sampler.SampleBucket(sample)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestAPIExport(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	gpus := []gpu.Info{{ID: "card0"}, {ID: "card1"}}
+	busy := 12.0
+	ts := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	srv := New(defaultTestConfig(), logger, gpus, nil, nil)
+	srv.EnableExport(exportSource{
+		{GPUId: "card0", Timestamp: ts, Metrics: sampler.Metrics{GPUBusyPct: &busy}},
+		{GPUId: "card1", Timestamp: ts, Metrics: sampler.Metrics{GPUBusyPct: &busy}},
+	})
+	server := httptest.NewServer(srv.httpServer.Handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/export?gpu=card1&format=ndjson")
+	if err != nil {
+		t.Fatalf("GET export failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if !strings.HasPrefix(string(body), `{"gpu_id":"card1","ts":"2025-03-01T12:00:00Z","gpu_busy_pct":12,`) || strings.Count(string(body), "\n") != 1 {
+		t.Fatalf("unexpected export body %q", body)
+	}
+
+	resp, err = http.Get(server.URL + "/api/export")
+	if err != nil {
+		t.Fatalf("GET csv export failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if lines := strings.Split(strings.TrimSpace(string(body)), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "gpu_id,ts,gpu_busy_pct") {
+		t.Fatalf("unexpected csv export %q", body)
+	}
+
+	for query, status := range map[string]int{
+		"gpu=card9":      http.StatusNotFound,
+		"format=parquet": http.StatusBadRequest,
+		"from=yesterday": http.StatusBadRequest,
+		"to=not-a-time":  http.StatusBadRequest,
+	} {
+		badResp, err := http.Get(server.URL + "/api/export?" + query)
+		if err != nil {
+			t.Fatalf("GET export %q failed: %v", query, err)
+		}
+		_ = badResp.Body.Close()
+		if badResp.StatusCode != status {
+			t.Fatalf("expected %d for %q, got %d", status, query, badResp.StatusCode)
+		}
+	}
+
+	tsNoExport := newTestHTTPServer(t, defaultTestConfig(), gpus, nil, nil)
+	respNoExport, err := http.Get(tsNoExport.URL + "/api/export")
+	if err != nil {
+		t.Fatalf("GET export without store failed: %v", err)
+	}
+	_ = respNoExport.Body.Close()
+	if respNoExport.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without store, got %d", respNoExport.StatusCode)
+	}
+}
+
+func TestAPIAlerts(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	gpus := []gpu.Info{{ID: "card0"}}
+	engine, err := alerts.NewEngine([]alerts.Rule{{Name: "hot", Expr: "temp_c > 90", Clear: "temp_c < 85", Severity: "critical"}}, logger)
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	observe := func(temp float64) {
+		engine.Observe(sampler.Sample{GPUId: "card0", Timestamp: time.Now(), Metrics: sampler.Metrics{TempC: &temp}})
+	}
+	observe(95)
+
+	cfg := defaultTestConfig()
+	cfg.EnablePrometheus = true
+	srv := New(cfg, logger, gpus, nil, nil)
+	srv.EnableAlerts(engine)
+	server := httptest.NewServer(srv.httpServer.Handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/alerts")
+	if err != nil {
+		t.Fatalf("GET alerts failed: %v", err)
+	}
+	var active []alerts.Alert
+	err = json.NewDecoder(resp.Body).Decode(&active)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode alerts: %v", err)
+	}
+	if len(active) != 1 || active[0].Rule != "hot" || active[0].State != alerts.StateFiring || active[0].Value == nil || *active[0].Value != 95 {
+		t.Fatalf("unexpected alerts %+v", active)
+	}
+
+	resp, err = http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET metrics failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.Contains(string(body), `amdgputop_alerts{alertname="hot",alertstate="firing",gpu_id="card0",severity="critical"} 1`) {
+		t.Fatalf("alerts series missing from metrics:\n%s", body)
+	}
+
+	cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer ccancel()
+	conn, wsResp, err := websocket.Dial(cctx, toWebsocketURL(server.URL+"/ws"), nil)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	if wsResp != nil && wsResp.Body != nil {
+		defer wsResp.Body.Close()
+	}
+	defer closeWebsocket(nil, conn)
+
+	hello, err := expectHelloMessage(cctx, conn)
+	if err != nil {
+		t.Fatalf("read hello: %v", err)
+	}
+	if features, _ := hello["features"].(map[string]any); features["alerts"] != true {
+		t.Fatalf("expected alerts feature, got %+v", hello["features"])
+	}
+	// Without a sampler the connection also reports a subscription error;
+	// only alert messages matter here.
+	readAlert := func() map[string]any {
+		t.Helper()
+		for {
+			_, data, err := conn.Read(cctx)
+			if err != nil {
+				t.Fatalf("read alert: %v", err)
+			}
+			var msg map[string]any
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatalf("decode alert: %v", err)
+			}
+			if msg["type"] == "alert" {
+				return msg
+			}
+		}
+	}
+	if msg := readAlert(); msg["rule"] != "hot" || msg["state"] != "firing" {
+		t.Fatalf("unexpected initial alert %+v", msg)
+	}
+	observe(88)
+	observe(80)
+	if msg := readAlert(); msg["state"] != "resolved" {
+		t.Fatalf("expected resolved alert, got %+v", msg)
+	}
+
+	for payload, status := range map[string]int{
+		`{"rule":"hot","duration":"1h"}`:                  http.StatusOK,
+		`{"rule":"cold","duration":"1h"}`:                 http.StatusNotFound,
+		`{"rule":"hot","gpu_id":"card9","duration":"1h"}`: http.StatusNotFound,
+		`{"rule":"hot","duration":"soon"}`:                http.StatusBadRequest,
+		`not json`:                                        http.StatusBadRequest,
+	} {
+		postResp, err := http.Post(server.URL+"/api/alerts/silences", "application/json", strings.NewReader(payload))
+		if err != nil {
+			t.Fatalf("POST silence %s failed: %v", payload, err)
+		}
+		_ = postResp.Body.Close()
+		if postResp.StatusCode != status {
+			t.Fatalf("expected %d for %s, got %d", status, payload, postResp.StatusCode)
+		}
+	}
+
+	// The default "*" does not let other sites silence alerts.
+	for origin, status := range map[string]int{
+		"https://evil.example": http.StatusForbidden,
+		server.URL:             http.StatusOK,
+	} {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/api/alerts/silences", strings.NewReader(`{"rule":"hot","duration":"1h"}`))
+		if err != nil {
+			t.Fatalf("build silence request: %v", err)
+		}
+		req.Header.Set("Origin", origin)
+		postResp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST silence from %s failed: %v", origin, err)
+		}
+		_ = postResp.Body.Close()
+		if postResp.StatusCode != status {
+			t.Fatalf("expected %d for origin %s, got %d", status, origin, postResp.StatusCode)
+		}
+	}
+
+	resp, err = http.Get(server.URL + "/api/alerts/silences")
+	if err != nil {
+		t.Fatalf("GET silences failed: %v", err)
+	}
+	var silences []alerts.Silence
+	err = json.NewDecoder(resp.Body).Decode(&silences)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode silences: %v", err)
+	}
+	if len(silences) != 1 || silences[0].Rule != "hot" || silences[0].GPUId != "" {
+		t.Fatalf("unexpected silences %+v", silences)
+	}
+
+	tsNoAlerts := newTestHTTPServer(t, defaultTestConfig(), gpus, nil, nil)
+	respNoAlerts, err := http.Get(tsNoAlerts.URL + "/api/alerts")
+	if err != nil {
+		t.Fatalf("GET alerts without engine failed: %v", err)
+	}
+	_ = respNoAlerts.Body.Close()
+	if respNoAlerts.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without engine, got %d", respNoAlerts.StatusCode)
+	}
+}
+
+func TestAPIEvents(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	gpus := []gpu.Info{{ID: "card0"}, {ID: "card1"}}
+	eventLog, err := events.Open(events.Options{})
+	if err != nil {
+		t.Fatalf("events.Open error: %v", err)
+	}
+	base := time.Now().Add(-time.Hour)
+	eventLog.Append(events.Event{GPUId: "card0", Time: base, Kind: events.KindThrottleStarted})
+	eventLog.Append(events.Event{GPUId: "card1", Time: base.Add(time.Minute), Kind: events.KindThrottleStarted})
+	eventLog.Append(events.Event{GPUId: "card0", Time: base.Add(50 * time.Minute), Kind: events.KindThrottleEnded})
+
+	srv := New(defaultTestConfig(), logger, gpus, nil, nil)
+	srv.EnableEvents(eventLog)
+	server := httptest.NewServer(srv.httpServer.Handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/events?gpu=card0&since=30m")
+	if err != nil {
+		t.Fatalf("GET events failed: %v", err)
+	}
+	var got []events.Event
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode events: %v", err)
+	}
+	if len(got) != 1 || got[0].Kind != events.KindThrottleEnded {
+		t.Fatalf("unexpected events %+v", got)
+	}
+
+	for query, status := range map[string]int{
+		"gpu=card9":   http.StatusNotFound,
+		"since=later": http.StatusBadRequest,
+		"limit=-1":    http.StatusBadRequest,
+	} {
+		badResp, err := http.Get(server.URL + "/api/events?" + query)
+		if err != nil {
+			t.Fatalf("GET events %q failed: %v", query, err)
+		}
+		_ = badResp.Body.Close()
+		if badResp.StatusCode != status {
+			t.Fatalf("expected %d for %q, got %d", status, query, badResp.StatusCode)
+		}
+	}
+
+	cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer ccancel()
+	conn, wsResp, err := websocket.Dial(cctx, toWebsocketURL(server.URL+"/ws"), nil)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	if wsResp != nil && wsResp.Body != nil {
+		defer wsResp.Body.Close()
+	}
+	defer closeWebsocket(nil, conn)
+	if _, err := expectHelloMessage(cctx, conn); err != nil {
+		t.Fatalf("read hello: %v", err)
+	}
+
+	// Without a GPU subscription only global events are pushed.
+	eventLog.Append(events.Event{GPUId: "card1", Kind: events.KindReaderError})
+	eventLog.Append(events.Event{Kind: events.KindSamplerIdle})
+	for {
+		_, data, err := conn.Read(cctx)
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		if msg["type"] != "event" {
+			continue
+		}
+		if msg["kind"] != string(events.KindSamplerIdle) {
+			t.Fatalf("expected only the global event, got %+v", msg)
+		}
+
+		break
+	}
+}
+
+func TestWebSocketHelloAndStats(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	sysfsRoot := t.TempDir()
+	debugRoot := t.TempDir()
+	devicePath := createDeviceTree(t, sysfsRoot)
+	busyPath := filepath.Join(devicePath, "gpu_busy_percent")
+	writeFile(t, busyPath, "5\n")
+
+	reader, err := sampler.NewReader("card0", sysfsRoot, debugRoot, logger)
+	if err != nil {
+		t.Fatalf("NewReader error: %v", err)
+	}
+
+	manager, err := sampler.NewManager(5*time.Millisecond, map[string]*sampler.Reader{"card0": reader}, logger)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = manager.Run(ctx) }()
+
+	waitFor(t, 2*time.Second, manager.Ready)
+
+	cfg := defaultTestConfig()
+	cfg.SampleInterval = 5 * time.Millisecond
+	gpus := []gpu.Info{{ID: "card0"}}
+
+	ts := newTestHTTPServer(t, cfg, gpus, manager, nil)
+	defer ts.Close()
+
+	wsURL := toWebsocketURL(ts.URL + "/ws")
+	cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer ccancel()
+
+	conn, resp, err := websocket.Dial(cctx, wsURL, nil)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	defer closeWebsocket(nil, conn)
+
+	helloType, helloData, err := conn.Read(cctx)
+	if err != nil {
+		t.Fatalf("read hello: %v", err)
+	}
+	if helloType != websocket.MessageText {
+		t.Fatalf("unexpected hello type %v", helloType)
+	}
+
+	var helloMsg map[string]interface{}
+	if err := json.Unmarshal(helloData, &helloMsg); err != nil {
+		t.Fatalf("decode hello: %v", err)
+	}
+	if helloMsg["type"] != "hello" {
+		t.Fatalf("expected hello message, got %q", helloMsg["type"])
+	}
+	capabilities, _ := helloMsg["capabilities"].(map[string]interface{})
+	if summary, ok := capabilities["card0"].(map[string]interface{}); !ok || summary["available"] == nil {
+		t.Fatalf("expected capability summary for card0 in hello, got %v", helloMsg["capabilities"])
+	}
+
+	// Next message should be stats broadcast.
+	statsType, statsData, err := conn.Read(cctx)
+	if err != nil {
+		t.Fatalf("read stats: %v", err)
+	}
+	if statsType != websocket.MessageText {
+		t.Fatalf("unexpected stats type %v", statsType)
+	}
+
+	var statsMsg map[string]interface{}
+	if err := json.Unmarshal(statsData, &statsMsg); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if statsMsg["type"] != "stats" {
+		t.Fatalf("expected stats message, got %q", statsMsg["type"])
+	}
+
+	metrics, ok := statsMsg["metrics"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("metrics payload missing or wrong type")
+	}
+	if _, ok := metrics["gpu_busy_pct"]; !ok {
+		t.Fatalf("expected gpu_busy_pct value in stats")
+	}
+}
+
+func TestWebSocketSubStatsOptIn(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	sysfsRoot := t.TempDir()
+	devicePath := createDeviceTree(t, sysfsRoot)
+	writeFile(t, filepath.Join(devicePath, "gpu_busy_percent"), "5\n")
+
+	reader, err := sampler.NewReader("card0", sysfsRoot, t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("NewReader error: %v", err)
+	}
+	manager, err := sampler.NewManager(20*time.Millisecond, map[string]*sampler.Reader{"card0": reader}, logger)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	if err := manager.SetSubInterval(2 * time.Millisecond); err != nil {
+		t.Fatalf("SetSubInterval error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = manager.Run(ctx) }()
+	waitFor(t, 2*time.Second, manager.Ready)
+
+	cfg := defaultTestConfig()
+	cfg.SampleInterval = 20 * time.Millisecond
+	ts := newTestHTTPServer(t, cfg, []gpu.Info{{ID: "card0"}}, manager, nil)
+	defer ts.Close()
+
+	cctx, ccancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer ccancel()
+	conn, resp, err := websocket.Dial(cctx, toWebsocketURL(ts.URL+"/ws"), nil)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	defer closeWebsocket(nil, conn)
+
+	nextStats := func() map[string]any {
+		t.Helper()
+		for {
+			_, data, err := conn.Read(cctx)
+			if err != nil {
+				t.Fatalf("read message: %v", err)
+			}
+			var msg map[string]any
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatalf("decode message: %v", err)
+			}
+			if msg["type"] == "stats" {
+				return msg
+			}
+		}
+	}
+
+	for range 5 {
+		if msg := nextStats(); msg["stats"] != nil || msg["sub_samples"] != nil {
+			t.Fatalf("expected sub-interval stats to be left out by default, got %v", msg)
+		}
+	}
+
+	subscribe, err := json.Marshal(map[string]any{"type": "subscribe", "gpu_id": "card0", "sub_stats": true})
+	if err != nil {
+		t.Fatalf("marshal subscribe: %v", err)
+	}
+	if err := conn.Write(cctx, websocket.MessageText, subscribe); err != nil {
+		t.Fatalf("write subscribe: %v", err)
+	}
+	for {
+		if msg := nextStats(); msg["stats"] != nil && msg["sub_samples"] != nil {
+			break
+		}
+	}
+}
+
+func TestWebSocketHistoryBackfill(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	sysfsRoot := t.TempDir()
+	debugRoot := t.TempDir()
+	devicePath := createDeviceTree(t, sysfsRoot)
+	writeFile(t, filepath.Join(devicePath, "gpu_busy_percent"), "7\n")
+
+	reader, err := sampler.NewReader("card0", sysfsRoot, debugRoot, logger)
+	if err != nil {
+		t.Fatalf("NewReader error: %v", err)
+	}
+
+	manager, err := sampler.NewManager(5*time.Millisecond, map[string]*sampler.Reader{"card0": reader}, logger)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+	if err := manager.EnableHistory(50); err != nil {
+		t.Fatalf("EnableHistory error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = manager.Run(ctx) }()
+
+	waitFor(t, 2*time.Second, func() bool {
+		samples, _, err := manager.Recent("card0", 0)
+
+		return err == nil && len(samples) >= 3
+	})
+
+	cfg := defaultTestConfig()
+	cfg.SampleInterval = 5 * time.Millisecond
+	cfg.Charts.MaxPoints = 2
+	gpus := []gpu.Info{{ID: "card0"}}
+
+	srv := New(cfg, logger, gpus, manager, nil)
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	defer ts.Close()
+
+	wsURL := toWebsocketURL(ts.URL + "/ws")
+	cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer ccancel()
+
+	conn, resp, err := websocket.Dial(cctx, wsURL, nil)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	defer closeWebsocket(nil, conn)
+
+	if _, err := expectHelloMessage(cctx, conn); err != nil {
+		t.Fatalf("hello: %v", err)
+	}
+
+	_, data, err := conn.Read(cctx)
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	var msg api.HistoryMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("decode history: %v", err)
+	}
+	if msg.Type != "history" || msg.GPUId != "card0" {
+		t.Fatalf("expected history message first, got %s", data)
+	}
+	if len(msg.TimestampsMS) != 2 {
+		t.Fatalf("expected backfill capped at 2 points, got %d", len(msg.TimestampsMS))
+	}
+	busy := msg.Metrics["gpu_busy_pct"]
+	if len(busy) != 2 || busy[1] == nil || *busy[1] != 7 {
+		t.Fatalf("unexpected gpu_busy_pct column %v", busy)
+	}
+
+	// Stop sampling so the history version stays put between encodings.
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+
+	first, ok, err := srv.backfill.encoded("card0")
+	if err != nil || !ok {
+		t.Fatalf("encoded returned ok=%v err=%v", ok, err)
+	}
+	second, _, _ := srv.backfill.encoded("card0")
+	if &first[0] != &second[0] {
+		t.Fatalf("expected backfill buffer to be shared between calls")
+	}
+}
+
+func TestWebSocketStatsAndProcs(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	sysfsRoot := t.TempDir()
+	debugRoot := t.TempDir()
+	devicePath := createDeviceTree(t, sysfsRoot)
+	busyPath := filepath.Join(devicePath, "gpu_busy_percent")
+	writeFile(t, busyPath, "7\n")
+
+	reader, err := sampler.NewReader("card0", sysfsRoot, debugRoot, logger)
+	if err != nil {
+		t.Fatalf("NewReader error: %v", err)
+	}
+
+	samplerManager, err := sampler.NewManager(5*time.Millisecond, map[string]*sampler.Reader{"card0": reader}, logger)
+	if err != nil {
+		t.Fatalf("NewManager error: %v", err)
+	}
+
+	samplerCtx, samplerCancel := context.WithCancel(context.Background())
+	t.Cleanup(samplerCancel)
+	go func() { _ = samplerManager.Run(samplerCtx) }()
+
+	waitFor(t, 2*time.Second, samplerManager.Ready)
+
+	procRoot := t.TempDir()
+	pidDir := filepath.Join(procRoot, "2200")
+	if err := os.MkdirAll(filepath.Join(pidDir, "fdinfo"), 0o750); err != nil {
+		t.Fatalf("mkdir proc fdinfo: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(pidDir, "fd"), 0o750); err != nil {
+		t.Fatalf("mkdir proc fd: %v", err)
+	}
+	writeFile(t, filepath.Join(pidDir, "comm"), "proc\n")
+	writeFile(t, filepath.Join(pidDir, "cmdline"), "proc\x00--gpu\x00")
+	writeFile(t, filepath.Join(pidDir, "status"), "Name:\tproc\nUid:\t0\t0\t0\t0\n")
+	fdinfoData, err := os.ReadFile(filepath.Join("..", "procscan", "testdata", "fdinfo_mem_engine.txt"))
+	if err != nil {
+		t.Fatalf("read fdinfo fixture: %v", err)
+	}
+	writeFile(t, filepath.Join(pidDir, "fdinfo", "5"), string(fdinfoData))
+	if err := os.Symlink("/dev/dri/renderD128", filepath.Join(pidDir, "fd", "5")); err != nil {
+		t.Fatalf("symlink fd: %v", err)
+	}
+
+	procCfg := config.ProcConfig{
+		Enable:       true,
+		ScanInterval: 25 * time.Millisecond,
+		MaxPIDs:      16,
+		MaxFDsPerPID: 16,
+	}
+
+	gpus := []gpu.Info{{ID: "card0", RenderNode: "/dev/dri/renderD128"}}
+
+	procManager, err := procscan.NewManager(procCfg, procRoot, gpus, logger)
+	if err != nil {
+		t.Fatalf("NewProcManager error: %v", err)
+	}
+
+	procCtx, procCancel := context.WithCancel(context.Background())
+	t.Cleanup(procCancel)
+	go func() { _ = procManager.Run(procCtx) }()
+
+	waitFor(t, 2*time.Second, procManager.Ready)
+
+	cfg := defaultTestConfig()
+	cfg.SampleInterval = 5 * time.Millisecond
+	cfg.Proc = procCfg
+	cfg.ProcRoot = procRoot
+
+	ts := newTestHTTPServer(t, cfg, gpus, samplerManager, procManager)
+	defer ts.Close()
+
+	wsURL := toWebsocketURL(ts.URL + "/ws")
+	cctx, ccancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer ccancel()
+
+	conn, resp, err := websocket.Dial(cctx, wsURL, nil)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	defer closeWebsocket(nil, conn)
+
+	helloMsg, err := expectHelloMessage(cctx, conn)
+	if err != nil {
+		t.Fatalf("hello message error: %v", err)
+	}
+	features, ok := helloMsg["features"].(map[string]any)
+	if !ok {
+		t.Fatalf("hello features missing")
+	}
+	if procs, ok := features["procs"].(bool); !ok || !procs {
+		t.Fatalf("expected procs feature true")
+	}
+	if charts, ok := features["charts"].(bool); !ok || !charts {
+		t.Fatalf("expected charts feature true")
+	}
+	if maxPoints, ok := helloMsg["charts_max_points"].(float64); !ok || int(maxPoints) != cfg.Charts.MaxPoints {
+		t.Fatalf("expected charts max points %d", cfg.Charts.MaxPoints)
 	}
 
 	gotStats := false
@@ -1012,6 +1944,43 @@ func TestWebSocketStatsAndProcs(t *testing.T) {
 	if !gotProcs {
 		t.Fatalf("did not receive procs message")
 	}
+
+	subscribeMsg, err := json.Marshal(map[string]any{
+		"type":         "subscribe",
+		"gpu_id":       "card0",
+		"proc_history": true,
+	})
+	if err != nil {
+		t.Fatalf("marshal subscribe: %v", err)
+	}
+	writeCtx, writeCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	if err := conn.Write(writeCtx, websocket.MessageText, subscribeMsg); err != nil {
+		writeCancel()
+		t.Fatalf("write subscribe: %v", err)
+	}
+	writeCancel()
+
+	for {
+		_, data, err := conn.Read(cctx)
+		if err != nil {
+			t.Fatalf("read proc history: %v", err)
+		}
+		var msg api.ProcHistoryMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("decode message: %v", err)
+		}
+		if msg.Type != "proc_history" {
+			continue
+		}
+		if msg.GPUId != "card0" || len(msg.Processes) != 1 {
+			t.Fatalf("unexpected proc history payload %+v", msg)
+		}
+		if msg.Processes[0].PID != 2200 || len(msg.Processes[0].Points) == 0 {
+			t.Fatalf("unexpected process history %+v", msg.Processes[0])
+		}
+
+		break
+	}
 }
 
 func newTestHTTPServer(t *testing.T, cfg config.Config, gpus []gpu.Info, samplerManager *sampler.Manager, procManager *procscan.Manager) *httptest.Server {
@@ -1221,3 +2190,72 @@ func toWebsocketURL(httpURL string) string {
 
 	return u.String()
 }
+
+func TestKernelLogMetrics(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "kmsg")
+	writeFile(t, path, strings.Join([]string{
+		"amdgpu 0000:03:00.0: amdgpu: ring gfx_0.0.0 timeout, signaled seq=1, emitted seq=2",
+		"amdgpu 0000:03:00.0: amdgpu: GPU reset begin!",
+		"amdgpu 0000:09:00.0: amdgpu: GPU reset begin!",
+		"",
+	}, "\n"))
+
+	var seen atomic.Int32
+	watcher := kmsg.NewWatcher(path, func(kmsg.Record) { seen.Add(1) }, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = watcher.Run(ctx) }()
+	waitFor(t, 2*time.Second, func() bool { return seen.Load() == 3 })
+
+	cfg := defaultTestConfig()
+	cfg.EnablePrometheus = true
+	srv := New(cfg, logger, []gpu.Info{{ID: "card0", PCI: "0000:03:00.0"}}, nil, nil)
+	srv.EnableKernelLog(watcher)
+	server := httptest.NewServer(srv.httpServer.Handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET metrics failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	for _, want := range []string{
+		`amdgputop_gpu_resets_total{gpu_id="card0",pci="0000:03:00.0"} 1`,
+		`amdgputop_gpu_resets_total{gpu_id="",pci="0000:09:00.0"} 1`,
+		`amdgputop_gpu_ring_timeouts_total{gpu_id="card0",pci="0000:03:00.0"} 1`,
+		`amdgputop_gpu_page_faults_total{gpu_id="card0",pci="0000:03:00.0"} 0`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("expected %q in metrics:\n%s", want, body)
+		}
+	}
+}
+
+func TestMutationOriginAllowed(t *testing.T) {
+	t.Parallel()
+
+	cfg := defaultTestConfig()
+	cfg.AllowedOrigins = []string{"*", "dash.example", "https://*.lan"}
+	s := &Server{cfg: cfg}
+	for origin, want := range map[string]bool{
+		"":                     true,
+		"http://gpu-box:8080":  true,
+		"https://dash.example": true,
+		"https://grafana.lan":  true,
+		"http://grafana.lan":   false,
+		"https://evil.example": false,
+		"://not a url":         false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "http://gpu-box:8080/api/alerts/silences", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if got := s.mutationOriginAllowed(req); got != want {
+			t.Fatalf("origin %q: expected %v, got %v", origin, want, got)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package kmsg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/events"
+)
+
+var testTime = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func parseAll(lines ...string) []Record {
+	p := &parser{now: func() time.Time { return testTime }}
+	var out []Record
+	for _, line := range lines {
+		out = append(out, p.feed(line)...)
+	}
+
+	return append(out, p.flush()...)
+}
+
+func TestParserRingTimeoutAndReset(t *testing.T) {
+	t.Parallel()
+
+	records := parseAll(
+		"3,1201,5123456789,-;[drm:amdgpu_job_timedout [amdgpu]] *ERROR* ring gfx_0.0.0 timeout, signaled seq=4821, emitted seq=4823",
+		" SUBSYSTEM=pci",
+		" DEVICE=+pci:0000:03:00.0",
+		"3,1202,5123456800,-;[drm:amdgpu_job_timedout [amdgpu]] *ERROR* Process information: process game.exe pid 4321 thread game.exe:cs0 pid 4325",
+		"6,1203,5123456900,-;usb 1-1: new high-speed USB device number 3 using xhci_hcd",
+		"4,1204,5123457000,-;amdgpu 0000:03:00.0: amdgpu: GPU reset begin!",
+		"6,1205,5123459000,-;amdgpu 0000:03:00.0: amdgpu: GPU reset(2) succeeded!",
+	)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %+v", records)
+	}
+
+	timeout := records[0]
+	if timeout.Kind != events.KindRingTimeout || timeout.Ring != "gfx_0.0.0" || timeout.PCI != "0000:03:00.0" {
+		t.Fatalf("unexpected ring timeout %+v", timeout)
+	}
+	if timeout.PID != 4321 || timeout.Process != "game.exe" {
+		t.Fatalf("expected process information to be attached, got %+v", timeout)
+	}
+	if timeout.Message != "ring gfx_0.0.0 timeout, signaled seq=4821, emitted seq=4823" || !timeout.Time.Equal(testTime) {
+		t.Fatalf("unexpected message or time %+v", timeout)
+	}
+	if records[1].Kind != events.KindGPUReset || records[1].PCI != "0000:03:00.0" || records[1].Message != "GPU reset begin!" {
+		t.Fatalf("unexpected reset record %+v", records[1])
+	}
+	if records[2].Kind != events.KindGPUResetSucceeded {
+		t.Fatalf("unexpected reset result %+v", records[2])
+	}
+}
+
+func TestParserPageFaults(t *testing.T) {
+	t.Parallel()
+
+	records := parseAll(
+		// Recent kernels print the process on the following line.
+		"[ 8123.456789] amdgpu 0000:0a:00.0: amdgpu: [gfxhub] page fault (src_id:0 ring:24 vmid:1 pasid:32769)",
+		"[ 8123.456790] amdgpu 0000:0a:00.0: amdgpu:  in process RDD Process pid 6789 thread firefox:cs0 pid 6801",
+		"[ 8123.456791] amdgpu 0000:0a:00.0: amdgpu:   in page starting at address 0x00008000deadb000 from client 10",
+		"[ 8123.456792] amdgpu 0000:0a:00.0: amdgpu: GCVM_L2_PROTECTION_FAULT_STATUS:0x00301031",
+		// Older kernels print it on the same line.
+		"[ 8124.000000] amdgpu 0000:0a:00.0: amdgpu: [gfxhub0] retry page fault (src_id:0 ring:0 vmid:3 pasid:32771, for process Xorg pid 1234 thread Xorg:cs0 pid 1240)",
+		"[ 8125.000000] amdgpu 0000:0a:00.0: amdgpu: GPU reset end with ret = -22",
+	)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %+v", records)
+	}
+
+	first := records[0]
+	if first.Kind != events.KindPageFault || first.Hub != "gfxhub" || first.PCI != "0000:0a:00.0" {
+		t.Fatalf("unexpected page fault %+v", first)
+	}
+	if first.PID != 6789 || first.Process != "RDD Process" || first.Address != "0x00008000deadb000" {
+		t.Fatalf("expected follow-up lines to be attached, got %+v", first)
+	}
+	if records[1].PID != 1234 || records[1].Process != "Xorg" || records[1].Hub != "gfxhub0" {
+		t.Fatalf("unexpected inline page fault %+v", records[1])
+	}
+	if records[2].Kind != events.KindGPUResetFailed {
+		t.Fatalf("unexpected reset failure %+v", records[2])
+	}
+
+	event := first.Event("card0")
+	want := map[string]string{"pci": "0000:0a:00.0", "pid": "6789", "process": "RDD Process", "hub": "gfxhub", "address": "0x00008000deadb000"}
+	if event.GPUId != "card0" || event.Kind != events.KindPageFault || len(event.Attrs) != len(want) {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	for key, value := range want {
+		if event.Attrs[key] != value {
+			t.Fatalf("attr %s = %q, want %q", key, event.Attrs[key], value)
+		}
+	}
+}
+
+func TestWatcherFollowsFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "kmsg")
+	initial := "amdgpu 0000:03:00.0: amdgpu: GPU reset begin!\n"
+	if err := os.WriteFile(path, []byte(initial), 0o644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	records := make(chan Record, 4)
+	w := NewWatcher(path, func(r Record) { records <- r }, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+
+	next := func() Record {
+		t.Helper()
+		select {
+		case r := <-records:
+			return r
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for record")
+
+			return Record{}
+		}
+	}
+	if r := next(); r.Kind != events.KindGPUReset {
+		t.Fatalf("unexpected first record %+v", r)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	_, _ = file.WriteString(strings.Join([]string{
+		"amdgpu 0000:03:00.0: amdgpu: ring sdma0 timeout, signaled seq=10, emitted seq=12",
+		"amdgpu 0000:03:00.0: amdgpu: GPU reset begin!",
+		"",
+	}, "\n"))
+	_ = file.Close()
+
+	if r := next(); r.Kind != events.KindRingTimeout || r.Ring != "sdma0" {
+		t.Fatalf("unexpected appended record %+v", r)
+	}
+	if r := next(); r.Kind != events.KindGPUReset {
+		t.Fatalf("unexpected appended record %+v", r)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	counts := w.Counts()
+	if len(counts) != 2 || counts[0] != (Count{PCI: "0000:03:00.0", Kind: events.KindGPUReset, Total: 2}) ||
+		counts[1] != (Count{PCI: "0000:03:00.0", Kind: events.KindRingTimeout, Total: 1}) {
+		t.Fatalf("unexpected counts %+v", counts)
+	}
+
+	if err := NewWatcher(filepath.Join(t.TempDir(), "missing"), nil, nil).Run(context.Background()); err == nil {
+		t.Fatalf("expected error for missing log")
+	}
+}
@@ -0,0 +1,206 @@
+package kmsg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/events"
+)
+
+var (
+	// kmsgPrefix is the "priority,sequence,timestamp,flags;" header of a
+	// /dev/kmsg record.
+	kmsgPrefix = regexp.MustCompile(`^\d+,\d+,\d+,[^;]*;`)
+	// dmesgPrefix is the "[  123.456789] " timestamp of dmesg output.
+	dmesgPrefix = regexp.MustCompile(`^\[\s*\d+\.\d+\]\s*`)
+
+	pciPattern      = regexp.MustCompile(`amdgpu (\d{4}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}\.[0-7]):`)
+	resetBegin      = regexp.MustCompile(`GPU reset begin`)
+	resetSucceeded  = regexp.MustCompile(`GPU reset(?:\(\d+\))? succeeded`)
+	resetFailed     = regexp.MustCompile(`GPU reset(?:\(\d+\))? failed|GPU Recovery Failed|GPU reset end with ret = -\d+`)
+	ringTimeout     = regexp.MustCompile(`ring (\S+?),? timeout`)
+	pageFault       = regexp.MustCompile(`\[(\w+)\] (?:retry |no-retry )?page fault`)
+	processInfo     = regexp.MustCompile(`[Pp]rocess (?:information: process )?(.*?) pid (\d+)`)
+	faultAddress    = regexp.MustCompile(`in page starting at address (0x[0-9a-fA-F]+)`)
+	devicePCIPrefix = " DEVICE=+pci:"
+)
+
+// Record is one driver error reported in the kernel log.
+type Record struct {
+	Time time.Time
+	Kind events.Kind
+	// PCI is the slot of the GPU, e.g. "0000:03:00.0"; empty when the
+	// message does not name one.
+	PCI     string
+	PID     int
+	Process string
+	Ring    string
+	Hub     string
+	Address string
+	Message string
+}
+
+// Event converts the record to a timeline event of gpuID.
+func (r Record) Event(gpuID string) events.Event {
+	attrs := make(map[string]string)
+	set := func(key, value string) {
+		if value != "" {
+			attrs[key] = value
+		}
+	}
+	set("pci", r.PCI)
+	if r.PID > 0 {
+		attrs["pid"] = strconv.Itoa(r.PID)
+	}
+	set("process", r.Process)
+	set("ring", r.Ring)
+	set("hub", r.Hub)
+	set("address", r.Address)
+	if len(attrs) == 0 {
+		attrs = nil
+	}
+
+	return events.Event{GPUId: gpuID, Time: r.Time, Kind: r.Kind, Message: r.Message, Attrs: attrs}
+}
+
+// parser turns kernel log lines into records. Ring timeouts and page faults
+// are held back until the next driver error (or a flush) because the
+// offending process and fault address are reported on the lines after them.
+type parser struct {
+	now     func() time.Time
+	pending *Record
+	// attached is set when the last message updated pending, so that its
+	// /dev/kmsg dictionary lines (DEVICE=...) apply to it.
+	attached bool
+}
+
+// feed parses one line and returns the records it completes.
+func (p *parser) feed(line string) []Record {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil
+	}
+	if line[0] == ' ' || line[0] == '\t' {
+		p.continuation(line)
+
+		return nil
+	}
+
+	p.attached = false
+	msg := kmsgPrefix.ReplaceAllString(line, "")
+	msg = dmesgPrefix.ReplaceAllString(msg, "")
+	if !strings.Contains(msg, "amdgpu") {
+		return nil
+	}
+
+	pci := ""
+	if m := pciPattern.FindStringSubmatch(msg); m != nil {
+		pci = strings.ToLower(m[1])
+	}
+
+	record := Record{PCI: pci, Message: cleanMessage(msg)}
+	switch {
+	case resetBegin.MatchString(msg):
+		record.Kind = events.KindGPUReset
+	case resetSucceeded.MatchString(msg):
+		record.Kind = events.KindGPUResetSucceeded
+	case resetFailed.MatchString(msg):
+		record.Kind = events.KindGPUResetFailed
+	case ringTimeout.MatchString(msg):
+		record.Kind = events.KindRingTimeout
+		record.Ring = ringTimeout.FindStringSubmatch(msg)[1]
+	case pageFault.MatchString(msg):
+		record.Kind = events.KindPageFault
+		record.Hub = pageFault.FindStringSubmatch(msg)[1]
+		setProcess(&record, msg)
+	default:
+		p.followUp(pci, msg)
+
+		return nil
+	}
+
+	out := p.flush()
+	record.Time = p.now()
+	p.pending = &record
+	p.attached = true
+
+	return out
+}
+
+// followUp attaches details printed after a ring timeout or page fault.
+func (p *parser) followUp(pci, msg string) {
+	if p.pending == nil || (pci != "" && p.pending.PCI != "" && pci != p.pending.PCI) {
+		return
+	}
+	if m := faultAddress.FindStringSubmatch(msg); m != nil && p.pending.Kind == events.KindPageFault {
+		p.pending.Address = m[1]
+		p.attached = true
+
+		return
+	}
+	if !processInfo.MatchString(msg) {
+		return
+	}
+	if p.pending.PID == 0 {
+		setProcess(p.pending, msg)
+	}
+	if p.pending.PCI == "" {
+		p.pending.PCI = pci
+	}
+	p.attached = true
+}
+
+// continuation handles a /dev/kmsg dictionary line such as
+// " DEVICE=+pci:0000:03:00.0".
+func (p *parser) continuation(line string) {
+	if !p.attached || p.pending.PCI != "" {
+		return
+	}
+	if pci, ok := strings.CutPrefix(line, devicePCIPrefix); ok {
+		p.pending.PCI = strings.ToLower(strings.TrimSpace(pci))
+	}
+}
+
+// flush returns the held back record, if any.
+func (p *parser) flush() []Record {
+	if p.pending == nil {
+		return nil
+	}
+	record := *p.pending
+	p.pending = nil
+	p.attached = false
+
+	return []Record{record}
+}
+
+func setProcess(record *Record, msg string) {
+	m := processInfo.FindStringSubmatch(msg)
+	if m == nil {
+		return
+	}
+	pid, err := strconv.Atoi(m[2])
+	if err != nil || pid <= 0 {
+		// Kernel jobs are reported as "process  pid 0".
+		return
+	}
+	record.PID = pid
+	record.Process = strings.TrimSpace(m[1])
+}
+
+// cleanMessage strips the "amdgpu 0000:03:00.0: amdgpu: " and
+// "[drm:func [amdgpu]] *ERROR* " prefixes from a message.
+func cleanMessage(msg string) string {
+	if loc := pciPattern.FindStringIndex(msg); loc != nil && loc[0] == 0 {
+		msg = strings.TrimPrefix(strings.TrimSpace(msg[loc[1]:]), "amdgpu:")
+	}
+	if strings.HasPrefix(msg, "[drm") {
+		if _, rest, ok := strings.Cut(msg, "]] "); ok {
+			msg = rest
+		}
+	}
+	msg = strings.TrimPrefix(strings.TrimSpace(msg), "*ERROR*")
+
+	return strings.TrimSpace(msg)
+}
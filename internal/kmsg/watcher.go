@@ -0,0 +1,199 @@
+// Package kmsg watches the kernel log for amdgpu GPU resets, ring timeouts
+// and VM page faults.
+package kmsg
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/events"
+)
+
+const (
+	// flushDelay is how long a held back record waits for follow-up lines.
+	flushDelay = time.Second
+	// pollInterval is how often a regular file is checked for new lines.
+	pollInterval = 250 * time.Millisecond
+	readBufSize  = 8192
+)
+
+// Count is the number of records of one kind seen for a GPU.
+type Count struct {
+	PCI   string
+	Kind  events.Kind
+	Total uint64
+}
+
+type countKey struct {
+	pci  string
+	kind events.Kind
+}
+
+// Watcher follows a kernel log and reports amdgpu driver errors.
+type Watcher struct {
+	path    string
+	handler func(Record)
+	logger  *slog.Logger
+	now     func() time.Time
+
+	mu     sync.Mutex
+	counts map[countKey]uint64
+}
+
+// NewWatcher creates a watcher of path, normally /dev/kmsg. Regular files
+// (e.g. saved dmesg output in tests) are read from the start and followed;
+// on the device only messages logged after Run starts are reported.
+func NewWatcher(path string, handler func(Record), logger *slog.Logger) *Watcher {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	return &Watcher{
+		path:    path,
+		handler: handler,
+		logger:  logger,
+		now:     time.Now,
+		counts:  make(map[countKey]uint64),
+	}
+}
+
+// Counts returns the number of records seen since start, per GPU and kind.
+func (w *Watcher) Counts() []Count {
+	w.mu.Lock()
+	out := make([]Count, 0, len(w.counts))
+	for key, total := range w.counts {
+		out = append(out, Count{PCI: key.pci, Kind: key.kind, Total: total})
+	}
+	w.mu.Unlock()
+
+	slices.SortFunc(out, func(a, b Count) int {
+		return cmp.Or(strings.Compare(a.PCI, b.PCI), strings.Compare(string(a.Kind), string(b.Kind)))
+	})
+
+	return out
+}
+
+// Run follows the log until ctx is cancelled. Records are timestamped when
+// they are read.
+func (w *Watcher) Run(ctx context.Context) error {
+	file, err := os.Open(w.path)
+	if err != nil {
+		return fmt.Errorf("open kernel log: %w", err)
+	}
+	defer file.Close()
+	// Closing the file unblocks a pending read on the device.
+	stop := context.AfterFunc(ctx, func() { _ = file.Close() })
+	defer stop()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat kernel log: %w", err)
+	}
+
+	p := &parser{now: w.now}
+	if info.Mode()&fs.ModeCharDevice != 0 {
+		err = w.followDevice(ctx, file, p)
+	} else {
+		err = w.followFile(ctx, file, p)
+	}
+	w.emit(p.flush())
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// followDevice reads /dev/kmsg, which returns one record per read.
+func (w *Watcher) followDevice(ctx context.Context, file *os.File, p *parser) error {
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("seek kernel log: %w", err)
+	}
+
+	buf := make([]byte, readBufSize)
+	deadlines := true
+	for {
+		if deadlines {
+			if err := file.SetReadDeadline(time.Now().Add(flushDelay)); err != nil {
+				// Without deadlines held back records wait for the next
+				// driver error.
+				deadlines = false
+			}
+		}
+		n, err := file.Read(buf)
+		switch {
+		case err == nil:
+			for line := range strings.Lines(string(buf[:n])) {
+				w.emit(p.feed(line))
+			}
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			w.emit(p.flush())
+		case errors.Is(err, syscall.EPIPE):
+			// Records were overwritten before we read them.
+			w.logger.Debug("kernel log records lost")
+		case ctx.Err() != nil:
+			return ctx.Err()
+		default:
+			return fmt.Errorf("read kernel log: %w", err)
+		}
+	}
+}
+
+// followFile reads a regular file to the end and then polls for appended
+// lines.
+func (w *Watcher) followFile(ctx context.Context, file *os.File, p *parser) error {
+	reader := bufio.NewReader(file)
+	var partial strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		partial.WriteString(line)
+		if err == nil {
+			w.emit(p.feed(partial.String()))
+			partial.Reset()
+
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return fmt.Errorf("read kernel log: %w", err)
+		}
+
+		w.emit(p.flush())
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *Watcher) emit(records []Record) {
+	for _, record := range records {
+		w.mu.Lock()
+		w.counts[countKey{pci: record.PCI, kind: record.Kind}]++
+		w.mu.Unlock()
+
+		w.logger.Warn("amdgpu driver error", "kind", record.Kind, "pci", record.PCI, "pid", record.PID, "message", record.Message)
+		if w.handler != nil {
+			w.handler(record)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Exec runs a local command for every notification. The command line is
+// split on whitespace and run without a shell; details are passed in
+// AMDGPUTOP_* environment variables.
+type Exec struct {
+	args []string
+}
+
+// NewExec creates a command notifier.
+func NewExec(command string) (*Exec, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("command is empty")
+	}
+
+	return &Exec{args: args}, nil
+}
+
+// Name implements Notifier.
+func (e *Exec) Name() string {
+	return "exec"
+}
+
+// Notify implements Notifier.
+func (e *Exec) Notify(ctx context.Context, n Notification) error {
+	cmd := exec.CommandContext(ctx, e.args[0], e.args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"AMDGPUTOP_RULE="+n.Rule,
+		"AMDGPUTOP_GPU_ID="+n.GPUId,
+		"AMDGPUTOP_STATE="+n.State,
+		"AMDGPUTOP_SEVERITY="+n.Severity,
+		"AMDGPUTOP_EXPR="+n.Expr,
+		"AMDGPUTOP_VALUE="+n.ValueString(),
+		"AMDGPUTOP_TIME="+n.Time.Format(time.RFC3339),
+		"AMDGPUTOP_TITLE="+n.Title,
+		"AMDGPUTOP_MESSAGE="+n.Message,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if trimmed := strings.TrimSpace(string(output)); trimmed != "" {
+			return fmt.Errorf("run %s: %w: %s", e.args[0], err, trimmed)
+		}
+
+		return fmt.Errorf("run %s: %w", e.args[0], err)
+	}
+
+	return nil
+}
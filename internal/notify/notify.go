@@ -0,0 +1,420 @@
+// Package notify delivers alert state changes to external targets: HTTP
+// webhooks, SMTP email and local commands.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/alerts"
+	"github.com/skobkin/amdgputop-web/internal/config"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	maxBackoff     = 5 * time.Minute
+	// maxQueued bounds the notifications waiting behind a slow delivery of
+	// the same rule and GPU.
+	maxQueued = 32
+)
+
+// Notification is the payload handed to notifiers and webhook templates.
+type Notification struct {
+	Rule     string    `json:"rule"`
+	GPUId    string    `json:"gpu_id"`
+	State    string    `json:"state"`
+	Severity string    `json:"severity"`
+	Expr     string    `json:"expr"`
+	Value    *float64  `json:"value"`
+	Time     time.Time `json:"time"`
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+}
+
+// FromAlert builds a notification for an alert state change.
+func FromAlert(alert alerts.Alert) Notification {
+	n := Notification{
+		Rule:     alert.Rule,
+		GPUId:    alert.GPUId,
+		State:    string(alert.State),
+		Severity: alert.Severity,
+		Expr:     alert.Expr,
+		Value:    alert.Value,
+		Time:     alert.ActiveSince,
+		Title:    fmt.Sprintf("%s %s on %s", alert.Rule, alert.State, alert.GPUId),
+	}
+	switch {
+	case alert.ResolvedAt != nil:
+		n.Time = *alert.ResolvedAt
+	case alert.FiredAt != nil:
+		n.Time = *alert.FiredAt
+	}
+
+	n.Message = fmt.Sprintf("%s (severity %s)", alert.Expr, alert.Severity)
+	if alert.Value != nil {
+		n.Message = fmt.Sprintf("%s, value %s (severity %s)", alert.Expr, n.ValueString(), alert.Severity)
+	}
+
+	return n
+}
+
+// ValueString formats Value, or returns an empty string when it is unknown.
+func (n Notification) ValueString() string {
+	if n.Value == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(*n.Value, 'f', -1, 64)
+}
+
+// Notifier delivers a notification to one target.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// permanentError marks failures that retrying cannot fix, such as a webhook
+// rejecting the payload.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// FromConfig creates the notifiers configured in cfg.
+func FromConfig(cfg config.NotifyConfig) ([]Notifier, error) {
+	var notifiers []Notifier
+	if cfg.WebhookURL != "" {
+		webhook, err := NewWebhook(WebhookOptions{
+			URL:     cfg.WebhookURL,
+			Format:  cfg.WebhookFormat,
+			Body:    cfg.WebhookBody,
+			Headers: cfg.WebhookHeaders,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("webhook: %w", err)
+		}
+		notifiers = append(notifiers, webhook)
+	}
+	if cfg.SMTPAddr != "" {
+		notifiers = append(notifiers, NewSMTP(SMTPOptions{
+			Addr:     cfg.SMTPAddr,
+			From:     cfg.SMTPFrom,
+			To:       cfg.SMTPTo,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+		}))
+	}
+	if cfg.Exec != "" {
+		command, err := NewExec(cfg.Exec)
+		if err != nil {
+			return nil, fmt.Errorf("exec: %w", err)
+		}
+		notifiers = append(notifiers, command)
+	}
+
+	return notifiers, nil
+}
+
+// LimitRules turns the simple per-metric limits of cfg into alert rules.
+func LimitRules(cfg config.NotifyConfig) []alerts.Rule {
+	hold := ""
+	if cfg.For > 0 {
+		hold = cfg.For.String()
+	}
+
+	var rules []alerts.Rule
+	if cfg.TempC > 0 {
+		rules = append(rules, alerts.Rule{
+			Name:     "gpu_temp_high",
+			Expr:     "temp_c > " + formatLimit(cfg.TempC),
+			Clear:    "temp_c < " + formatLimit(cfg.TempC-5),
+			For:      hold,
+			Severity: "critical",
+		})
+	}
+	if cfg.FanStoppedTempC > 0 {
+		rules = append(rules, alerts.Rule{
+			Name:     "gpu_fan_stopped",
+			Expr:     "fan_rpm == 0 while temp_c > " + formatLimit(cfg.FanStoppedTempC),
+			For:      hold,
+			Severity: "critical",
+		})
+	}
+	if cfg.VRAMPct > 0 {
+		rules = append(rules, alerts.Rule{
+			Name:     "gpu_vram_high",
+			Expr:     "vram_used * 100 / vram_total > " + formatLimit(cfg.VRAMPct),
+			Clear:    "vram_used * 100 / vram_total < " + formatLimit(max(cfg.VRAMPct-5, 0)),
+			For:      hold,
+			Severity: alerts.DefaultSeverity,
+		})
+	}
+	if cfg.ReaderFailures {
+		rules = append(rules, alerts.Rule{
+			Name:     "gpu_reader_failed",
+			Expr:     "readings == 0",
+			For:      hold,
+			Severity: "critical",
+		})
+	}
+
+	return rules
+}
+
+func formatLimit(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// Options tune delivery.
+type Options struct {
+	// Retries is the number of extra attempts after a failed delivery.
+	Retries int
+	// Backoff is the delay before the first retry; it doubles on every
+	// further attempt.
+	Backoff time.Duration
+	// Dedupe damps flapping: a resolution arriving within this window of
+	// the last firing notification of the same rule and GPU is held until
+	// the window ends, and a re-fire before then drops both, as the target
+	// still sees the alert firing.
+	Dedupe time.Duration
+	// Timeout bounds a single delivery attempt.
+	Timeout time.Duration
+}
+
+type streamKey struct {
+	rule  string
+	gpuID string
+}
+
+// stream holds the delivery state of one rule and GPU. Its notifications
+// are delivered one at a time so targets see them in order.
+type stream struct {
+	// firing tells whether the last notification queued was a firing one,
+	// and firedAt when it was queued.
+	firing  bool
+	firedAt time.Time
+	// held is a resolution waiting for the dedupe window to end.
+	held    *Notification
+	timer   *time.Timer
+	queue   []Notification
+	running bool
+}
+
+// Dispatcher fans alert state changes out to notifiers.
+type Dispatcher struct {
+	notifiers []Notifier
+	opts      Options
+	logger    *slog.Logger
+	now       func() time.Time
+	after     func(time.Duration, func()) *time.Timer
+
+	mu      sync.Mutex
+	streams map[streamKey]*stream
+	// dropped counts notifications lost to full queues.
+	dropped uint64
+
+	wg sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher delivering to notifiers.
+func NewDispatcher(notifiers []Notifier, opts Options, logger *slog.Logger) *Dispatcher {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	return &Dispatcher{
+		notifiers: notifiers,
+		opts:      opts,
+		logger:    logger,
+		now:       time.Now,
+		after:     time.AfterFunc,
+		streams:   make(map[streamKey]*stream),
+	}
+}
+
+// Run dispatches alerts from updates until ctx is cancelled or updates is
+// closed, then waits for deliveries in flight.
+func (d *Dispatcher) Run(ctx context.Context, updates <-chan alerts.Alert) error {
+	defer d.wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case alert, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			d.Dispatch(ctx, alert)
+		}
+	}
+}
+
+// Dispatch delivers firing alerts and resolutions of fired alerts in the
+// background. Pending alerts are skipped, and flaps within the dedupe window
+// are damped as described for Options.Dedupe. Held resolutions are dropped
+// when ctx is done before their window ends.
+func (d *Dispatcher) Dispatch(ctx context.Context, alert alerts.Alert) {
+	switch {
+	case alert.State == alerts.StateFiring:
+	case alert.State == alerts.StateResolved && alert.FiredAt != nil:
+	default:
+		return
+	}
+
+	key := streamKey{rule: alert.Rule, gpuID: alert.GPUId}
+	now := d.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, ok := d.streams[key]
+	if !ok {
+		st = &stream{}
+		d.streams[key] = st
+	}
+
+	if alert.State == alerts.StateFiring {
+		switch {
+		case st.held != nil:
+			st.timer.Stop()
+			st.held, st.timer = nil, nil
+			d.logger.Debug("flap within dedupe window suppressed", "rule", alert.Rule, "gpu_id", alert.GPUId)
+		case st.firing:
+			d.logger.Debug("notification deduplicated", "rule", alert.Rule, "gpu_id", alert.GPUId, "state", alert.State)
+		default:
+			st.firing, st.firedAt = true, now
+			d.enqueueLocked(ctx, st, FromAlert(alert))
+		}
+
+		return
+	}
+
+	if !st.firing || st.held != nil {
+		return
+	}
+	n := FromAlert(alert)
+	if wait := st.firedAt.Add(d.opts.Dedupe).Sub(now); wait > 0 {
+		st.held = &n
+		st.timer = d.after(wait, func() { d.releaseHeld(ctx, st) })
+
+		return
+	}
+	st.firing = false
+	d.enqueueLocked(ctx, st, n)
+}
+
+// releaseHeld queues the resolution held on st once its dedupe window has
+// ended without a re-fire.
+func (d *Dispatcher) releaseHeld(ctx context.Context, st *stream) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if st.held == nil {
+		return
+	}
+	n := *st.held
+	st.held, st.timer = nil, nil
+	st.firing = false
+	if ctx.Err() != nil {
+		return
+	}
+	d.enqueueLocked(ctx, st, n)
+}
+
+// enqueueLocked queues n on st and starts draining it. Callers hold d.mu.
+func (d *Dispatcher) enqueueLocked(ctx context.Context, st *stream, n Notification) {
+	if len(st.queue) >= maxQueued {
+		dropped := st.queue[0]
+		st.queue = st.queue[1:]
+		d.dropped++
+		d.logger.Warn("notification queue full, dropped oldest",
+			"rule", dropped.Rule, "gpu_id", dropped.GPUId, "state", dropped.State, "dropped_total", d.dropped)
+	}
+	st.queue = append(st.queue, n)
+	if st.running {
+		return
+	}
+	st.running = true
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.drain(ctx, st)
+	}()
+}
+
+// drain delivers the queued notifications of st in order. Each one goes to
+// all notifiers concurrently, and the next starts once all are done.
+func (d *Dispatcher) drain(ctx context.Context, st *stream) {
+	for {
+		d.mu.Lock()
+		if len(st.queue) == 0 {
+			st.running = false
+			d.mu.Unlock()
+
+			return
+		}
+		n := st.queue[0]
+		st.queue = st.queue[1:]
+		d.mu.Unlock()
+
+		var wg sync.WaitGroup
+		for _, notifier := range d.notifiers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.deliver(ctx, notifier, n)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// Wait blocks until all deliveries started so far have finished.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, notifier Notifier, n Notification) {
+	logger := d.logger.With("notifier", notifier.Name(), "rule", n.Rule, "gpu_id", n.GPUId, "state", n.State)
+	backoff := d.opts.Backoff
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+		err := notifier.Notify(attemptCtx, n)
+		cancel()
+		if err == nil {
+			logger.Info("notification sent", "attempts", attempt+1)
+
+			return
+		}
+
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt >= d.opts.Retries || ctx.Err() != nil {
+			logger.Warn("notification failed", "attempts", attempt+1, "err", err)
+
+			return
+		}
+		logger.Debug("notification attempt failed", "attempt", attempt+1, "retry_in", backoff, "err", err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Warn("notification abandoned", "attempts", attempt+1, "err", err)
+
+			return
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
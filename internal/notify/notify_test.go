@@ -0,0 +1,446 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/alerts"
+	"github.com/skobkin/amdgputop-web/internal/config"
+)
+
+type recordingNotifier struct {
+	mu       sync.Mutex
+	failures int
+	err      error
+	calls    []Notification
+}
+
+func (r *recordingNotifier) Name() string { return "recording" }
+
+func (r *recordingNotifier) Notify(_ context.Context, n Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, n)
+	if r.failures > 0 {
+		r.failures--
+
+		return r.err
+	}
+
+	return nil
+}
+
+func (r *recordingNotifier) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.calls)
+}
+
+func firingAlert(rule string, value float64) alerts.Alert {
+	ts := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	return alerts.Alert{
+		Rule:        rule,
+		GPUId:       "card0",
+		State:       alerts.StateFiring,
+		Severity:    "critical",
+		Expr:        "temp_c > 95",
+		Value:       &value,
+		ActiveSince: ts,
+		FiredAt:     &ts,
+	}
+}
+
+// heldTimers replaces Dispatcher.after, so tests end dedupe windows on
+// demand.
+type heldTimers struct {
+	mu      sync.Mutex
+	pending []func()
+}
+
+func (h *heldTimers) install(d *Dispatcher) {
+	d.after = func(_ time.Duration, fn func()) *time.Timer {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.pending = append(h.pending, fn)
+
+		// Never fires on its own; Stop still works.
+		return time.AfterFunc(time.Hour, func() {})
+	}
+}
+
+// fire runs the callbacks of all timers created so far.
+func (h *heldTimers) fire() {
+	h.mu.Lock()
+	pending := h.pending
+	h.pending = nil
+	h.mu.Unlock()
+	for _, fn := range pending {
+		fn()
+	}
+}
+
+func resolvedAlert(rule string, at time.Time) alerts.Alert {
+	alert := firingAlert(rule, 80)
+	alert.State = alerts.StateResolved
+	alert.ResolvedAt = &at
+
+	return alert
+}
+
+func TestDispatcherRetriesAndDedupes(t *testing.T) {
+	t.Parallel()
+
+	flaky := &recordingNotifier{failures: 2, err: errors.New("unavailable")}
+	rejecting := &recordingNotifier{failures: 5, err: permanentError{errors.New("bad request")}}
+	d := NewDispatcher([]Notifier{flaky, rejecting}, Options{Retries: 3, Backoff: time.Millisecond, Dedupe: time.Minute}, nil)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+	var timers heldTimers
+	timers.install(d)
+
+	ctx := context.Background()
+	d.Dispatch(ctx, firingAlert("hot", 97))
+	d.Wait()
+	if got := flaky.count(); got != 3 {
+		t.Fatalf("expected 3 attempts after 2 failures, got %d", got)
+	}
+	if got := rejecting.count(); got != 1 {
+		t.Fatalf("expected permanent errors not to be retried, got %d attempts", got)
+	}
+
+	// The engine alternates firing and resolved; a flap within the window
+	// sends neither the resolution nor the re-fire.
+	now = now.Add(10 * time.Second)
+	d.Dispatch(ctx, resolvedAlert("hot", now))
+	now = now.Add(10 * time.Second)
+	d.Dispatch(ctx, firingAlert("hot", 98))
+	timers.fire()
+	d.Wait()
+	if got := flaky.count(); got != 3 {
+		t.Fatalf("expected flap within window to be suppressed, got %d calls", got)
+	}
+
+	pending := firingAlert("warm", 91)
+	pending.State = alerts.StatePending
+	pending.FiredAt = nil
+	d.Dispatch(ctx, pending)
+	unfired := pending
+	unfired.State = alerts.StateResolved
+	d.Dispatch(ctx, unfired)
+	d.Wait()
+	if got := flaky.count(); got != 3 {
+		t.Fatalf("expected pending and never-fired alerts to be skipped, got %d calls", got)
+	}
+
+	// A resolution that stands until the window ends is delivered then.
+	now = now.Add(10 * time.Second)
+	d.Dispatch(ctx, resolvedAlert("hot", now))
+	d.Wait()
+	if got := flaky.count(); got != 3 {
+		t.Fatalf("expected resolution to be held within window, got %d calls", got)
+	}
+	timers.fire()
+	d.Wait()
+	if got := flaky.count(); got != 4 {
+		t.Fatalf("expected held resolution to be sent, got %d calls", got)
+	}
+
+	// After the window, firing and resolution go out as they come.
+	now = now.Add(2 * time.Minute)
+	d.Dispatch(ctx, firingAlert("hot", 99))
+	now = now.Add(2 * time.Minute)
+	d.Dispatch(ctx, resolvedAlert("hot", now))
+	d.Wait()
+	if got := flaky.count(); got != 6 {
+		t.Fatalf("expected firing after window and resolution to be sent, got %d calls", got)
+	}
+}
+
+// blockingNotifier holds the first delivery until release is closed.
+type blockingNotifier struct {
+	recordingNotifier
+	release chan struct{}
+	once    sync.Once
+}
+
+func (b *blockingNotifier) Notify(ctx context.Context, n Notification) error {
+	b.once.Do(func() { <-b.release })
+
+	return b.recordingNotifier.Notify(ctx, n)
+}
+
+func TestDispatcherDeliversFlapsInOrder(t *testing.T) {
+	t.Parallel()
+
+	slow := &blockingNotifier{release: make(chan struct{})}
+	d := NewDispatcher([]Notifier{slow}, Options{Dedupe: time.Minute}, nil)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+	var timers heldTimers
+	timers.install(d)
+
+	ctx := context.Background()
+	d.Dispatch(ctx, firingAlert("hot", 97))
+	now = now.Add(2 * time.Minute)
+	d.Dispatch(ctx, resolvedAlert("hot", now))
+	d.Dispatch(ctx, firingAlert("hot", 98))
+	now = now.Add(time.Second)
+	d.Dispatch(ctx, resolvedAlert("hot", now))
+	timers.fire()
+	close(slow.release)
+	d.Wait()
+
+	slow.mu.Lock()
+	defer slow.mu.Unlock()
+	var states []string
+	for _, n := range slow.calls {
+		states = append(states, n.State)
+	}
+	want := []string{"firing", "resolved", "firing", "resolved"}
+	if strings.Join(states, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected deliveries %v, got %v", want, states)
+	}
+}
+
+func TestLimitRulesCompile(t *testing.T) {
+	t.Parallel()
+
+	rules := LimitRules(config.NotifyConfig{TempC: 95, FanStoppedTempC: 70, VRAMPct: 97.5, ReaderFailures: true, For: 30 * time.Second})
+	if len(rules) != 4 {
+		t.Fatalf("expected 4 rules, got %d", len(rules))
+	}
+	if rules[0].Expr != "temp_c > 95" || rules[0].Clear != "temp_c < 90" || rules[0].For != "30s" {
+		t.Fatalf("unexpected temperature rule %+v", rules[0])
+	}
+	if _, err := alerts.NewEngine(rules, nil); err != nil {
+		t.Fatalf("limit rules do not compile: %v", err)
+	}
+	if got := LimitRules(config.NotifyConfig{}); len(got) != 0 {
+		t.Fatalf("expected no rules without limits, got %+v", got)
+	}
+}
+
+func TestWebhookTemplatesAndRetry(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		body    string
+		headers http.Header
+	}
+	requests := make(chan request, 4)
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+		requests <- request{body: string(body), headers: r.Header.Clone()}
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhook(WebhookOptions{
+		URL:     server.URL,
+		Format:  "discord",
+		Headers: []string{"Authorization: Bearer secret", "X-GPU: {{.GPUId}}"},
+	})
+	if err != nil {
+		t.Fatalf("NewWebhook error: %v", err)
+	}
+
+	d := NewDispatcher([]Notifier{webhook}, Options{Retries: 2, Backoff: time.Millisecond}, nil)
+	d.Dispatch(context.Background(), firingAlert("hot", 97.5))
+	d.Wait()
+
+	select {
+	case req := <-requests:
+		want := `{"content":"**hot firing on card0**\ntemp_c > 95, value 97.5 (severity critical)"}`
+		if req.body != want {
+			t.Fatalf("unexpected body:\n got %s\nwant %s", req.body, want)
+		}
+		if req.headers.Get("Authorization") != "Bearer secret" || req.headers.Get("X-GPU") != "card0" {
+			t.Fatalf("unexpected headers %+v", req.headers)
+		}
+		if req.headers.Get("Content-Type") != "application/json" {
+			t.Fatalf("unexpected content type %q", req.headers.Get("Content-Type"))
+		}
+	default:
+		t.Fatalf("webhook was not retried after a 502")
+	}
+
+	ntfy, err := NewWebhook(WebhookOptions{URL: server.URL, Format: "ntfy"})
+	if err != nil {
+		t.Fatalf("NewWebhook ntfy error: %v", err)
+	}
+	if err := ntfy.Notify(context.Background(), FromAlert(firingAlert("hot", 97))); err != nil {
+		t.Fatalf("ntfy notify: %v", err)
+	}
+	req := <-requests
+	if req.headers.Get("Title") != "hot firing on card0" || req.headers.Get("Priority") != "urgent" || !strings.HasPrefix(req.body, "temp_c > 95") {
+		t.Fatalf("unexpected ntfy request %+v", req)
+	}
+
+	if _, err := NewWebhook(WebhookOptions{URL: server.URL, Body: "{{.Missing"}); err == nil {
+		t.Fatalf("expected template parse error")
+	}
+}
+
+func TestSMTPDelivery(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	type envelope struct {
+		auth string
+		from string
+		to   []string
+		data string
+	}
+	received := make(chan envelope, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		received <- serveSMTP(conn)
+	}()
+
+	notifier := NewSMTP(SMTPOptions{
+		Addr:     listener.Addr().String(),
+		From:     "gpu@example.com",
+		To:       []string{"ops@example.com", "oncall@example.com"},
+		Username: "gpu",
+		Password: "hunter2",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := notifier.Notify(ctx, FromAlert(firingAlert("hot", 97))); err != nil {
+		t.Fatalf("smtp notify: %v", err)
+	}
+
+	msg := <-received
+	if msg.auth != "\x00gpu\x00hunter2" {
+		t.Fatalf("unexpected auth %q", msg.auth)
+	}
+	if msg.from != "<gpu@example.com>" || len(msg.to) != 2 {
+		t.Fatalf("unexpected envelope %+v", msg)
+	}
+	if !strings.Contains(msg.data, "Subject: [amdgputop] hot firing on card0\r\n") || !strings.Contains(msg.data, "Value:    97") {
+		t.Fatalf("unexpected message:\n%s", msg.data)
+	}
+}
+
+// serveSMTP speaks just enough SMTP for one message.
+func serveSMTP(conn net.Conn) (env struct {
+	auth string
+	from string
+	to   []string
+	data string
+}) {
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return env
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			fields := strings.Fields(line)
+			decoded, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			env.auth = string(decoded)
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			env.from = strings.TrimPrefix(line, "MAIL FROM:")
+			reply("250 OK")
+		case "RCPT":
+			env.to = append(env.to, strings.TrimPrefix(line, "RCPT TO:"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil || dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			env.data = data.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+
+			return env
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestExecPassesEnvironment(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "notify.sh")
+	output := filepath.Join(dir, "env.txt")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nenv > \"$1\"\n"), 0o755); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	notifier, err := NewExec(script + " " + output)
+	if err != nil {
+		t.Fatalf("NewExec error: %v", err)
+	}
+	if err := notifier.Notify(context.Background(), FromAlert(firingAlert("hot", 97))); err != nil {
+		t.Fatalf("exec notify: %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read env: %v", err)
+	}
+	for _, want := range []string{"AMDGPUTOP_RULE=hot", "AMDGPUTOP_GPU_ID=card0", "AMDGPUTOP_STATE=firing", "AMDGPUTOP_VALUE=97"} {
+		if !strings.Contains(string(data), want+"\n") {
+			t.Fatalf("expected %q in environment:\n%s", want, data)
+		}
+	}
+
+	failing, err := NewExec("/bin/sh -c false")
+	if err != nil {
+		t.Fatalf("NewExec error: %v", err)
+	}
+	if err := failing.Notify(context.Background(), Notification{}); err == nil {
+		t.Fatalf("expected error from failing command")
+	}
+	if _, err := NewExec("  "); err == nil {
+		t.Fatalf("expected error for empty command")
+	}
+}
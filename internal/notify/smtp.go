@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPOptions configure an email notifier. Authentication uses PLAIN and
+// is only attempted when Username is set; STARTTLS is used when offered.
+type SMTPOptions struct {
+	Addr     string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+// SMTP sends notifications as plain-text email.
+type SMTP struct {
+	opts SMTPOptions
+}
+
+// NewSMTP creates an email notifier.
+func NewSMTP(opts SMTPOptions) *SMTP {
+	return &SMTP{opts: opts}
+}
+
+// Name implements Notifier.
+func (s *SMTP) Name() string {
+	return "smtp"
+}
+
+// Notify implements Notifier.
+func (s *SMTP) Notify(ctx context.Context, n Notification) error {
+	host, _, err := net.SplitHostPort(s.opts.Addr)
+	if err != nil {
+		return permanentError{fmt.Errorf("parse smtp address: %w", err)}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("dial smtp: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if s.opts.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return permanentError{fmt.Errorf("smtp server does not support AUTH")}
+		}
+		if err := client.Auth(smtp.PlainAuth("", s.opts.Username, s.opts.Password, host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.opts.From); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	for _, rcpt := range s.opts.To {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp rcpt to %q: %w", rcpt, err)
+		}
+	}
+	data, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := data.Write(s.message(n)); err != nil {
+		_ = data.Close()
+
+		return fmt.Errorf("smtp write message: %w", err)
+	}
+	if err := data.Close(); err != nil {
+		return fmt.Errorf("smtp send message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+func (s *SMTP) message(n Notification) []byte {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		buf.WriteString(name + ": " + strings.NewReplacer("\r", "", "\n", "").Replace(value) + "\r\n")
+	}
+	header("From", s.opts.From)
+	header("To", strings.Join(s.opts.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", "[amdgputop] "+n.Title))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	buf.WriteString("\r\n")
+
+	lines := []string{
+		n.Message,
+		"",
+		"Rule:     " + n.Rule,
+		"GPU:      " + n.GPUId,
+		"State:    " + n.State,
+		"Severity: " + n.Severity,
+		"Time:     " + n.Time.Format(time.RFC3339),
+	}
+	if value := n.ValueString(); value != "" {
+		lines = append(lines, "Value:    "+value)
+	}
+	buf.WriteString(strings.Join(lines, "\r\n") + "\r\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,179 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// webhookPreset is the default body, content type and headers of a
+// webhook format.
+type webhookPreset struct {
+	body        string
+	contentType string
+	headers     []string
+}
+
+var webhookPresets = map[string]webhookPreset{
+	"json": {
+		body:        `{{json .}}`,
+		contentType: "application/json",
+	},
+	"ntfy": {
+		body:        `{{.Message}}`,
+		contentType: "text/plain; charset=utf-8",
+		headers: []string{
+			`Title: {{.Title}}`,
+			`Priority: {{if eq .State "resolved"}}default{{else if eq .Severity "critical"}}urgent{{else}}high{{end}}`,
+			`Tags: {{if eq .State "resolved"}}white_check_mark{{else}}warning{{end}}`,
+		},
+	},
+	"gotify": {
+		body:        `{"title":{{json .Title}},"message":{{json .Message}},"priority":{{if eq .State "resolved"}}2{{else}}8{{end}}}`,
+		contentType: "application/json",
+	},
+	"discord": {
+		body:        `{"content":{{json (printf "**%s**\n%s" .Title .Message)}}}`,
+		contentType: "application/json",
+	},
+	"slack": {
+		body:        `{"text":{{json (printf "*%s*\n%s" .Title .Message)}}}`,
+		contentType: "application/json",
+	},
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return "", err
+		}
+
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	},
+}
+
+// WebhookOptions configure a webhook notifier. Body and header values are
+// text/template templates executed with a Notification; they override the
+// defaults of Format.
+type WebhookOptions struct {
+	URL     string
+	Format  string
+	Body    string
+	Headers []string
+	Client  *http.Client
+}
+
+type headerTemplate struct {
+	name  string
+	value *template.Template
+}
+
+// Webhook posts notifications to an HTTP endpoint.
+type Webhook struct {
+	url         string
+	body        *template.Template
+	contentType string
+	headers     []headerTemplate
+	client      *http.Client
+}
+
+// NewWebhook parses the body and header templates.
+func NewWebhook(opts WebhookOptions) (*Webhook, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	format := opts.Format
+	if format == "" {
+		format = "json"
+	}
+	preset, ok := webhookPresets[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	bodyText := preset.body
+	if opts.Body != "" {
+		bodyText = opts.Body
+	}
+	body, err := template.New("body").Funcs(templateFuncs).Parse(bodyText)
+	if err != nil {
+		return nil, fmt.Errorf("parse body template: %w", err)
+	}
+
+	w := &Webhook{
+		url:         opts.URL,
+		body:        body,
+		contentType: preset.contentType,
+		client:      opts.Client,
+	}
+	if w.client == nil {
+		w.client = http.DefaultClient
+	}
+
+	for _, header := range append(append([]string(nil), preset.headers...), opts.Headers...) {
+		name, value, ok := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q", header)
+		}
+		tmpl, err := template.New(name).Funcs(templateFuncs).Parse(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("parse header %q template: %w", name, err)
+		}
+		w.headers = append(w.headers, headerTemplate{name: name, value: tmpl})
+	}
+
+	return w, nil
+}
+
+// Name implements Notifier.
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(ctx context.Context, n Notification) error {
+	var body bytes.Buffer
+	if err := w.body.Execute(&body, n); err != nil {
+		return permanentError{fmt.Errorf("render body: %w", err)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, &body)
+	if err != nil {
+		return permanentError{fmt.Errorf("build request: %w", err)}
+	}
+	req.Header.Set("Content-Type", w.contentType)
+	for _, header := range w.headers {
+		var value strings.Builder
+		if err := header.value.Execute(&value, n); err != nil {
+			return permanentError{fmt.Errorf("render header %q: %w", header.name, err)}
+		}
+		// Later headers (user supplied) replace preset ones of the same name.
+		req.Header.Set(header.name, strings.NewReplacer("\r", " ", "\n", " ").Replace(value.String()))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusRequestTimeout {
+		return permanentError{err}
+	}
+
+	return err
+}
@@ -0,0 +1,206 @@
+package procscan
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultMaxExited = 256
+
+// processAccount tracks the running totals of a live GPU process.
+type processAccount struct {
+	pid         int
+	uid         int
+	user        string
+	name        string
+	command     string
+	renderNode  string
+	displayName string
+	category    string
+	startTime   uint64
+	firstSeen   time.Time
+	lastSeen    time.Time
+
+	engineTotal uint64
+	hasEngine   bool
+	peakVRAM    uint64
+	peakGTT     uint64
+	hasMemory   bool
+	energyJ     float64
+	hasEnergy   bool
+}
+
+// exitTracker keeps the last seen totals of GPU processes and retains a
+// bounded list of processes that disappeared between scans.
+type exitTracker struct {
+	maxExited int
+
+	mu     sync.RWMutex
+	live   map[string]map[int]*processAccount
+	exited map[string][]ExitedProcess
+}
+
+func newExitTracker(maxExited int) *exitTracker {
+	if maxExited <= 0 {
+		maxExited = defaultMaxExited
+	}
+
+	return &exitTracker{
+		maxExited: maxExited,
+		live:      make(map[string]map[int]*processAccount),
+		exited:    make(map[string][]ExitedProcess),
+	}
+}
+
+// observe records a process seen on the GPU at the supplied time.
+func (t *exitTracker) observe(gpuID string, raw rawProcess, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	accounts, ok := t.live[gpuID]
+	if !ok {
+		accounts = make(map[int]*processAccount)
+		t.live[gpuID] = accounts
+	}
+
+	account, ok := accounts[raw.pid]
+	if ok && account.reusedBy(raw) {
+		// PID was reused by another process since the last observation.
+		t.retireLocked(gpuID, account, now)
+		ok = false
+	}
+	if !ok {
+		account = &processAccount{
+			pid:        raw.pid,
+			startTime:  raw.startTime,
+			firstSeen:  now,
+			renderNode: raw.renderNode,
+		}
+		accounts[raw.pid] = account
+	}
+
+	account.uid = raw.uid
+	account.user = raw.user
+	account.name = raw.name
+	account.command = raw.command
+	account.lastSeen = now
+	if raw.displayName != "" {
+		account.displayName = raw.displayName
+	}
+	if raw.category != "" {
+		account.category = raw.category
+	}
+	if raw.renderNode != "" {
+		account.renderNode = raw.renderNode
+	}
+	if raw.hasEngine && raw.engineTotal >= account.engineTotal {
+		account.engineTotal = raw.engineTotal
+		account.hasEngine = true
+	}
+	if raw.hasMemory {
+		account.hasMemory = true
+		if raw.vramBytes > account.peakVRAM {
+			account.peakVRAM = raw.vramBytes
+		}
+		if raw.gttBytes > account.peakGTT {
+			account.peakGTT = raw.gttBytes
+		}
+	}
+}
+
+// reusedBy reports whether raw is a different process than the one the
+// account tracks. The start time tells a relaunch of the same program apart;
+// sources that do not report it fall back to the name.
+func (a *processAccount) reusedBy(raw rawProcess) bool {
+	if a.startTime != 0 && raw.startTime != 0 {
+		return a.startTime != raw.startTime
+	}
+
+	return a.name != raw.name
+}
+
+// addEnergy adds estimated energy to a live process and returns its total.
+func (t *exitTracker) addEnergy(gpuID string, pid int, joules float64) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	account, ok := t.live[gpuID][pid]
+	if !ok {
+		return joules
+	}
+	account.energyJ += joules
+	account.hasEnergy = true
+
+	return account.energyJ
+}
+
+// sweep retires every live process of the GPU that was not part of seen.
+func (t *exitTracker) sweep(gpuID string, seen map[int]struct{}, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for pid, account := range t.live[gpuID] {
+		if _, ok := seen[pid]; ok {
+			continue
+		}
+		t.retireLocked(gpuID, account, now)
+	}
+	if len(t.live[gpuID]) == 0 {
+		delete(t.live, gpuID)
+	}
+}
+
+func (t *exitTracker) retireLocked(gpuID string, account *processAccount, now time.Time) {
+	delete(t.live[gpuID], account.pid)
+
+	exited := ExitedProcess{
+		PID:         account.pid,
+		UID:         account.uid,
+		User:        account.user,
+		Name:        account.name,
+		Command:     account.command,
+		RenderNode:  account.renderNode,
+		DisplayName: account.displayName,
+		Category:    account.category,
+		FirstSeen:   account.firstSeen.UTC(),
+		LastSeen:    account.lastSeen.UTC(),
+		ExitedAt:    now.UTC(),
+	}
+	if account.hasEngine {
+		ms := float64(account.engineTotal) / 1_000_000
+		exited.GPUTimeMS = &ms
+	}
+	if account.hasMemory {
+		vram := account.peakVRAM
+		gtt := account.peakGTT
+		exited.PeakVRAMBytes = &vram
+		exited.PeakGTTBytes = &gtt
+	}
+
+	if account.hasEnergy {
+		joules := account.energyJ
+		wh := joules / joulesPerWh
+		exited.EnergyJ = &joules
+		exited.EnergyWh = &wh
+	}
+
+	list := append(t.exited[gpuID], exited)
+	if len(list) > t.maxExited {
+		list = list[len(list)-t.maxExited:]
+	}
+	t.exited[gpuID] = list
+}
+
+// list returns exited processes for the GPU ordered newest first.
+func (t *exitTracker) list(gpuID string) []ExitedProcess {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	src := t.exited[gpuID]
+	out := make([]ExitedProcess, 0, len(src))
+	for i := len(src) - 1; i >= 0; i-- {
+		out = append(out, src[i])
+	}
+
+	return out
+}
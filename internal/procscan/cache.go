@@ -0,0 +1,126 @@
+package procscan
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// coldRecheckScans is how many scans a PID without DRM fds is skipped for
+// before its fd table is inspected again.
+const coldRecheckScans = 5
+
+// coldEntry remembers a PID that had no DRM fds when last inspected. The start
+// time guards against PID reuse.
+type coldEntry struct {
+	startTime uint64
+	checkedAt uint64
+}
+
+// fdCacheEntry remembers which GPU a DRM fd pointed to, keyed by the inode
+// fdinfo reported for it.
+type fdCacheEntry struct {
+	ino   uint64
+	entry gpuEntry
+}
+
+// startTime returns the process start time in clock ticks from
+// /proc/<pid>/stat.
+func (c *collector) startTime(pid int) (uint64, bool) {
+	data, err := c.procRoot.ReadFile(filepath.Join(strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, false
+	}
+
+	return parseStartTime(data)
+}
+
+// parseStartTime extracts field 22 (starttime) from a stat line. The command
+// name may contain spaces and parentheses, so fields are counted from the
+// last closing parenthesis.
+func parseStartTime(data []byte) (uint64, bool) {
+	idx := bytes.LastIndexByte(data, ')')
+	if idx < 0 {
+		return 0, false
+	}
+	fields := bytes.Fields(data[idx+1:])
+	// fields[0] is field 3 (state).
+	const startTimeIndex = 22 - 3
+	if len(fields) <= startTimeIndex {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(string(fields[startTimeIndex]), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return value, true
+}
+
+// isCold reports whether the PID can be skipped in the current scan.
+func (c *collector) isCold(pid int, startTime uint64) bool {
+	entry, ok := c.cold[pid]
+	if !ok || entry.startTime != startTime {
+		return false
+	}
+
+	return c.scans-entry.checkedAt < coldRecheckScans
+}
+
+// matchFD resolves an fd to the GPU it has open and parses its fdinfo. Only
+// DRM fds are cached: their fdinfo is read anyway, and its ino line tells
+// whether the fd still refers to the cached file, which saves the readlink.
+func (c *collector) matchFD(pid int, procDir *os.Root, fdName string, next map[string]fdCacheEntry) (gpuEntry, fdMetrics, bool) {
+	fdinfoPath := filepath.Join("fdinfo", fdName)
+
+	var (
+		metrics fdMetrics
+		read    bool
+	)
+	if cached, ok := c.fdCache[pid][fdName]; ok && !c.noCache {
+		data, err := procDir.ReadFile(fdinfoPath)
+		if err != nil {
+			return gpuEntry{}, fdMetrics{}, false
+		}
+		metrics, read = parseFDInfo(data), true
+		if metrics.HasInode && metrics.Inode == cached.ino {
+			next[fdName] = cached
+
+			return cached.entry, metrics, true
+		}
+	}
+
+	target, err := procDir.Readlink(filepath.Join("fd", fdName))
+	if err != nil {
+		return gpuEntry{}, fdMetrics{}, false
+	}
+	target = strings.TrimSuffix(target, " (deleted)")
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(procDir.Name(), "fd", target)
+	}
+	entry, ok := c.lookup.match(filepath.Clean(target))
+	if !ok {
+		return gpuEntry{}, fdMetrics{}, false
+	}
+
+	if !read {
+		data, err := procDir.ReadFile(fdinfoPath)
+		if err != nil {
+			return gpuEntry{}, fdMetrics{}, false
+		}
+		metrics = parseFDInfo(data)
+	}
+	if metrics.HasInode {
+		next[fdName] = fdCacheEntry{ino: metrics.Inode, entry: entry}
+	}
+
+	return entry, metrics, true
+}
+
+// resetCaches drops all incremental scan state, forcing a full scan.
+func (c *collector) resetCaches() {
+	c.cold = make(map[int]coldEntry)
+	c.fdCache = make(map[int]map[string]fdCacheEntry)
+}
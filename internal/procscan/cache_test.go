@@ -0,0 +1,242 @@
+package procscan
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestParseStartTime(t *testing.T) {
+	stat := "1234 (Web Content) (x)) S 1 1234 1234 0 -1 4194560 100 0 0 0 5 3 0 0 20 0 12 0 987654 1000 50 18446744073709551615\n"
+	got, ok := parseStartTime([]byte(stat))
+	if !ok || got != 987654 {
+		t.Fatalf("parseStartTime = %d, %v", got, ok)
+	}
+	if _, ok := parseStartTime([]byte("1234 (short) S 1 2")); ok {
+		t.Fatalf("expected failure for truncated stat")
+	}
+}
+
+func TestCollectorSkipsColdProcesses(t *testing.T) {
+	root := t.TempDir()
+	renderNode := filepath.Join(t.TempDir(), "renderD128")
+	writeFile(t, renderNode, "")
+
+	procDir := setupProcEntry(t, root, 500)
+	writeStat(t, procDir.root, 500, 1111)
+	if err := procDir.linkFD("0", os.DevNull); err != nil {
+		t.Fatalf("symlink fd: %v", err)
+	}
+
+	coll := newBenchCollector(t, root, renderNode)
+
+	collectGPU := func() int {
+		t.Helper()
+		result, err := coll.collect()
+		if err != nil {
+			t.Fatalf("collect: %v", err)
+		}
+
+		return len(result["card0"].processes)
+	}
+
+	if n := collectGPU(); n != 0 {
+		t.Fatalf("expected no gpu processes, got %d", n)
+	}
+	if _, ok := coll.cold[500]; !ok {
+		t.Fatalf("expected pid 500 to be cached as cold")
+	}
+
+	// The process opens the render node; it is only noticed once the cold
+	// entry is due for a recheck.
+	writeFile(t, procDir.fdinfo("5"), string(readTestdata(t, "fdinfo_mem_engine.txt")))
+	if err := procDir.linkFD("5", renderNode); err != nil {
+		t.Fatalf("symlink fd: %v", err)
+	}
+	for i := 1; i < coldRecheckScans; i++ {
+		if n := collectGPU(); n != 0 {
+			t.Fatalf("scan %d: expected cold pid to be skipped", i)
+		}
+	}
+	if n := collectGPU(); n != 1 {
+		t.Fatalf("expected pid to be picked up on recheck, got %d", n)
+	}
+	if _, ok := coll.cold[500]; ok {
+		t.Fatalf("expected gpu pid to leave the cold cache")
+	}
+	cached, ok := coll.fdCache[500]["5"]
+	if !ok || cached.entry.path != renderNode || cached.ino != 123456 {
+		t.Fatalf("expected render node fd to be cached, got %+v", coll.fdCache[500])
+	}
+
+	// A reused PID with a new start time is inspected immediately.
+	other := setupProcEntry(t, root, 600)
+	writeStat(t, other.root, 600, 2222)
+	if n := collectGPU(); n != 1 {
+		t.Fatalf("unexpected gpu processes %d", n)
+	}
+	if err := os.RemoveAll(other.root); err != nil {
+		t.Fatalf("remove proc dir: %v", err)
+	}
+	other = setupProcEntry(t, root, 600)
+	writeStat(t, other.root, 600, 3333)
+	writeFile(t, other.fdinfo("7"), string(readTestdata(t, "fdinfo_mem_engine.txt")))
+	if err := other.linkFD("7", renderNode); err != nil {
+		t.Fatalf("symlink fd: %v", err)
+	}
+	if n := collectGPU(); n != 2 {
+		t.Fatalf("expected reused pid to be scanned, got %d", n)
+	}
+
+	if err := os.RemoveAll(procDir.root); err != nil {
+		t.Fatalf("remove proc dir: %v", err)
+	}
+	collectGPU()
+	if _, ok := coll.fdCache[500]; ok {
+		t.Fatalf("expected fd cache of exited pid to be pruned")
+	}
+}
+
+func TestCollectorProbeClearsColdEntry(t *testing.T) {
+	root := t.TempDir()
+	renderNode := filepath.Join(t.TempDir(), "renderD128")
+	writeFile(t, renderNode, "")
+
+	procDir := setupProcEntry(t, root, 700)
+	writeStat(t, procDir.root, 700, 4444)
+	coll := newBenchCollector(t, root, renderNode)
+
+	if _, err := coll.collect(); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if _, ok := coll.cold[700]; !ok {
+		t.Fatalf("expected pid 700 to be cached as cold")
+	}
+
+	writeFile(t, procDir.fdinfo("3"), string(readTestdata(t, "fdinfo_mem_engine.txt")))
+	if err := procDir.linkFD("3", renderNode); err != nil {
+		t.Fatalf("symlink fd: %v", err)
+	}
+	if got := coll.collectPIDs([]int{700}); len(got["card0"].processes) != 1 {
+		t.Fatalf("expected probe to find the process, got %+v", got)
+	}
+	if _, ok := coll.cold[700]; ok {
+		t.Fatalf("expected probe to clear the cold entry")
+	}
+
+	result, err := coll.collect()
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if n := len(result["card0"].processes); n != 1 {
+		t.Fatalf("expected probed pid to be scanned right away, got %d", n)
+	}
+}
+
+// BenchmarkCollectUncached is the baseline for the incremental benchmarks:
+// every process is inspected and every fd resolved on each scan.
+func BenchmarkCollectUncached(b *testing.B) {
+	root, renderNode := buildSyntheticProcfs(b, 1000, 10, 16)
+	coll := newBenchCollector(b, root, renderNode)
+	coll.noCache = true
+
+	b.ResetTimer()
+	for b.Loop() {
+		if _, err := coll.collect(); err != nil {
+			b.Fatalf("collect: %v", err)
+		}
+	}
+}
+
+// BenchmarkCollectFull measures a scan that starts from empty caches and
+// fills them.
+func BenchmarkCollectFull(b *testing.B) {
+	root, renderNode := buildSyntheticProcfs(b, 1000, 10, 16)
+	coll := newBenchCollector(b, root, renderNode)
+
+	b.ResetTimer()
+	for b.Loop() {
+		coll.resetCaches()
+		if _, err := coll.collect(); err != nil {
+			b.Fatalf("collect: %v", err)
+		}
+	}
+}
+
+func BenchmarkCollectIncremental(b *testing.B) {
+	root, renderNode := buildSyntheticProcfs(b, 1000, 10, 16)
+	coll := newBenchCollector(b, root, renderNode)
+	if _, err := coll.collect(); err != nil {
+		b.Fatalf("collect: %v", err)
+	}
+
+	b.ResetTimer()
+	for b.Loop() {
+		if _, err := coll.collect(); err != nil {
+			b.Fatalf("collect: %v", err)
+		}
+	}
+}
+
+func newBenchCollector(tb testing.TB, root, renderNode string) *collector {
+	tb.Helper()
+
+	lookup := newGPULookup([]string{"card0"}, map[string]string{"card0": renderNode})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	coll, err := newCollector(root, 0, 64, lookup, logger)
+	if err != nil {
+		tb.Fatalf("newCollector: %v", err)
+	}
+	tb.Cleanup(func() {
+		_ = coll.Close()
+	})
+	coll.userCache[1000] = "alice"
+
+	return coll
+}
+
+// buildSyntheticProcfs lays out total fake processes with fdsPerPID fds each,
+// of which the first gpuPIDs also hold the render node open.
+func buildSyntheticProcfs(tb testing.TB, total, gpuPIDs, fdsPerPID int) (string, string) {
+	tb.Helper()
+
+	root := tb.TempDir()
+	renderNode := filepath.Join(tb.TempDir(), "renderD128")
+	writeFile(tb, renderNode, "")
+	fdinfo := string(readTestdata(tb, "fdinfo_mem_engine.txt"))
+
+	for pid := 100; pid < 100+total; pid++ {
+		dir := filepath.Join(root, strconv.Itoa(pid))
+		mustMkdir(tb, filepath.Join(dir, "fd"))
+		mustMkdir(tb, filepath.Join(dir, "fdinfo"))
+		writeFile(tb, filepath.Join(dir, "comm"), "proc\n")
+		writeFile(tb, filepath.Join(dir, "cmdline"), "proc\x00")
+		writeFile(tb, filepath.Join(dir, "status"), "Name:\tproc\nUid:\t1000\t1000\t1000\t1000\n")
+		writeStat(tb, dir, pid, uint64(pid))
+
+		for fd := 0; fd < fdsPerPID; fd++ {
+			if err := os.Symlink(os.DevNull, filepath.Join(dir, "fd", strconv.Itoa(fd))); err != nil {
+				tb.Fatalf("symlink: %v", err)
+			}
+		}
+		if pid-100 < gpuPIDs {
+			fd := strconv.Itoa(fdsPerPID)
+			if err := os.Symlink(renderNode, filepath.Join(dir, "fd", fd)); err != nil {
+				tb.Fatalf("symlink: %v", err)
+			}
+			writeFile(tb, filepath.Join(dir, "fdinfo", fd), fdinfo)
+		}
+	}
+
+	return root, renderNode
+}
+
+func writeStat(tb testing.TB, dir string, pid int, startTime uint64) {
+	tb.Helper()
+	stat := fmt.Sprintf("%d (proc) S 1 %d %d 0 -1 0 0 0 0 0 0 0 0 0 20 0 1 0 %d 0 0\n", pid, pid, pid, startTime)
+	writeFile(tb, filepath.Join(dir, "stat"), stat)
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,11 +23,19 @@ type rawProcess struct {
 	name        string
 	command     string
 	renderNode  string
+	cgroup      string
+	container   string
+	displayName string
+	category    string
+	steamAppID  string
 	vramBytes   uint64
 	gttBytes    uint64
 	hasMemory   bool
 	engineTotal uint64
 	hasEngine   bool
+	// startTime is the start time from /proc/<pid>/stat in clock ticks,
+	// 0 when unknown.
+	startTime uint64
 }
 
 type gpuCollection struct {
@@ -42,6 +51,18 @@ type collector struct {
 	lookup    *gpuLookup
 	logger    *slog.Logger
 	userCache map[int]string
+	enricher  *enricher
+	// denied counts processes skipped during the last scan because their
+	// fd table could not be read.
+	denied int
+	// scans counts completed collect calls; cold and fdCache carry state
+	// between them so quiet processes are not re-inspected every tick.
+	scans   uint64
+	cold    map[int]coldEntry
+	fdCache map[int]map[string]fdCacheEntry
+	// noCache inspects every process and resolves every fd on each scan.
+	// It exists to measure the caches against.
+	noCache   bool
 	closeOnce sync.Once
 	closeErr  error
 }
@@ -67,6 +88,8 @@ func newCollector(procRoot string, maxPIDs, maxFDs int, lookup *gpuLookup, logge
 		lookup:    lookup,
 		logger:    logger,
 		userCache: make(map[int]string),
+		cold:      make(map[int]coldEntry),
+		fdCache:   make(map[int]map[string]fdCacheEntry),
 	}, nil
 }
 
@@ -78,11 +101,12 @@ func (c *collector) collect() (map[string]gpuCollection, error) {
 
 	results := make(map[string]gpuCollection)
 	var scanned int
+	c.denied = 0
+	c.scans++
 
+	listed := make(map[int]struct{}, len(entries))
+	hot := make(map[int]struct{})
 	for _, entry := range entries {
-		if c.maxPIDs > 0 && scanned >= c.maxPIDs {
-			break
-		}
 		if !entry.IsDir() {
 			continue
 		}
@@ -90,41 +114,106 @@ func (c *collector) collect() (map[string]gpuCollection, error) {
 		if err != nil || pid <= 0 {
 			continue
 		}
+		listed[pid] = struct{}{}
 
-		procDir, err := c.procRoot.OpenRoot(entry.Name())
-		if err != nil {
+		if c.maxPIDs > 0 && scanned >= c.maxPIDs {
 			continue
 		}
 
-		procs := c.scanProcess(pid, procDir)
-		if err := procDir.Close(); err != nil {
-			c.logger.Debug("failed to close proc dir", "pid", pid, "err", err)
+		// The start time is only needed to trust a cold entry, so processes
+		// holding DRM fds cost no extra stat read.
+		var (
+			startTime uint64
+			hasStart  bool
+		)
+		if _, ok := c.cold[pid]; ok {
+			startTime, hasStart = c.startTime(pid)
+			if hasStart && c.isCold(pid, startTime) {
+				continue
+			}
 		}
 
-		if len(procs) == 0 {
+		deniedBefore := c.denied
+		if c.collectPID(pid, results) {
+			scanned++
+			hot[pid] = struct{}{}
+
 			continue
 		}
-
-		for gpuID, procList := range procs {
-			col := results[gpuID]
-			col.processes = append(col.processes, procList...)
-			for _, raw := range procList {
-				if raw.hasMemory {
-					col.hasMemory = true
-				}
-				if raw.hasEngine {
-					col.hasEngine = true
-				}
-			}
-			results[gpuID] = col
+		if c.noCache || c.denied != deniedBefore {
+			continue
+		}
+		if !hasStart {
+			startTime, hasStart = c.startTime(pid)
 		}
+		if hasStart {
+			c.cold[pid] = coldEntry{startTime: startTime, checkedAt: c.scans}
+		}
+	}
 
-		scanned++
+	for pid := range c.cold {
+		if _, ok := listed[pid]; !ok {
+			delete(c.cold, pid)
+		}
+	}
+	for pid := range c.fdCache {
+		if _, ok := hot[pid]; !ok {
+			delete(c.fdCache, pid)
+		}
 	}
 
 	return results, nil
 }
 
+// collectPIDs scans only the supplied PIDs, skipping ones that are already gone.
+func (c *collector) collectPIDs(pids []int) map[string]gpuCollection {
+	results := make(map[string]gpuCollection)
+	for _, pid := range pids {
+		if pid <= 0 {
+			continue
+		}
+		c.collectPID(pid, results)
+	}
+
+	return results
+}
+
+func (c *collector) collectPID(pid int, results map[string]gpuCollection) bool {
+	name := strconv.Itoa(pid)
+	procDir, err := c.procRoot.OpenRoot(name)
+	if err != nil {
+		return false
+	}
+
+	procs := c.scanProcess(pid, procDir)
+	if err := procDir.Close(); err != nil {
+		c.logger.Debug("failed to close proc dir", "pid", pid, "err", err)
+	}
+
+	if len(procs) == 0 {
+		return false
+	}
+	// Probes find processes between scans; one holding a DRM fd must not
+	// stay skipped as cold.
+	delete(c.cold, pid)
+
+	for gpuID, procList := range procs {
+		col := results[gpuID]
+		col.processes = append(col.processes, procList...)
+		for _, raw := range procList {
+			if raw.hasMemory {
+				col.hasMemory = true
+			}
+			if raw.hasEngine {
+				col.hasEngine = true
+			}
+		}
+		results[gpuID] = col
+	}
+
+	return true
+}
+
 func (c *collector) scanProcess(pid int, procDir *os.Root) map[string][]rawProcess {
 	comm, err := readTrimmed(procDir, "comm")
 	if err != nil {
@@ -146,13 +235,22 @@ func (c *collector) scanProcess(pid int, procDir *os.Root) map[string][]rawProce
 
 	fdEntries, err := fs.ReadDir(procDir.FS(), "fd")
 	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			c.denied++
+		}
+
 		return nil
 	}
 
 	result := make(map[string]*rawProcess)
 	clientTotals := make(map[string]map[int]clientMemory)
 	fdCount := 0
-	fdBasePath := filepath.Join(procDir.Name(), "fd")
+	fdTargets := make(map[string]fdCacheEntry, len(fdEntries))
+	defer func() {
+		if len(result) > 0 {
+			c.fdCache[pid] = fdTargets
+		}
+	}()
 
 	for _, fdEntry := range fdEntries {
 		if c.maxFDs > 0 && fdCount >= c.maxFDs {
@@ -160,31 +258,11 @@ func (c *collector) scanProcess(pid int, procDir *os.Root) map[string][]rawProce
 		}
 		fdCount++
 
-		fdName := fdEntry.Name()
-		fdPath := filepath.Join("fd", fdName)
-		target, err := procDir.Readlink(fdPath)
-		if err != nil {
-			continue
-		}
-		target = strings.TrimSuffix(target, " (deleted)")
-		if !filepath.IsAbs(target) {
-			target = filepath.Clean(filepath.Join(fdBasePath, target))
-		} else {
-			target = filepath.Clean(target)
-		}
-
-		entry, ok := c.lookup.match(target)
+		entry, metrics, ok := c.matchFD(pid, procDir, fdEntry.Name(), fdTargets)
 		if !ok {
 			continue
 		}
 
-		fdinfoPath := filepath.Join("fdinfo", fdName)
-		data, err := procDir.ReadFile(fdinfoPath)
-		if err != nil {
-			continue
-		}
-		metrics := parseFDInfo(data)
-
 		raw := result[entry.gpuID]
 		if raw == nil {
 			raw = &rawProcess{
@@ -239,8 +317,33 @@ func (c *collector) scanProcess(pid int, procDir *os.Root) map[string][]rawProce
 		return nil
 	}
 
+	var cgroup, container string
+	if data, err := procDir.ReadFile("cgroup"); err == nil {
+		cgroup, container = parseCgroup(data)
+	}
+	var startTime uint64
+	if data, err := procDir.ReadFile("stat"); err == nil {
+		startTime, _ = parseStartTime(data)
+	}
+
+	var id identity
+	if c.enricher != nil {
+		// Enrichment relies on the environment, which is only readable for
+		// processes of the same user or with CAP_SYS_PTRACE.
+		if environ, err := procDir.ReadFile("environ"); err == nil {
+			exe, _ := procDir.Readlink("exe")
+			id = c.enricher.identify(comm, exe, command, environ)
+		}
+	}
+
 	out := make(map[string][]rawProcess, len(result))
 	for gpuID, raw := range result {
+		raw.cgroup = cgroup
+		raw.container = container
+		raw.displayName = id.displayName
+		raw.category = id.category
+		raw.steamAppID = id.steamAppID
+		raw.startTime = startTime
 		out[gpuID] = append(out[gpuID], *raw)
 	}
 
@@ -349,6 +452,50 @@ func readUID(root *os.Root, name string) (int, error) {
 	return 0, errors.New("uid not found")
 }
 
+// parseCgroup returns the process cgroup path (preferring the unified v2
+// hierarchy) and a short container identifier when the path belongs to one.
+func parseCgroup(data []byte) (string, string) {
+	var path string
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			path = parts[2]
+
+			break
+		}
+		if path == "" {
+			path = parts[2]
+		}
+	}
+
+	return path, containerFromCgroup(path)
+}
+
+func containerFromCgroup(path string) string {
+	if id := containerIDPattern.FindString(path); id != "" {
+		return id[:12]
+	}
+	for _, marker := range []string{"/lxc.payload.", "/lxc/"} {
+		idx := strings.Index(path, marker)
+		if idx < 0 {
+			continue
+		}
+		name := path[idx+len(marker):]
+		if end := strings.IndexByte(name, '/'); end >= 0 {
+			name = name[:end]
+		}
+
+		return name
+	}
+
+	return ""
+}
+
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
 func formatCmdline(data []byte) string {
 	if len(data) == 0 {
 		return ""
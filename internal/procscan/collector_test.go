@@ -17,6 +17,7 @@ func TestCollectorCollectsProcessMemoryAndEngine(t *testing.T) {
 	writeFile(t, filepath.Join(procDir, "comm"), "testproc\n")
 	writeFile(t, filepath.Join(procDir, "cmdline"), "test\x00--flag\x00")
 	writeFile(t, filepath.Join(procDir, "status"), "Name:\ttestproc\nUid:\t1000\t1000\t1000\t1000\n")
+	writeFile(t, filepath.Join(procDir, "cgroup"), "0::/user.slice/user-1000.slice/session-2.scope\n")
 
 	fdinfoData := readTestdata(t, "fdinfo_mem_engine.txt")
 	writeFile(t, filepath.Join(procDir, "fdinfo", "5"), string(fdinfoData))
@@ -56,6 +57,9 @@ func TestCollectorCollectsProcessMemoryAndEngine(t *testing.T) {
 	if proc.pid != 1234 {
 		t.Fatalf("unexpected pid %d", proc.pid)
 	}
+	if proc.cgroup != "/user.slice/user-1000.slice/session-2.scope" || proc.container != "" {
+		t.Fatalf("unexpected cgroup %q / container %q", proc.cgroup, proc.container)
+	}
 	if proc.uid != 1000 {
 		t.Fatalf("unexpected uid %d", proc.uid)
 	}
@@ -229,16 +233,84 @@ drm-memory:
 	}
 }
 
-func mustMkdir(t *testing.T, path string) {
+func mustMkdir(t testing.TB, path string) {
 	t.Helper()
 	if err := os.MkdirAll(path, 0o750); err != nil {
 		t.Fatalf("mkdir %s: %v", path, err)
 	}
 }
 
-func writeFile(t *testing.T, path, contents string) {
+func writeFile(t testing.TB, path, contents string) {
 	t.Helper()
 	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
 		t.Fatalf("write file %s: %v", path, err)
 	}
 }
+
+func TestParseCgroup(t *testing.T) {
+	dockerID := "4f1c2b9a8d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a"
+	cases := []struct {
+		name      string
+		data      string
+		path      string
+		container string
+	}{
+		{name: "host v2", data: "0::/user.slice\n", path: "/user.slice"},
+		{
+			name:      "docker v2",
+			data:      "0::/system.slice/docker-" + dockerID + ".scope\n",
+			path:      "/system.slice/docker-" + dockerID + ".scope",
+			container: dockerID[:12],
+		},
+		{
+			name:      "hybrid prefers unified",
+			data:      "12:memory:/docker/" + dockerID + "\n0::/lxc.payload.build/init.scope\n",
+			path:      "/lxc.payload.build/init.scope",
+			container: "build",
+		},
+		{name: "v1 only", data: "4:devices:/lxc/web\n", path: "/lxc/web", container: "web"},
+		{name: "empty", data: ""},
+	}
+
+	for _, tc := range cases {
+		path, container := parseCgroup([]byte(tc.data))
+		if path != tc.path || container != tc.container {
+			t.Fatalf("%s: got (%q, %q), want (%q, %q)", tc.name, path, container, tc.path, tc.container)
+		}
+	}
+}
+
+func TestCollectorCountsPermissionDenied(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permission checks do not apply to root")
+	}
+
+	root := t.TempDir()
+	procDir := filepath.Join(root, "4242")
+	mustMkdir(t, filepath.Join(procDir, "fd"))
+	writeFile(t, filepath.Join(procDir, "comm"), "secret\n")
+	writeFile(t, filepath.Join(procDir, "status"), "Name:\tsecret\nUid:\t0\t0\t0\t0\n")
+	if err := os.Chmod(filepath.Join(procDir, "fd"), 0); err != nil {
+		t.Fatalf("chmod fd: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chmod(filepath.Join(procDir, "fd"), 0o750)
+	})
+
+	lookup := newGPULookup([]string{"card0"}, map[string]string{"card0": "/dev/dri/renderD128"})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	coll, err := newCollector(root, 10, 16, lookup, logger)
+	if err != nil {
+		t.Fatalf("newCollector: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = coll.Close()
+	})
+
+	if _, err := coll.collect(); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if coll.denied != 1 {
+		t.Fatalf("expected one denied process, got %d", coll.denied)
+	}
+}
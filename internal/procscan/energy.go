@@ -0,0 +1,138 @@
+package procscan
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	joulesPerWh = 3600
+	// energyGapScans bounds the time one scan's power reading is charged
+	// for, in scan intervals. After an idle period of the lazy scanner the
+	// current draw says nothing about the hours before it.
+	energyGapScans = 3
+)
+
+// PowerSource reports the current board power draw of a GPU in watts. The
+// boolean is false when no fresh reading is available.
+type PowerSource func(gpuID string) (float64, bool)
+
+// energyLedger accumulates estimated energy per GPU, user and application
+// for the lifetime of the process scanner.
+type energyLedger struct {
+	since time.Time
+
+	mu   sync.Mutex
+	gpus map[string]*gpuEnergy
+}
+
+type gpuEnergy struct {
+	totalJ float64
+	users  map[string]float64
+	apps   map[appKey]float64
+}
+
+type appKey struct {
+	user string
+	name string
+}
+
+func newEnergyLedger(now time.Time) *energyLedger {
+	return &energyLedger{
+		since: now,
+		gpus:  make(map[string]*gpuEnergy),
+	}
+}
+
+func (l *energyLedger) add(gpuID, user, name string, joules float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.gpus[gpuID]
+	if !ok {
+		entry = &gpuEnergy{
+			users: make(map[string]float64),
+			apps:  make(map[appKey]float64),
+		}
+		l.gpus[gpuID] = entry
+	}
+	entry.totalJ += joules
+	entry.users[user] += joules
+	entry.apps[appKey{user: user, name: name}] += joules
+}
+
+func (l *energyLedger) snapshot(gpuID string) EnergySnapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	snapshot := EnergySnapshot{
+		GPUId:     gpuID,
+		Since:     l.since.UTC(),
+		Users:     []UserEnergy{},
+		Processes: []AppEnergy{},
+	}
+
+	entry, ok := l.gpus[gpuID]
+	if !ok {
+		return snapshot
+	}
+
+	snapshot.EnergyJ = entry.totalJ
+	snapshot.EnergyWh = entry.totalJ / joulesPerWh
+	for user, joules := range entry.users {
+		snapshot.Users = append(snapshot.Users, UserEnergy{User: user, EnergyJ: joules, EnergyWh: joules / joulesPerWh})
+	}
+	for key, joules := range entry.apps {
+		snapshot.Processes = append(snapshot.Processes, AppEnergy{User: key.user, Name: key.name, EnergyJ: joules, EnergyWh: joules / joulesPerWh})
+	}
+
+	sort.Slice(snapshot.Users, func(i, j int) bool {
+		if snapshot.Users[i].EnergyJ == snapshot.Users[j].EnergyJ {
+			return snapshot.Users[i].User < snapshot.Users[j].User
+		}
+
+		return snapshot.Users[i].EnergyJ > snapshot.Users[j].EnergyJ
+	})
+	sort.Slice(snapshot.Processes, func(i, j int) bool {
+		a, b := snapshot.Processes[i], snapshot.Processes[j]
+		if a.EnergyJ == b.EnergyJ {
+			if a.Name == b.Name {
+				return a.User < b.User
+			}
+
+			return a.Name < b.Name
+		}
+
+		return a.EnergyJ > b.EnergyJ
+	})
+
+	return snapshot
+}
+
+// estimatePower gives each process the fraction of board power matching the
+// fraction of the last scan interval it kept the GPU busy
+// (GPUTimeMSPerS/1000). The power of idle time is left unattributed, so a
+// nearly idle process gets a near-zero estimate. When engine time adds up to
+// more than the interval, e.g. across several engines, the whole board power
+// is split in proportion to engine time.
+func estimatePower(processes []Process, boardW float64) []float64 {
+	shares := make([]float64, len(processes))
+	if boardW <= 0 {
+		return shares
+	}
+	var total float64
+	for _, proc := range processes {
+		if proc.GPUTimeMSPerS != nil && *proc.GPUTimeMSPerS > 0 {
+			total += *proc.GPUTimeMSPerS
+		}
+	}
+	total = max(total, 1000)
+	for i, proc := range processes {
+		if proc.GPUTimeMSPerS != nil && *proc.GPUTimeMSPerS > 0 {
+			shares[i] = boardW * *proc.GPUTimeMSPerS / total
+		}
+	}
+
+	return shares
+}
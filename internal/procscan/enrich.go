@@ -0,0 +1,176 @@
+package procscan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Rule maps processes to a friendly name and category. Match is a regular
+// expression applied to Field: "exe", "cmdline", "name" or "steam_app_id".
+// An empty Field matches against either the executable path or the command
+// line.
+type Rule struct {
+	Match       string `json:"match"`
+	Field       string `json:"field,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Category    string `json:"category,omitempty"`
+}
+
+// Well-known categories assigned by the built-in rules.
+const (
+	CategoryGame    = "game"
+	CategoryBrowser = "browser"
+	CategoryML      = "ml"
+	CategoryEncoder = "encoder"
+)
+
+// steamEnvKeys lists environment variables carrying a Steam app ID, in order
+// of preference.
+var steamEnvKeys = []string{"SteamAppId", "STEAM_COMPAT_APP_ID", "SteamGameId"}
+
+var defaultRules = []Rule{
+	{Field: "exe", Match: `(^|/)(firefox|firefox-bin|librewolf)$`, DisplayName: "Firefox", Category: CategoryBrowser},
+	{Field: "exe", Match: `(^|/)(chrome|chromium|chromium-browser|brave|msedge|vivaldi-bin)$`, Category: CategoryBrowser},
+	{Field: "exe", Match: `(^|/)(ffmpeg|obs|HandBrakeCLI|gst-launch-1\.0)$`, Category: CategoryEncoder},
+	{Field: "exe", Match: `(^|/)(ollama|llama-server|llama-cli)$`, Category: CategoryML},
+	{Field: "cmdline", Match: `(?i)\b(torch|tensorflow|vllm|comfyui|stable[-_]diffusion)\b`, Category: CategoryML},
+	{Field: "cmdline", Match: `(wine64-preloader|wine-preloader|/proton\b|steamapps/common/)`, Category: CategoryGame},
+}
+
+// LoadRules reads a JSON array of rules from path.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rules file: %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("decode rules file: %w", err)
+	}
+
+	return rules, nil
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// enricher derives a display name and category for GPU processes.
+type enricher struct {
+	rules []compiledRule
+}
+
+type identity struct {
+	displayName string
+	category    string
+	steamAppID  string
+}
+
+// newEnricher compiles the supplied rules ahead of the built-in defaults.
+func newEnricher(rules []Rule) (*enricher, error) {
+	all := make([]Rule, 0, len(rules)+len(defaultRules))
+	all = append(all, rules...)
+	all = append(all, defaultRules...)
+
+	compiled := make([]compiledRule, 0, len(all))
+	for i, rule := range all {
+		switch rule.Field {
+		case "", "exe", "cmdline", "name", "steam_app_id":
+		default:
+			return nil, fmt.Errorf("rule %d: unsupported field %q", i, rule.Field)
+		}
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: compile %q: %w", i, rule.Match, err)
+		}
+		compiled = append(compiled, compiledRule{Rule: rule, re: re})
+	}
+
+	return &enricher{rules: compiled}, nil
+}
+
+// identify applies the rules to a process. The first matching rule wins.
+func (e *enricher) identify(name, exe, cmdline string, environ []byte) identity {
+	id := identity{steamAppID: steamAppID(environ)}
+
+	for _, rule := range e.rules {
+		if !rule.matches(name, exe, cmdline, id.steamAppID) {
+			continue
+		}
+		id.displayName = rule.DisplayName
+		id.category = rule.Category
+
+		break
+	}
+
+	if id.steamAppID != "" && id.category == "" {
+		id.category = CategoryGame
+	}
+	if id.category == CategoryGame && id.displayName == "" {
+		id.displayName = windowsExeName(cmdline)
+		if id.displayName == "" && id.steamAppID != "" {
+			id.displayName = "Steam app " + id.steamAppID
+		}
+	}
+
+	return id
+}
+
+func (r compiledRule) matches(name, exe, cmdline, steamApp string) bool {
+	switch r.Field {
+	case "exe":
+		return exe != "" && r.re.MatchString(exe)
+	case "cmdline":
+		return cmdline != "" && r.re.MatchString(cmdline)
+	case "name":
+		return name != "" && r.re.MatchString(name)
+	case "steam_app_id":
+		return steamApp != "" && r.re.MatchString(steamApp)
+	default:
+		return (exe != "" && r.re.MatchString(exe)) || (cmdline != "" && r.re.MatchString(cmdline))
+	}
+}
+
+func steamAppID(environ []byte) string {
+	values := make(map[string]string, len(steamEnvKeys))
+	for _, entry := range strings.Split(string(environ), "\x00") {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		for _, wanted := range steamEnvKeys {
+			if key == wanted {
+				values[key] = value
+			}
+		}
+	}
+	for _, key := range steamEnvKeys {
+		if value := strings.TrimSpace(values[key]); value != "" && value != "0" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+var windowsExePattern = regexp.MustCompile(`(?i)[^\s"]+\.exe\b`)
+
+// windowsExeName extracts the executable name Wine/Proton was started with.
+func windowsExeName(cmdline string) string {
+	match := windowsExePattern.FindString(cmdline)
+	if match == "" {
+		return ""
+	}
+	match = path.Base(strings.ReplaceAll(match, `\`, "/"))
+	if strings.EqualFold(match, "proton.exe") || strings.EqualFold(match, "steam.exe") {
+		return ""
+	}
+
+	return match
+}
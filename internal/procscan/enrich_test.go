@@ -0,0 +1,179 @@
+package procscan
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnricherIdentify(t *testing.T) {
+	enr, err := newEnricher([]Rule{
+		{Field: "steam_app_id", Match: `^570$`, DisplayName: "Dota 2", Category: CategoryGame},
+		{Match: `blender`, DisplayName: "Blender", Category: "3d"},
+	})
+	if err != nil {
+		t.Fatalf("newEnricher: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		comm     string
+		exe      string
+		cmdline  string
+		environ  string
+		wantName string
+		wantCat  string
+		wantApp  string
+	}{
+		{
+			name:     "steam rule",
+			comm:     "dota2",
+			exe:      "/games/dota2",
+			environ:  "HOME=/home/a\x00SteamAppId=570\x00",
+			wantName: "Dota 2",
+			wantCat:  CategoryGame,
+			wantApp:  "570",
+		},
+		{
+			name:     "proton exe fallback",
+			comm:     "GameThread",
+			exe:      "/usr/lib/wine/wine64-preloader",
+			cmdline:  `Z:\games\Foo\Foo-Win64-Shipping.exe -dx12`,
+			environ:  "STEAM_COMPAT_APP_ID=1234\x00SteamGameId=1234\x00",
+			wantName: "Foo-Win64-Shipping.exe",
+			wantCat:  CategoryGame,
+			wantApp:  "1234",
+		},
+		{
+			name:     "steam id only",
+			comm:     "game",
+			exe:      "/opt/game/game",
+			environ:  "SteamGameId=4321\x00",
+			wantName: "Steam app 4321",
+			wantCat:  CategoryGame,
+			wantApp:  "4321",
+		},
+		{
+			name:     "custom rule on cmdline",
+			comm:     "python3",
+			exe:      "/usr/bin/python3",
+			cmdline:  "/usr/bin/blender --background",
+			wantName: "Blender",
+			wantCat:  "3d",
+		},
+		{
+			name:     "builtin browser",
+			comm:     "firefox-bin",
+			exe:      "/usr/lib/firefox/firefox-bin",
+			wantName: "Firefox",
+			wantCat:  CategoryBrowser,
+		},
+		{
+			name:    "builtin ml",
+			comm:    "python3",
+			exe:     "/usr/bin/python3",
+			cmdline: "python3 -c import torch",
+			wantCat: CategoryML,
+		},
+		{
+			name: "unknown",
+			comm: "glxgears",
+			exe:  "/usr/bin/glxgears",
+		},
+	}
+
+	for _, tc := range cases {
+		got := enr.identify(tc.comm, tc.exe, tc.cmdline, []byte(tc.environ))
+		if got.displayName != tc.wantName || got.category != tc.wantCat || got.steamAppID != tc.wantApp {
+			t.Fatalf("%s: got %+v, want name=%q category=%q app=%q", tc.name, got, tc.wantName, tc.wantCat, tc.wantApp)
+		}
+	}
+}
+
+func TestNewEnricherRejectsInvalidRules(t *testing.T) {
+	if _, err := newEnricher([]Rule{{Match: `(`}}); err == nil {
+		t.Fatalf("expected error for invalid regexp")
+	}
+	if _, err := newEnricher([]Rule{{Field: "pid", Match: `1`}}); err == nil {
+		t.Fatalf("expected error for unsupported field")
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	writeFile(t, path, `[{"field":"exe","match":"obs$","display_name":"OBS","category":"encoder"}]`)
+
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules: %v", err)
+	}
+	if len(rules) != 1 || rules[0].DisplayName != "OBS" || rules[0].Field != "exe" {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	writeFile(t, path, `{"rules":`)
+	if _, err := LoadRules(path); err == nil {
+		t.Fatalf("expected decode error")
+	}
+}
+
+func TestCollectorEnrichesFromEnviron(t *testing.T) {
+	root := t.TempDir()
+	procDir := filepath.Join(root, "4321")
+	mustMkdir(t, filepath.Join(procDir, "fd"))
+	mustMkdir(t, filepath.Join(procDir, "fdinfo"))
+
+	writeFile(t, filepath.Join(procDir, "comm"), "GameThread\n")
+	writeFile(t, filepath.Join(procDir, "cmdline"), "Z:\\games\\Bar\\Bar.exe\x00")
+	writeFile(t, filepath.Join(procDir, "status"), "Name:\tGameThread\nUid:\t1000\t1000\t1000\t1000\n")
+	writeFile(t, filepath.Join(procDir, "environ"), "SteamAppId=99\x00")
+	writeFile(t, filepath.Join(procDir, "fdinfo", "5"), string(readTestdata(t, "fdinfo_mem_engine.txt")))
+	if err := os.Symlink("/dev/dri/renderD128", filepath.Join(procDir, "fd", "5")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	lookup := newGPULookup([]string{"card0"}, map[string]string{"card0": "/dev/dri/renderD128"})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	coll, err := newCollector(root, 10, 16, lookup, logger)
+	if err != nil {
+		t.Fatalf("newCollector: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = coll.Close()
+	})
+	coll.userCache[1000] = "alice"
+
+	result, err := coll.collect()
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if proc := result["card0"].processes[0]; proc.displayName != "" || proc.category != "" {
+		t.Fatalf("expected no enrichment when disabled, got %+v", proc)
+	}
+
+	coll.enricher, err = newEnricher(nil)
+	if err != nil {
+		t.Fatalf("newEnricher: %v", err)
+	}
+	result, err = coll.collect()
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	proc := result["card0"].processes[0]
+	if proc.displayName != "Bar.exe" || proc.category != CategoryGame || proc.steamAppID != "99" {
+		t.Fatalf("unexpected enrichment %+v", proc)
+	}
+
+	if err := os.Remove(filepath.Join(procDir, "environ")); err != nil {
+		t.Fatalf("remove environ: %v", err)
+	}
+	result, err = coll.collect()
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if proc := result["card0"].processes[0]; proc.displayName != "" || proc.category != "" {
+		t.Fatalf("expected no enrichment without environ, got %+v", proc)
+	}
+}
@@ -15,6 +15,9 @@ type fdMetrics struct {
 	EngineTotal uint64
 	HasEngine   bool
 	ClientID    int
+	// Inode is the inode of the file the fd refers to, from the ino line.
+	Inode    uint64
+	HasInode bool
 }
 
 func parseFDInfo(data []byte) fdMetrics {
@@ -81,10 +84,16 @@ func parseFDInfo(data []byte) fdMetrics {
 				metrics.HasEngine = true
 			}
 		default:
-			if strings.HasPrefix(lower, "drm-client-id") {
+			switch {
+			case strings.HasPrefix(lower, "drm-client-id"):
 				if value, ok := parseIntValue(trimmed); ok {
 					metrics.ClientID = value
 				}
+			case strings.HasPrefix(lower, "ino:"):
+				if value, err := strconv.ParseUint(strings.TrimSpace(trimmed[len("ino:"):]), 10, 64); err == nil {
+					metrics.Inode = value
+					metrics.HasInode = true
+				}
 			}
 		}
 	}
@@ -48,7 +48,7 @@ func TestParseFDInfoMemoryOnly(t *testing.T) {
 	}
 }
 
-func readTestdata(t *testing.T, name string) []byte {
+func readTestdata(t testing.TB, name string) []byte {
 	t.Helper()
 	path := filepath.Join("testdata", name)
 	// #nosec G304 -- reading controlled testdata fixtures.
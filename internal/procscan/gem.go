@@ -0,0 +1,151 @@
+package procscan
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const gemInfoFile = "amdgpu_gem_info"
+
+// boBucketLimits are the upper bounds of the BO size histogram buckets; the
+// last bucket is unbounded.
+var boBucketLimits = []uint64{64 << 10, 1 << 20, 16 << 20, 256 << 20}
+
+// gemUsage sums the buffer objects a process holds on a GPU.
+type gemUsage struct {
+	command   string
+	vramBytes uint64
+	gttBytes  uint64
+	histogram []BOBucket
+}
+
+// gemSource reads amdgpu_gem_info from debugfs for each tracked card.
+type gemSource struct {
+	root  *os.Root
+	cards map[string]string
+}
+
+func newGEMSource(debugfsRoot string, gpuIDs []string) (*gemSource, error) {
+	root, err := os.OpenRoot(debugfsRoot)
+	if err != nil {
+		return nil, fmt.Errorf("open debugfs root: %w", err)
+	}
+
+	cards := make(map[string]string, len(gpuIDs))
+	for _, gpuID := range gpuIDs {
+		index, ok := strings.CutPrefix(gpuID, "card")
+		if !ok {
+			continue
+		}
+		if _, err := strconv.Atoi(index); err != nil {
+			continue
+		}
+		cards[gpuID] = filepath.Join("dri", index, gemInfoFile)
+	}
+
+	return &gemSource{root: root, cards: cards}, nil
+}
+
+// read returns per-PID usage for the GPU, or false when the file is not
+// readable (debugfs not mounted or insufficient privileges).
+func (g *gemSource) read(gpuID string) (map[int]*gemUsage, bool) {
+	path, ok := g.cards[gpuID]
+	if !ok {
+		return nil, false
+	}
+	data, err := g.root.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	return parseGEMInfo(data), true
+}
+
+func (g *gemSource) Close() error {
+	return g.root.Close()
+}
+
+// parseGEMInfo parses amdgpu_gem_info, which lists the BOs of each open DRM
+// file grouped under "pid <n> command <name>:" headers:
+//
+//	pid     1234 command glxgears:
+//		0x00000001:      2097152 byte VRAM NO_CPU_ACCESS
+//		0x00000002:         4096 byte  GTT CPU_GTT_USWC
+//
+// A process with several open files appears once per file.
+func parseGEMInfo(data []byte) map[int]*gemUsage {
+	usage := make(map[int]*gemUsage)
+	var current *gemUsage
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if fields[0] == "pid" {
+			current = nil
+			if len(fields) < 2 {
+				continue
+			}
+			pid, err := strconv.Atoi(fields[1])
+			if err != nil || pid <= 0 {
+				continue
+			}
+			current = usage[pid]
+			if current == nil {
+				current = &gemUsage{histogram: newBOHistogram()}
+				usage[pid] = current
+			}
+			if len(fields) >= 4 && fields[2] == "command" {
+				current.command = strings.TrimSuffix(strings.Join(fields[3:], " "), ":")
+			}
+
+			continue
+		}
+
+		if current == nil || len(fields) < 4 || !strings.HasSuffix(fields[0], ":") || fields[2] != "byte" {
+			continue
+		}
+		size, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[3] {
+		case "VRAM":
+			current.vramBytes += size
+		case "GTT":
+			current.gttBytes += size
+		}
+		current.histogram[boBucketIndex(size)].Count++
+		current.histogram[boBucketIndex(size)].Bytes += size
+	}
+
+	return usage
+}
+
+func newBOHistogram() []BOBucket {
+	buckets := make([]BOBucket, len(boBucketLimits)+1)
+	for i, limit := range boBucketLimits {
+		buckets[i].MaxBytes = limit
+	}
+
+	return buckets
+}
+
+func boBucketIndex(size uint64) int {
+	for i, limit := range boBucketLimits {
+		if size <= limit {
+			return i
+		}
+	}
+
+	return len(boBucketLimits)
+}
@@ -0,0 +1,110 @@
+package procscan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/config"
+	"github.com/skobkin/amdgputop-web/internal/gpu"
+)
+
+func TestParseGEMInfo(t *testing.T) {
+	usage := parseGEMInfo(readTestdata(t, "amdgpu_gem_info.txt"))
+	if len(usage) != 2 {
+		t.Fatalf("expected 2 pids, got %d", len(usage))
+	}
+
+	glx := usage[4321]
+	if glx == nil || glx.command != "glxgears" {
+		t.Fatalf("unexpected glxgears usage %+v", glx)
+	}
+	if glx.vramBytes != 2097152+65536 {
+		t.Fatalf("unexpected vram %d", glx.vramBytes)
+	}
+	if glx.gttBytes != 4096+33554432 {
+		t.Fatalf("unexpected gtt %d", glx.gttBytes)
+	}
+	wantCounts := []int{2, 0, 1, 1, 0}
+	for i, bucket := range glx.histogram {
+		if bucket.Count != wantCounts[i] {
+			t.Fatalf("bucket %d: expected %d BOs, got %d", i, wantCounts[i], bucket.Count)
+		}
+	}
+	if glx.histogram[0].MaxBytes != 64<<10 || glx.histogram[4].MaxBytes != 0 {
+		t.Fatalf("unexpected bucket bounds %+v", glx.histogram)
+	}
+
+	xorg := usage[900]
+	if xorg == nil || xorg.vramBytes != 536870912 || xorg.histogram[4].Count != 1 {
+		t.Fatalf("unexpected Xorg usage %+v", xorg)
+	}
+}
+
+func TestManagerFallsBackToGEMInfo(t *testing.T) {
+	root := t.TempDir()
+	procDir := setupProcEntry(t, root, 4321)
+	writeFile(t, procDir.fdinfo("5"), "drm-engine:\n\tgfx: 100 ns\n")
+	if err := procDir.linkFD("5", "/dev/dri/renderD128"); err != nil {
+		t.Fatalf("symlink fd: %v", err)
+	}
+
+	debugRoot := t.TempDir()
+	mustMkdir(t, filepath.Join(debugRoot, "dri", "0"))
+	writeFile(t, filepath.Join(debugRoot, "dri", "0", gemInfoFile), string(readTestdata(t, "amdgpu_gem_info.txt")))
+
+	cfg := config.ProcConfig{
+		Enable:       true,
+		ScanInterval: 2 * time.Second,
+		MaxPIDs:      10,
+		MaxFDsPerPID: 16,
+	}
+	gpus := []gpu.Info{{ID: "card0", RenderNode: "/dev/dri/renderD128"}}
+
+	manager, err := NewManager(cfg, root, gpus, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+	manager.collector.userCache[1000] = "alice"
+
+	manager.performScan(time.Unix(100, 0))
+	snapshot, _ := manager.Latest("card0")
+	if snapshot.Capabilities.VRAMGTTFromGEMInfo || snapshot.Processes[0].VRAMBytes != nil {
+		t.Fatalf("expected no memory without gem info, got %+v", snapshot)
+	}
+
+	if err := manager.EnableGEMInfo(filepath.Join(debugRoot, "missing")); err == nil {
+		t.Fatalf("expected error for missing debugfs root")
+	}
+	if err := manager.EnableGEMInfo(debugRoot); err != nil {
+		t.Fatalf("EnableGEMInfo: %v", err)
+	}
+
+	manager.performScan(time.Unix(102, 0))
+	snapshot, _ = manager.Latest("card0")
+	if !snapshot.Capabilities.VRAMGTTFromGEMInfo || snapshot.Capabilities.VRAMGTTFromFDInfo {
+		t.Fatalf("unexpected capabilities %+v", snapshot.Capabilities)
+	}
+	proc := snapshot.Processes[0]
+	if proc.VRAMBytes == nil || *proc.VRAMBytes != 2097152+65536 {
+		t.Fatalf("unexpected vram %v", proc.VRAMBytes)
+	}
+	if proc.GTTBytes == nil || *proc.GTTBytes != 4096+33554432 {
+		t.Fatalf("unexpected gtt %v", proc.GTTBytes)
+	}
+	if len(proc.BOHistogram) != len(boBucketLimits)+1 {
+		t.Fatalf("expected bo histogram, got %+v", proc.BOHistogram)
+	}
+
+	// Without read access (e.g. debugfs unmounted) the fallback is skipped.
+	if err := os.Remove(filepath.Join(debugRoot, "dri", "0", gemInfoFile)); err != nil {
+		t.Fatalf("remove gem info: %v", err)
+	}
+	manager.performScan(time.Unix(104, 0))
+	snapshot, _ = manager.Latest("card0")
+	if snapshot.Capabilities.VRAMGTTFromGEMInfo || snapshot.Processes[0].VRAMBytes != nil {
+		t.Fatalf("expected gem fallback to be unavailable, got %+v", snapshot.Capabilities)
+	}
+}
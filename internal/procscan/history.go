@@ -0,0 +1,198 @@
+package procscan
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHistoryMaxPIDs = 64
+	defaultHistoryPoints  = 150
+	// historyStickyScans is how many scans a process must have stayed out
+	// of the top PIDs before its history can make room for another one.
+	historyStickyScans = 30
+)
+
+// pidHistory is a fixed-size ring of samples for a single process.
+type pidHistory struct {
+	name   string
+	points []ProcessPoint
+	next   int
+	full   bool
+	// topScan is the last scan in which the process ranked within the
+	// top PIDs.
+	topScan uint64
+}
+
+func (h *pidHistory) push(point ProcessPoint) {
+	h.points[h.next] = point
+	h.next++
+	if h.next == len(h.points) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+func (h *pidHistory) ordered() []ProcessPoint {
+	if !h.full {
+		out := make([]ProcessPoint, h.next)
+		copy(out, h.points[:h.next])
+
+		return out
+	}
+
+	out := make([]ProcessPoint, 0, len(h.points))
+	out = append(out, h.points[h.next:]...)
+	out = append(out, h.points[:h.next]...)
+
+	return out
+}
+
+// historyStore keeps bounded per-PID histories for the processes of each GPU.
+type historyStore struct {
+	maxPIDs   int
+	maxPoints int
+
+	mu   sync.RWMutex
+	gpus map[string]*gpuHistory
+}
+
+type gpuHistory struct {
+	scans uint64
+	pids  map[int]*pidHistory
+}
+
+func newHistoryStore(maxPIDs, maxPoints int) *historyStore {
+	if maxPIDs <= 0 {
+		maxPIDs = defaultHistoryMaxPIDs
+	}
+	if maxPoints <= 0 {
+		maxPoints = defaultHistoryPoints
+	}
+
+	return &historyStore{
+		maxPIDs:   maxPIDs,
+		maxPoints: maxPoints,
+		gpus:      make(map[string]*gpuHistory),
+	}
+}
+
+// record appends a point for each tracked process of the scan. processes
+// are expected in display order. A process starts being tracked when it
+// ranks within the top maxPIDs and there is room, and stays tracked until
+// it exits, so processes hovering around the cutoff keep their history.
+// When the cap is reached, the process that left the top longest ago, at
+// least historyStickyScans scans back, makes room for a newcomer.
+func (s *historyStore) record(gpuID string, now time.Time, processes []Process) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gpu, ok := s.gpus[gpuID]
+	if !ok {
+		gpu = &gpuHistory{pids: make(map[int]*pidHistory)}
+		s.gpus[gpuID] = gpu
+	}
+	gpu.scans++
+
+	seen := make(map[int]string, len(processes))
+	for _, proc := range processes {
+		seen[proc.PID] = proc.Name
+	}
+	for pid, hist := range gpu.pids {
+		// Exited, or the PID was reused by a different program.
+		if name, ok := seen[pid]; !ok || name != hist.name {
+			delete(gpu.pids, pid)
+		}
+	}
+
+	// Tracked processes are updated first so their rank in this scan
+	// counts before any of them is evicted for a newcomer.
+	var newcomers []Process
+	for rank, proc := range processes {
+		top := rank < s.maxPIDs
+		hist, ok := gpu.pids[proc.PID]
+		if !ok {
+			if top {
+				newcomers = append(newcomers, proc)
+			}
+
+			continue
+		}
+		if top {
+			hist.topScan = gpu.scans
+		}
+		hist.push(processPoint(now, proc))
+	}
+	for _, proc := range newcomers {
+		if len(gpu.pids) >= s.maxPIDs && !s.evictLocked(gpu) {
+			break
+		}
+		hist := &pidHistory{name: proc.Name, points: make([]ProcessPoint, s.maxPoints), topScan: gpu.scans}
+		hist.push(processPoint(now, proc))
+		gpu.pids[proc.PID] = hist
+	}
+}
+
+// evictLocked drops the history of the process that left the top longest
+// ago, if that was at least historyStickyScans scans back.
+func (s *historyStore) evictLocked(gpu *gpuHistory) bool {
+	victim, found := 0, false
+	for pid, hist := range gpu.pids {
+		if gpu.scans-hist.topScan < historyStickyScans {
+			continue
+		}
+		if !found || hist.topScan < gpu.pids[victim].topScan {
+			victim, found = pid, true
+		}
+	}
+	if found {
+		delete(gpu.pids, victim)
+	}
+
+	return found
+}
+
+func processPoint(now time.Time, proc Process) ProcessPoint {
+	return ProcessPoint{
+		Timestamp:     now.UTC(),
+		VRAMBytes:     proc.VRAMBytes,
+		GTTBytes:      proc.GTTBytes,
+		GPUTimeMSPerS: proc.GPUTimeMSPerS,
+	}
+}
+
+func (s *historyStore) get(gpuID string, pid int) (ProcessHistory, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gpu, ok := s.gpus[gpuID]
+	if !ok {
+		return ProcessHistory{}, false
+	}
+	hist, ok := gpu.pids[pid]
+	if !ok {
+		return ProcessHistory{}, false
+	}
+
+	return ProcessHistory{GPUId: gpuID, PID: pid, Name: hist.name, Points: hist.ordered()}, true
+}
+
+func (s *historyStore) all(gpuID string) []ProcessHistory {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gpu, ok := s.gpus[gpuID]
+	if !ok {
+		return []ProcessHistory{}
+	}
+	out := make([]ProcessHistory, 0, len(gpu.pids))
+	for pid, hist := range gpu.pids {
+		out = append(out, ProcessHistory{GPUId: gpuID, PID: pid, Name: hist.name, Points: hist.ordered()})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].PID < out[j].PID
+	})
+
+	return out
+}
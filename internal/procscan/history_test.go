@@ -0,0 +1,92 @@
+package procscan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryStoreRingAndCaps(t *testing.T) {
+	store := newHistoryStore(2, 3)
+	base := time.Unix(1_700_000_000, 0)
+
+	for i := 0; i < 5; i++ {
+		vram := uint64(i)
+		store.record("card0", base.Add(time.Duration(i)*time.Second), []Process{
+			{PID: 10, Name: "leaky", VRAMBytes: &vram},
+			{PID: 20, Name: "other"},
+			{PID: 30, Name: "over-cap"},
+		})
+	}
+
+	history, ok := store.get("card0", 10)
+	if !ok {
+		t.Fatalf("expected history for pid 10")
+	}
+	if len(history.Points) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(history.Points))
+	}
+	for i, point := range history.Points {
+		if point.VRAMBytes == nil || *point.VRAMBytes != uint64(i+2) {
+			t.Fatalf("unexpected point %d: %+v", i, point)
+		}
+	}
+	if _, ok := store.get("card0", 30); ok {
+		t.Fatalf("expected pid beyond cap to be untracked")
+	}
+	if all := store.all("card0"); len(all) != 2 || all[0].PID != 10 || all[1].PID != 20 {
+		t.Fatalf("unexpected histories %+v", all)
+	}
+
+	// PID reuse by another program starts a fresh history; gone PIDs are dropped.
+	store.record("card0", base.Add(10*time.Second), []Process{{PID: 10, Name: "new"}})
+	history, ok = store.get("card0", 10)
+	if !ok || history.Name != "new" || len(history.Points) != 1 {
+		t.Fatalf("expected fresh history after pid reuse, got %+v", history)
+	}
+	if _, ok := store.get("card0", 20); ok {
+		t.Fatalf("expected history of vanished pid to be dropped")
+	}
+}
+
+func TestHistoryStoreKeepsProcessesNearCutoff(t *testing.T) {
+	store := newHistoryStore(2, 3)
+	base := time.Unix(1_700_000_000, 0)
+	scan := func(i int, pids ...int) {
+		t.Helper()
+		processes := make([]Process, 0, len(pids))
+		for _, pid := range pids {
+			processes = append(processes, Process{PID: pid, Name: "proc"})
+		}
+		store.record("card0", base.Add(time.Duration(i)*time.Second), processes)
+	}
+
+	scan(0, 10, 20, 30)
+	// 20 and 30 swap places around the cutoff; 20 keeps its history.
+	scan(1, 10, 30, 20)
+	if history, ok := store.get("card0", 20); !ok || len(history.Points) != 2 {
+		t.Fatalf("expected pid 20 to keep its history, got %+v", history)
+	}
+	if _, ok := store.get("card0", 30); ok {
+		t.Fatalf("expected no room for pid 30 yet")
+	}
+
+	// Once 20 has stayed out of the top long enough, 30 takes its place.
+	for i := 2; i < 2+historyStickyScans; i++ {
+		scan(i, 10, 30, 20)
+	}
+	if _, ok := store.get("card0", 20); ok {
+		t.Fatalf("expected pid 20 to be evicted")
+	}
+	if _, ok := store.get("card0", 30); !ok {
+		t.Fatalf("expected pid 30 to be tracked")
+	}
+	if all := store.all("card0"); len(all) != 2 {
+		t.Fatalf("expected the cap to hold, got %+v", all)
+	}
+
+	// An exit frees a slot right away.
+	scan(100, 10, 20)
+	if _, ok := store.get("card0", 20); !ok {
+		t.Fatalf("expected pid 20 to take the slot freed by pid 30")
+	}
+}
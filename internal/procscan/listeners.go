@@ -0,0 +1,111 @@
+package procscan
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// maxPendingSnapshots bounds the snapshots waiting for slow listeners.
+const maxPendingSnapshots = 64
+
+// listenerQueue hands published snapshots to the listeners on a goroutine
+// of its own, so slow listeners hold up neither the scan nor the callers
+// waiting for it. Snapshots are delivered in publish order.
+type listenerQueue struct {
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	listeners []func(Snapshot)
+	pending   []Snapshot
+	dropped   int
+	closed    bool
+	wake      chan struct{}
+	done      chan struct{}
+}
+
+func newListenerQueue(logger *slog.Logger) *listenerQueue {
+	q := &listenerQueue{
+		logger: logger,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go q.run()
+
+	return q
+}
+
+func (q *listenerQueue) add(fn func(Snapshot)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.listeners = append(q.listeners, fn)
+}
+
+// push queues a snapshot. When the listeners have fallen too far behind,
+// the oldest pending snapshot is dropped. The first drop is logged; the
+// total is logged once the listeners have caught up.
+func (q *listenerQueue) push(snapshot Snapshot) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+
+		return
+	}
+	lagging := false
+	if len(q.pending) >= maxPendingSnapshots {
+		q.pending = q.pending[1:]
+		q.dropped++
+		lagging = q.dropped == 1
+	}
+	q.pending = append(q.pending, snapshot)
+	q.mu.Unlock()
+	if lagging {
+		q.logger.Warn("snapshot listeners lagging, dropping oldest snapshots", "gpu_id", snapshot.GPUId)
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *listenerQueue) run() {
+	defer close(q.done)
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			closed := q.closed
+			dropped := q.dropped
+			q.dropped = 0
+			q.mu.Unlock()
+			if dropped > 0 {
+				q.logger.Warn("snapshot listeners caught up", "dropped", dropped)
+			}
+			if closed {
+				return
+			}
+			<-q.wake
+
+			continue
+		}
+		snapshot := q.pending[0]
+		q.pending = q.pending[1:]
+		listeners := q.listeners
+		q.mu.Unlock()
+
+		for _, fn := range listeners {
+			fn(snapshot)
+		}
+	}
+}
+
+// close delivers the pending snapshots and stops the queue.
+func (q *listenerQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	<-q.done
+}
@@ -0,0 +1,44 @@
+package procscan
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestListenerQueueLogsDropsOnce(t *testing.T) {
+	var logs bytes.Buffer
+	queue := newListenerQueue(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	delivered := 0
+	queue.add(func(Snapshot) {
+		if delivered == 0 {
+			close(started)
+			<-release
+		}
+		delivered++
+	})
+
+	queue.push(Snapshot{GPUId: "card0"})
+	<-started
+	const extra = 10
+	for range maxPendingSnapshots + extra {
+		queue.push(Snapshot{GPUId: "card0"})
+	}
+	close(release)
+	queue.close()
+
+	if want := 1 + maxPendingSnapshots; delivered != want {
+		t.Fatalf("expected %d snapshots delivered, got %d", want, delivered)
+	}
+	out := logs.String()
+	if n := strings.Count(out, "snapshot listeners lagging"); n != 1 {
+		t.Fatalf("expected one lagging warning, got %d:\n%s", n, out)
+	}
+	if !strings.Contains(out, "snapshot listeners caught up") || !strings.Contains(out, "dropped=10") {
+		t.Fatalf("expected the drop total once caught up, got:\n%s", out)
+	}
+}
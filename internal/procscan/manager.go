@@ -24,13 +24,21 @@ type Manager struct {
 	renderNode map[string]string
 	lookup     *gpuLookup
 	collector  *collector
+	source     Source
+	exits      *exitTracker
+	history    *historyStore
+	energy     *energyLedger
+	gem        *gemSource
 
 	mu              sync.RWMutex
 	latest          map[string]Snapshot
 	subscribers     map[string]map[*procSubscriber]struct{}
 	subscriberCount int
+	listeners       *listenerQueue
+	powerSource     PowerSource
 	prevEngine      map[string]map[int]uint64
 	lastScan        time.Time
+	denied          int
 	lastDemandAt    time.Time
 	lazy            bool
 	idleTTL         time.Duration
@@ -53,31 +61,33 @@ func NewManager(cfg config.ProcConfig, procRoot string, gpus []gpu.Info, logger
 		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
 	}
 
-	gpuIDs := make([]string, 0, len(gpus))
-	renderNodes := make(map[string]string, len(gpus))
-	for _, info := range gpus {
-		gpuIDs = append(gpuIDs, info.ID)
-		renderNodes[info.ID] = info.RenderNode
-	}
-
-	manager := &Manager{
-		cfg:         cfg,
-		procRoot:    procRoot,
-		logger:      logger.With("component", "procscan_manager"),
-		gpuIDs:      gpuIDs,
-		renderNode:  renderNodes,
-		lookup:      newGPULookup(gpuIDs, renderNodes),
-		latest:      make(map[string]Snapshot),
-		subscribers: make(map[string]map[*procSubscriber]struct{}),
-		prevEngine:  make(map[string]map[int]uint64),
-		activity:    make(chan struct{}, 1),
-	}
+	manager := newManager(cfg, gpus, logger)
+	manager.procRoot = procRoot
 	coll, err := newCollector(procRoot, cfg.MaxPIDs, cfg.MaxFDsPerPID, manager.lookup, logger.With("component", "procscan_collector"))
 	if err != nil {
 		return nil, fmt.Errorf("init collector: %w", err)
 	}
 	manager.collector = coll
 
+	if cfg.Enrich {
+		var rules []Rule
+		if cfg.RulesFile != "" {
+			rules, err = LoadRules(cfg.RulesFile)
+			if err != nil {
+				_ = coll.Close()
+
+				return nil, fmt.Errorf("load enrichment rules: %w", err)
+			}
+		}
+		enr, err := newEnricher(rules)
+		if err != nil {
+			_ = coll.Close()
+
+			return nil, fmt.Errorf("init enrichment: %w", err)
+		}
+		coll.enricher = enr
+	}
+
 	return manager, nil
 }
 
@@ -94,6 +104,29 @@ func (m *Manager) EnableLazy(idleTTL time.Duration) error {
 	return nil
 }
 
+// EnableGEMInfo fills per-process VRAM/GTT from debugfs amdgpu_gem_info for
+// processes whose fdinfo lacks memory keys. It must be called before Run.
+func (m *Manager) EnableGEMInfo(debugfsRoot string) error {
+	if debugfsRoot == "" {
+		return fmt.Errorf("debugfs root is empty")
+	}
+	source, err := newGEMSource(debugfsRoot, m.gpuIDs)
+	if err != nil {
+		return err
+	}
+	m.gem = source
+
+	return nil
+}
+
+// SetPowerSource enables per-process power and energy estimates based on the
+// board power reported by source. It must be called before Run.
+func (m *Manager) SetPowerSource(source PowerSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.powerSource = source
+}
+
 // Run starts the periodic /proc scanner until the context is cancelled.
 func (m *Manager) Run(ctx context.Context) error {
 	if !m.cfg.Enable || len(m.gpuIDs) == 0 {
@@ -102,6 +135,10 @@ func (m *Manager) Run(ctx context.Context) error {
 		return m.Close()
 	}
 
+	if m.cfg.NetlinkEvents && m.collector != nil {
+		go m.watchProcEvents(ctx)
+	}
+
 	if !m.lazy {
 		m.logger.Info("process scanner started", "interval", m.cfg.ScanInterval, "lazy", false)
 		m.scanAll()
@@ -189,6 +226,48 @@ func (m *Manager) Current(gpuID string) (Snapshot, bool, error) {
 	return snapshot, ok, nil
 }
 
+// Exited returns the retained exit accounting for the supplied GPU, newest first.
+func (m *Manager) Exited(gpuID string) (ExitedSnapshot, error) {
+	if !m.knowsGPU(gpuID) {
+		return ExitedSnapshot{}, fmt.Errorf("unknown gpu %q", gpuID)
+	}
+
+	return ExitedSnapshot{
+		GPUId:     gpuID,
+		Processes: m.exits.list(gpuID),
+	}, nil
+}
+
+// Energy returns the estimated energy totals for the supplied GPU.
+func (m *Manager) Energy(gpuID string) (EnergySnapshot, error) {
+	if !m.knowsGPU(gpuID) {
+		return EnergySnapshot{}, fmt.Errorf("unknown gpu %q", gpuID)
+	}
+
+	return m.energy.snapshot(gpuID), nil
+}
+
+// History returns the retained samples of a live process on the GPU. The
+// boolean is false when the PID has no history.
+func (m *Manager) History(gpuID string, pid int) (ProcessHistory, bool, error) {
+	if !m.knowsGPU(gpuID) {
+		return ProcessHistory{}, false, fmt.Errorf("unknown gpu %q", gpuID)
+	}
+
+	history, ok := m.history.get(gpuID, pid)
+
+	return history, ok, nil
+}
+
+// HistoryAll returns the retained samples of every tracked process on the GPU.
+func (m *Manager) HistoryAll(gpuID string) ([]ProcessHistory, error) {
+	if !m.knowsGPU(gpuID) {
+		return nil, fmt.Errorf("unknown gpu %q", gpuID)
+	}
+
+	return m.history.all(gpuID), nil
+}
+
 // Subscribe registers for process snapshot updates for the supplied GPU.
 func (m *Manager) Subscribe(gpuID string) (<-chan Snapshot, func(), error) {
 	if !m.cfg.Enable {
@@ -223,6 +302,22 @@ func (m *Manager) Subscribe(gpuID string) (<-chan Snapshot, func(), error) {
 	return sub.channel(), unsubscribe, nil
 }
 
+// AddListener registers a callback invoked with every published snapshot.
+// Callbacks run in publish order on a goroutine shared by all listeners,
+// not on the scan path. Unlike subscriptions, listeners do not count as
+// demand in lazy mode.
+func (m *Manager) AddListener(fn func(Snapshot)) {
+	if fn == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.listeners == nil {
+		m.listeners = newListenerQueue(m.logger)
+	}
+	m.listeners.add(fn)
+}
+
 // GPUIDs enumerates GPUs tracked by the manager.
 func (m *Manager) GPUIDs() []string {
 	m.mu.RLock()
@@ -241,6 +336,16 @@ func (m *Manager) Ready() bool {
 	return !m.lastScan.IsZero()
 }
 
+// PermissionDenied returns how many processes the last scan skipped because
+// their fd table could not be read. The scan covers all GPUs at once, so the
+// count is not attributed to any of them.
+func (m *Manager) PermissionDenied() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.denied
+}
+
 // HasDemand reports whether lazy mode currently considers scanning active.
 func (m *Manager) HasDemand() bool {
 	m.mu.RLock()
@@ -317,7 +422,7 @@ func (m *Manager) scanAll() {
 }
 
 func (m *Manager) performScan(now time.Time) {
-	collections, err := m.collector.collect()
+	collections, denied, err := m.collect()
 	if err != nil {
 		m.logger.Warn("process scan failed", "err", err)
 
@@ -326,6 +431,7 @@ func (m *Manager) performScan(now time.Time) {
 
 	m.mu.RLock()
 	prevScan := m.lastScan
+	powerSource := m.powerSource
 	m.mu.RUnlock()
 
 	var elapsedSeconds float64
@@ -340,18 +446,33 @@ func (m *Manager) performScan(now time.Time) {
 	for _, gpuID := range m.gpuIDs {
 		col := collections[gpuID]
 		prev := m.getPrevEngine(gpuID)
+		gemInfo := m.readGEMInfo(gpuID, col)
 
 		processes := make([]Process, 0, len(col.processes))
 		nextTotals := make(map[int]uint64)
+		seen := make(map[int]struct{}, len(col.processes))
 
 		for _, raw := range col.processes {
+			if usage, ok := gemInfo[raw.pid]; ok && !raw.hasMemory {
+				raw.vramBytes = usage.vramBytes
+				raw.gttBytes = usage.gttBytes
+				raw.hasMemory = true
+			}
+			seen[raw.pid] = struct{}{}
+			m.exits.observe(gpuID, raw, now)
+
 			proc := Process{
-				PID:        raw.pid,
-				UID:        raw.uid,
-				User:       raw.user,
-				Name:       raw.name,
-				Command:    raw.command,
-				RenderNode: raw.renderNode,
+				PID:         raw.pid,
+				UID:         raw.uid,
+				User:        raw.user,
+				Name:        raw.name,
+				Command:     raw.command,
+				RenderNode:  raw.renderNode,
+				Cgroup:      raw.cgroup,
+				Container:   raw.container,
+				DisplayName: raw.displayName,
+				Category:    raw.category,
+				SteamAppID:  raw.steamAppID,
 			}
 
 			if raw.hasMemory {
@@ -360,6 +481,9 @@ func (m *Manager) performScan(now time.Time) {
 				proc.VRAMBytes = &vram
 				proc.GTTBytes = &gtt
 			}
+			if usage, ok := gemInfo[raw.pid]; ok {
+				proc.BOHistogram = usage.histogram
+			}
 
 			if raw.hasEngine {
 				nextTotals[raw.pid] = raw.engineTotal
@@ -375,6 +499,11 @@ func (m *Manager) performScan(now time.Time) {
 
 			processes = append(processes, proc)
 		}
+		m.exits.sweep(gpuID, seen, now)
+		if powerSource != nil {
+			energySeconds := min(elapsedSeconds, (energyGapScans * m.cfg.ScanInterval).Seconds())
+			m.estimateEnergy(gpuID, processes, powerSource, energySeconds)
+		}
 
 		sort.Slice(processes, func(i, j int) bool {
 			var vi, vj uint64
@@ -390,6 +519,7 @@ func (m *Manager) performScan(now time.Time) {
 
 			return vi > vj
 		})
+		m.history.record(gpuID, now, processes)
 
 		snapshot := Snapshot{
 			GPUId:     gpuID,
@@ -397,6 +527,7 @@ func (m *Manager) performScan(now time.Time) {
 			Capabilities: Capabilities{
 				VRAMGTTFromFDInfo:    col.hasMemory,
 				EngineTimeFromFDInfo: col.hasEngine,
+				VRAMGTTFromGEMInfo:   gemInfo != nil,
 			},
 			Processes: processes,
 		}
@@ -410,9 +541,58 @@ func (m *Manager) performScan(now time.Time) {
 
 	m.mu.Lock()
 	m.lastScan = now
+	m.denied = denied
 	m.mu.Unlock()
 }
 
+// readGEMInfo loads debugfs BO accounting when some process on the GPU has no
+// memory figures from fdinfo.
+func (m *Manager) readGEMInfo(gpuID string, col gpuCollection) map[int]*gemUsage {
+	if m.gem == nil {
+		return nil
+	}
+	needed := false
+	for _, raw := range col.processes {
+		if !raw.hasMemory {
+			needed = true
+
+			break
+		}
+	}
+	if !needed {
+		return nil
+	}
+	usage, ok := m.gem.read(gpuID)
+	if !ok {
+		return nil
+	}
+
+	return usage
+}
+
+func (m *Manager) estimateEnergy(gpuID string, processes []Process, source PowerSource, elapsedSeconds float64) {
+	var shares []float64
+	if boardW, ok := source(gpuID); ok && elapsedSeconds > 0 {
+		shares = estimatePower(processes, boardW)
+	}
+
+	for i := range processes {
+		var joules float64
+		if shares != nil {
+			watts := shares[i]
+			processes[i].PowerW = &watts
+			joules = watts * elapsedSeconds
+			if joules > 0 {
+				m.energy.add(gpuID, processes[i].User, processes[i].Name, joules)
+			}
+		}
+		total := m.exits.addEnergy(gpuID, processes[i].PID, joules)
+		wh := total / joulesPerWh
+		processes[i].EnergyJ = &total
+		processes[i].EnergyWh = &wh
+	}
+}
+
 func (m *Manager) publish(snapshot Snapshot, engineTotals map[int]uint64) {
 	m.mu.Lock()
 	m.latest[snapshot.GPUId] = snapshot
@@ -425,11 +605,15 @@ func (m *Manager) publish(snapshot Snapshot, engineTotals map[int]uint64) {
 	for sub := range m.subscribers[snapshot.GPUId] {
 		subs = append(subs, sub)
 	}
+	listeners := m.listeners
 	m.mu.Unlock()
 
 	for _, sub := range subs {
 		sub.send(snapshot)
 	}
+	if listeners != nil {
+		listeners.push(snapshot)
+	}
 }
 
 func (m *Manager) getPrevEngine(gpuID string) map[int]uint64 {
@@ -478,12 +662,29 @@ func (m *Manager) signalActivity() {
 // Close releases any filesystem handles retained by the manager.
 func (m *Manager) Close() error {
 	m.closeOnce.Do(func() {
+		m.mu.RLock()
+		listeners := m.listeners
+		m.mu.RUnlock()
+		if listeners != nil {
+			listeners.close()
+		}
+
 		var errs []error
 		if m.collector != nil {
 			if err := m.collector.Close(); err != nil {
 				errs = append(errs, fmt.Errorf("close collector: %w", err))
 			}
 		}
+		if m.source != nil {
+			if err := m.source.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close source: %w", err))
+			}
+		}
+		if m.gem != nil {
+			if err := m.gem.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close gem info source: %w", err))
+			}
+		}
 		m.closeErr = errors.Join(errs...)
 	})
 
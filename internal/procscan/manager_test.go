@@ -114,6 +114,270 @@ drm-engine:
 	}
 }
 
+func TestManagerRecordsExitedProcesses(t *testing.T) {
+	root := t.TempDir()
+	procDir := setupProcEntry(t, root, 4321)
+	writeFile(t, procDir.fdinfo("5"), string(readTestdata(t, "fdinfo_mem_engine.txt")))
+	if err := procDir.linkFD("5", "/dev/dri/renderD128"); err != nil {
+		t.Fatalf("symlink fd: %v", err)
+	}
+
+	cfg := config.ProcConfig{
+		Enable:       true,
+		ScanInterval: 2 * time.Second,
+		MaxPIDs:      10,
+		MaxFDsPerPID: 16,
+		MaxExited:    4,
+	}
+	gpus := []gpu.Info{{ID: "card0", RenderNode: "/dev/dri/renderD128"}}
+
+	manager, err := NewManager(cfg, root, gpus, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+	manager.collector.userCache[1000] = "alice"
+
+	first := time.Unix(100, 0)
+	manager.performScan(first)
+
+	exited, err := manager.Exited("card0")
+	if err != nil {
+		t.Fatalf("Exited: %v", err)
+	}
+	if len(exited.Processes) != 0 {
+		t.Fatalf("expected no exited processes while running, got %d", len(exited.Processes))
+	}
+
+	if err := os.RemoveAll(procDir.root); err != nil {
+		t.Fatalf("remove proc dir: %v", err)
+	}
+
+	second := first.Add(2 * time.Second)
+	manager.performScan(second)
+
+	exited, err = manager.Exited("card0")
+	if err != nil {
+		t.Fatalf("Exited: %v", err)
+	}
+	if len(exited.Processes) != 1 {
+		t.Fatalf("expected one exited process, got %d", len(exited.Processes))
+	}
+	p := exited.Processes[0]
+	if p.PID != 4321 || p.User != "alice" || p.Name != "proc" {
+		t.Fatalf("unexpected exited process %+v", p)
+	}
+	if !p.LastSeen.Equal(first) || !p.ExitedAt.Equal(second) {
+		t.Fatalf("unexpected exit timestamps last_seen=%s exited_at=%s", p.LastSeen, p.ExitedAt)
+	}
+	if p.GPUTimeMS == nil || *p.GPUTimeMS != 350 {
+		t.Fatalf("unexpected gpu time %+v", p.GPUTimeMS)
+	}
+	if p.PeakVRAMBytes == nil || *p.PeakVRAMBytes != 268435456 {
+		t.Fatalf("unexpected peak VRAM %+v", p.PeakVRAMBytes)
+	}
+
+	if _, err := manager.Exited("card9"); err == nil {
+		t.Fatalf("expected error for unknown gpu")
+	}
+}
+
+func TestManagerDetectsPIDReuseByStartTime(t *testing.T) {
+	root := t.TempDir()
+	procDir := setupProcEntry(t, root, 4321)
+	writeFile(t, procDir.fdinfo("5"), string(readTestdata(t, "fdinfo_mem_engine.txt")))
+	if err := procDir.linkFD("5", "/dev/dri/renderD128"); err != nil {
+		t.Fatalf("symlink fd: %v", err)
+	}
+	stat := func(startTime int) {
+		writeFile(t, filepath.Join(procDir.root, "stat"), "4321 (proc) S 1 4321 4321 0 -1 4194560 0 0 0 0 0 0 0 0 20 0 1 0 "+strconv.Itoa(startTime)+" 0 0\n")
+	}
+	stat(1000)
+
+	cfg := config.ProcConfig{Enable: true, ScanInterval: 2 * time.Second, MaxPIDs: 10, MaxFDsPerPID: 16, MaxExited: 4}
+	gpus := []gpu.Info{{ID: "card0", RenderNode: "/dev/dri/renderD128"}}
+	manager, err := NewManager(cfg, root, gpus, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+
+	first := time.Unix(100, 0)
+	manager.performScan(first)
+	manager.performScan(first.Add(2 * time.Second))
+	// The same short-lived tool gets the PID again between two scans.
+	stat(1500)
+	manager.performScan(first.Add(4 * time.Second))
+
+	exited, err := manager.Exited("card0")
+	if err != nil {
+		t.Fatalf("Exited: %v", err)
+	}
+	if len(exited.Processes) != 1 || exited.Processes[0].PID != 4321 || !exited.Processes[0].LastSeen.Equal(first.Add(2*time.Second)) {
+		t.Fatalf("expected the first run to be retired on PID reuse, got %+v", exited.Processes)
+	}
+	if account := manager.exits.live["card0"][4321]; account == nil || account.startTime != 1500 || !account.firstSeen.Equal(first.Add(4*time.Second)) {
+		t.Fatalf("expected a fresh account for the new run, got %+v", account)
+	}
+}
+
+func TestManagerProbeFeedsExitAccounting(t *testing.T) {
+	root := t.TempDir()
+	cfg := config.ProcConfig{
+		Enable:       true,
+		ScanInterval: 2 * time.Second,
+		MaxPIDs:      10,
+		MaxFDsPerPID: 16,
+	}
+	gpus := []gpu.Info{{ID: "card0", RenderNode: "/dev/dri/renderD128"}}
+
+	manager, err := NewManager(cfg, root, gpus, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+	manager.collector.userCache[1000] = "bob"
+
+	first := time.Unix(200, 0)
+	manager.performScan(first)
+
+	// A short-lived process appears and disappears between two scans.
+	procDir := setupProcEntry(t, root, 777)
+	writeFile(t, procDir.fdinfo("5"), string(readTestdata(t, "fdinfo_mem_engine.txt")))
+	if err := procDir.linkFD("5", "/dev/dri/renderD128"); err != nil {
+		t.Fatalf("symlink fd: %v", err)
+	}
+	manager.probePIDs([]int{777, 778}, first.Add(500*time.Millisecond))
+	if err := os.RemoveAll(procDir.root); err != nil {
+		t.Fatalf("remove proc dir: %v", err)
+	}
+
+	manager.performScan(first.Add(2 * time.Second))
+
+	exited, err := manager.Exited("card0")
+	if err != nil {
+		t.Fatalf("Exited: %v", err)
+	}
+	if len(exited.Processes) != 1 || exited.Processes[0].PID != 777 {
+		t.Fatalf("expected probed process in exit accounting, got %+v", exited.Processes)
+	}
+}
+
+func TestManagerEstimatesProcessEnergy(t *testing.T) {
+	root := t.TempDir()
+	heavy := setupProcEntry(t, root, 10)
+	light := setupProcEntry(t, root, 20)
+	for _, fixture := range []procFixture{heavy, light} {
+		writeFile(t, fixture.fdinfo("5"), "drm-engine:\n\tgfx: 0 ns\n")
+		if err := fixture.linkFD("5", "/dev/dri/renderD128"); err != nil {
+			t.Fatalf("symlink fd: %v", err)
+		}
+	}
+
+	cfg := config.ProcConfig{
+		Enable:       true,
+		ScanInterval: 2 * time.Second,
+		MaxPIDs:      10,
+		MaxFDsPerPID: 16,
+	}
+	gpus := []gpu.Info{{ID: "card0", RenderNode: "/dev/dri/renderD128"}}
+
+	manager, err := NewManager(cfg, root, gpus, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+	manager.collector.userCache[1000] = "alice"
+	manager.SetPowerSource(func(gpuID string) (float64, bool) {
+		return 100, gpuID == "card0"
+	})
+
+	first := time.Unix(300, 0)
+	manager.performScan(first)
+
+	writeFile(t, heavy.fdinfo("5"), "drm-engine:\n\tgfx: 1500000000 ns\n")
+	writeFile(t, light.fdinfo("5"), "drm-engine:\n\tgfx: 500000000 ns\n")
+	manager.performScan(first.Add(2 * time.Second))
+
+	snapshot, ok := manager.Latest("card0")
+	if !ok || len(snapshot.Processes) != 2 {
+		t.Fatalf("expected two processes, got %+v", snapshot.Processes)
+	}
+	want := map[int]float64{10: 75, 20: 25}
+	for _, proc := range snapshot.Processes {
+		if proc.PowerW == nil || *proc.PowerW != want[proc.PID] {
+			t.Fatalf("unexpected power for pid %d: %v", proc.PID, proc.PowerW)
+		}
+		if proc.EnergyJ == nil || *proc.EnergyJ != want[proc.PID]*2 {
+			t.Fatalf("unexpected energy for pid %d: %v", proc.PID, proc.EnergyJ)
+		}
+	}
+
+	energy, err := manager.Energy("card0")
+	if err != nil {
+		t.Fatalf("Energy: %v", err)
+	}
+	if energy.EnergyJ != 200 || len(energy.Users) != 1 || energy.Users[0].User != "alice" {
+		t.Fatalf("unexpected energy totals %+v", energy)
+	}
+	if len(energy.Processes) != 1 || energy.Processes[0].Name != "proc" || energy.Processes[0].EnergyJ != 200 {
+		t.Fatalf("unexpected per-app energy %+v", energy.Processes)
+	}
+
+	if err := os.RemoveAll(heavy.root); err != nil {
+		t.Fatalf("remove proc dir: %v", err)
+	}
+	manager.performScan(first.Add(4 * time.Second))
+
+	exited, err := manager.Exited("card0")
+	if err != nil {
+		t.Fatalf("Exited: %v", err)
+	}
+	if len(exited.Processes) != 1 || exited.Processes[0].EnergyJ == nil || *exited.Processes[0].EnergyJ != 150 {
+		t.Fatalf("expected exited energy total, got %+v", exited.Processes)
+	}
+	if wh := *exited.Processes[0].EnergyWh; wh != 150.0/3600 {
+		t.Fatalf("unexpected exited energy Wh %v", wh)
+	}
+	if _, ok := manager.exits.live["card0"][10]; ok {
+		t.Fatalf("expected energy account of swept pid to be evicted")
+	}
+
+	// After an hour without scans, the current draw is only charged for a
+	// few scan intervals.
+	writeFile(t, light.fdinfo("5"), "drm-engine:\n\tgfx: 1800500000000 ns\n")
+	manager.performScan(first.Add(4*time.Second + time.Hour))
+	snapshot, _ = manager.Latest("card0")
+	if len(snapshot.Processes) != 1 || snapshot.Processes[0].EnergyJ == nil || *snapshot.Processes[0].EnergyJ != 50+50*3*2 {
+		t.Fatalf("expected energy after an idle gap to be capped, got %+v", snapshot.Processes)
+	}
+}
+
+func TestEstimatePowerWeightsByBusyTime(t *testing.T) {
+	msPerS := func(v float64) *float64 { return &v }
+	processes := []Process{
+		{PID: 1, GPUTimeMSPerS: msPerS(1)},
+		{PID: 2, GPUTimeMSPerS: msPerS(250)},
+		{PID: 3},
+	}
+	shares := estimatePower(processes, 100)
+	if shares[0] > 0.2 {
+		t.Fatalf("expected near-idle process to get near-zero power, got %v", shares[0])
+	}
+	if shares[1] != 25 || shares[2] != 0 {
+		t.Fatalf("unexpected shares %v", shares)
+	}
+
+	// Engine time across several engines can exceed the interval; the
+	// board power is then split without attributing more than it.
+	processes[0].GPUTimeMSPerS = msPerS(1500)
+	processes[1].GPUTimeMSPerS = msPerS(500)
+	shares = estimatePower(processes, 100)
+	if shares[0] != 75 || shares[1] != 25 {
+		t.Fatalf("unexpected saturated shares %v", shares)
+	}
+}
+
 type procFixture struct {
 	root string
 	pid  int
@@ -143,3 +407,43 @@ func (p procFixture) fdinfo(fd string) string {
 func (p procFixture) linkFD(fd, target string) error {
 	return os.Symlink(target, filepath.Join(p.root, "fd", fd))
 }
+
+func TestManagerListenersDoNotBlockScans(t *testing.T) {
+	root := t.TempDir()
+	setupProcEntry(t, root, 1234)
+
+	cfg := config.ProcConfig{Enable: true, ScanInterval: 2 * time.Second, MaxPIDs: 10, MaxFDsPerPID: 16}
+	gpus := []gpu.Info{{ID: "card0", RenderNode: "/dev/dri/renderD128"}}
+	manager, err := NewManager(cfg, root, gpus, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	release := make(chan struct{})
+	var seen []time.Time
+	manager.AddListener(func(s Snapshot) {
+		<-release
+		seen = append(seen, s.Timestamp)
+	})
+
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		for i := range 3 {
+			manager.performScan(time.Unix(int64(i*2), 0))
+		}
+	}()
+	select {
+	case <-scanned:
+	case <-time.After(time.Second):
+		t.Fatalf("scans blocked on a slow listener")
+	}
+
+	close(release)
+	if err := manager.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if len(seen) != 3 || !seen[0].Before(seen[1]) || !seen[1].Before(seen[2]) {
+		t.Fatalf("expected every snapshot in order after Close, got %v", seen)
+	}
+}
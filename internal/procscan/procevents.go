@@ -0,0 +1,92 @@
+package procscan
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	procEventQueueSize  = 256
+	procEventMaxPending = 4096
+	procEventProbeTick  = 100 * time.Millisecond
+)
+
+// procEventProbeDelays lists when a freshly exec'd process is probed. Clients
+// usually open the render node shortly after start, so a couple of early
+// probes catch short-lived jobs that would otherwise fall between scans.
+var procEventProbeDelays = []time.Duration{250 * time.Millisecond, time.Second}
+
+type pendingProbe struct {
+	execAt  time.Time
+	attempt int
+}
+
+// watchProcEvents listens for exec notifications from the proc connector and
+// probes new PIDs ahead of the regular scan cadence.
+func (m *Manager) watchProcEvents(ctx context.Context) {
+	execs := make(chan int, procEventQueueSize)
+
+	go func() {
+		err := listenProcEvents(ctx, func(pid int) {
+			select {
+			case execs <- pid:
+			default:
+			}
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			m.logger.Warn("proc connector unavailable, relying on periodic scans", "err", err)
+		}
+	}()
+
+	m.logger.Info("proc connector watcher started")
+
+	pending := make(map[int]pendingProbe)
+	ticker := time.NewTicker(procEventProbeTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case pid := <-execs:
+			if len(pending) >= procEventMaxPending {
+				continue
+			}
+			pending[pid] = pendingProbe{execAt: time.Now()}
+		case now := <-ticker.C:
+			if len(pending) == 0 {
+				continue
+			}
+			due := make([]int, 0, len(pending))
+			for pid, probe := range pending {
+				if now.Sub(probe.execAt) < procEventProbeDelays[probe.attempt] {
+					continue
+				}
+				due = append(due, pid)
+				probe.attempt++
+				if probe.attempt >= len(procEventProbeDelays) {
+					delete(pending, pid)
+				} else {
+					pending[pid] = probe
+				}
+			}
+			if len(due) > 0 && m.HasDemand() {
+				m.probePIDs(due, now)
+			}
+		}
+	}
+}
+
+// probePIDs scans the supplied PIDs and feeds the results into exit
+// accounting without publishing a new snapshot.
+func (m *Manager) probePIDs(pids []int, now time.Time) {
+	m.scanMu.Lock()
+	defer m.scanMu.Unlock()
+
+	for gpuID, col := range m.collector.collectPIDs(pids) {
+		for _, raw := range col.processes {
+			m.exits.observe(gpuID, raw, now)
+		}
+	}
+}
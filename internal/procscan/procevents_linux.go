@@ -0,0 +1,136 @@
+//go:build linux
+
+package procscan
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+const (
+	netlinkConnector  = 11 // NETLINK_CONNECTOR
+	cnIdxProc         = 1  // CN_IDX_PROC
+	cnValProc         = 1  // CN_VAL_PROC
+	procCnMcastListen = 1  // PROC_CN_MCAST_LISTEN
+	procCnMcastIgnore = 2  // PROC_CN_MCAST_IGNORE
+	procEventExec     = 0x00000002
+
+	nlMsgHdrLen  = 16
+	cnMsgHdrLen  = 20
+	procEventHdr = 16
+)
+
+// listenProcEvents subscribes to the kernel proc connector and reports the
+// TGID of every process that calls exec. It blocks until ctx is cancelled.
+// Subscribing requires CAP_NET_ADMIN.
+func listenProcEvents(ctx context.Context, onExec func(pid int)) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, netlinkConnector)
+	if err != nil {
+		return fmt.Errorf("open netlink socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	addr := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: cnIdxProc}
+	if err := syscall.Bind(fd, addr); err != nil {
+		return fmt.Errorf("bind netlink socket: %w", err)
+	}
+
+	// Wake up periodically so cancellation is observed without closing the fd
+	// under a blocked reader.
+	timeout := syscall.Timeval{Sec: 1}
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		return fmt.Errorf("set netlink receive timeout: %w", err)
+	}
+
+	if err := sendProcConnectorOp(fd, procCnMcastListen); err != nil {
+		return fmt.Errorf("subscribe proc connector: %w", err)
+	}
+	defer func() {
+		_ = sendProcConnectorOp(fd, procCnMcastIgnore)
+	}()
+
+	buf := make([]byte, syscall.Getpagesize())
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			if errors.Is(err, syscall.ENOBUFS) {
+				// Receive queue overflowed; periodic scans cover the lost events.
+				continue
+			}
+
+			return fmt.Errorf("receive proc event: %w", err)
+		}
+
+		for _, pid := range parseProcExecEvents(buf[:n]) {
+			onExec(pid)
+		}
+	}
+}
+
+func sendProcConnectorOp(fd int, op uint32) error {
+	msg := make([]byte, nlMsgHdrLen+cnMsgHdrLen+4)
+	order := binary.NativeEndian
+
+	// struct nlmsghdr
+	order.PutUint32(msg[0:4], uint32(len(msg)))
+	order.PutUint16(msg[4:6], syscall.NLMSG_DONE)
+	order.PutUint16(msg[6:8], 0)
+	order.PutUint32(msg[8:12], 0)
+	order.PutUint32(msg[12:16], uint32(syscall.Getpid()))
+
+	// struct cn_msg
+	cn := msg[nlMsgHdrLen:]
+	order.PutUint32(cn[0:4], cnIdxProc)
+	order.PutUint32(cn[4:8], cnValProc)
+	order.PutUint32(cn[8:12], 0)
+	order.PutUint32(cn[12:16], 0)
+	order.PutUint16(cn[16:18], 4)
+	order.PutUint16(cn[18:20], 0)
+
+	order.PutUint32(cn[cnMsgHdrLen:], op)
+
+	return syscall.Sendto(fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+}
+
+// parseProcExecEvents extracts exec event TGIDs from a netlink datagram.
+func parseProcExecEvents(data []byte) []int {
+	order := binary.NativeEndian
+	var pids []int
+
+	for len(data) >= nlMsgHdrLen {
+		msgLen := int(order.Uint32(data[0:4]))
+		if msgLen < nlMsgHdrLen || msgLen > len(data) {
+			break
+		}
+		payload := data[nlMsgHdrLen:msgLen]
+		if len(payload) >= cnMsgHdrLen+procEventHdr+8 {
+			event := payload[cnMsgHdrLen:]
+			what := order.Uint32(event[0:4])
+			if what == procEventExec {
+				// struct exec_proc_event { pid_t process_pid; pid_t process_tgid; }
+				tgid := int(int32(order.Uint32(event[procEventHdr+4 : procEventHdr+8])))
+				if tgid > 0 {
+					pids = append(pids, tgid)
+				}
+			}
+		}
+
+		aligned := (msgLen + 3) &^ 3
+		if aligned >= len(data) {
+			break
+		}
+		data = data[aligned:]
+	}
+
+	return pids
+}
@@ -0,0 +1,34 @@
+//go:build linux
+
+package procscan
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseProcExecEvents(t *testing.T) {
+	order := binary.NativeEndian
+	message := func(what uint32, pid, tgid uint32) []byte {
+		msg := make([]byte, nlMsgHdrLen+cnMsgHdrLen+procEventHdr+8)
+		order.PutUint32(msg[0:4], uint32(len(msg)))
+		event := msg[nlMsgHdrLen+cnMsgHdrLen:]
+		order.PutUint32(event[0:4], what)
+		order.PutUint32(event[procEventHdr:procEventHdr+4], pid)
+		order.PutUint32(event[procEventHdr+4:procEventHdr+8], tgid)
+
+		return msg
+	}
+
+	data := append(message(procEventExec, 4100, 4000), message(0x1, 10, 10)...)
+	data = append(data, message(procEventExec, 5001, 5001)...)
+
+	pids := parseProcExecEvents(data)
+	if len(pids) != 2 || pids[0] != 4000 || pids[1] != 5001 {
+		t.Fatalf("unexpected exec pids %v", pids)
+	}
+
+	if pids := parseProcExecEvents(data[:10]); len(pids) != 0 {
+		t.Fatalf("expected no pids from truncated datagram, got %v", pids)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package procscan
+
+import (
+	"context"
+	"errors"
+)
+
+func listenProcEvents(_ context.Context, _ func(pid int)) error {
+	return errors.New("proc connector is only available on linux")
+}
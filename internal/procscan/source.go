@@ -0,0 +1,135 @@
+package procscan
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/config"
+	"github.com/skobkin/amdgputop-web/internal/gpu"
+)
+
+// Source replaces the procfs collector, e.g. to replay a recording or to
+// simulate GPU clients. Collect is called once per scan.
+type Source interface {
+	Collect() (map[string]SourceCollection, error)
+	Close() error
+}
+
+// SourceCollection holds the processes a Source observed on one GPU.
+type SourceCollection struct {
+	Processes []SourceProcess
+	HasMemory bool
+	HasEngine bool
+}
+
+// SourceProcess is a single process observation before rate computation.
+// EngineTimeNS is cumulative, like the fdinfo engine counters.
+type SourceProcess struct {
+	PID          int
+	UID          int
+	User         string
+	Name         string
+	Command      string
+	RenderNode   string
+	Cgroup       string
+	Container    string
+	DisplayName  string
+	Category     string
+	SteamAppID   string
+	VRAMBytes    uint64
+	GTTBytes     uint64
+	HasMemory    bool
+	EngineTimeNS uint64
+	HasEngine    bool
+}
+
+// NewManagerWithSource constructs a process scanner manager that reads from
+// source instead of procfs. Enrichment, debugfs fallbacks and the proc
+// connector do not apply.
+func NewManagerWithSource(cfg config.ProcConfig, source Source, gpus []gpu.Info, logger *slog.Logger) (*Manager, error) {
+	if source == nil {
+		return nil, fmt.Errorf("source is nil")
+	}
+	if cfg.ScanInterval <= 0 {
+		return nil, fmt.Errorf("scan interval must be > 0")
+	}
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	manager := newManager(cfg, gpus, logger)
+	manager.source = source
+
+	return manager, nil
+}
+
+// collect gathers one scan worth of processes from the configured backend.
+func (m *Manager) collect() (map[string]gpuCollection, int, error) {
+	if m.source == nil {
+		collections, err := m.collector.collect()
+
+		return collections, m.collector.denied, err
+	}
+
+	sourced, err := m.source.Collect()
+	if err != nil {
+		return nil, 0, err
+	}
+	collections := make(map[string]gpuCollection, len(sourced))
+	for gpuID, col := range sourced {
+		converted := gpuCollection{
+			processes: make([]rawProcess, 0, len(col.Processes)),
+			hasMemory: col.HasMemory,
+			hasEngine: col.HasEngine,
+		}
+		for _, proc := range col.Processes {
+			converted.processes = append(converted.processes, rawProcess{
+				pid:         proc.PID,
+				uid:         proc.UID,
+				user:        proc.User,
+				name:        proc.Name,
+				command:     proc.Command,
+				renderNode:  proc.RenderNode,
+				cgroup:      proc.Cgroup,
+				container:   proc.Container,
+				displayName: proc.DisplayName,
+				category:    proc.Category,
+				steamAppID:  proc.SteamAppID,
+				vramBytes:   proc.VRAMBytes,
+				gttBytes:    proc.GTTBytes,
+				hasMemory:   proc.HasMemory,
+				engineTotal: proc.EngineTimeNS,
+				hasEngine:   proc.HasEngine,
+			})
+		}
+		collections[gpuID] = converted
+	}
+
+	return collections, 0, nil
+}
+
+func newManager(cfg config.ProcConfig, gpus []gpu.Info, logger *slog.Logger) *Manager {
+	gpuIDs := make([]string, 0, len(gpus))
+	renderNodes := make(map[string]string, len(gpus))
+	for _, info := range gpus {
+		gpuIDs = append(gpuIDs, info.ID)
+		renderNodes[info.ID] = info.RenderNode
+	}
+
+	return &Manager{
+		cfg:         cfg,
+		logger:      logger.With("component", "procscan_manager"),
+		gpuIDs:      gpuIDs,
+		renderNode:  renderNodes,
+		lookup:      newGPULookup(gpuIDs, renderNodes),
+		latest:      make(map[string]Snapshot),
+		subscribers: make(map[string]map[*procSubscriber]struct{}),
+		prevEngine:  make(map[string]map[int]uint64),
+		exits:       newExitTracker(cfg.MaxExited),
+		history:     newHistoryStore(cfg.HistoryMaxPIDs, cfg.HistoryPoints),
+		energy:      newEnergyLedger(time.Now()),
+		activity:    make(chan struct{}, 1),
+	}
+}
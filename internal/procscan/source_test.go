@@ -0,0 +1,63 @@
+package procscan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/config"
+	"github.com/skobkin/amdgputop-web/internal/gpu"
+)
+
+type stubSource struct {
+	engine uint64
+}
+
+func (s *stubSource) Collect() (map[string]SourceCollection, error) {
+	s.engine += 500_000_000
+
+	return map[string]SourceCollection{
+		"card0": {
+			HasMemory: true,
+			HasEngine: true,
+			Processes: []SourceProcess{{
+				PID:          42,
+				Name:         "game",
+				VRAMBytes:    1 << 20,
+				HasMemory:    true,
+				EngineTimeNS: s.engine,
+				HasEngine:    true,
+			}},
+		},
+	}, nil
+}
+
+func (s *stubSource) Close() error { return nil }
+
+func TestManagerWithSource(t *testing.T) {
+	cfg := config.ProcConfig{Enable: true, ScanInterval: time.Second, MaxPIDs: 10}
+	gpus := []gpu.Info{{ID: "card0", RenderNode: "/dev/dri/renderD128"}}
+
+	manager, err := NewManagerWithSource(cfg, &stubSource{}, gpus, nil)
+	if err != nil {
+		t.Fatalf("NewManagerWithSource: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+
+	manager.performScan(time.Unix(0, 0))
+	manager.performScan(time.Unix(1, 0))
+
+	snap, ok := manager.Latest("card0")
+	if !ok || len(snap.Processes) != 1 {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+	p := snap.Processes[0]
+	if p.VRAMBytes == nil || *p.VRAMBytes != 1<<20 {
+		t.Fatalf("unexpected VRAM %+v", p.VRAMBytes)
+	}
+	if p.GPUTimeMSPerS == nil || *p.GPUTimeMSPerS != 500 {
+		t.Fatalf("expected 500 ms/s of GPU time, got %+v", p.GPUTimeMSPerS)
+	}
+	if !snap.Capabilities.VRAMGTTFromFDInfo || !snap.Capabilities.EngineTimeFromFDInfo {
+		t.Fatalf("unexpected capabilities %+v", snap.Capabilities)
+	}
+}
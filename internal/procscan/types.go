@@ -14,17 +14,106 @@ type Snapshot struct {
 type Capabilities struct {
 	VRAMGTTFromFDInfo    bool `json:"vram_gtt_from_fdinfo"`
 	EngineTimeFromFDInfo bool `json:"engine_time_from_fdinfo"`
+	VRAMGTTFromGEMInfo   bool `json:"vram_gtt_from_gem_info"`
 }
 
 // Process summarises GPU memory usage for a process observed via fdinfo.
 type Process struct {
-	PID           int      `json:"pid"`
-	UID           int      `json:"uid"`
-	User          string   `json:"user"`
-	Name          string   `json:"name"`
-	Command       string   `json:"cmd"`
-	RenderNode    string   `json:"render_node"`
-	VRAMBytes     *uint64  `json:"vram_bytes"`
-	GTTBytes      *uint64  `json:"gtt_bytes"`
-	GPUTimeMSPerS *float64 `json:"gpu_time_ms_per_s"`
+	PID           int        `json:"pid"`
+	UID           int        `json:"uid"`
+	User          string     `json:"user"`
+	Name          string     `json:"name"`
+	Command       string     `json:"cmd"`
+	RenderNode    string     `json:"render_node"`
+	Cgroup        string     `json:"cgroup,omitempty"`
+	Container     string     `json:"container,omitempty"`
+	DisplayName   string     `json:"display_name,omitempty"`
+	Category      string     `json:"category,omitempty"`
+	SteamAppID    string     `json:"steam_app_id,omitempty"`
+	VRAMBytes     *uint64    `json:"vram_bytes"`
+	GTTBytes      *uint64    `json:"gtt_bytes"`
+	GPUTimeMSPerS *float64   `json:"gpu_time_ms_per_s"`
+	BOHistogram   []BOBucket `json:"bo_histogram,omitempty"`
+	PowerW        *float64   `json:"power_w,omitempty"`
+	EnergyJ       *float64   `json:"energy_j,omitempty"`
+	EnergyWh      *float64   `json:"energy_wh,omitempty"`
+}
+
+// BOBucket counts buffer objects up to MaxBytes in size; a zero MaxBytes marks
+// the unbounded last bucket.
+type BOBucket struct {
+	MaxBytes uint64 `json:"max_bytes,omitempty"`
+	Count    int    `json:"count"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+// ExitedProcess records the last observed totals of a GPU process that is gone.
+// ExitedAt is when the exit was detected, not when it happened: the process
+// ended between LastSeen and ExitedAt, which can be far apart after the lazy
+// scanner was idle.
+type ExitedProcess struct {
+	PID           int       `json:"pid"`
+	UID           int       `json:"uid"`
+	User          string    `json:"user"`
+	Name          string    `json:"name"`
+	Command       string    `json:"cmd"`
+	RenderNode    string    `json:"render_node"`
+	DisplayName   string    `json:"display_name,omitempty"`
+	Category      string    `json:"category,omitempty"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+	ExitedAt      time.Time `json:"exited_at"`
+	GPUTimeMS     *float64  `json:"gpu_time_ms"`
+	PeakVRAMBytes *uint64   `json:"peak_vram_bytes"`
+	PeakGTTBytes  *uint64   `json:"peak_gtt_bytes"`
+	EnergyJ       *float64  `json:"energy_j,omitempty"`
+	EnergyWh      *float64  `json:"energy_wh,omitempty"`
+}
+
+// ExitedSnapshot lists exited GPU processes for a GPU, newest first.
+type ExitedSnapshot struct {
+	GPUId     string          `json:"gpu_id"`
+	Processes []ExitedProcess `json:"processes"`
+}
+
+// ProcessPoint is a single per-process history sample taken at scan time.
+type ProcessPoint struct {
+	Timestamp     time.Time `json:"ts"`
+	VRAMBytes     *uint64   `json:"vram_bytes"`
+	GTTBytes      *uint64   `json:"gtt_bytes"`
+	GPUTimeMSPerS *float64  `json:"gpu_time_ms_per_s"`
+}
+
+// ProcessHistory holds recent samples of a live GPU process, oldest first.
+type ProcessHistory struct {
+	GPUId  string         `json:"gpu_id"`
+	PID    int            `json:"pid"`
+	Name   string         `json:"name"`
+	Points []ProcessPoint `json:"points"`
+}
+
+// EnergySnapshot summarises estimated GPU energy per user and application
+// since the process scanner started.
+type EnergySnapshot struct {
+	GPUId     string       `json:"gpu_id"`
+	Since     time.Time    `json:"since"`
+	EnergyJ   float64      `json:"energy_j"`
+	EnergyWh  float64      `json:"energy_wh"`
+	Users     []UserEnergy `json:"users"`
+	Processes []AppEnergy  `json:"processes"`
+}
+
+// UserEnergy is the estimated energy attributed to a user.
+type UserEnergy struct {
+	User     string  `json:"user"`
+	EnergyJ  float64 `json:"energy_j"`
+	EnergyWh float64 `json:"energy_wh"`
+}
+
+// AppEnergy is the estimated energy attributed to a user's application.
+type AppEnergy struct {
+	User     string  `json:"user"`
+	Name     string  `json:"name"`
+	EnergyJ  float64 `json:"energy_j"`
+	EnergyWh float64 `json:"energy_wh"`
 }
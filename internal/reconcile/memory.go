@@ -0,0 +1,78 @@
+// Package reconcile compares GPU-wide memory usage with the per-process
+// totals observed by the process scanner.
+package reconcile
+
+import (
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+// Pool reports how much of a memory pool is attributed to visible processes.
+// Unattributed memory covers the kernel, firmware, display scanout and
+// processes whose fdinfo could not be read.
+type Pool struct {
+	UsedBytes         *uint64 `json:"used_bytes"`
+	TotalBytes        *uint64 `json:"total_bytes"`
+	AttributedBytes   uint64  `json:"attributed_bytes"`
+	UnattributedBytes *uint64 `json:"unattributed_bytes"`
+}
+
+// MemoryReport joins the latest sample and process snapshot of a GPU.
+type MemoryReport struct {
+	GPUId           string    `json:"gpu_id"`
+	SampleTimestamp time.Time `json:"sample_ts"`
+	ProcsTimestamp  time.Time `json:"procs_ts"`
+	VRAM            Pool      `json:"vram"`
+	GTT             Pool      `json:"gtt"`
+	Processes       int       `json:"processes"`
+	// ProcsPermissionDenied counts processes the last scan could not
+	// inspect. The scan covers all GPUs, so some of them may not use this
+	// one; their memory, if any, is part of the unattributed bytes.
+	ProcsPermissionDenied int `json:"procs_permission_denied"`
+}
+
+// Memory builds a reconciliation report. Attributed totals only include
+// processes that expose memory in fdinfo; denied is the scan-wide count of
+// processes that could not be inspected.
+func Memory(sample sampler.Sample, snapshot procscan.Snapshot, denied int) MemoryReport {
+	var vram, gtt uint64
+	for _, proc := range snapshot.Processes {
+		if proc.VRAMBytes != nil {
+			vram += *proc.VRAMBytes
+		}
+		if proc.GTTBytes != nil {
+			gtt += *proc.GTTBytes
+		}
+	}
+
+	return MemoryReport{
+		GPUId:                 sample.GPUId,
+		SampleTimestamp:       sample.Timestamp,
+		ProcsTimestamp:        snapshot.Timestamp,
+		VRAM:                  newPool(sample.Metrics.VRAMUsedBytes, sample.Metrics.VRAMTotalBytes, vram),
+		GTT:                   newPool(sample.Metrics.GTTUsedBytes, sample.Metrics.GTTTotalBytes, gtt),
+		Processes:             len(snapshot.Processes),
+		ProcsPermissionDenied: denied,
+	}
+}
+
+func newPool(used, total *uint64, attributed uint64) Pool {
+	pool := Pool{
+		UsedBytes:       used,
+		TotalBytes:      total,
+		AttributedBytes: attributed,
+	}
+	if used != nil {
+		// Shared BOs are counted once per process, so attributed memory can
+		// exceed the GPU-wide figure; clamp instead of underflowing.
+		var rest uint64
+		if *used > attributed {
+			rest = *used - attributed
+		}
+		pool.UnattributedBytes = &rest
+	}
+
+	return pool
+}
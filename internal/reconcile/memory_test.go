@@ -0,0 +1,53 @@
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+func TestMemory(t *testing.T) {
+	ptr := func(v uint64) *uint64 { return &v }
+	now := time.Unix(1_700_000_000, 0)
+
+	sample := sampler.Sample{
+		GPUId:     "card0",
+		Timestamp: now,
+		Metrics: sampler.Metrics{
+			VRAMUsedBytes:  ptr(1000),
+			VRAMTotalBytes: ptr(4000),
+			GTTUsedBytes:   ptr(100),
+		},
+	}
+	snapshot := procscan.Snapshot{
+		GPUId:     "card0",
+		Timestamp: now.Add(-time.Second),
+		Processes: []procscan.Process{
+			{PID: 1, VRAMBytes: ptr(600), GTTBytes: ptr(150)},
+			{PID: 2, VRAMBytes: ptr(100)},
+			{PID: 3},
+		},
+	}
+
+	report := Memory(sample, snapshot, 2)
+	if report.GPUId != "card0" || report.Processes != 3 || report.ProcsPermissionDenied != 2 {
+		t.Fatalf("unexpected report header %+v", report)
+	}
+	if report.VRAM.AttributedBytes != 700 || report.VRAM.UnattributedBytes == nil || *report.VRAM.UnattributedBytes != 300 {
+		t.Fatalf("unexpected vram pool %+v", report.VRAM)
+	}
+	if report.VRAM.TotalBytes == nil || *report.VRAM.TotalBytes != 4000 {
+		t.Fatalf("unexpected vram total %+v", report.VRAM.TotalBytes)
+	}
+	// Shared buffers can push the attributed total above the GPU-wide figure.
+	if report.GTT.AttributedBytes != 150 || report.GTT.UnattributedBytes == nil || *report.GTT.UnattributedBytes != 0 {
+		t.Fatalf("unexpected gtt pool %+v", report.GTT)
+	}
+
+	empty := Memory(sampler.Sample{GPUId: "card1"}, procscan.Snapshot{}, 0)
+	if empty.VRAM.UnattributedBytes != nil || empty.VRAM.AttributedBytes != 0 {
+		t.Fatalf("expected unknown unattributed vram, got %+v", empty.VRAM)
+	}
+}
@@ -0,0 +1,266 @@
+package replay
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/gpu"
+	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+// maxFrameSize bounds a single NDJSON line; snapshots of busy hosts with
+// long command lines can get large.
+const maxFrameSize = 16 << 20
+
+// Recording is a loaded recording ready to be played back.
+type Recording struct {
+	GPUs     []gpu.Info
+	Interval time.Duration
+
+	samples   map[string][]sampleFrame
+	snapshots map[string][]snapshotFrame
+	duration  time.Duration
+}
+
+type sampleFrame struct {
+	offset time.Duration
+	sample sampler.Sample
+}
+
+type snapshotFrame struct {
+	offset   time.Duration
+	snapshot procscan.Snapshot
+}
+
+// Load reads a recording written by Recorder. A truncated tail, as left by
+// an unclean shutdown, is tolerated.
+func Load(path string) (*Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open recording: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("open recording: %w", err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64<<10), maxFrameSize)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("read recording header: %w", errors.Join(scanner.Err(), io.ErrUnexpectedEOF))
+	}
+	var hdr header
+	if err := json.Unmarshal(scanner.Bytes(), &hdr); err != nil {
+		return nil, fmt.Errorf("decode recording header: %w", err)
+	}
+	if hdr.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported recording version %d", hdr.Version)
+	}
+
+	rec := &Recording{
+		GPUs:      hdr.GPUs,
+		Interval:  time.Duration(hdr.IntervalMS) * time.Millisecond,
+		samples:   make(map[string][]sampleFrame),
+		snapshots: make(map[string][]snapshotFrame),
+	}
+	for scanner.Scan() {
+		var f frame
+		if err := json.Unmarshal(scanner.Bytes(), &f); err != nil {
+			// A partially written last line.
+			break
+		}
+		offset := time.Duration(f.OffsetMS) * time.Millisecond
+		rec.duration = max(rec.duration, offset)
+		switch {
+		case f.Stats != nil:
+			rec.samples[f.Stats.GPUId] = append(rec.samples[f.Stats.GPUId], sampleFrame{offset: offset, sample: *f.Stats})
+		case f.Procs != nil:
+			rec.snapshots[f.Procs.GPUId] = append(rec.snapshots[f.Procs.GPUId], snapshotFrame{offset: offset, snapshot: *f.Procs})
+		}
+	}
+	// Truncated gzip streams surface as read errors; keep what was decoded.
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, gzip.ErrChecksum) {
+		return nil, fmt.Errorf("read recording: %w", err)
+	}
+
+	for id := range rec.samples {
+		frames := rec.samples[id]
+		sort.SliceStable(frames, func(i, j int) bool { return frames[i].offset < frames[j].offset })
+	}
+	for id := range rec.snapshots {
+		frames := rec.snapshots[id]
+		sort.SliceStable(frames, func(i, j int) bool { return frames[i].offset < frames[j].offset })
+	}
+
+	return rec, nil
+}
+
+// HasProcesses reports whether the recording contains process snapshots.
+func (r *Recording) HasProcesses() bool {
+	return len(r.snapshots) > 0
+}
+
+// Player plays a recording back in a loop at a fixed speed. Frames are
+// re-stamped with the current time so charts and history behave as live.
+type Player struct {
+	rec   *Recording
+	speed float64
+	start time.Time
+	now   func() time.Time
+}
+
+// NewPlayer starts playback of rec at speed (1 = real time).
+func NewPlayer(rec *Recording, speed float64) (*Player, error) {
+	return newPlayer(rec, speed, time.Now)
+}
+
+func newPlayer(rec *Recording, speed float64, now func() time.Time) (*Player, error) {
+	if speed <= 0 {
+		return nil, fmt.Errorf("replay speed must be > 0")
+	}
+
+	return &Player{rec: rec, speed: speed, start: now(), now: now}, nil
+}
+
+// position returns the current offset into the recording.
+func (p *Player) position(now time.Time) time.Duration {
+	elapsed := time.Duration(float64(now.Sub(p.start)) * p.speed)
+	if p.rec.duration <= 0 {
+		return 0
+	}
+	// Loop with a one-interval pause so the last frame is shown too.
+	return elapsed % (p.rec.duration + p.rec.Interval)
+}
+
+// SamplerSources returns one sampler source per recorded GPU.
+func (p *Player) SamplerSources() map[string]sampler.Source {
+	sources := make(map[string]sampler.Source, len(p.rec.GPUs))
+	for _, info := range p.rec.GPUs {
+		sources[info.ID] = &sampleSource{player: p, gpuID: info.ID, frames: p.rec.samples[info.ID]}
+	}
+
+	return sources
+}
+
+// ProcSource returns a procscan source replaying recorded snapshots.
+func (p *Player) ProcSource() procscan.Source {
+	return &procSource{player: p, engine: make(map[string]map[int]float64)}
+}
+
+type sampleSource struct {
+	player *Player
+	gpuID  string
+	frames []sampleFrame
+}
+
+func (s *sampleSource) Sample() sampler.Sample {
+	now := s.player.now()
+	if len(s.frames) == 0 {
+		return sampler.Sample{GPUId: s.gpuID, Timestamp: now.UTC()}
+	}
+	pos := s.player.position(now)
+	idx := sort.Search(len(s.frames), func(i int) bool { return s.frames[i].offset > pos }) - 1
+	sample := s.frames[max(idx, 0)].sample
+	sample.GPUId = s.gpuID
+	sample.Timestamp = now.UTC()
+
+	return sample
+}
+
+func (s *sampleSource) Close() error {
+	return nil
+}
+
+// procSource rebuilds cumulative engine counters from the recorded rates so
+// the scanner reports the original GPU time at any playback speed.
+type procSource struct {
+	player *Player
+
+	mu      sync.Mutex
+	lastNow time.Time
+	engine  map[string]map[int]float64
+}
+
+func (s *procSource) Collect() (map[string]procscan.SourceCollection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.player.now()
+	var elapsed float64
+	if !s.lastNow.IsZero() {
+		elapsed = now.Sub(s.lastNow).Seconds()
+	}
+	s.lastNow = now
+	pos := s.player.position(now)
+
+	result := make(map[string]procscan.SourceCollection, len(s.player.rec.snapshots))
+	for gpuID, frames := range s.player.rec.snapshots {
+		idx := sort.Search(len(frames), func(i int) bool { return frames[i].offset > pos }) - 1
+		snapshot := frames[max(idx, 0)].snapshot
+
+		totals := s.engine[gpuID]
+		if totals == nil {
+			totals = make(map[int]float64)
+			s.engine[gpuID] = totals
+		}
+		next := make(map[int]float64, len(snapshot.Processes))
+
+		col := procscan.SourceCollection{
+			HasMemory: snapshot.Capabilities.VRAMGTTFromFDInfo || snapshot.Capabilities.VRAMGTTFromGEMInfo,
+			HasEngine: snapshot.Capabilities.EngineTimeFromFDInfo,
+			Processes: make([]procscan.SourceProcess, 0, len(snapshot.Processes)),
+		}
+		for _, proc := range snapshot.Processes {
+			out := procscan.SourceProcess{
+				PID:         proc.PID,
+				UID:         proc.UID,
+				User:        proc.User,
+				Name:        proc.Name,
+				Command:     proc.Command,
+				RenderNode:  proc.RenderNode,
+				Cgroup:      proc.Cgroup,
+				Container:   proc.Container,
+				DisplayName: proc.DisplayName,
+				Category:    proc.Category,
+				SteamAppID:  proc.SteamAppID,
+			}
+			if proc.VRAMBytes != nil {
+				out.VRAMBytes = *proc.VRAMBytes
+				out.HasMemory = true
+			}
+			if proc.GTTBytes != nil {
+				out.GTTBytes = *proc.GTTBytes
+			}
+			if proc.GPUTimeMSPerS != nil || col.HasEngine {
+				total := totals[proc.PID]
+				if proc.GPUTimeMSPerS != nil {
+					total += *proc.GPUTimeMSPerS * elapsed * 1_000_000
+				}
+				next[proc.PID] = total
+				out.EngineTimeNS = uint64(total)
+				out.HasEngine = true
+			}
+			col.Processes = append(col.Processes, out)
+		}
+		s.engine[gpuID] = next
+		result[gpuID] = col
+	}
+
+	return result, nil
+}
+
+func (s *procSource) Close() error {
+	return nil
+}
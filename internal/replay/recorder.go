@@ -0,0 +1,126 @@
+// Package replay records live sampler and process scanner streams to a file
+// and plays them back as sampler and procscan sources.
+package replay
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/gpu"
+	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+// formatVersion is bumped on incompatible changes to the recording layout.
+const formatVersion = 1
+
+// header is the first line of a recording. Recordings are gzip-compressed
+// NDJSON: the header followed by one frame per sample or snapshot.
+type header struct {
+	Version    int        `json:"version"`
+	StartedAt  time.Time  `json:"started_at"`
+	IntervalMS int64      `json:"interval_ms"`
+	GPUs       []gpu.Info `json:"gpus"`
+}
+
+// frame carries exactly one of Stats or Procs, offset from the start.
+type frame struct {
+	OffsetMS int64              `json:"t_ms"`
+	Stats    *sampler.Sample    `json:"stats,omitempty"`
+	Procs    *procscan.Snapshot `json:"procs,omitempty"`
+}
+
+// Recorder appends live samples and snapshots to a recording file. Its
+// Record methods are meant to be registered as manager listeners.
+type Recorder struct {
+	file  *os.File
+	gz    *gzip.Writer
+	enc   *json.Encoder
+	start time.Time
+
+	mu     sync.Mutex
+	err    error
+	closed bool
+}
+
+// NewRecorder creates (or truncates) path and writes the recording header.
+func NewRecorder(path string, gpus []gpu.Info, interval time.Duration) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create recording: %w", err)
+	}
+
+	r := &Recorder{
+		file:  file,
+		gz:    gzip.NewWriter(file),
+		start: time.Now(),
+	}
+	r.enc = json.NewEncoder(r.gz)
+	if err := r.enc.Encode(header{
+		Version:    formatVersion,
+		StartedAt:  r.start.UTC(),
+		IntervalMS: interval.Milliseconds(),
+		GPUs:       gpus,
+	}); err != nil {
+		_ = file.Close()
+
+		return nil, fmt.Errorf("write recording header: %w", err)
+	}
+
+	return r, nil
+}
+
+// RecordSample appends a sampler sample.
+func (r *Recorder) RecordSample(sample sampler.Sample) {
+	r.write(frame{OffsetMS: r.offset(sample.Timestamp), Stats: &sample})
+}
+
+// RecordSnapshot appends a process snapshot.
+func (r *Recorder) RecordSnapshot(snapshot procscan.Snapshot) {
+	r.write(frame{OffsetMS: r.offset(snapshot.Timestamp), Procs: &snapshot})
+}
+
+// Err reports the first write error, after which recording stops.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// Close flushes the recording. It returns the first write error, if any.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return r.err
+	}
+	r.closed = true
+
+	gzErr := r.gz.Close()
+	closeErr := r.file.Close()
+
+	return errors.Join(r.err, gzErr, closeErr)
+}
+
+func (r *Recorder) offset(ts time.Time) int64 {
+	return max(ts.Sub(r.start).Milliseconds(), 0)
+}
+
+func (r *Recorder) write(f frame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed || r.err != nil {
+		return
+	}
+	if err := r.enc.Encode(f); err != nil {
+		r.err = fmt.Errorf("write recording frame: %w", err)
+	}
+}
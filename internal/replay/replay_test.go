@@ -0,0 +1,128 @@
+package replay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/gpu"
+	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func recordSession(t *testing.T, path string) {
+	t.Helper()
+
+	gpus := []gpu.Info{{ID: "card0", Name: "Test GPU", RenderNode: "/dev/dri/renderD128"}}
+	recorder, err := NewRecorder(path, gpus, time.Second)
+	if err != nil {
+		t.Fatalf("NewRecorder returned error: %v", err)
+	}
+	for i := range 3 {
+		busy := float64(i+1) * 10
+		recorder.RecordSample(sampler.Sample{
+			GPUId:     "card0",
+			Timestamp: recorder.start.Add(time.Duration(i) * time.Second),
+			Metrics:   sampler.Metrics{GPUBusyPct: &busy},
+		})
+	}
+	vram := uint64(1 << 20)
+	rate := 250.0
+	recorder.RecordSnapshot(procscan.Snapshot{
+		GPUId:        "card0",
+		Timestamp:    recorder.start,
+		Capabilities: procscan.Capabilities{VRAMGTTFromFDInfo: true, EngineTimeFromFDInfo: true},
+		Processes:    []procscan.Process{{PID: 42, Name: "game", VRAMBytes: &vram, GPUTimeMSPerS: &rate}},
+	})
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "session.ndjson.gz")
+	recordSession(t, path)
+
+	rec, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(rec.GPUs) != 1 || rec.GPUs[0].Name != "Test GPU" || rec.Interval != time.Second || !rec.HasProcesses() {
+		t.Fatalf("unexpected recording %+v", rec)
+	}
+
+	clock := &testClock{now: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	player, err := newPlayer(rec, 2, clock.Now)
+	if err != nil {
+		t.Fatalf("newPlayer returned error: %v", err)
+	}
+	source := player.SamplerSources()["card0"]
+	procs := player.ProcSource()
+
+	if _, err := procs.Collect(); err != nil {
+		t.Fatalf("Collect returned error: %v", err)
+	}
+
+	// At double speed one second of wall time reaches the last frame.
+	clock.now = clock.now.Add(time.Second)
+	sample := source.Sample()
+	if sample.Metrics.GPUBusyPct == nil || *sample.Metrics.GPUBusyPct != 30 || !sample.Timestamp.Equal(clock.now) {
+		t.Fatalf("unexpected sample %+v", sample)
+	}
+
+	// The recorded rate is preserved regardless of playback speed.
+	collected, err := procs.Collect()
+	if err != nil {
+		t.Fatalf("Collect returned error: %v", err)
+	}
+	proc := collected["card0"].Processes[0]
+	if proc.PID != 42 || proc.VRAMBytes != 1<<20 || !proc.HasEngine || proc.EngineTimeNS != 250_000_000 {
+		t.Fatalf("unexpected process %+v", proc)
+	}
+
+	// Playback loops: 4s into a 3s cycle is back at the second frame.
+	clock.now = clock.now.Add(time.Second)
+	if sample := source.Sample(); *sample.Metrics.GPUBusyPct != 20 {
+		t.Fatalf("expected loop to second frame, got %v", *sample.Metrics.GPUBusyPct)
+	}
+}
+
+func TestLoadToleratesTruncatedRecording(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "session.ndjson.gz")
+	recordSession(t, path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	if err := os.WriteFile(path, data[:len(data)-12], 0o644); err != nil {
+		t.Fatalf("truncate recording: %v", err)
+	}
+
+	rec, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(rec.GPUs) != 1 {
+		t.Fatalf("unexpected recording %+v", rec)
+	}
+}
+
+func TestNewPlayerRejectsInvalidSpeed(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewPlayer(&Recording{}, 0); err == nil {
+		t.Fatalf("expected error for zero speed")
+	}
+}
@@ -0,0 +1,253 @@
+package sampler
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Sources a field can be read from.
+const (
+	SourceSysfs      = "sysfs"
+	SourceHwmon      = "hwmon"
+	SourceDebugfs    = "debugfs"
+	SourceGPUMetrics = "gpu_metrics"
+)
+
+// Reasons a field is unavailable.
+const (
+	// ReasonUnsupported means the driver does not expose the attribute for
+	// this GPU, or exposes it in a format that is not understood.
+	ReasonUnsupported = "unsupported"
+	// ReasonPermissionDenied means the attribute exists but is not readable
+	// by the process (typical for debugfs without CAP_SYS_ADMIN).
+	ReasonPermissionDenied = "permission_denied"
+	// ReasonNoHwmon means the device has no hwmon directory.
+	ReasonNoHwmon = "no_hwmon"
+	// ReasonDebugfsUnavailable means debugfs is not configured, not mounted
+	// or has no directory for the card.
+	ReasonDebugfsUnavailable = "debugfs_unavailable"
+	// ReasonParseError means the attribute was read but its value could not
+	// be parsed.
+	ReasonParseError = "parse_error"
+	// ReasonReadError is any other read failure, e.g. EIO during a reset.
+	ReasonReadError = "read_error"
+)
+
+var (
+	errNoHwmon      = errors.New("no hwmon device")
+	errNoDebugfs    = errors.New("debugfs not available")
+	errInvalidValue = errors.New("invalid value")
+	errNotReported  = errors.New("value not reported")
+)
+
+// StateFields lists the fields of State in the order capabilities report
+// them.
+var StateFields = []string{
+	"perf_level",
+	"power_profile",
+	"link_speed",
+	"link_width",
+	"max_link_speed",
+	"max_link_width",
+	"throttle_status",
+}
+
+// Attempt is one source tried for a field.
+type Attempt struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	// Reason and Error are empty when the attempt produced the value.
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Capability tells where a field of the last sample came from or why it is
+// missing.
+type Capability struct {
+	Field     string `json:"field"`
+	Available bool   `json:"available"`
+	// Source and Path name the attempt that produced the value.
+	Source string `json:"source,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// Reason is the reason of the primary source when the field is missing.
+	Reason   string    `json:"reason,omitempty"`
+	Attempts []Attempt `json:"attempts"`
+}
+
+// Capabilities is the provenance of every metric and state field of a GPU,
+// as of its last sample taken while the GPU was awake.
+type Capabilities struct {
+	GPUId     string       `json:"gpu_id"`
+	Timestamp time.Time    `json:"ts"`
+	Fields    []Capability `json:"fields"`
+}
+
+// CapabilitySummary condenses Capabilities to the available fields and the
+// reasons of the missing ones.
+type CapabilitySummary struct {
+	Available []string          `json:"available"`
+	Missing   map[string]string `json:"missing,omitempty"`
+}
+
+// Summary returns the summary of c.
+func (c Capabilities) Summary() CapabilitySummary {
+	summary := CapabilitySummary{Available: make([]string, 0, len(c.Fields))}
+	for _, field := range c.Fields {
+		if field.Available {
+			summary.Available = append(summary.Available, field.Field)
+
+			continue
+		}
+		if summary.Missing == nil {
+			summary.Missing = make(map[string]string)
+		}
+		summary.Missing[field.Field] = field.Reason
+	}
+
+	return summary
+}
+
+// CapabilityReporter is implemented by sources that know where their
+// readings come from. *Reader implements it.
+type CapabilityReporter interface {
+	// Capabilities returns the provenance of the last sample; false until
+	// a GPU has been sampled while awake.
+	Capabilities() (Capabilities, bool)
+}
+
+// Capabilities returns the field provenance of a GPU. It reports false
+// when the source does not track provenance or has not sampled yet.
+func (m *Manager) Capabilities(gpuID string) (Capabilities, bool, error) {
+	m.mu.RLock()
+	reader, ok := m.readers[gpuID]
+	m.mu.RUnlock()
+	if !ok {
+		return Capabilities{}, false, fmt.Errorf("unknown gpu %q", gpuID)
+	}
+	reporter, ok := reader.(CapabilityReporter)
+	if !ok {
+		return Capabilities{}, false, nil
+	}
+	caps, ok := reporter.Capabilities()
+
+	return caps, ok, nil
+}
+
+// Capabilities implements CapabilityReporter.
+func (r *Reader) Capabilities() (Capabilities, bool) {
+	r.capsMu.Lock()
+	defer r.capsMu.Unlock()
+	if len(r.capsFields) == 0 {
+		return Capabilities{}, false
+	}
+
+	return r.capsFields.capabilities(r.cardID, r.capsAt), true
+}
+
+// provenance collects the attempts made for each field during one sample.
+type provenance map[string]*Capability
+
+// observe records an attempt to read field and returns value, or nil when
+// err is set.
+func observe[T any](p provenance, field, source, path string, value *T, err error) *T {
+	c, ok := p[field]
+	if !ok {
+		c = &Capability{Field: field}
+		p[field] = c
+	}
+	attempt := Attempt{Source: source, Path: path}
+	if err == nil && value == nil {
+		err = errNotReported
+	}
+	if err != nil {
+		attempt.Reason, attempt.Error = absenceReason(err), err.Error()
+		if len(c.Attempts) == 0 {
+			c.Reason = attempt.Reason
+		}
+		c.Attempts = append(c.Attempts, attempt)
+
+		return nil
+	}
+	c.Available, c.Source, c.Path, c.Reason = true, source, path, ""
+	c.Attempts = append(c.Attempts, attempt)
+
+	return value
+}
+
+// observeString is observe for string fields, where "" is not a value.
+func observeString(p provenance, field, source, path, value string, err error) string {
+	var ptr *string
+	if value != "" {
+		ptr = &value
+	}
+	if got := observe(p, field, source, path, ptr, err); got != nil {
+		return *got
+	}
+
+	return ""
+}
+
+// capabilities orders the recorded fields like MetricFields and StateFields.
+func (p provenance) capabilities(gpuID string, ts time.Time) Capabilities {
+	caps := Capabilities{GPUId: gpuID, Timestamp: ts, Fields: make([]Capability, 0, len(p))}
+	order := make(map[string]int, len(MetricFields)+len(StateFields))
+	for i, field := range MetricFields {
+		order[field.Name] = i
+	}
+	for i, field := range StateFields {
+		order[field] = len(MetricFields) + i
+	}
+	for _, c := range p {
+		caps.Fields = append(caps.Fields, *c)
+	}
+	sort.Slice(caps.Fields, func(i, j int) bool {
+		return order[caps.Fields[i].Field] < order[caps.Fields[j].Field]
+	})
+
+	return caps
+}
+
+func absenceReason(err error) string {
+	switch {
+	case errors.Is(err, errNoHwmon):
+		return ReasonNoHwmon
+	case errors.Is(err, errNoDebugfs):
+		return ReasonDebugfsUnavailable
+	case errors.Is(err, errInvalidValue):
+		return ReasonParseError
+	case errors.Is(err, fs.ErrPermission):
+		return ReasonPermissionDenied
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, errNotReported), errors.Is(err, errors.ErrUnsupported),
+		errors.Is(err, syscall.EOPNOTSUPP), errors.Is(err, syscall.ENODATA), errors.Is(err, syscall.EINVAL):
+		// amdgpu returns EOPNOTSUPP/EINVAL/ENODATA for attributes the ASIC
+		// does not implement.
+		return ReasonUnsupported
+	}
+
+	return ReasonReadError
+}
+
+// devicePath is the sysfs path of a device attribute.
+func (r *Reader) devicePath(name string) string {
+	return filepath.Join(r.sysfsPath, drmClassPath, r.cardID, "device", name)
+}
+
+// hwmonPath is the path of a hwmon attribute.
+func (r *Reader) hwmonPath(name string) string {
+	if r.hwmonRoot == nil {
+		return filepath.Join(r.sysfsPath, drmClassPath, r.cardID, "device", "hwmon", "*", name)
+	}
+
+	return filepath.Join(r.hwmonRoot.Name(), name)
+}
+
+// debugPath is the path of a debugfs file of the card.
+func (r *Reader) debugPath(name string) string {
+	return filepath.Join(r.debugfsPath, "dri", strconv.Itoa(r.cardIndex), name)
+}
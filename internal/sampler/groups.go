@@ -0,0 +1,190 @@
+package sampler
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MetricGroup is a set of sample fields read at the same cadence.
+type MetricGroup uint8
+
+const (
+	// GroupFast holds busy %, clocks and power. It is read every sample.
+	GroupFast MetricGroup = 1 << iota
+	// GroupMedium holds temperature, fan speed, memory usage and the
+	// throttle status.
+	GroupMedium
+	// GroupSlow holds memory totals, the PCIe link, the performance level
+	// and the power profile.
+	GroupSlow
+
+	// AllGroups reads every field.
+	AllGroups = GroupFast | GroupMedium | GroupSlow
+)
+
+// metricGroups lists the groups in cadence order.
+var metricGroups = []MetricGroup{GroupFast, GroupMedium, GroupSlow}
+
+// groupFields maps each group to the names of its fields in MetricFields
+// and StateFields.
+var groupFields = map[MetricGroup][]string{
+	GroupFast:   {"gpu_busy_pct", "mem_busy_pct", "sclk_mhz", "mclk_mhz", "power_w"},
+	GroupMedium: {"temp_c", "fan_rpm", "vram_used_bytes", "gtt_used_bytes", "throttle_status"},
+	GroupSlow: {
+		"vram_total_bytes", "gtt_total_bytes",
+		"perf_level", "power_profile", "link_speed", "link_width", "max_link_speed", "max_link_width",
+	},
+}
+
+// GroupSampler is implemented by sources that can read metric groups
+// separately. *Reader implements it.
+type GroupSampler interface {
+	// SampleGroups reads the fields of groups only; the others are left
+	// empty.
+	SampleGroups(groups MetricGroup) Sample
+}
+
+// groupCache holds the last merged sample of a GPU and when each group was
+// last read.
+type groupCache struct {
+	mu      sync.Mutex
+	read    map[MetricGroup]time.Time
+	last    Sample
+	failing bool
+}
+
+// SetGroupIntervals sets how often the medium and slow metric groups are
+// read. The fast group is read every sample; between reads the last values
+// of the other groups are merged into published samples. Zero reads a
+// group every sample, which is the default. It must be called before Run.
+func (m *Manager) SetGroupIntervals(medium, slow time.Duration) error {
+	if medium < 0 || slow < 0 {
+		return fmt.Errorf("group intervals must be >= 0")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.groupIntervals = map[MetricGroup]time.Duration{GroupMedium: medium, GroupSlow: slow}
+
+	return nil
+}
+
+// readSource samples a GPU, reading only the groups that are due when the
+// source supports it.
+func (m *Manager) readSource(id string, reader Source) Sample {
+	grouped, ok := reader.(GroupSampler)
+	if !ok {
+		return reader.Sample()
+	}
+
+	m.mu.RLock()
+	intervals := m.groupIntervals
+	m.mu.RUnlock()
+	if len(intervals) == 0 {
+		return grouped.SampleGroups(AllGroups)
+	}
+
+	m.groupMu.Lock()
+	cache, ok := m.groups[id]
+	if !ok {
+		cache = &groupCache{read: make(map[MetricGroup]time.Time, len(metricGroups))}
+		m.groups[id] = cache
+	}
+	m.groupMu.Unlock()
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	now := time.Now()
+	// Ticks jitter; a group due "about now" is read on this tick rather
+	// than a whole interval later.
+	slack := m.interval / 10
+	due := GroupFast
+	for _, group := range metricGroups[1:] {
+		last, seen := cache.read[group]
+		if !seen || now.Sub(last) >= intervals[group]-slack {
+			due |= group
+		}
+	}
+
+	sample := grouped.SampleGroups(due)
+	switch {
+	case sample.Suspended():
+		// Everything is re-read on resume.
+		clear(cache.read)
+		cache.last = Sample{}
+
+		return sample
+	case !groupsHaveReadings(sample, due) && (cache.failing || groupsHaveReadings(cache.last, due)):
+		// A failing reader must not be masked by cached values; read
+		// everything again until it recovers.
+		clear(cache.read)
+		cache.last = Sample{}
+		cache.failing = true
+
+		return sample
+	}
+	cache.failing = false
+
+	for _, group := range metricGroups {
+		if due&group != 0 {
+			cache.read[group] = now
+		} else {
+			copyGroup(&sample, cache.last, group)
+		}
+	}
+	cache.last = sample
+
+	return sample
+}
+
+// copyGroup copies the fields of group from src to dst.
+func copyGroup(dst *Sample, src Sample, group MetricGroup) {
+	switch group {
+	case GroupFast:
+		dst.Metrics.GPUBusyPct = src.Metrics.GPUBusyPct
+		dst.Metrics.MemBusyPct = src.Metrics.MemBusyPct
+		dst.Metrics.SCLKMHz = src.Metrics.SCLKMHz
+		dst.Metrics.MCLKMHz = src.Metrics.MCLKMHz
+		dst.Metrics.PowerW = src.Metrics.PowerW
+	case GroupMedium:
+		dst.Metrics.TempC = src.Metrics.TempC
+		dst.Metrics.FanRPM = src.Metrics.FanRPM
+		dst.Metrics.VRAMUsedBytes = src.Metrics.VRAMUsedBytes
+		dst.Metrics.GTTUsedBytes = src.Metrics.GTTUsedBytes
+		dst.State.ThrottleStatus = src.State.ThrottleStatus
+	case GroupSlow:
+		dst.Metrics.VRAMTotalBytes = src.Metrics.VRAMTotalBytes
+		dst.Metrics.GTTTotalBytes = src.Metrics.GTTTotalBytes
+		dst.State.PerfLevel = src.State.PerfLevel
+		dst.State.PowerProfile = src.State.PowerProfile
+		dst.State.LinkSpeed = src.State.LinkSpeed
+		dst.State.LinkWidth = src.State.LinkWidth
+		dst.State.MaxLinkSpeed = src.State.MaxLinkSpeed
+		dst.State.MaxLinkWidth = src.State.MaxLinkWidth
+	}
+}
+
+// groupsHaveReadings reports whether any metric of groups is set.
+func groupsHaveReadings(sample Sample, groups MetricGroup) bool {
+	for _, field := range MetricFields {
+		if groups&groupOf(field.Name) == 0 {
+			continue
+		}
+		if _, ok := field.Value(sample.Metrics); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func groupOf(field string) MetricGroup {
+	for group, fields := range groupFields {
+		if slices.Contains(fields, field) {
+			return group
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,120 @@
+package sampler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// reopenAfter is the number of consecutive samples without any reading
+// after which a Reader reopens its directory handles. Handles opened before
+// a GPU reset or an amdgpu reload point at removed sysfs nodes, and hwmon
+// may come back under another index.
+const reopenAfter = 3
+
+// OnReopen registers fn to be called after the reader has reopened its
+// handles, with the number of consecutive samples that had no readings.
+func (r *Reader) OnReopen(fn func(failedSamples int)) {
+	r.healMu.Lock()
+	defer r.healMu.Unlock()
+	r.onReopen = fn
+}
+
+// heal counts samples without readings and reopens the roots every
+// reopenAfter of them until readings return. A sample of some groups only
+// counts as failed when those groups had readings before, or when no other
+// group has any, so a group the GPU never reports does not trigger it.
+func (r *Reader) heal(sample Sample, groups MetricGroup) {
+	if sample.Suspended() {
+		return
+	}
+
+	r.healMu.Lock()
+	reopened := r.healLocked(sample, groups)
+	failures, onReopen := r.failures, r.onReopen
+	r.healMu.Unlock()
+	if reopened && onReopen != nil {
+		onReopen(failures)
+	}
+}
+
+// healLocked does the work of heal and reports whether the roots were
+// reopened. Callers hold r.healMu.
+func (r *Reader) healLocked(sample Sample, groups MetricGroup) bool {
+	if r.closed {
+		return false
+	}
+	previous := r.readingGroups
+	for _, group := range metricGroups {
+		if groups&group == 0 {
+			continue
+		}
+		if groupsHaveReadings(sample, group) {
+			r.readingGroups |= group
+		} else {
+			r.readingGroups &^= group
+		}
+	}
+	if groupsHaveReadings(sample, groups) {
+		if r.failures >= reopenAfter {
+			r.logger.Info("reader recovered", "failed_samples", r.failures)
+		}
+		r.failures = 0
+
+		return false
+	}
+	if r.failures == 0 && previous&groups == 0 && previous&^groups != 0 {
+		return false
+	}
+
+	r.failures++
+	if r.failures%reopenAfter != 0 {
+		return false
+	}
+	if err := r.reopen(); err != nil {
+		r.logger.Warn("reader reopen failed", "failed_samples", r.failures, "err", err)
+
+		return false
+	}
+	r.logger.Info("reader reopened after failed samples", "failed_samples", r.failures)
+
+	return true
+}
+
+// reopen replaces all roots, re-detecting hwmon. The old roots are kept
+// when the device cannot be opened. Callers hold r.healMu.
+func (r *Reader) reopen() error {
+	roots, err := openRoots(r.cardID, r.cardIndex, r.sysfsPath, r.debugfsPath)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	old := r.setRoots(roots)
+	r.mu.Unlock()
+
+	return old.close()
+}
+
+func (roots readerRoots) close() error {
+	var errs []error
+	for _, handle := range []struct {
+		name string
+		root *os.Root
+	}{
+		{"hwmon", roots.hwmon},
+		{"debug", roots.debugCard},
+		{"device", roots.device},
+		{"debugfs", roots.debug},
+		{"sysfs", roots.sys},
+	} {
+		if handle.root == nil {
+			continue
+		}
+		if err := handle.root.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close %s root: %w", handle.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
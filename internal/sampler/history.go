@@ -0,0 +1,326 @@
+package sampler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrHistoryDisabled is returned by History when EnableHistory was not called.
+var ErrHistoryDisabled = errors.New("sample history disabled")
+
+// HistoryStore persists samples beyond the in-memory ring.
+type HistoryStore interface {
+	// Append stores a freshly taken sample.
+	Append(sample Sample) error
+	// Query returns time-ordered buckets for the GPU at the store's finest
+	// resolution that still covers the range and is not finer than step.
+	Query(gpuID string, since, until time.Time, step time.Duration) ([]HistoryBucket, error)
+}
+
+// MetricField describes a numeric metric of a Sample by its JSON name.
+type MetricField struct {
+	Name  string
+	Value func(Metrics) (float64, bool)
+}
+
+func floatField(name string, get func(Metrics) *float64) MetricField {
+	return MetricField{Name: name, Value: func(m Metrics) (float64, bool) {
+		if v := get(m); v != nil {
+			return *v, true
+		}
+
+		return 0, false
+	}}
+}
+
+func uintField(name string, get func(Metrics) *uint64) MetricField {
+	return MetricField{Name: name, Value: func(m Metrics) (float64, bool) {
+		if v := get(m); v != nil {
+			return float64(*v), true
+		}
+
+		return 0, false
+	}}
+}
+
+// MetricFields lists every numeric metric in Metrics. The order is part of the
+// on-disk format of persisted history, so new metrics must be appended.
+var MetricFields = []MetricField{
+	floatField("gpu_busy_pct", func(m Metrics) *float64 { return m.GPUBusyPct }),
+	floatField("mem_busy_pct", func(m Metrics) *float64 { return m.MemBusyPct }),
+	floatField("sclk_mhz", func(m Metrics) *float64 { return m.SCLKMHz }),
+	floatField("mclk_mhz", func(m Metrics) *float64 { return m.MCLKMHz }),
+	floatField("temp_c", func(m Metrics) *float64 { return m.TempC }),
+	floatField("fan_rpm", func(m Metrics) *float64 { return m.FanRPM }),
+	floatField("power_w", func(m Metrics) *float64 { return m.PowerW }),
+	uintField("vram_used_bytes", func(m Metrics) *uint64 { return m.VRAMUsedBytes }),
+	uintField("vram_total_bytes", func(m Metrics) *uint64 { return m.VRAMTotalBytes }),
+	uintField("gtt_used_bytes", func(m Metrics) *uint64 { return m.GTTUsedBytes }),
+	uintField("gtt_total_bytes", func(m Metrics) *uint64 { return m.GTTTotalBytes }),
+}
+
+// Aggregate summarises the values of a metric within a history bucket.
+type Aggregate struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+// HistoryBucket aggregates the samples that fall into [Timestamp, Timestamp+step).
+type HistoryBucket struct {
+	Timestamp time.Time            `json:"ts"`
+	Count     int                  `json:"count"`
+	Metrics   map[string]Aggregate `json:"metrics"`
+}
+
+// History is a downsampled view of the retained samples of a GPU.
+type History struct {
+	GPUId   string          `json:"gpu_id"`
+	Since   time.Time       `json:"since"`
+	Until   time.Time       `json:"until"`
+	StepMS  int64           `json:"step_ms"`
+	Buckets []HistoryBucket `json:"buckets"`
+}
+
+// sampleRing is a fixed-size ring of samples ordered by insertion time.
+type sampleRing struct {
+	samples []Sample
+	next    int
+	full    bool
+	version uint64
+}
+
+func newSampleRing(size int) *sampleRing {
+	return &sampleRing{samples: make([]Sample, size)}
+}
+
+func (r *sampleRing) push(sample Sample) {
+	r.samples[r.next] = sample
+	r.version++
+	r.next++
+	if r.next == len(r.samples) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// each visits the retained samples from oldest to newest.
+func (r *sampleRing) each(fn func(Sample)) {
+	if r.full {
+		for _, sample := range r.samples[r.next:] {
+			fn(sample)
+		}
+	}
+	for _, sample := range r.samples[:r.next] {
+		fn(sample)
+	}
+}
+
+func (r *sampleRing) len() int {
+	if r.full {
+		return len(r.samples)
+	}
+
+	return r.next
+}
+
+// EnableHistory retains up to maxPoints samples per GPU for History queries.
+// It must be called before Run.
+func (m *Manager) EnableHistory(maxPoints int) error {
+	if maxPoints <= 0 {
+		return fmt.Errorf("history points must be > 0")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.historySize = maxPoints
+	m.history = make(map[string]*sampleRing, len(m.readers))
+
+	return nil
+}
+
+// History returns samples between since and until aggregated into buckets of
+// step. A zero since or until leaves that side of the range open, and a zero
+// step falls back to the sampling interval. With a HistoryStore attached the
+// query is served from it instead of the in-memory ring.
+func (m *Manager) History(gpuID string, since, until time.Time, step time.Duration) (History, error) {
+	m.mu.RLock()
+	_, ok := m.readers[gpuID]
+	m.mu.RUnlock()
+	if !ok {
+		return History{}, fmt.Errorf("unknown gpu %q", gpuID)
+	}
+	if step < 0 {
+		return History{}, fmt.Errorf("step must be >= 0")
+	}
+	if step == 0 {
+		step = m.interval
+	}
+
+	result := History{
+		GPUId:   gpuID,
+		Since:   since,
+		Until:   until,
+		StepMS:  step.Milliseconds(),
+		Buckets: []HistoryBucket{},
+	}
+
+	if m.store != nil {
+		points, err := m.store.Query(gpuID, since, until, step)
+		if err != nil {
+			return History{}, err
+		}
+		result.Buckets = Downsample(points, step)
+
+		return result, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.history == nil {
+		return History{}, ErrHistoryDisabled
+	}
+	ring, ok := m.history[gpuID]
+	if !ok {
+		return result, nil
+	}
+
+	points := make([]HistoryBucket, 0, ring.len())
+	ring.each(func(sample Sample) {
+		if !since.IsZero() && sample.Timestamp.Before(since) {
+			return
+		}
+		if !until.IsZero() && sample.Timestamp.After(until) {
+			return
+		}
+		points = append(points, SampleBucket(sample))
+	})
+	result.Buckets = Downsample(points, step)
+
+	return result, nil
+}
+
+// SampleBucket wraps a single sample as a bucket with a count of one.
+func SampleBucket(sample Sample) HistoryBucket {
+	bucket := HistoryBucket{
+		Timestamp: sample.Timestamp,
+		Count:     1,
+		Metrics:   make(map[string]Aggregate, len(MetricFields)),
+	}
+	for _, field := range MetricFields {
+		if value, ok := field.Value(sample.Metrics); ok {
+			bucket.Metrics[field.Name] = Aggregate{Min: value, Avg: value, Max: value}
+		}
+	}
+
+	return bucket
+}
+
+// Downsample merges time-ordered buckets into buckets aligned to step.
+// Averages are weighted by the sample count of each input bucket.
+func Downsample(points []HistoryBucket, step time.Duration) []HistoryBucket {
+	type accumulator struct {
+		min, max, sum float64
+		count         int
+	}
+
+	result := []HistoryBucket{}
+	var (
+		current HistoryBucket
+		accs    map[string]*accumulator
+	)
+	flush := func() {
+		if current.Count == 0 {
+			return
+		}
+		current.Metrics = make(map[string]Aggregate, len(accs))
+		for name, acc := range accs {
+			current.Metrics[name] = Aggregate{Min: acc.min, Avg: acc.sum / float64(acc.count), Max: acc.max}
+		}
+		result = append(result, current)
+	}
+
+	for _, point := range points {
+		start := point.Timestamp.Truncate(step)
+		if current.Count == 0 || !start.Equal(current.Timestamp) {
+			flush()
+			current = HistoryBucket{Timestamp: start}
+			accs = make(map[string]*accumulator, len(MetricFields))
+		}
+		weight := max(point.Count, 1)
+		current.Count += weight
+
+		for name, agg := range point.Metrics {
+			acc, ok := accs[name]
+			if !ok {
+				accs[name] = &accumulator{min: agg.Min, max: agg.Max, sum: agg.Avg * float64(weight), count: weight}
+
+				continue
+			}
+			acc.min = min(acc.min, agg.Min)
+			acc.max = max(acc.max, agg.Max)
+			acc.sum += agg.Avg * float64(weight)
+			acc.count += weight
+		}
+	}
+	flush()
+
+	return result
+}
+
+// SetHistoryStore persists every sample to store and serves History queries
+// from it. It must be called before Run.
+func (m *Manager) SetHistoryStore(store HistoryStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+}
+
+// HistoryVersion returns a counter that changes whenever a sample is added to
+// the GPU's history, allowing callers to cache data derived from Recent.
+func (m *Manager) HistoryVersion(gpuID string) uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if ring, ok := m.history[gpuID]; ok {
+		return ring.version
+	}
+
+	return 0
+}
+
+// Recent returns up to limit of the newest retained samples, oldest first,
+// together with the HistoryVersion they correspond to.
+func (m *Manager) Recent(gpuID string, limit int) ([]Sample, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.readers[gpuID]; !ok {
+		return nil, 0, fmt.Errorf("unknown gpu %q", gpuID)
+	}
+
+	if m.history == nil {
+		return nil, 0, ErrHistoryDisabled
+	}
+	ring, ok := m.history[gpuID]
+	if !ok {
+		return nil, 0, nil
+	}
+
+	skip := 0
+	if limit > 0 && ring.len() > limit {
+		skip = ring.len() - limit
+	}
+	samples := make([]Sample, 0, ring.len()-skip)
+	ring.each(func(sample Sample) {
+		if skip > 0 {
+			skip--
+
+			return
+		}
+		samples = append(samples, sample)
+	})
+
+	return samples, ring.version, nil
+}
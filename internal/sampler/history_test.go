@@ -0,0 +1,87 @@
+package sampler
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManagerHistoryDownsamples(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	manager, err := NewManager(time.Second, map[string]*Reader{"card0": nil}, logger)
+	if err != nil {
+		t.Fatalf("NewManager returned error: %v", err)
+	}
+
+	if _, err := manager.History("card0", time.Time{}, time.Time{}, 0); !errors.Is(err, ErrHistoryDisabled) {
+		t.Fatalf("expected ErrHistoryDisabled, got %v", err)
+	}
+	if err := manager.EnableHistory(0); err == nil {
+		t.Fatalf("EnableHistory should reject zero size")
+	}
+	if err := manager.EnableHistory(4); err != nil {
+		t.Fatalf("EnableHistory returned error: %v", err)
+	}
+
+	base := time.Unix(1_700_000_000, 0)
+	for i, busy := range []float64{10, 20, 30, 40, 50, 60} {
+		value := busy
+		manager.storeSample(Sample{
+			GPUId:     "card0",
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Metrics:   Metrics{GPUBusyPct: &value},
+		})
+	}
+
+	// Only the last four samples (30..60) are retained.
+	history, err := manager.History("card0", time.Time{}, time.Time{}, 2*time.Second)
+	if err != nil {
+		t.Fatalf("History returned error: %v", err)
+	}
+	if len(history.Buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %+v", history.Buckets)
+	}
+	first := history.Buckets[0]
+	if first.Count != 2 || !first.Timestamp.Equal(base.Add(2*time.Second)) {
+		t.Fatalf("unexpected first bucket: %+v", first)
+	}
+	if got := first.Metrics["gpu_busy_pct"]; got != (Aggregate{Min: 30, Avg: 35, Max: 40}) {
+		t.Fatalf("unexpected aggregate: %+v", got)
+	}
+	if _, ok := first.Metrics["temp_c"]; ok {
+		t.Fatalf("missing metrics should be omitted: %+v", first.Metrics)
+	}
+
+	history, err = manager.History("card0", base.Add(5*time.Second), time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("History returned error: %v", err)
+	}
+	if len(history.Buckets) != 1 || history.Buckets[0].Metrics["gpu_busy_pct"].Max != 60 {
+		t.Fatalf("unexpected filtered history: %+v", history.Buckets)
+	}
+
+	if _, err := manager.History("unknown", time.Time{}, time.Time{}, 0); err == nil {
+		t.Fatalf("History should fail for unknown gpu id")
+	}
+}
+
+func TestMetricFieldsMatchJSONTags(t *testing.T) {
+	t.Parallel()
+
+	typ := reflect.TypeFor[Metrics]()
+	if typ.NumField() != len(MetricFields) {
+		t.Fatalf("Metrics has %d fields, MetricFields lists %d", typ.NumField(), len(MetricFields))
+	}
+	for i, field := range MetricFields {
+		tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if tag != field.Name {
+			t.Fatalf("MetricFields[%d] = %q, want JSON tag %q", i, field.Name, tag)
+		}
+	}
+}
@@ -6,15 +6,23 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"sync"
 	"time"
 )
 
+// Source produces telemetry samples for a single GPU. *Reader is the sysfs
+// implementation; replay and simulation provide others.
+type Source interface {
+	Sample() Sample
+	Close() error
+}
+
 // Manager orchestrates per-GPU samplers, caches the latest snapshot,
 // and fan-outs updates to subscribers.
 type Manager struct {
 	interval time.Duration
-	readers  map[string]*Reader
+	readers  map[string]Source
 	logger   *slog.Logger
 
 	mu              sync.RWMutex
@@ -24,6 +32,25 @@ type Manager struct {
 	lastDemandAt    time.Time
 	lazy            bool
 	idleTTL         time.Duration
+	history         map[string]*sampleRing
+	historySize     int
+	store           HistoryStore
+	listeners       []func(Sample)
+	activityFns     []func(active bool)
+	readTimeout     time.Duration
+
+	healthMu sync.Mutex
+	health   map[string]*readerHealth
+	// reads tracks the goroutines of reads started by sampleReader.
+	reads sync.WaitGroup
+
+	subInterval time.Duration
+	subMu       sync.Mutex
+	sub         map[string]*subSamples
+
+	groupIntervals map[MetricGroup]time.Duration
+	groupMu        sync.Mutex
+	groups         map[string]*groupCache
 
 	sampleMu  sync.Mutex
 	activity  chan struct{}
@@ -33,19 +60,41 @@ type Manager struct {
 
 // NewManager builds a Manager from pre-constructed readers.
 func NewManager(interval time.Duration, readers map[string]*Reader, logger *slog.Logger) (*Manager, error) {
+	sources := make(map[string]Source, len(readers))
+	for id, reader := range readers {
+		if reader == nil {
+			sources[id] = nil
+
+			continue
+		}
+		sources[id] = reader
+	}
+
+	return NewManagerFromSources(interval, sources, logger)
+}
+
+// NewManagerFromSources builds a Manager from arbitrary sample sources.
+func NewManagerFromSources(interval time.Duration, readers map[string]Source, logger *slog.Logger) (*Manager, error) {
 	if interval <= 0 {
 		return nil, fmt.Errorf("interval must be > 0")
 	}
 	if logger == nil {
 		logger = slog.Default()
 	}
+	if readers == nil {
+		readers = make(map[string]Source)
+	}
 	manager := &Manager{
 		interval:    interval,
-		readers:     readers,
+		readers:     maps.Clone(readers),
 		logger:      logger.With("component", "sampler_manager"),
 		latest:      make(map[string]Sample),
 		subscribers: make(map[string]map[*subscriber]struct{}),
 		activity:    make(chan struct{}, 1),
+		readTimeout: DefaultReadTimeout,
+		health:      make(map[string]*readerHealth),
+		sub:         make(map[string]*subSamples),
+		groups:      make(map[string]*groupCache),
 	}
 
 	return manager, nil
@@ -66,12 +115,9 @@ func (m *Manager) EnableLazy(idleTTL time.Duration) error {
 
 // Run starts the sampling loop for all configured GPUs until the context is canceled.
 func (m *Manager) Run(ctx context.Context) error {
-	if len(m.readers) == 0 {
-		<-ctx.Done()
-
-		return m.Close()
+	if m.subInterval > 0 {
+		go m.runSubSampling(ctx, m.subInterval)
 	}
-
 	if !m.lazy {
 		m.logger.Info("sampler started", "lazy", false)
 		m.sampleAll()
@@ -97,6 +143,7 @@ func (m *Manager) Run(ctx context.Context) error {
 		if !m.HasDemand() && !sleeping {
 			m.logger.Info("sampler going idle")
 			sleeping = true
+			m.notifyActivity(false)
 		}
 
 		if !m.waitUntilDemand(ctx) {
@@ -107,6 +154,7 @@ func (m *Manager) Run(ctx context.Context) error {
 		if sleeping {
 			m.logger.Info("sampler resuming from idle")
 			sleeping = false
+			m.notifyActivity(true)
 		}
 
 		if !m.allFreshWithin(m.interval) {
@@ -125,6 +173,26 @@ func (m *Manager) Run(ctx context.Context) error {
 	}
 }
 
+// AddSource starts sampling a GPU whose reader only became available after
+// the manager was created. It is safe to call while Run is active.
+func (m *Manager) AddSource(gpuID string, source Source) error {
+	if source == nil {
+		return fmt.Errorf("source for gpu %q is nil", gpuID)
+	}
+	m.sampleMu.Lock()
+	defer m.sampleMu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing := m.readers[gpuID]; existing != nil {
+		return fmt.Errorf("gpu %q already has a source", gpuID)
+	}
+	m.readers[gpuID] = source
+	m.signalActivity()
+
+	return nil
+}
+
 // Latest returns the most recent cached sample for the given GPU.
 func (m *Manager) Latest(gpuID string) (Sample, bool) {
 	m.mu.RLock()
@@ -153,7 +221,7 @@ func (m *Manager) Current(gpuID string) (Sample, bool, error) {
 		return Sample{}, false, fmt.Errorf("unknown gpu %q", gpuID)
 	}
 
-	sample := reader.Sample()
+	sample := m.sampleReader(gpuID, reader)
 	m.storeSample(sample)
 	m.touchDemand(now, true)
 
@@ -352,18 +420,25 @@ func (m *Manager) sampleAll() {
 	}
 }
 
-func (m *Manager) sampleAllUnlocked() []Sample {
-	samples := make([]Sample, 0, len(m.readers))
-	for _, reader := range m.readers {
-		samples = append(samples, reader.Sample())
-	}
-
-	return samples
-}
-
 func (m *Manager) storeSample(sample Sample) {
 	m.mu.Lock()
 	m.latest[sample.GPUId] = sample
+	// A quarantined sample says nothing about the GPU, only that its reader
+	// is hung, so it is published but not recorded.
+	if m.history != nil && !sample.Quarantined {
+		ring, ok := m.history[sample.GPUId]
+		if !ok {
+			ring = newSampleRing(m.historySize)
+			m.history[sample.GPUId] = ring
+		}
+		ring.push(sample)
+	}
+
+	store := m.store
+	if sample.Quarantined {
+		store = nil
+	}
+	listeners := m.listeners
 
 	targetSubs := make([]*subscriber, 0, len(m.subscribers[sample.GPUId]))
 	for sub := range m.subscribers[sample.GPUId] {
@@ -374,6 +449,15 @@ func (m *Manager) storeSample(sample Sample) {
 	for _, sub := range targetSubs {
 		sub.send(sample)
 	}
+	for _, fn := range listeners {
+		fn(sample)
+	}
+
+	if store != nil {
+		if err := store.Append(sample); err != nil {
+			m.logger.Warn("failed to persist sample", "gpu_id", sample.GPUId, "err", err)
+		}
+	}
 }
 
 func (m *Manager) removeSubscriber(gpuID string, sub *subscriber) {
@@ -426,11 +510,43 @@ func (m *Manager) copyLatestLocked() map[string]Sample {
 	return out
 }
 
-// Close releases all reader resources. Safe for repeated use.
+// AddListener registers fn to be called with every new sample. Listeners do
+// not count as demand for lazy sampling. It must be called before Run.
+func (m *Manager) AddListener(fn func(Sample)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// AddActivityListener registers fn to be called when a lazy sampler goes
+// idle (false) or resumes (true). It must be called before Run.
+func (m *Manager) AddActivityListener(fn func(active bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.activityFns = append(m.activityFns, fn)
+}
+
+func (m *Manager) notifyActivity(active bool) {
+	m.mu.RLock()
+	fns := m.activityFns
+	m.mu.RUnlock()
+	for _, fn := range fns {
+		fn(active)
+	}
+}
+
+// Close releases all reader resources. Reads still in flight are waited for
+// up to the read timeout. Safe for repeated use.
 func (m *Manager) Close() error {
 	m.closeOnce.Do(func() {
+		m.waitReads()
+
+		m.mu.RLock()
+		readers := maps.Clone(m.readers)
+		m.mu.RUnlock()
+
 		var errs []error
-		for id, reader := range m.readers {
+		for id, reader := range readers {
 			if reader == nil {
 				continue
 			}
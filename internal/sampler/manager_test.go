@@ -6,6 +6,9 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -174,3 +177,475 @@ func waitFor(t *testing.T, timeout time.Duration, condition func() bool) {
 	}
 	t.Fatalf("condition not met within %s", timeout)
 }
+
+type staticSource struct{ id string }
+
+func (s staticSource) Sample() Sample { return Sample{GPUId: s.id, Timestamp: time.Now()} }
+
+func (s staticSource) Close() error { return nil }
+
+func TestManagerActivityListener(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	manager, err := NewManagerFromSources(5*time.Millisecond, map[string]Source{"card0": staticSource{id: "card0"}}, logger)
+	if err != nil {
+		t.Fatalf("NewManagerFromSources returned error: %v", err)
+	}
+	if err := manager.EnableLazy(20 * time.Millisecond); err != nil {
+		t.Fatalf("EnableLazy returned error: %v", err)
+	}
+	transitions := make(chan bool, 8)
+	manager.AddActivityListener(func(active bool) { transitions <- active })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = manager.Run(ctx) }()
+
+	expect := func(want bool) {
+		t.Helper()
+		select {
+		case got := <-transitions:
+			if got != want {
+				t.Fatalf("expected activity %v, got %v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for activity %v", want)
+		}
+	}
+
+	expect(false)
+	_, unsubscribe, err := manager.Subscribe("card0")
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	expect(true)
+	unsubscribe()
+	expect(false)
+}
+
+// blockingSource hangs in Sample while block is set.
+type blockingSource struct {
+	id      string
+	block   atomic.Bool
+	release chan struct{}
+}
+
+func (s *blockingSource) Sample() Sample {
+	if s.block.Load() {
+		<-s.release
+	}
+	value := 42.0
+
+	return Sample{GPUId: s.id, Timestamp: time.Now(), Metrics: Metrics{GPUBusyPct: &value}}
+}
+
+func (s *blockingSource) Close() error { return nil }
+
+func TestManagerQuarantinesHungReader(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	hung := &blockingSource{id: "card1", release: make(chan struct{})}
+	hung.block.Store(true)
+	manager, err := NewManagerFromSources(time.Second, map[string]Source{
+		"card0": staticSource{id: "card0"},
+		"card1": hung,
+	}, logger)
+	if err != nil {
+		t.Fatalf("NewManagerFromSources returned error: %v", err)
+	}
+	if err := manager.SetReadTimeout(20 * time.Millisecond); err != nil {
+		t.Fatalf("SetReadTimeout returned error: %v", err)
+	}
+	if err := manager.EnableHistory(10); err != nil {
+		t.Fatalf("EnableHistory returned error: %v", err)
+	}
+
+	start := time.Now()
+	samples := manager.CurrentAll()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("hung reader blocked sampling for %s", elapsed)
+	}
+	if _, ok := samples["card0"]; !ok {
+		t.Fatalf("expected healthy GPU to be sampled, got %+v", samples)
+	}
+	if got := samples["card1"]; got.GPUId != "card1" || !got.Quarantined || got.Metrics.GPUBusyPct != nil {
+		t.Fatalf("expected quarantined sample for hung GPU, got %+v", got)
+	}
+
+	quarantined := manager.Quarantined()
+	if len(quarantined) != 1 || quarantined[0].GPUId != "card1" || !quarantined[0].Pending {
+		t.Fatalf("unexpected quarantine %+v", quarantined)
+	}
+
+	// While the read is still stuck no new one is started and the call
+	// returns at once.
+	start = time.Now()
+	manager.sampleAll()
+	if sample, _ := manager.Latest("card1"); !sample.Quarantined || sample.Metrics.GPUBusyPct != nil {
+		t.Fatalf("unexpected sample %+v", sample)
+	}
+	if elapsed := time.Since(start); elapsed >= 20*time.Millisecond {
+		t.Fatalf("expected pending read to be skipped, took %s", elapsed)
+	}
+	if recent, _, _ := manager.Recent("card1", 10); len(recent) != 0 {
+		t.Fatalf("expected quarantined samples to stay out of history, got %+v", recent)
+	}
+
+	hung.block.Store(false)
+	close(hung.release)
+	deadline := time.Now().Add(2 * time.Second)
+	for manager.Quarantined()[0].Pending {
+		if time.Now().After(deadline) {
+			t.Fatalf("hung read did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := manager.Quarantined(); len(got) != 1 || got[0].Pending {
+		t.Fatalf("expected quarantine until the next successful read, got %+v", got)
+	}
+
+	manager.sampleAll()
+	if sample, _ := manager.Latest("card1"); sample.Quarantined || sample.Metrics.GPUBusyPct == nil || *sample.Metrics.GPUBusyPct != 42 {
+		t.Fatalf("expected recovered reading, got %+v", sample)
+	}
+	if recent, _, _ := manager.Recent("card1", 10); len(recent) != 1 {
+		t.Fatalf("expected recovered sample in history, got %+v", recent)
+	}
+	if got := manager.Quarantined(); len(got) != 0 {
+		t.Fatalf("expected quarantine to be lifted, got %+v", got)
+	}
+
+	if err := manager.SetReadTimeout(0); err == nil {
+		t.Fatalf("expected error for non-positive read timeout")
+	}
+}
+
+func TestManagerCloseBoundsHungRead(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	hung := &blockingSource{id: "card0", release: make(chan struct{})}
+	hung.block.Store(true)
+	t.Cleanup(func() { close(hung.release) })
+	manager, err := NewManagerFromSources(time.Second, map[string]Source{"card0": hung}, logger)
+	if err != nil {
+		t.Fatalf("NewManagerFromSources returned error: %v", err)
+	}
+	if err := manager.SetReadTimeout(20 * time.Millisecond); err != nil {
+		t.Fatalf("SetReadTimeout returned error: %v", err)
+	}
+	manager.sampleAll()
+
+	start := time.Now()
+	if err := manager.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Close waited %s for a hung read", elapsed)
+	}
+}
+
+func TestManagerAddSource(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	manager, err := NewManagerFromSources(time.Second, nil, logger)
+	if err != nil {
+		t.Fatalf("NewManagerFromSources returned error: %v", err)
+	}
+	if err := manager.AddSource("card0", nil); err == nil {
+		t.Fatalf("expected error for nil source")
+	}
+	if err := manager.AddSource("card0", staticSource{id: "card0"}); err != nil {
+		t.Fatalf("AddSource returned error: %v", err)
+	}
+	if err := manager.AddSource("card0", staticSource{id: "card0"}); err == nil {
+		t.Fatalf("expected error for duplicate source")
+	}
+
+	sample, ok, err := manager.Current("card0")
+	if err != nil || !ok || sample.GPUId != "card0" {
+		t.Fatalf("expected added source to be sampled, got %+v ok=%v err=%v", sample, ok, err)
+	}
+}
+
+func TestManagerAddSourceWhileQueryingHistory(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	manager, err := NewManagerFromSources(time.Second, map[string]Source{"card0": staticSource{id: "card0"}}, logger)
+	if err != nil {
+		t.Fatalf("NewManagerFromSources returned error: %v", err)
+	}
+	if err := manager.EnableHistory(8); err != nil {
+		t.Fatalf("EnableHistory returned error: %v", err)
+	}
+
+	// Run with -race: lookups must not race with sources added by the
+	// startup retry.
+	var wg sync.WaitGroup
+	stop, started := make(chan struct{}), make(chan struct{})
+	wg.Go(func() {
+		close(started)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_, _ = manager.History("card0", time.Time{}, time.Time{}, 0)
+			_, _, _ = manager.Recent("card0", 4)
+		}
+	})
+	<-started
+	for i := 1; i <= 256; i++ {
+		id := "card" + strconv.Itoa(i)
+		if err := manager.AddSource(id, staticSource{id: id}); err != nil {
+			t.Fatalf("AddSource returned error: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if _, _, err := manager.Recent("card256", 4); err != nil {
+		t.Fatalf("expected added source to be known, got %v", err)
+	}
+}
+
+// burstySource reports a busy GPU on every fourth fast read.
+type burstySource struct {
+	id    string
+	reads atomic.Int64
+}
+
+func (s *burstySource) Sample() Sample {
+	idle := 0.0
+
+	return Sample{GPUId: s.id, Timestamp: time.Now(), Metrics: Metrics{GPUBusyPct: &idle}}
+}
+
+func (s *burstySource) SampleFast() (Metrics, bool) {
+	busy := 0.0
+	if s.reads.Add(1)%4 == 0 {
+		busy = 100
+	}
+
+	return Metrics{GPUBusyPct: &busy}, true
+}
+
+func (s *burstySource) Close() error { return nil }
+
+func TestManagerSubSampling(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	source := &burstySource{id: "card0"}
+	manager, err := NewManagerFromSources(time.Second, map[string]Source{"card0": source}, logger)
+	if err != nil {
+		t.Fatalf("NewManagerFromSources returned error: %v", err)
+	}
+	if err := manager.SetSubInterval(time.Second); err == nil {
+		t.Fatalf("expected error for sub-interval not below the interval")
+	}
+	if err := manager.SetSubInterval(time.Millisecond); err != nil {
+		t.Fatalf("SetSubInterval returned error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		manager.runSubSampling(ctx, time.Millisecond)
+		close(stopped)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for source.reads.Load() < 8 {
+		if time.Now().After(deadline) {
+			t.Fatalf("sub-sampling did not run")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-stopped
+	// Let an in-flight read finish so the window is stable.
+	for manager.subSamplesFor("card0").busy.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	manager.sampleAll()
+	sample, _ := manager.Latest("card0")
+	stats, ok := sample.Stats["gpu_busy_pct"]
+	if !ok || stats.Min != 0 || stats.Max != 100 || sample.SubSamples < 9 {
+		t.Fatalf("expected the bursts in the stats, got %+v (%d readings)", sample.Stats, sample.SubSamples)
+	}
+	if sample.Metrics.GPUBusyPct == nil || *sample.Metrics.GPUBusyPct != stats.Avg || stats.Avg <= 0 || stats.Avg >= 100 {
+		t.Fatalf("expected the average as gpu_busy_pct, got %v (stats %+v)", sample.Metrics.GPUBusyPct, stats)
+	}
+	if _, ok := sample.Stats["mem_busy_pct"]; ok {
+		t.Fatalf("unexpected stats for a metric without readings: %+v", sample.Stats)
+	}
+
+	// The window starts over after each published sample.
+	manager.sampleAll()
+	sample, _ = manager.Latest("card0")
+	if stats := sample.Stats["gpu_busy_pct"]; sample.SubSamples != 1 || stats.Max != 0 {
+		t.Fatalf("expected a fresh window, got %+v (%d readings)", sample.Stats, sample.SubSamples)
+	}
+}
+
+// stallingSource blocks in SampleFast until release is closed and then
+// reports a busy GPU.
+type stallingSource struct {
+	id      string
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *stallingSource) Sample() Sample {
+	idle := 0.0
+
+	return Sample{GPUId: s.id, Timestamp: time.Now(), Metrics: Metrics{GPUBusyPct: &idle}}
+}
+
+func (s *stallingSource) SampleFast() (Metrics, bool) {
+	close(s.entered)
+	<-s.release
+	busy := 100.0
+
+	return Metrics{GPUBusyPct: &busy}, true
+}
+
+func (s *stallingSource) Close() error { return nil }
+
+func TestManagerDropsStaleSubSamples(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	source := &stallingSource{id: "card0", entered: make(chan struct{}), release: make(chan struct{})}
+	manager, err := NewManagerFromSources(time.Second, map[string]Source{"card0": source}, logger)
+	if err != nil {
+		t.Fatalf("NewManagerFromSources returned error: %v", err)
+	}
+
+	acc := manager.subSamplesFor("card0")
+	acc.busy.Store(true)
+	go manager.subSample(acc, source)
+	<-source.entered
+	// The sample is published while the reading is still in flight; the
+	// reading must not leak into the next window.
+	manager.sampleAll()
+	close(source.release)
+	for acc.busy.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	manager.sampleAll()
+	sample, _ := manager.Latest("card0")
+	if stats := sample.Stats["gpu_busy_pct"]; sample.SubSamples != 1 || stats.Max != 0 {
+		t.Fatalf("expected the stale reading to be dropped, got %+v (%d readings)", sample.Stats, sample.SubSamples)
+	}
+}
+
+// groupSource records the groups it is asked for and reports a value in
+// each of them unless broken is set.
+type groupSource struct {
+	id     string
+	broken atomic.Bool
+	asked  chan MetricGroup
+}
+
+func (s *groupSource) Sample() Sample { return s.SampleGroups(AllGroups) }
+
+func (s *groupSource) SampleGroups(groups MetricGroup) Sample {
+	s.asked <- groups
+	sample := Sample{GPUId: s.id, Timestamp: time.Now()}
+	if s.broken.Load() {
+		return sample
+	}
+	value := 1.0
+	total := uint64(1 << 30)
+	if groups&GroupFast != 0 {
+		sample.Metrics.GPUBusyPct = &value
+	}
+	if groups&GroupMedium != 0 {
+		sample.Metrics.TempC = &value
+	}
+	if groups&GroupSlow != 0 {
+		sample.Metrics.VRAMTotalBytes = &total
+		sample.State.LinkWidth = "16"
+	}
+
+	return sample
+}
+
+func (s *groupSource) Close() error { return nil }
+
+func TestManagerGroupCadences(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	source := &groupSource{id: "card0", asked: make(chan MetricGroup, 8)}
+	manager, err := NewManagerFromSources(time.Second, map[string]Source{"card0": source}, logger)
+	if err != nil {
+		t.Fatalf("NewManagerFromSources returned error: %v", err)
+	}
+	if err := manager.SetGroupIntervals(-time.Second, time.Hour); err == nil {
+		t.Fatalf("expected error for negative interval")
+	}
+	if err := manager.SetGroupIntervals(time.Hour, time.Hour); err != nil {
+		t.Fatalf("SetGroupIntervals returned error: %v", err)
+	}
+
+	sampleAndCheck := func(wantGroups MetricGroup) Sample {
+		t.Helper()
+		manager.sampleAll()
+		if got := <-source.asked; got != wantGroups {
+			t.Fatalf("expected groups %b to be read, got %b", wantGroups, got)
+		}
+		sample, _ := manager.Latest("card0")
+
+		return sample
+	}
+
+	sampleAndCheck(AllGroups)
+	sample := sampleAndCheck(GroupFast)
+	if sample.Metrics.GPUBusyPct == nil || sample.Metrics.TempC == nil || sample.Metrics.VRAMTotalBytes == nil || sample.State.LinkWidth != "16" {
+		t.Fatalf("expected groups not read to be merged from the previous sample, got %+v", sample)
+	}
+
+	// A failing reader is not hidden behind cached values and everything
+	// is read again until it recovers.
+	source.broken.Store(true)
+	if sample := sampleAndCheck(GroupFast); sample.Metrics.TempC != nil || sample.State.LinkWidth != "" {
+		t.Fatalf("expected an empty sample from a failing reader, got %+v", sample)
+	}
+	sampleAndCheck(AllGroups)
+	source.broken.Store(false)
+	sampleAndCheck(AllGroups)
+	sampleAndCheck(GroupFast)
+}
+
+func TestGroupFieldsCoverEveryField(t *testing.T) {
+	t.Parallel()
+
+	seen := make(map[string]int)
+	for _, fields := range groupFields {
+		for _, name := range fields {
+			seen[name]++
+		}
+	}
+	for _, field := range MetricFields {
+		if seen[field.Name] != 1 {
+			t.Fatalf("metric %s is in %d groups", field.Name, seen[field.Name])
+		}
+	}
+	for _, name := range StateFields {
+		if seen[name] != 1 {
+			t.Fatalf("state field %s is in %d groups", name, seen[name])
+		}
+	}
+	if len(seen) != len(MetricFields)+len(StateFields) {
+		t.Fatalf("unknown fields in groups: %v", seen)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"maps"
 	"math"
 	"os"
 	"path/filepath"
@@ -34,6 +35,8 @@ const (
 type Reader struct {
 	cardID        string
 	cardIndex     int
+	sysfsPath     string
+	debugfsPath   string
 	logger        *slog.Logger
 	sysRoot       *os.Root
 	debugRoot     *os.Root
@@ -43,6 +46,28 @@ type Reader struct {
 	mu            sync.RWMutex
 	closeOnce     sync.Once
 	closeErr      error
+
+	// healMu guards the self-healing state; see heal.go.
+	healMu        sync.Mutex
+	failures      int
+	closed        bool
+	readingGroups MetricGroup
+	onReopen      func(failedSamples int)
+
+	// capsMu guards the provenance of the last sample; see capabilities.go.
+	// Fields keep their last provenance when their group is not read.
+	capsMu     sync.Mutex
+	capsFields provenance
+	capsAt     time.Time
+}
+
+// readerRoots are the directory handles a Reader samples through.
+type readerRoots struct {
+	sys       *os.Root
+	debug     *os.Root
+	device    *os.Root
+	debugCard *os.Root
+	hwmon     *os.Root
 }
 
 // NewReader constructs a Reader for the provided card identifier (e.g. "card0").
@@ -56,121 +81,207 @@ func NewReader(cardID, sysfsRoot, debugfsRoot string, logger *slog.Logger) (*Rea
 		return nil, err
 	}
 
+	roots, err := openRoots(cardID, cardIndex, sysfsRoot, debugfsRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &Reader{
+		cardID:      cardID,
+		cardIndex:   cardIndex,
+		sysfsPath:   sysfsRoot,
+		debugfsPath: debugfsRoot,
+		logger:      logger.With("card", cardID),
+	}
+	reader.setRoots(roots)
+
+	return reader, nil
+}
+
+func openRoots(cardID string, cardIndex int, sysfsRoot, debugfsRoot string) (readerRoots, error) {
 	sysRoot, err := os.OpenRoot(sysfsRoot)
 	if err != nil {
-		return nil, fmt.Errorf("open sysfs root: %w", err)
+		return readerRoots{}, fmt.Errorf("open sysfs root: %w", err)
 	}
 
 	deviceRoot, err := sysRoot.OpenRoot(filepath.Join(drmClassPath, cardID, "device"))
 	if err != nil {
 		_ = sysRoot.Close()
 
-		return nil, fmt.Errorf("open device root: %w", err)
+		return readerRoots{}, fmt.Errorf("open device root: %w", err)
 	}
 
-	var debugRoot *os.Root
-	var debugCardRoot *os.Root
+	roots := readerRoots{sys: sysRoot, device: deviceRoot, hwmon: detectHwmon(deviceRoot)}
 	if debugfsRoot != "" {
 		if dbgRoot, err := os.OpenRoot(debugfsRoot); err == nil {
-			debugRoot = dbgRoot
+			roots.debug = dbgRoot
 			if sub, err := dbgRoot.OpenRoot(filepath.Join("dri", strconv.Itoa(cardIndex))); err == nil {
-				debugCardRoot = sub
+				roots.debugCard = sub
 			}
 		}
 	}
 
-	reader := &Reader{
-		cardID:        cardID,
-		cardIndex:     cardIndex,
-		logger:        logger.With("card", cardID),
-		sysRoot:       sysRoot,
-		debugRoot:     debugRoot,
-		deviceRoot:    deviceRoot,
-		debugCardRoot: debugCardRoot,
-		hwmonRoot:     detectHwmon(deviceRoot),
-	}
+	return roots, nil
+}
 
-	return reader, nil
+// setRoots installs roots and returns the previous ones. Callers hold r.mu
+// for writing, or own r exclusively.
+func (r *Reader) setRoots(roots readerRoots) readerRoots {
+	old := readerRoots{sys: r.sysRoot, debug: r.debugRoot, device: r.deviceRoot, debugCard: r.debugCardRoot, hwmon: r.hwmonRoot}
+	r.sysRoot = roots.sys
+	r.debugRoot = roots.debug
+	r.deviceRoot = roots.device
+	r.debugCardRoot = roots.debugCard
+	r.hwmonRoot = roots.hwmon
+
+	return old
 }
 
-// Sample collects metrics for the GPU. Non-fatal read errors result in nil fields.
+// Sample collects metrics for the GPU. Non-fatal read errors result in nil
+// fields; persistent failures make the reader reopen its handles.
 func (r *Reader) Sample() Sample {
+	return r.SampleGroups(AllGroups)
+}
+
+// SampleGroups implements GroupSampler.
+func (r *Reader) SampleGroups(groups MetricGroup) Sample {
+	sample := r.sample(groups)
+	r.heal(sample, groups)
+
+	return sample
+}
+
+func (r *Reader) sample(groups MetricGroup) Sample {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	now := time.Now().UTC()
-	metrics := Metrics{}
 
-	metrics.GPUBusyPct = r.readPercent(gpuBusyFilename)
-	metrics.MemBusyPct = r.readPercent(memBusyFilename)
+	// Reading almost any other attribute of a runtime-suspended dGPU wakes
+	// it up, so a sleeping GPU is reported without metrics.
+	powerState := r.readPowerState()
+	if powerState == PowerStateSuspended {
+		return Sample{GPUId: r.cardID, Timestamp: now, PowerState: powerState}
+	}
 
-	metrics.SCLKMHz = r.readCurrentClock(ppDpmSclkFilename)
-	metrics.MCLKMHz = r.readCurrentClock(ppDpmMclkFilename)
+	prov := provenance{}
+	sample := Sample{GPUId: r.cardID, Timestamp: now, PowerState: powerState}
+	if groups&GroupFast != 0 {
+		r.readFast(prov, &sample.Metrics)
+	}
+	if groups&GroupMedium != 0 {
+		r.readMedium(prov, &sample.Metrics)
+		sample.State.ThrottleStatus = r.readThrottleStatus(prov)
+	}
+	if groups&GroupSlow != 0 {
+		r.readSlow(prov, &sample.Metrics)
+		r.readSlowState(prov, &sample.State)
+	}
+	r.readDebugFallback(prov, &sample.Metrics, groups)
 
-	metrics.VRAMUsedBytes = r.readUint("mem_info_vram_used")
-	metrics.VRAMTotalBytes = r.readUint("mem_info_vram_total")
-	metrics.GTTUsedBytes = r.readUint("mem_info_gtt_used")
-	metrics.GTTTotalBytes = r.readUint("mem_info_gtt_total")
+	r.capsMu.Lock()
+	if r.capsFields == nil {
+		r.capsFields = provenance{}
+	}
+	maps.Copy(r.capsFields, prov)
+	r.capsAt = now
+	r.capsMu.Unlock()
 
-	if r.hwmonRoot != nil {
-		metrics.TempC = r.readScaledFloat(r.hwmonRoot, hwmonTempFile, 1000)
-		metrics.FanRPM = r.readFloat(r.hwmonRoot, hwmonFanFile)
-		metrics.PowerW = r.readScaledFloat(r.hwmonRoot, hwmonPowerAverageFile, 1_000_000)
-		if metrics.PowerW == nil {
-			metrics.PowerW = r.readScaledFloat(r.hwmonRoot, hwmonPowerInputFile, 1_000_000)
-		}
+	return sample
+}
+
+func (r *Reader) readFast(prov provenance, metrics *Metrics) {
+	busy, err := r.readPercent(gpuBusyFilename)
+	metrics.GPUBusyPct = observe(prov, "gpu_busy_pct", SourceSysfs, r.devicePath(gpuBusyFilename), busy, err)
+	memBusy, err := r.readPercent(memBusyFilename)
+	metrics.MemBusyPct = observe(prov, "mem_busy_pct", SourceSysfs, r.devicePath(memBusyFilename), memBusy, err)
+
+	sclk, err := r.readCurrentClock(ppDpmSclkFilename)
+	metrics.SCLKMHz = observe(prov, "sclk_mhz", SourceSysfs, r.devicePath(ppDpmSclkFilename), sclk, err)
+	mclk, err := r.readCurrentClock(ppDpmMclkFilename)
+	metrics.MCLKMHz = observe(prov, "mclk_mhz", SourceSysfs, r.devicePath(ppDpmMclkFilename), mclk, err)
+
+	power, err := r.readHwmon(hwmonPowerAverageFile, 1_000_000)
+	metrics.PowerW = observe(prov, "power_w", SourceHwmon, r.hwmonPath(hwmonPowerAverageFile), power, err)
+	if metrics.PowerW == nil {
+		power, err = r.readHwmon(hwmonPowerInputFile, 1_000_000)
+		metrics.PowerW = observe(prov, "power_w", SourceHwmon, r.hwmonPath(hwmonPowerInputFile), power, err)
 	}
+}
 
-	// Optional debugfs fallback for select metrics.
-	if metrics.GPUBusyPct == nil || metrics.SCLKMHz == nil || metrics.MCLKMHz == nil || metrics.PowerW == nil || metrics.TempC == nil {
-		info := r.readDebugFSInfo()
-		if metrics.GPUBusyPct == nil && info.gpuLoad != nil {
-			metrics.GPUBusyPct = info.gpuLoad
-		}
-		if metrics.SCLKMHz == nil && info.sclkMHz != nil {
-			metrics.SCLKMHz = info.sclkMHz
+func (r *Reader) readMedium(prov provenance, metrics *Metrics) {
+	temp, err := r.readHwmon(hwmonTempFile, 1000)
+	metrics.TempC = observe(prov, "temp_c", SourceHwmon, r.hwmonPath(hwmonTempFile), temp, err)
+	fan, err := r.readHwmon(hwmonFanFile, 1)
+	metrics.FanRPM = observe(prov, "fan_rpm", SourceHwmon, r.hwmonPath(hwmonFanFile), fan, err)
+
+	vramUsed, err := r.readUint("mem_info_vram_used")
+	metrics.VRAMUsedBytes = observe(prov, "vram_used_bytes", SourceSysfs, r.devicePath("mem_info_vram_used"), vramUsed, err)
+	gttUsed, err := r.readUint("mem_info_gtt_used")
+	metrics.GTTUsedBytes = observe(prov, "gtt_used_bytes", SourceSysfs, r.devicePath("mem_info_gtt_used"), gttUsed, err)
+}
+
+func (r *Reader) readSlow(prov provenance, metrics *Metrics) {
+	vramTotal, err := r.readUint("mem_info_vram_total")
+	metrics.VRAMTotalBytes = observe(prov, "vram_total_bytes", SourceSysfs, r.devicePath("mem_info_vram_total"), vramTotal, err)
+	gttTotal, err := r.readUint("mem_info_gtt_total")
+	metrics.GTTTotalBytes = observe(prov, "gtt_total_bytes", SourceSysfs, r.devicePath("mem_info_gtt_total"), gttTotal, err)
+}
+
+// readDebugFallback fills the metrics of groups that sysfs did not provide
+// from debugfs.
+func (r *Reader) readDebugFallback(prov provenance, metrics *Metrics, groups MetricGroup) {
+	fast := groups&GroupFast != 0 &&
+		(metrics.GPUBusyPct == nil || metrics.SCLKMHz == nil || metrics.MCLKMHz == nil || metrics.PowerW == nil)
+	medium := groups&GroupMedium != 0 && metrics.TempC == nil
+	if !fast && !medium {
+		return
+	}
+
+	info, err := r.readDebugFSInfo()
+	path := r.debugPath(debugPmInfoFilename)
+	if fast {
+		if metrics.GPUBusyPct == nil {
+			metrics.GPUBusyPct = observe(prov, "gpu_busy_pct", SourceDebugfs, path, info.gpuLoad, err)
 		}
-		if metrics.MCLKMHz == nil && info.mclkMHz != nil {
-			metrics.MCLKMHz = info.mclkMHz
+		if metrics.SCLKMHz == nil {
+			metrics.SCLKMHz = observe(prov, "sclk_mhz", SourceDebugfs, path, info.sclkMHz, err)
 		}
-		if metrics.PowerW == nil && info.powerW != nil {
-			metrics.PowerW = info.powerW
+		if metrics.MCLKMHz == nil {
+			metrics.MCLKMHz = observe(prov, "mclk_mhz", SourceDebugfs, path, info.mclkMHz, err)
 		}
-		if metrics.TempC == nil && info.tempC != nil {
-			metrics.TempC = info.tempC
+		if metrics.PowerW == nil {
+			metrics.PowerW = observe(prov, "power_w", SourceDebugfs, path, info.powerW, err)
 		}
 	}
-
-	return Sample{
-		GPUId:     r.cardID,
-		Timestamp: now,
-		Metrics:   metrics,
+	if medium {
+		metrics.TempC = observe(prov, "temp_c", SourceDebugfs, path, info.tempC, err)
 	}
 }
 
-func (r *Reader) readPercent(name string) *float64 {
+func (r *Reader) readPercent(name string) (*float64, error) {
 	value, err := r.readFloatValue(r.deviceRoot, name)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	if value < 0 {
-		return nil
+		return nil, fmt.Errorf("%w: negative percentage %v", errInvalidValue, value)
 	}
 	if value > 100 {
 		// Some kernels report busy % scaled by 100.
 		value = clamp(value/100, 0, 100)
 	}
 
-	return float64Ptr(value)
+	return float64Ptr(value), nil
 }
 
-func (r *Reader) readCurrentClock(filename string) *float64 {
+func (r *Reader) readCurrentClock(filename string) (*float64, error) {
 	if r.deviceRoot == nil {
-		return nil
+		return nil, fs.ErrNotExist
 	}
 
 	raw, err := r.deviceRoot.ReadFile(filename)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	scanner := bufio.NewScanner(bytes.NewReader(raw))
@@ -180,52 +291,47 @@ func (r *Reader) readCurrentClock(filename string) *float64 {
 			continue
 		}
 		if clock, ok := extractClockMHz(line); ok {
-			return float64Ptr(clock)
+			return float64Ptr(clock), nil
 		}
 	}
 
-	return nil
+	return nil, fmt.Errorf("%w: no current clock level", errInvalidValue)
 }
 
-func (r *Reader) readUint(path string) *uint64 {
+func (r *Reader) readUint(path string) (*uint64, error) {
 	if r.deviceRoot == nil {
-		return nil
+		return nil, fs.ErrNotExist
 	}
 
 	data, err := r.deviceRoot.ReadFile(path)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	valueStr := strings.TrimSpace(string(data))
 	if valueStr == "" {
-		return nil
+		return nil, fmt.Errorf("%w: empty value", errInvalidValue)
 	}
 	value, err := strconv.ParseUint(valueStr, 10, 64)
 	if err != nil {
 		r.logger.Debug("failed to parse uint value", "path", path, "value", valueStr, "err", err)
 
-		return nil
+		return nil, fmt.Errorf("%w: %w", errInvalidValue, err)
 	}
 
-	return uint64Ptr(value)
+	return uint64Ptr(value), nil
 }
 
-func (r *Reader) readScaledFloat(root *os.Root, name string, divisor float64) *float64 {
-	value, err := r.readFloatValue(root, name)
-	if err != nil {
-		return nil
+// readHwmon reads a hwmon attribute and divides it by divisor.
+func (r *Reader) readHwmon(name string, divisor float64) (*float64, error) {
+	if r.hwmonRoot == nil {
+		return nil, errNoHwmon
 	}
-
-	return float64Ptr(value / divisor)
-}
-
-func (r *Reader) readFloat(root *os.Root, name string) *float64 {
-	value, err := r.readFloatValue(root, name)
+	value, err := r.readFloatValue(r.hwmonRoot, name)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	return float64Ptr(value)
+	return float64Ptr(value / divisor), nil
 }
 
 func (r *Reader) readFloatValue(root *os.Root, name string) (float64, error) {
@@ -238,23 +344,23 @@ func (r *Reader) readFloatValue(root *os.Root, name string) (float64, error) {
 	}
 	valueStr := strings.TrimSpace(string(data))
 	if valueStr == "" {
-		return 0, fmt.Errorf("empty value")
+		return 0, fmt.Errorf("%w: empty value", errInvalidValue)
 	}
 	value, err := strconv.ParseFloat(valueStr, 64)
 	if err != nil {
-		return 0, fmt.Errorf("parse float: %w", err)
+		return 0, fmt.Errorf("%w: parse float: %w", errInvalidValue, err)
 	}
 
 	return value, nil
 }
 
-func (r *Reader) readDebugFSInfo() debugInfo {
+func (r *Reader) readDebugFSInfo() (debugInfo, error) {
 	if r.debugCardRoot == nil {
-		return debugInfo{}
+		return debugInfo{}, errNoDebugfs
 	}
 	data, err := r.debugCardRoot.ReadFile(debugPmInfoFilename)
 	if err != nil {
-		return debugInfo{}
+		return debugInfo{}, err
 	}
 
 	info := debugInfo{}
@@ -306,7 +412,7 @@ func (r *Reader) readDebugFSInfo() debugInfo {
 		}
 	}
 
-	return info
+	return info, nil
 }
 
 type debugInfo struct {
@@ -350,6 +456,10 @@ func detectHwmon(deviceRoot *os.Root) *os.Root {
 // multiple times; subsequent calls return the same error result.
 func (r *Reader) Close() error {
 	r.closeOnce.Do(func() {
+		r.healMu.Lock()
+		r.closed = true
+		r.healMu.Unlock()
+
 		r.mu.Lock()
 		defer r.mu.Unlock()
 		var errs []error
@@ -3,10 +3,32 @@ package sampler
 import (
 	"io"
 	"log/slog"
+	"os"
 	"path/filepath"
 	"testing"
 )
 
+func TestReaderSampleFast(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reader, err := NewReader("card0", filepath.Join("testdata", "sysfs_full"), t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("NewReader returned error: %v", err)
+	}
+
+	metrics, ok := reader.SampleFast()
+	if !ok {
+		t.Fatalf("expected an active GPU to be read")
+	}
+	assertFloatEqual(t, metrics.GPUBusyPct, 47)
+	assertFloatEqual(t, metrics.MemBusyPct, 31)
+	// Clocks would cost an SMU message per read.
+	if metrics.SCLKMHz != nil || metrics.MCLKMHz != nil || metrics.TempC != nil || metrics.PowerW != nil {
+		t.Fatalf("expected only sub-sampled metrics, got %+v", metrics)
+	}
+}
+
 func TestReaderSampleSysfs(t *testing.T) {
 	t.Parallel()
 
@@ -39,6 +61,80 @@ func TestReaderSampleSysfs(t *testing.T) {
 	assertUintEqual(t, sample.Metrics.VRAMTotalBytes, 2147483648)
 	assertUintEqual(t, sample.Metrics.GTTUsedBytes, 52428800)
 	assertUintEqual(t, sample.Metrics.GTTTotalBytes, 4294967296)
+
+	state := sample.State
+	if state.PerfLevel != "auto" || state.PowerProfile != "3D_FULL_SCREEN" {
+		t.Fatalf("unexpected perf level/profile %q/%q", state.PerfLevel, state.PowerProfile)
+	}
+	if state.LinkSpeed != "8.0 GT/s PCIe" || state.LinkWidth != "16" || state.MaxLinkSpeed != "16.0 GT/s PCIe" {
+		t.Fatalf("unexpected link state %+v", state)
+	}
+	if throttled, ok := state.Throttled(); !ok || !throttled || *state.ThrottleStatus != 0x4 {
+		t.Fatalf("expected throttle status 0x4, got %v", state.ThrottleStatus)
+	}
+	if sample.PowerState != PowerStateActive || sample.Suspended() {
+		t.Fatalf("unexpected power state %q", sample.PowerState)
+	}
+
+	caps, _ := reader.Capabilities()
+	if summary := caps.Summary(); len(summary.Missing) != 0 {
+		t.Fatalf("expected every field to be available, missing %v", summary.Missing)
+	}
+	wantTemp := filepath.Join(sysfsRoot, "class/drm/card0/device/hwmon/hwmon0", hwmonTempFile)
+	for _, c := range caps.Fields {
+		if c.Field == "temp_c" && (c.Source != SourceHwmon || c.Path != wantTemp) {
+			t.Fatalf("unexpected temp capability %+v", c)
+		}
+	}
+}
+
+func TestReaderSkipsSuspendedDevice(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	device := createMinimalDevice(t, root, "card0")
+	writeFile(t, filepath.Join(device, "gpu_busy_percent"), "50\n")
+	writeFile(t, filepath.Join(device, "current_link_speed"), "16.0 GT/s PCIe\n")
+	writeFile(t, filepath.Join(device, "power", "runtime_status"), "suspended\n")
+
+	reader, err := NewReader("card0", root, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewReader returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = reader.Close() })
+
+	sample := reader.Sample()
+	if !sample.Suspended() || sample.Metrics.GPUBusyPct != nil || sample.State.LinkSpeed != "" {
+		t.Fatalf("expected suspended sample without readings, got %+v", sample)
+	}
+
+	writeFile(t, filepath.Join(device, "power", "runtime_status"), "suspending\n")
+	if sample := reader.Sample(); !sample.Suspended() {
+		t.Fatalf("expected suspending device to be left alone, got %q", sample.PowerState)
+	}
+
+	writeFile(t, filepath.Join(device, "power", "runtime_status"), "active\n")
+	sample = reader.Sample()
+	if sample.PowerState != PowerStateActive {
+		t.Fatalf("unexpected power state %q", sample.PowerState)
+	}
+	assertFloatEqual(t, sample.Metrics.GPUBusyPct, 50)
+}
+
+func TestParseActivePowerProfile(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		" 0 BOOTUP_DEFAULT :\n 1 3D_FULL_SCREEN*:\n":                            "3D_FULL_SCREEN",
+		"NUM        MODE_NAME     SCLK_UP_HYST\n  4          VR *:         0\n": "VR",
+		"  0 BOOTUP_DEFAULT*:\n                    0(       GFXCLK)       1\n":  "BOOTUP_DEFAULT",
+		"no profile selected\n": "",
+	}
+	for input, want := range testCases {
+		if got := parseActivePowerProfile(input); got != want {
+			t.Fatalf("parseActivePowerProfile(%q) = %q, want %q", input, got, want)
+		}
+	}
 }
 
 func TestReaderSampleDebugFallback(t *testing.T) {
@@ -122,3 +218,148 @@ func assertUintEqual(t *testing.T, value *uint64, expected uint64) {
 		t.Fatalf("expected %d, got %d", expected, *value)
 	}
 }
+
+func TestReaderReopensAfterDriverReload(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	populate := func(hwmon string) {
+		device := createMinimalDevice(t, root, "card0")
+		writeFile(t, filepath.Join(device, "gpu_busy_percent"), "20\n")
+		writeFile(t, filepath.Join(device, "hwmon", hwmon, "temp1_input"), "50000\n")
+	}
+	populate("hwmon3")
+
+	reader, err := NewReader("card0", root, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewReader returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = reader.Close() })
+	var reopened []int
+	reader.OnReopen(func(failedSamples int) { reopened = append(reopened, failedSamples) })
+	assertFloatEqual(t, reader.Sample().Metrics.TempC, 50)
+
+	// A module reload removes the device directory and recreates it with a
+	// new hwmon index; the handles opened before keep pointing at the
+	// removed directory.
+	if err := os.RemoveAll(filepath.Join(root, "class", "drm", "card0")); err != nil {
+		t.Fatalf("remove device: %v", err)
+	}
+	populate("hwmon5")
+
+	for i := range reopenAfter {
+		if sample := reader.Sample(); groupsHaveReadings(sample, AllGroups) {
+			t.Fatalf("sample %d: expected stale handles to fail, got %+v", i, sample.Metrics)
+		}
+	}
+	if len(reopened) != 1 || reopened[0] != reopenAfter {
+		t.Fatalf("expected one reopen notification after %d failed samples, got %v", reopenAfter, reopened)
+	}
+	sample := reader.Sample()
+	assertFloatEqual(t, sample.Metrics.GPUBusyPct, 20)
+	assertFloatEqual(t, sample.Metrics.TempC, 50)
+}
+
+func TestReaderCapabilities(t *testing.T) {
+	t.Parallel()
+
+	sysfsRoot := filepath.Join("testdata", "sysfs_fallback")
+	debugfsRoot := filepath.Join("testdata", "debugfs_fallback")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	reader, err := NewReader("card1", sysfsRoot, debugfsRoot, logger)
+	if err != nil {
+		t.Fatalf("NewReader returned error: %v", err)
+	}
+	defer reader.Close()
+
+	if _, ok := reader.Capabilities(); ok {
+		t.Fatalf("expected no capabilities before the first sample")
+	}
+	reader.Sample()
+	caps, ok := reader.Capabilities()
+	if !ok || caps.GPUId != "card1" {
+		t.Fatalf("unexpected capabilities %+v", caps)
+	}
+	if len(caps.Fields) != len(MetricFields)+len(StateFields) || caps.Fields[0].Field != "gpu_busy_pct" {
+		t.Fatalf("expected every field in order, got %+v", caps.Fields)
+	}
+	byField := make(map[string]Capability, len(caps.Fields))
+	for _, c := range caps.Fields {
+		byField[c.Field] = c
+	}
+
+	busy := byField["gpu_busy_pct"]
+	if !busy.Available || busy.Source != SourceDebugfs || busy.Path != filepath.Join(debugfsRoot, "dri", "1", debugPmInfoFilename) {
+		t.Fatalf("expected busy from debugfs, got %+v", busy)
+	}
+	if len(busy.Attempts) != 2 || busy.Attempts[0].Source != SourceSysfs || busy.Attempts[0].Reason != ReasonUnsupported || busy.Attempts[1].Reason != "" {
+		t.Fatalf("unexpected busy attempts %+v", busy.Attempts)
+	}
+	if vram := byField["vram_total_bytes"]; !vram.Available || vram.Source != SourceSysfs || len(vram.Attempts) != 1 {
+		t.Fatalf("unexpected vram capability %+v", vram)
+	}
+	if fan := byField["fan_rpm"]; fan.Available || fan.Reason != ReasonNoHwmon {
+		t.Fatalf("expected fan to be missing without hwmon, got %+v", fan)
+	}
+	if throttle := byField["throttle_status"]; throttle.Available || throttle.Reason != ReasonUnsupported || throttle.Attempts[0].Source != SourceGPUMetrics {
+		t.Fatalf("unexpected throttle capability %+v", throttle)
+	}
+
+	summary := caps.Summary()
+	if summary.Missing["mem_busy_pct"] != ReasonUnsupported || summary.Missing["fan_rpm"] != ReasonNoHwmon {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if len(summary.Available)+len(summary.Missing) != len(caps.Fields) {
+		t.Fatalf("summary does not cover every field: %+v", summary)
+	}
+
+	// Without debugfs the fallback reports why it could not be used.
+	noDebug, err := NewReader("card1", sysfsRoot, "", logger)
+	if err != nil {
+		t.Fatalf("NewReader returned error: %v", err)
+	}
+	defer noDebug.Close()
+	noDebug.Sample()
+	caps, _ = noDebug.Capabilities()
+	for _, c := range caps.Fields {
+		if c.Field != "sclk_mhz" {
+			continue
+		}
+		if c.Available || c.Reason != ReasonUnsupported || len(c.Attempts) != 2 || c.Attempts[1].Reason != ReasonDebugfsUnavailable {
+			t.Fatalf("unexpected sclk capability %+v", c)
+		}
+	}
+}
+
+func TestReaderSampleGroups(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reader, err := NewReader("card0", filepath.Join("testdata", "sysfs_full"), "", logger)
+	if err != nil {
+		t.Fatalf("NewReader returned error: %v", err)
+	}
+	defer reader.Close()
+
+	reader.Sample()
+	sample := reader.SampleGroups(GroupFast)
+	assertFloatEqual(t, sample.Metrics.GPUBusyPct, 47)
+	assertFloatEqual(t, sample.Metrics.PowerW, 120)
+	if sample.Metrics.TempC != nil || sample.Metrics.VRAMTotalBytes != nil || sample.State.LinkSpeed != "" || sample.State.ThrottleStatus != nil {
+		t.Fatalf("expected only the fast group to be read, got %+v", sample)
+	}
+
+	sample = reader.SampleGroups(GroupMedium)
+	assertFloatEqual(t, sample.Metrics.TempC, 65)
+	assertUintEqual(t, sample.Metrics.VRAMUsedBytes, 104857600)
+	if sample.Metrics.GPUBusyPct != nil || sample.Metrics.GTTTotalBytes != nil || sample.State.ThrottleStatus == nil {
+		t.Fatalf("expected only the medium group to be read, got %+v", sample)
+	}
+
+	// Fields not read keep the provenance of their last read.
+	caps, _ := reader.Capabilities()
+	if summary := caps.Summary(); len(summary.Available) != len(MetricFields)+len(StateFields) {
+		t.Fatalf("expected every field to stay available, got %+v", summary)
+	}
+}
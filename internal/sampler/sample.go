@@ -7,6 +7,24 @@ type Sample struct {
 	GPUId     string    `json:"gpu_id"`
 	Timestamp time.Time `json:"ts"`
 	Metrics   Metrics   `json:"metrics"`
+	State     State     `json:"state"`
+	// PowerState is the runtime PM status of the device ("active",
+	// "suspended", ...); empty when unknown. Suspended GPUs are not read,
+	// so their metrics and state are empty.
+	PowerState string `json:"power_state,omitempty"`
+	// Quarantined marks a sample of a GPU whose reader is hung. It carries
+	// no readings and is not kept in history.
+	Quarantined bool `json:"quarantined,omitempty"`
+	// Stats holds the min/avg/max of the sub-sampled metrics since the
+	// previous sample when sub-interval sampling is enabled; those metrics
+	// then carry the average. SubSamples is the number of readings.
+	Stats      map[string]Aggregate `json:"stats,omitempty"`
+	SubSamples int                  `json:"sub_samples,omitempty"`
+}
+
+// Suspended reports whether the GPU was runtime-suspended when sampled.
+func (s Sample) Suspended() bool {
+	return s.PowerState == PowerStateSuspended
 }
 
 // Metrics contains GPU telemetry values. Pointer fields serialize as null when unavailable.
@@ -0,0 +1,173 @@
+package sampler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+const (
+	perfLevelFilename    = "power_dpm_force_performance_level"
+	powerProfileFilename = "pp_power_profile_mode"
+	linkSpeedFilename    = "current_link_speed"
+	linkWidthFilename    = "current_link_width"
+	maxLinkSpeedFilename = "max_link_speed"
+	maxLinkWidthFilename = "max_link_width"
+	gpuMetricsFilename   = "gpu_metrics"
+	runtimeStatusPath    = "power/runtime_status"
+
+	// throttleStatusOffset is the offset of throttle_status in the
+	// gpu_metrics v1.1 to v1.3 tables used by discrete GPUs.
+	throttleStatusOffset = 68
+)
+
+// Runtime PM states of Sample.PowerState.
+const (
+	PowerStateActive    = "active"
+	PowerStateSuspended = "suspended"
+)
+
+// State holds non-numeric device state. Empty fields are unavailable.
+type State struct {
+	PerfLevel    string `json:"perf_level,omitempty"`
+	PowerProfile string `json:"power_profile,omitempty"`
+	LinkSpeed    string `json:"link_speed,omitempty"`
+	LinkWidth    string `json:"link_width,omitempty"`
+	MaxLinkSpeed string `json:"max_link_speed,omitempty"`
+	MaxLinkWidth string `json:"max_link_width,omitempty"`
+	// ThrottleStatus is the raw, ASIC-specific throttle_status bit mask of
+	// gpu_metrics; non-zero means the SMU is throttling.
+	ThrottleStatus *uint32 `json:"throttle_status,omitempty"`
+}
+
+// Throttled reports whether the GPU is throttling and whether it is known.
+func (s State) Throttled() (throttled, ok bool) {
+	if s.ThrottleStatus == nil {
+		return false, false
+	}
+
+	return *s.ThrottleStatus != 0, true
+}
+
+func (r *Reader) readSlowState(prov provenance, state *State) {
+	str := func(field, name string) string {
+		value, err := r.readString(name)
+
+		return observeString(prov, field, SourceSysfs, r.devicePath(name), value, err)
+	}
+	state.PerfLevel = str("perf_level", perfLevelFilename)
+	state.LinkSpeed = str("link_speed", linkSpeedFilename)
+	state.LinkWidth = str("link_width", linkWidthFilename)
+	state.MaxLinkSpeed = str("max_link_speed", maxLinkSpeedFilename)
+	state.MaxLinkWidth = str("max_link_width", maxLinkWidthFilename)
+
+	profile, err := r.readString(powerProfileFilename)
+	if err == nil {
+		profile = parseActivePowerProfile(profile)
+		if profile == "" {
+			err = fmt.Errorf("%w: no active power profile", errInvalidValue)
+		}
+	}
+	state.PowerProfile = observeString(prov, "power_profile", SourceSysfs, r.devicePath(powerProfileFilename), profile, err)
+}
+
+func (r *Reader) readThrottleStatus(prov provenance) *uint32 {
+	var status *uint32
+	data, err := r.readFile(gpuMetricsFilename)
+	if err == nil {
+		if value, ok := parseThrottleStatus(data); ok {
+			status = &value
+		} else {
+			err = fmt.Errorf("gpu_metrics table format: %w", errors.ErrUnsupported)
+		}
+	}
+
+	return observe(prov, "throttle_status", SourceGPUMetrics, r.devicePath(gpuMetricsFilename), status, err)
+}
+
+// readPowerState reads the runtime PM status, which unlike most amdgpu
+// attributes does not resume a suspended device. A device on its way to
+// suspend is reported as suspended so it is not woken right back up.
+func (r *Reader) readPowerState() string {
+	if r.deviceRoot == nil {
+		return ""
+	}
+	status, _ := r.readString(runtimeStatusPath)
+	if status == "suspending" {
+		return PowerStateSuspended
+	}
+
+	return status
+}
+
+func (r *Reader) readString(name string) (string, error) {
+	data, err := r.readFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (r *Reader) readFile(name string) ([]byte, error) {
+	if r.deviceRoot == nil {
+		return nil, fs.ErrNotExist
+	}
+
+	return r.deviceRoot.ReadFile(name)
+}
+
+// parseActivePowerProfile returns the name of the profile marked with "*"
+// in pp_power_profile_mode, e.g. "3D_FULL_SCREEN" from " 1 3D_FULL_SCREEN*:".
+func parseActivePowerProfile(text string) string {
+	for line := range strings.Lines(text) {
+		if !strings.Contains(line, "*") {
+			continue
+		}
+		fields := strings.Fields(strings.ReplaceAll(line, "*", " "))
+		if len(fields) < 2 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			continue
+		}
+
+		return strings.TrimSuffix(fields[1], ":")
+	}
+
+	return ""
+}
+
+// parseThrottleStatus extracts throttle_status from a gpu_metrics table.
+// Only format 1, content revisions 1 to 3 are understood.
+func parseThrottleStatus(data []byte) (uint32, bool) {
+	if len(data) < throttleStatusOffset+4 {
+		return 0, false
+	}
+	format, content := data[2], data[3]
+	if format != 1 || content < 1 || content > 3 {
+		return 0, false
+	}
+	if size := binary.LittleEndian.Uint16(data[0:2]); int(size) < throttleStatusOffset+4 {
+		return 0, false
+	}
+
+	return binary.LittleEndian.Uint32(data[throttleStatusOffset:]), true
+}
+
+// LinkSpeedGTs parses a PCIe link speed such as "16.0 GT/s PCIe".
+func LinkSpeedGTs(speed string) (float64, bool) {
+	fields := strings.Fields(speed)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return value, true
+}
@@ -0,0 +1,232 @@
+package sampler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// subSampledFields are the metrics read between published samples. They are
+// instant snapshots that alias with bursty loads when read only once per
+// interval. Clocks are not among them: the published values are DPM levels
+// from pp_dpm_*, which cannot be polled cheaply and would not average with
+// the current clocks of gpu_metrics.
+var subSampledFields = []struct {
+	name  string
+	field func(*Metrics) **float64
+}{
+	{"gpu_busy_pct", func(m *Metrics) **float64 { return &m.GPUBusyPct }},
+	{"mem_busy_pct", func(m *Metrics) **float64 { return &m.MemBusyPct }},
+}
+
+// SubSampler is implemented by sources that can read the sub-sampled
+// metrics on their own. *Reader implements it.
+type SubSampler interface {
+	// SampleFast reads the sub-sampled metrics only, from sources cheap
+	// enough to poll at a high rate. Metrics it cannot read that cheaply
+	// are left nil. It reports false when the GPU must not be read, e.g.
+	// while it is runtime-suspended.
+	SampleFast() (Metrics, bool)
+}
+
+type subAccumulator struct {
+	min, max, sum float64
+	count         int
+}
+
+func (a *subAccumulator) add(value float64) {
+	if a.count == 0 || value < a.min {
+		a.min = value
+	}
+	if a.count == 0 || value > a.max {
+		a.max = value
+	}
+	a.sum += value
+	a.count++
+}
+
+// subSamples collects the sub-interval readings of one GPU until the next
+// published sample.
+type subSamples struct {
+	busy   atomic.Bool
+	mu     sync.Mutex
+	reads  int
+	fields map[string]*subAccumulator
+	// windowStart is when the current aggregation window began. Readings
+	// started before it belong to a published sample already.
+	windowStart time.Time
+}
+
+// SetSubInterval enables reading the cheap metrics every interval between
+// published samples. Published samples then carry the average of those
+// readings and their min/max in Sample.Stats. It must be called before Run.
+func (m *Manager) SetSubInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("sub-interval must be > 0")
+	}
+	if interval >= m.interval {
+		return fmt.Errorf("sub-interval must be < sample interval")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subInterval = interval
+
+	return nil
+}
+
+// runSubSampling reads the sub-sampled metrics every sub-interval while
+// there is demand. Each GPU is read in its own goroutine; a read still
+// running at the next tick is not started again.
+func (m *Manager) runSubSampling(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !m.HasDemand() {
+			// Readings from before an idle period would skew the first
+			// sample after it.
+			m.resetSubSamples()
+
+			continue
+		}
+
+		m.mu.RLock()
+		for id, reader := range m.readers {
+			fast, ok := reader.(SubSampler)
+			if !ok {
+				continue
+			}
+			acc := m.subSamplesFor(id)
+			if !acc.busy.CompareAndSwap(false, true) {
+				continue
+			}
+			go m.subSample(acc, fast)
+		}
+		m.mu.RUnlock()
+	}
+}
+
+// subSample takes one sub-interval reading into acc. A reading that started
+// before the current window is dropped, as the sample it belonged to has
+// been published.
+func (m *Manager) subSample(acc *subSamples, fast SubSampler) {
+	defer acc.busy.Store(false)
+	started := time.Now()
+	metrics, ok := fast.SampleFast()
+	if !ok {
+		return
+	}
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+	if started.Before(acc.windowStart) {
+		return
+	}
+	acc.reads++
+	for _, f := range subSampledFields {
+		if v := *f.field(&metrics); v != nil {
+			acc.fields[f.name].add(*v)
+		}
+	}
+}
+
+func (m *Manager) subSamplesFor(id string) *subSamples {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	acc, ok := m.sub[id]
+	if !ok {
+		acc = &subSamples{fields: make(map[string]*subAccumulator, len(subSampledFields)), windowStart: time.Now()}
+		for _, f := range subSampledFields {
+			acc.fields[f.name] = &subAccumulator{}
+		}
+		m.sub[id] = acc
+	}
+
+	return acc
+}
+
+func (m *Manager) resetSubSamples() {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	for _, acc := range m.sub {
+		acc.mu.Lock()
+		acc.reads = 0
+		acc.windowStart = time.Now()
+		for _, f := range acc.fields {
+			*f = subAccumulator{}
+		}
+		acc.mu.Unlock()
+	}
+}
+
+// mergeSubSamples folds the readings taken since the previous sample of the
+// GPU, together with the sample's own, into the sample and starts a new
+// aggregation window.
+func (m *Manager) mergeSubSamples(sample Sample) Sample {
+	m.subMu.Lock()
+	acc, ok := m.sub[sample.GPUId]
+	m.subMu.Unlock()
+	if !ok {
+		return sample
+	}
+
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+	reads := acc.reads
+	acc.reads = 0
+	acc.windowStart = time.Now()
+	if sample.Suspended() {
+		for _, f := range acc.fields {
+			*f = subAccumulator{}
+		}
+
+		return sample
+	}
+
+	for _, f := range subSampledFields {
+		window := acc.fields[f.name]
+		value := *window
+		*window = subAccumulator{}
+		ptr := f.field(&sample.Metrics)
+		if *ptr != nil {
+			value.add(**ptr)
+		}
+		if value.count == 0 {
+			continue
+		}
+		if sample.Stats == nil {
+			sample.Stats = make(map[string]Aggregate, len(subSampledFields))
+		}
+		avg := value.sum / float64(value.count)
+		*ptr = &avg
+		sample.Stats[f.name] = Aggregate{Min: value.min, Avg: avg, Max: value.max}
+	}
+	if sample.Stats != nil {
+		sample.SubSamples = reads + 1
+	}
+
+	return sample
+}
+
+// SampleFast implements SubSampler. It skips runtime-suspended GPUs like
+// Sample does and reads gpu_busy_percent and mem_busy_percent only: both
+// are values the driver keeps current, while gpu_metrics and pp_dpm_* are
+// fetched from the SMU on every read.
+func (r *Reader) SampleFast() (Metrics, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.readPowerState() == PowerStateSuspended {
+		return Metrics{}, false
+	}
+
+	var metrics Metrics
+	metrics.GPUBusyPct, _ = r.readPercent(gpuBusyFilename)
+	metrics.MemBusyPct, _ = r.readPercent(memBusyFilename)
+
+	return metrics, true
+}
@@ -0,0 +1,182 @@
+package sampler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultReadTimeout bounds a single Sample call unless SetReadTimeout is
+// used.
+const DefaultReadTimeout = time.Second
+
+// Quarantine describes a GPU whose reader did not return within the read
+// timeout. Its samples carry no readings until a read succeeds in time.
+type Quarantine struct {
+	GPUId string    `json:"gpu_id"`
+	Since time.Time `json:"since"`
+	// Pending is set while the hung read has not returned yet; no new read
+	// is started for the GPU until it does.
+	Pending bool `json:"pending"`
+}
+
+type readerHealth struct {
+	inFlight bool
+	hung     bool
+	since    time.Time
+}
+
+// SetReadTimeout sets how long a GPU reader may take before it is
+// quarantined. It must be called before Run.
+func (m *Manager) SetReadTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("read timeout must be > 0")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.readTimeout = timeout
+
+	return nil
+}
+
+// Quarantined lists the GPUs whose readers are currently hung, sorted by id.
+func (m *Manager) Quarantined() []Quarantine {
+	m.healthMu.Lock()
+	out := make([]Quarantine, 0)
+	for id, h := range m.health {
+		if h.hung {
+			out = append(out, Quarantine{GPUId: id, Since: h.since, Pending: h.inFlight})
+		}
+	}
+	m.healthMu.Unlock()
+
+	slices.SortFunc(out, func(a, b Quarantine) int { return strings.Compare(a.GPUId, b.GPUId) })
+
+	return out
+}
+
+// sampleAllUnlocked samples every reader in its own goroutine, so one hung
+// sysfs read cannot hold up the other GPUs.
+func (m *Manager) sampleAllUnlocked() []Sample {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		samples = make([]Sample, 0, len(m.readers))
+	)
+	for id, reader := range m.readers {
+		if reader == nil {
+			continue
+		}
+		wg.Go(func() {
+			sample := m.sampleReader(id, reader)
+			mu.Lock()
+			samples = append(samples, sample)
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	return samples
+}
+
+// sampleReader reads one GPU within the read timeout. A read that does not
+// return in time quarantines the GPU and yields a quarantined sample; the
+// next read that returns in time lifts the quarantine. The goroutine of a
+// hung read lives until the read returns, which may be never; Close waits
+// for such reads only up to the read timeout.
+func (m *Manager) sampleReader(id string, reader Source) Sample {
+	m.mu.RLock()
+	timeout := m.readTimeout
+	m.mu.RUnlock()
+
+	m.healthMu.Lock()
+	h, ok := m.health[id]
+	if !ok {
+		h = &readerHealth{}
+		m.health[id] = h
+	}
+	if h.inFlight {
+		m.healthMu.Unlock()
+
+		return Sample{GPUId: id, Timestamp: time.Now(), Quarantined: true}
+	}
+	h.inFlight = true
+	m.healthMu.Unlock()
+
+	started := time.Now()
+	done := make(chan Sample, 1)
+	m.reads.Add(1)
+	go func() {
+		defer m.reads.Done()
+		sample := m.readSource(id, reader)
+		m.healthMu.Lock()
+		h.inFlight = false
+		late := h.hung
+		m.healthMu.Unlock()
+		if late {
+			m.logger.Info("hung reader returned", "gpu_id", id, "after", time.Since(started))
+		}
+		done <- sample
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case sample := <-done:
+		m.healthMu.Lock()
+		recovered := h.hung
+		h.hung = false
+		m.healthMu.Unlock()
+		if recovered {
+			m.logger.Info("reader recovered, quarantine lifted", "gpu_id", id)
+		}
+
+		return m.mergeSubSamples(sample)
+	case <-timer.C:
+		m.healthMu.Lock()
+		quarantined := !h.hung
+		if quarantined {
+			h.hung = true
+			h.since = started
+		}
+		m.healthMu.Unlock()
+		if quarantined {
+			m.logger.Warn("reader did not respond, quarantining gpu", "gpu_id", id, "timeout", timeout)
+		}
+
+		return Sample{GPUId: id, Timestamp: time.Now(), Quarantined: true}
+	}
+}
+
+// waitReads waits up to the read timeout for reads still in flight. Reads
+// stuck in the kernel cannot be interrupted; their goroutines are left
+// behind and exit whenever the read returns.
+func (m *Manager) waitReads() {
+	m.mu.RLock()
+	timeout := m.readTimeout
+	m.mu.RUnlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.reads.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		m.healthMu.Lock()
+		var pending []string
+		for id, h := range m.health {
+			if h.inFlight {
+				pending = append(pending, id)
+			}
+		}
+		m.healthMu.Unlock()
+		slices.Sort(pending)
+		m.logger.Warn("closing with hung reads still in flight", "gpu_ids", pending)
+	}
+}
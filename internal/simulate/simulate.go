@@ -0,0 +1,338 @@
+// Package simulate provides synthetic GPUs with correlated telemetry and fake
+// GPU clients, used in place of sysfs and procfs for development and demos.
+package simulate
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/config"
+	"github.com/skobkin/amdgputop-web/internal/gpu"
+	"github.com/skobkin/amdgputop-web/internal/procscan"
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+// MaxGPUs bounds the number of simulated GPUs.
+const MaxGPUs = config.MaxSimulatedGPUs
+
+const (
+	mib = 1 << 20
+	gib = 1 << 30
+
+	vramTotal = 16 * gib
+	gttTotal  = 32 * gib
+	vramBase  = 300 * mib
+
+	sclkIdle = 500.0
+	sclkMax  = 2500.0
+
+	loadTau = 2 * time.Second
+	tempTau = 20 * time.Second
+	fanTau  = 5 * time.Second
+
+	// maxStep caps a single integration step so a long idle gap (e.g. lazy
+	// sampling) does not overshoot.
+	maxStep = time.Second
+)
+
+// client describes a fake GPU process template.
+type client struct {
+	name     string
+	command  string
+	category string
+	share    float64
+	vram     uint64
+	gtt      uint64
+	// lifetime bounds how long an instance runs before it is replaced by a
+	// new PID; zero means it runs forever.
+	lifetime time.Duration
+}
+
+var clients = []client{
+	{name: "game", command: "/opt/game/bin/game -fullscreen", category: "game", share: 0.7, vram: 6 * gib, gtt: 256 * mib},
+	{name: "kwin_wayland", command: "/usr/bin/kwin_wayland", category: "compositor", share: 0.1, vram: 400 * mib, gtt: 64 * mib},
+	{name: "firefox", command: "/usr/lib/firefox/firefox -contentproc", category: "browser", share: 0.15, vram: 800 * mib, gtt: 128 * mib, lifetime: 5 * time.Minute},
+	{name: "ffmpeg", command: "ffmpeg -hwaccel vaapi -i input.mkv output.mp4", category: "encoder", share: 0.05, vram: 200 * mib, gtt: 32 * mib, lifetime: 90 * time.Second},
+}
+
+type process struct {
+	client   *client
+	pid      int
+	started  time.Time
+	expires  time.Time
+	engineNS float64
+	vram     uint64
+	phase    float64
+}
+
+type device struct {
+	info  gpu.Info
+	phase float64
+	// period of the dominant load wave.
+	period time.Duration
+
+	last  time.Time
+	load  float64
+	temp  float64
+	fan   float64
+	procs []*process
+}
+
+// Simulator owns the state of all simulated GPUs. Sampler sources and the
+// process source advance the same state, so per-process GPU time and memory
+// add up to the GPU-level readings.
+type Simulator struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	rng     *rand.Rand
+	start   time.Time
+	nextPID int
+	devices []*device
+}
+
+// New creates count simulated GPUs. The seed makes runs reproducible.
+func New(count int, seed uint64) (*Simulator, error) {
+	return newSimulator(count, seed, time.Now)
+}
+
+func newSimulator(count int, seed uint64, now func() time.Time) (*Simulator, error) {
+	if count <= 0 || count > MaxGPUs {
+		return nil, fmt.Errorf("gpu count must be between 1 and %d", MaxGPUs)
+	}
+
+	s := &Simulator{
+		now:     now,
+		rng:     rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+		start:   now(),
+		nextPID: 4000,
+	}
+	for i := range count {
+		dev := &device{
+			info: gpu.Info{
+				ID:         fmt.Sprintf("card%d", i),
+				PCI:        fmt.Sprintf("0000:%02x:00.0", 3+i),
+				PCIID:      "1002:744C",
+				Name:       fmt.Sprintf("Simulated Radeon GPU %d", i),
+				RenderNode: fmt.Sprintf("/dev/dri/renderD%d", 128+i),
+			},
+			phase:  s.rng.Float64() * 2 * math.Pi,
+			period: time.Duration(60+s.rng.IntN(120)) * time.Second,
+			last:   s.start,
+			temp:   35,
+		}
+		for c := range clients {
+			dev.procs = append(dev.procs, s.spawn(&clients[c], s.start))
+		}
+		dev.load = dev.targetLoad(0, 0)
+		s.devices = append(s.devices, dev)
+	}
+
+	return s, nil
+}
+
+// GPUs describes the simulated devices.
+func (s *Simulator) GPUs() []gpu.Info {
+	infos := make([]gpu.Info, 0, len(s.devices))
+	for _, dev := range s.devices {
+		infos = append(infos, dev.info)
+	}
+
+	return infos
+}
+
+// SamplerSources returns one sampler source per simulated GPU.
+func (s *Simulator) SamplerSources() map[string]sampler.Source {
+	sources := make(map[string]sampler.Source, len(s.devices))
+	for _, dev := range s.devices {
+		sources[dev.info.ID] = &sampleSource{sim: s, dev: dev}
+	}
+
+	return sources
+}
+
+// ProcSource returns a procscan source listing the fake GPU clients.
+func (s *Simulator) ProcSource() procscan.Source {
+	return &procSource{sim: s}
+}
+
+func (s *Simulator) spawn(c *client, now time.Time) *process {
+	s.nextPID += 1 + s.rng.IntN(50)
+	p := &process{
+		client:  c,
+		pid:     s.nextPID,
+		started: now,
+		phase:   s.rng.Float64() * 2 * math.Pi,
+		vram:    c.vram,
+	}
+	if c.lifetime > 0 {
+		jitter := time.Duration(s.rng.Int64N(int64(c.lifetime)))
+		p.expires = now.Add(c.lifetime/2 + jitter)
+	}
+
+	return p
+}
+
+// advance integrates the device state up to now. Callers hold s.mu.
+func (s *Simulator) advance(dev *device, now time.Time) {
+	for dev.last.Before(now) {
+		step := min(now.Sub(dev.last), maxStep)
+		dev.last = dev.last.Add(step)
+		s.step(dev, dev.last, step)
+	}
+}
+
+func (s *Simulator) step(dev *device, now time.Time, dt time.Duration) {
+	noise := s.rng.NormFloat64() * 4
+	dev.load = approach(dev.load, dev.targetLoad(now.Sub(s.start), noise), dt, loadTau)
+	dev.temp = approach(dev.temp, 35+dev.power()*0.2, dt, tempTau)
+	dev.fan = approach(dev.fan, fanTarget(dev.temp), dt, fanTau)
+
+	for i, p := range dev.procs {
+		if !p.expires.IsZero() && !now.Before(p.expires) {
+			p = s.spawn(p.client, now)
+			dev.procs[i] = p
+		}
+		p.engineNS += dev.load / 100 * p.client.share * float64(dt)
+		wobble := math.Sin(now.Sub(p.started).Seconds()/30 + p.phase)
+		p.vram = uint64(float64(p.client.vram) * (1 + 0.1*wobble))
+	}
+}
+
+// targetLoad mixes a slow wave, a faster ripple and noise into a 0-100 load.
+func (d *device) targetLoad(elapsed time.Duration, noise float64) float64 {
+	t := elapsed.Seconds()
+	wave := math.Sin(2*math.Pi*t/d.period.Seconds() + d.phase)
+	ripple := math.Sin(2*math.Pi*t/7 + d.phase*3)
+
+	return clamp(50+40*wave+8*ripple+noise, 0, 100)
+}
+
+func (d *device) power() float64 {
+	return 15 + 250*math.Pow(d.load/100, 1.3)
+}
+
+func (d *device) sample(now time.Time) sampler.Sample {
+	load := d.load
+	memBusy := clamp(load*0.6, 0, 100)
+	sclk := sclkIdle + (sclkMax-sclkIdle)*load/100
+	mclk := 96.0
+	switch {
+	case load > 40:
+		mclk = 1250
+	case load > 5:
+		mclk = 456
+	}
+	temp := d.temp
+	fan := d.fan
+	power := d.power()
+	vramUsed := uint64(vramBase)
+	var gttUsed uint64
+	for _, p := range d.procs {
+		vramUsed += p.vram
+		gttUsed += p.client.gtt
+	}
+	vramTotalBytes := uint64(vramTotal)
+	gttTotalBytes := uint64(gttTotal)
+
+	return sampler.Sample{
+		GPUId:     d.info.ID,
+		Timestamp: now.UTC(),
+		Metrics: sampler.Metrics{
+			GPUBusyPct:     &load,
+			MemBusyPct:     &memBusy,
+			SCLKMHz:        &sclk,
+			MCLKMHz:        &mclk,
+			TempC:          &temp,
+			FanRPM:         &fan,
+			PowerW:         &power,
+			VRAMUsedBytes:  &vramUsed,
+			VRAMTotalBytes: &vramTotalBytes,
+			GTTUsedBytes:   &gttUsed,
+			GTTTotalBytes:  &gttTotalBytes,
+		},
+	}
+}
+
+// fanTarget models a zero-RPM fan curve.
+func fanTarget(temp float64) float64 {
+	if temp < 50 {
+		return 0
+	}
+
+	return clamp(800+(temp-50)*60, 0, 3300)
+}
+
+// approach moves value towards target as a first-order lag with time
+// constant tau.
+func approach(value, target float64, dt, tau time.Duration) float64 {
+	return value + (target-value)*(1-math.Exp(-dt.Seconds()/tau.Seconds()))
+}
+
+func clamp(value, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, value))
+}
+
+type sampleSource struct {
+	sim *Simulator
+	dev *device
+}
+
+func (s *sampleSource) Sample() sampler.Sample {
+	s.sim.mu.Lock()
+	defer s.sim.mu.Unlock()
+
+	now := s.sim.now()
+	s.sim.advance(s.dev, now)
+
+	return s.dev.sample(now)
+}
+
+func (s *sampleSource) Close() error {
+	return nil
+}
+
+type procSource struct {
+	sim *Simulator
+}
+
+func (s *procSource) Collect() (map[string]procscan.SourceCollection, error) {
+	s.sim.mu.Lock()
+	defer s.sim.mu.Unlock()
+
+	now := s.sim.now()
+	result := make(map[string]procscan.SourceCollection, len(s.sim.devices))
+	for _, dev := range s.sim.devices {
+		s.sim.advance(dev, now)
+		col := procscan.SourceCollection{
+			HasMemory: true,
+			HasEngine: true,
+			Processes: make([]procscan.SourceProcess, 0, len(dev.procs)),
+		}
+		for _, p := range dev.procs {
+			col.Processes = append(col.Processes, procscan.SourceProcess{
+				PID:          p.pid,
+				UID:          1000,
+				User:         "demo",
+				Name:         p.client.name,
+				Command:      p.client.command,
+				RenderNode:   dev.info.RenderNode,
+				Category:     p.client.category,
+				VRAMBytes:    p.vram,
+				GTTBytes:     p.client.gtt,
+				HasMemory:    true,
+				EngineTimeNS: uint64(p.engineNS),
+				HasEngine:    true,
+			})
+		}
+		result[dev.info.ID] = col
+	}
+
+	return result, nil
+}
+
+func (s *procSource) Close() error {
+	return nil
+}
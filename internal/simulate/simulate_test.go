@@ -0,0 +1,124 @@
+package simulate
+
+import (
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func TestSimulatorSignals(t *testing.T) {
+	t.Parallel()
+
+	clock := &testClock{now: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	sim, err := newSimulator(2, 1, clock.Now)
+	if err != nil {
+		t.Fatalf("newSimulator returned error: %v", err)
+	}
+	if gpus := sim.GPUs(); len(gpus) != 2 || gpus[1].ID != "card1" || gpus[1].RenderNode != "/dev/dri/renderD129" {
+		t.Fatalf("unexpected gpus %+v", gpus)
+	}
+
+	source := sim.SamplerSources()["card0"]
+	for range 600 {
+		clock.now = clock.now.Add(time.Second)
+		sample := source.Sample()
+		m := sample.Metrics
+		if !sample.Timestamp.Equal(clock.now) {
+			t.Fatalf("unexpected timestamp %s", sample.Timestamp)
+		}
+		if *m.GPUBusyPct < 0 || *m.GPUBusyPct > 100 || *m.TempC < 30 || *m.TempC > 110 || *m.FanRPM < 0 || *m.FanRPM > 3300 {
+			t.Fatalf("reading out of range: %+v", m)
+		}
+		// Clocks and power are driven by the load.
+		wantSCLK := sclkIdle + (sclkMax-sclkIdle)**m.GPUBusyPct/100
+		if *m.SCLKMHz != wantSCLK || *m.PowerW < 15 || *m.PowerW > 265 {
+			t.Fatalf("uncorrelated readings: %+v", m)
+		}
+		if *m.VRAMUsedBytes > *m.VRAMTotalBytes {
+			t.Fatalf("VRAM used exceeds total: %d > %d", *m.VRAMUsedBytes, *m.VRAMTotalBytes)
+		}
+	}
+}
+
+func TestSimulatorProcesses(t *testing.T) {
+	t.Parallel()
+
+	clock := &testClock{now: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	sim, err := newSimulator(1, 7, clock.Now)
+	if err != nil {
+		t.Fatalf("newSimulator returned error: %v", err)
+	}
+	procs := sim.ProcSource()
+
+	first, err := procs.Collect()
+	if err != nil {
+		t.Fatalf("Collect returned error: %v", err)
+	}
+	initial := make(map[int]uint64)
+	for _, p := range first["card0"].Processes {
+		initial[p.PID] = p.EngineTimeNS
+	}
+	if len(initial) != len(clients) {
+		t.Fatalf("expected %d processes, got %+v", len(clients), first)
+	}
+
+	clock.now = clock.now.Add(10 * time.Minute)
+	second, err := procs.Collect()
+	if err != nil {
+		t.Fatalf("Collect returned error: %v", err)
+	}
+	var kept, respawned int
+	for _, p := range second["card0"].Processes {
+		if !p.HasMemory || !p.HasEngine || p.VRAMBytes == 0 {
+			t.Fatalf("incomplete process %+v", p)
+		}
+		before, ok := initial[p.PID]
+		if !ok {
+			respawned++
+
+			continue
+		}
+		kept++
+		if p.EngineTimeNS <= before {
+			t.Fatalf("engine time of pid %d did not advance: %d -> %d", p.PID, before, p.EngineTimeNS)
+		}
+	}
+	// Clients with a bounded lifetime are replaced by new PIDs.
+	if kept != 2 || respawned != 2 {
+		t.Fatalf("expected 2 long-lived and 2 respawned processes, got %d and %d", kept, respawned)
+	}
+}
+
+func TestSimulatorDeterministic(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	run := func() float64 {
+		clock := &testClock{now: start}
+		sim, err := newSimulator(1, 42, clock.Now)
+		if err != nil {
+			t.Fatalf("newSimulator returned error: %v", err)
+		}
+		clock.now = start.Add(time.Minute)
+
+		return *sim.SamplerSources()["card0"].Sample().Metrics.PowerW
+	}
+	if a, b := run(), run(); a != b {
+		t.Fatalf("expected identical runs for the same seed, got %v and %v", a, b)
+	}
+}
+
+func TestNewRejectsInvalidCount(t *testing.T) {
+	t.Parallel()
+
+	for _, count := range []int{0, MaxGPUs + 1} {
+		if _, err := New(count, 1); err == nil {
+			t.Fatalf("expected error for %d gpus", count)
+		}
+	}
+}
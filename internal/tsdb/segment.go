@@ -0,0 +1,175 @@
+package tsdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+// Records are framed as a little-endian uint32 payload length and an IEEE
+// CRC-32 of the payload, followed by the payload itself:
+//
+//	uvarint len(gpu_id) | gpu_id | varint unix_nano | uvarint count |
+//	uvarint metric_mask | float64 values...
+//
+// Bit i of metric_mask refers to sampler.MetricFields[i]. Raw samples
+// (count 1) store one value per metric, rollups store min, avg and max.
+const frameHeaderSize = 8
+
+var errCorruptRecord = errors.New("corrupt record")
+
+type record struct {
+	gpuID  string
+	bucket sampler.HistoryBucket
+}
+
+func appendRecord(dst []byte, gpuID string, bucket sampler.HistoryBucket) []byte {
+	payload := binary.AppendUvarint(nil, uint64(len(gpuID)))
+	payload = append(payload, gpuID...)
+	payload = binary.AppendVarint(payload, bucket.Timestamp.UnixNano())
+	payload = binary.AppendUvarint(payload, uint64(bucket.Count))
+
+	var mask uint64
+	for i, field := range sampler.MetricFields {
+		if _, ok := bucket.Metrics[field.Name]; ok {
+			mask |= 1 << i
+		}
+	}
+	payload = binary.AppendUvarint(payload, mask)
+
+	for i, field := range sampler.MetricFields {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		agg := bucket.Metrics[field.Name]
+		if bucket.Count == 1 {
+			payload = binary.LittleEndian.AppendUint64(payload, math.Float64bits(agg.Avg))
+
+			continue
+		}
+		payload = binary.LittleEndian.AppendUint64(payload, math.Float64bits(agg.Min))
+		payload = binary.LittleEndian.AppendUint64(payload, math.Float64bits(agg.Avg))
+		payload = binary.LittleEndian.AppendUint64(payload, math.Float64bits(agg.Max))
+	}
+
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(payload)))
+	dst = binary.LittleEndian.AppendUint32(dst, crc32.ChecksumIEEE(payload))
+
+	return append(dst, payload...)
+}
+
+// decodeRecords decodes the records of a segment. It stops at the first
+// truncated or corrupt frame and reports how many bytes were valid, which is
+// where a writer that crashed mid-append should resume.
+func decodeRecords(data []byte) ([]record, int) {
+	var (
+		records []record
+		offset  int
+	)
+	for len(data)-offset >= frameHeaderSize {
+		size := int(binary.LittleEndian.Uint32(data[offset:]))
+		sum := binary.LittleEndian.Uint32(data[offset+4:])
+		end := offset + frameHeaderSize + size
+		if size <= 0 || end > len(data) {
+			break
+		}
+		payload := data[offset+frameHeaderSize : end]
+		if crc32.ChecksumIEEE(payload) != sum {
+			break
+		}
+		rec, err := decodePayload(payload)
+		if err != nil {
+			break
+		}
+		records = append(records, rec)
+		offset = end
+	}
+
+	return records, offset
+}
+
+func decodePayload(payload []byte) (record, error) {
+	var rec record
+
+	idLen, n := binary.Uvarint(payload)
+	if n <= 0 || uint64(len(payload)-n) < idLen {
+		return rec, errCorruptRecord
+	}
+	payload = payload[n:]
+	rec.gpuID = string(payload[:idLen])
+	payload = payload[idLen:]
+
+	nanos, n := binary.Varint(payload)
+	if n <= 0 {
+		return rec, errCorruptRecord
+	}
+	payload = payload[n:]
+
+	count, n := binary.Uvarint(payload)
+	if n <= 0 || count == 0 {
+		return rec, errCorruptRecord
+	}
+	payload = payload[n:]
+
+	mask, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return rec, errCorruptRecord
+	}
+	payload = payload[n:]
+
+	rec.bucket = sampler.HistoryBucket{
+		Timestamp: time.Unix(0, nanos),
+		Count:     int(count),
+		Metrics:   make(map[string]sampler.Aggregate),
+	}
+	next := func() (float64, error) {
+		if len(payload) < 8 {
+			return 0, errCorruptRecord
+		}
+		value := math.Float64frombits(binary.LittleEndian.Uint64(payload))
+		payload = payload[8:]
+
+		return value, nil
+	}
+	for i := 0; mask != 0; i++ {
+		if mask&1 == 0 {
+			mask >>= 1
+
+			continue
+		}
+		mask >>= 1
+		var agg sampler.Aggregate
+		if count == 1 {
+			value, err := next()
+			if err != nil {
+				return rec, err
+			}
+			agg = sampler.Aggregate{Min: value, Avg: value, Max: value}
+		} else {
+			var err error
+			if agg.Min, err = next(); err != nil {
+				return rec, err
+			}
+			if agg.Avg, err = next(); err != nil {
+				return rec, err
+			}
+			if agg.Max, err = next(); err != nil {
+				return rec, err
+			}
+		}
+		// Metrics unknown to this build are skipped rather than rejected.
+		if i < len(sampler.MetricFields) {
+			rec.bucket.Metrics[sampler.MetricFields[i].Name] = agg
+		}
+	}
+	if len(payload) != 0 {
+		return rec, fmt.Errorf("%w: %d trailing bytes", errCorruptRecord, len(payload))
+	}
+
+	return rec, nil
+}
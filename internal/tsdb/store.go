@@ -0,0 +1,698 @@
+// Package tsdb persists GPU samples in append-only segment files with raw,
+// per-minute and per-hour retention tiers.
+package tsdb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+const (
+	segmentExt = ".seg"
+	// queueSize is how many samples Append buffers for the writer.
+	queueSize = 256
+	// retentionEvery is how often expired segments are looked for.
+	retentionEvery = 10 * time.Minute
+)
+
+var (
+	// ErrClosed is returned when the store is used after Close.
+	ErrClosed = errors.New("store closed")
+	// ErrReadOnly is returned by Append on a store opened read-only.
+	ErrReadOnly = errors.New("store is read-only")
+	// ErrQueueFull is returned by Append when the writer has fallen behind
+	// and the sample was dropped.
+	ErrQueueFull = errors.New("append queue full")
+)
+
+// tier is a resolution at which samples are kept. Each tier lives in its own
+// directory of segments covering span each.
+type tier struct {
+	name       string
+	resolution time.Duration
+	span       time.Duration
+	retention  time.Duration
+}
+
+var defaultTiers = []tier{
+	{name: "raw", resolution: 0, span: time.Hour, retention: 24 * time.Hour},
+	{name: "1m", resolution: time.Minute, span: 24 * time.Hour, retention: 30 * 24 * time.Hour},
+	{name: "1h", resolution: time.Hour, span: 7 * 24 * time.Hour, retention: 365 * 24 * time.Hour},
+}
+
+// Options configure a Store.
+type Options struct {
+	// Dir is created if missing.
+	Dir    string
+	Logger *slog.Logger
+	// Now overrides the clock used for retention and open-ended queries.
+	Now func() time.Time
+	// RawRetention overrides how long raw samples are kept (default 24h).
+	RawRetention time.Duration
+	// ReadOnly opens an existing store for reading alongside a running
+	// writer, skipping recovery and retention.
+	ReadOnly bool
+}
+
+// Store is an embedded time-series store implementing sampler.HistoryStore.
+type Store struct {
+	root   *os.Root
+	logger *slog.Logger
+	now    func() time.Time
+	tiers  []tier
+	ro     bool
+
+	// queue feeds Append to the writer goroutine, which exits once it is
+	// closed and drained.
+	queueMu  sync.RWMutex
+	stopping bool
+	queue    chan appendRequest
+	done     chan struct{}
+	// retainedAt is when retention last ran; only the writer touches it.
+	retainedAt time.Time
+
+	mu      sync.Mutex
+	closed  bool
+	writers []*segmentWriter
+	// rollups holds the in-progress bucket per GPU for each rollup tier,
+	// indexed like tiers (the raw tier entry is unused).
+	rollups []map[string]*rollup
+}
+
+// appendRequest is a sample to write, or with flushed set a request to
+// report once everything queued before it is written.
+type appendRequest struct {
+	sample  sampler.Sample
+	flushed chan struct{}
+}
+
+type segmentWriter struct {
+	file  *os.File
+	start time.Time
+	size  int64
+}
+
+type rollup struct {
+	start   time.Time
+	count   int
+	metrics map[string]*metricAcc
+}
+
+type metricAcc struct {
+	min, max, sum float64
+	n             int
+}
+
+// Open opens or creates a store in opts.Dir. Torn records left by a crash at
+// the end of the newest segments are truncated, and rollups that were still
+// in progress are rebuilt from the raw tier.
+func Open(opts Options) (*Store, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("data dir is required")
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if !opts.ReadOnly {
+		if err := os.MkdirAll(opts.Dir, 0o750); err != nil {
+			return nil, fmt.Errorf("create data dir: %w", err)
+		}
+	}
+	root, err := os.OpenRoot(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("open data dir: %w", err)
+	}
+
+	tiers := append([]tier(nil), defaultTiers...)
+	if opts.RawRetention > 0 {
+		tiers[0].retention = opts.RawRetention
+	}
+
+	s := &Store{
+		root:    root,
+		logger:  opts.Logger,
+		now:     opts.Now,
+		tiers:   tiers,
+		ro:      opts.ReadOnly,
+		writers: make([]*segmentWriter, len(tiers)),
+		rollups: make([]map[string]*rollup, len(tiers)),
+	}
+	for i, t := range tiers {
+		s.rollups[i] = make(map[string]*rollup)
+		if opts.ReadOnly {
+			continue
+		}
+		if err := root.MkdirAll(t.name, 0o750); err != nil {
+			_ = root.Close()
+
+			return nil, fmt.Errorf("create %s tier: %w", t.name, err)
+		}
+	}
+	if opts.ReadOnly {
+		return s, nil
+	}
+
+	if err := s.recover(); err != nil {
+		_ = root.Close()
+
+		return nil, err
+	}
+	s.applyRetention()
+	s.retainedAt = s.now()
+
+	s.queue = make(chan appendRequest, queueSize)
+	s.done = make(chan struct{})
+	go s.runWriter()
+
+	return s, nil
+}
+
+// Append queues a raw sample to be written and folded into the rollup
+// tiers. It does not wait for the write, so callers on the sampling path
+// are not held up by disk I/O; write errors are logged by the writer.
+func (s *Store) Append(sample sampler.Sample) error {
+	if s.ro {
+		return ErrReadOnly
+	}
+	s.queueMu.RLock()
+	defer s.queueMu.RUnlock()
+	if s.stopping {
+		return ErrClosed
+	}
+	select {
+	case s.queue <- appendRequest{sample: sample}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Flush waits until the samples appended so far are written.
+func (s *Store) Flush() error {
+	if s.ro {
+		return nil
+	}
+	flushed := make(chan struct{})
+	s.queueMu.RLock()
+	if s.stopping {
+		s.queueMu.RUnlock()
+
+		return ErrClosed
+	}
+	// Unlike samples, flush requests wait for room in the queue.
+	s.queue <- appendRequest{flushed: flushed}
+	s.queueMu.RUnlock()
+	<-flushed
+
+	return nil
+}
+
+// runWriter writes queued samples until the queue is closed. Retention runs
+// here too, at most every retentionEvery, so neither Append nor segment
+// rolls list directories.
+func (s *Store) runWriter() {
+	defer close(s.done)
+	for req := range s.queue {
+		if req.flushed != nil {
+			close(req.flushed)
+
+			continue
+		}
+		if err := s.write(req.sample); err != nil {
+			s.logger.Warn("failed to write sample", "gpu_id", req.sample.GPUId, "err", err)
+		}
+		if now := s.now(); now.Sub(s.retainedAt) >= retentionEvery {
+			s.applyRetention()
+			s.retainedAt = now
+		}
+	}
+}
+
+// write stores a raw sample and folds it into the rollup tiers.
+func (s *Store) write(sample sampler.Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket := sampler.SampleBucket(sample)
+	if err := s.writeLocked(0, sample.GPUId, bucket); err != nil {
+		return err
+	}
+	for i := 1; i < len(s.tiers); i++ {
+		if err := s.rollupLocked(i, sample.GPUId, bucket); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Query returns stored buckets of the GPU in [since, until]. It reads the
+// coarsest tier whose resolution does not exceed step and whose retention
+// covers since, falling back to the finest tier covering since. Samples
+// still queued by Append are not visible yet. Like Samples, segments are
+// read without holding the store lock.
+func (s *Store) Query(gpuID string, since, until time.Time, step time.Duration) ([]sampler.HistoryBucket, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+
+		return nil, ErrClosed
+	}
+
+	now := s.now()
+	idx := s.pickTier(now, since, step)
+	t := s.tiers[idx]
+	if since.IsZero() {
+		since = now.Add(-t.retention)
+	}
+	if until.IsZero() {
+		until = now
+	}
+
+	starts, err := s.segments(t)
+	// Expose the bucket that is still being rolled up so the newest data is
+	// visible before it is flushed.
+	var pending *sampler.HistoryBucket
+	if r, ok := s.rollups[idx][gpuID]; ok && idx > 0 && r.count > 0 {
+		if !r.start.Before(since) && !r.start.After(until) {
+			bucket := r.bucket()
+			pending = &bucket
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []sampler.HistoryBucket{}
+	for _, start := range starts {
+		if start.After(until) || !start.Add(t.span).After(since) {
+			continue
+		}
+		data, err := s.root.ReadFile(segmentPath(t, start))
+		if errors.Is(err, fs.ErrNotExist) {
+			// Removed by retention since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s segment: %w", t.name, err)
+		}
+		records, _ := decodeRecords(data)
+		for _, rec := range records {
+			if rec.gpuID != gpuID || rec.bucket.Timestamp.Before(since) || rec.bucket.Timestamp.After(until) {
+				continue
+			}
+			result = append(result, rec.bucket)
+		}
+	}
+	if pending != nil {
+		result = append(result, *pending)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+
+	return result, nil
+}
+
+// Samples calls fn for every raw sample in [from, to] in time order; a zero
+// bound leaves that side open and an empty gpuID selects all GPUs. Segments
+// are read without holding the store lock, so slow consumers such as HTTP
+// exports do not stall Append.
+func (s *Store) Samples(gpuID string, from, to time.Time, fn func(gpuID string, sample sampler.HistoryBucket) error) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+
+		return ErrClosed
+	}
+	t := s.tiers[0]
+	starts, err := s.segments(t)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, start := range starts {
+		if !to.IsZero() && start.After(to) {
+			break
+		}
+		if !from.IsZero() && !start.Add(t.span).After(from) {
+			continue
+		}
+		data, err := s.root.ReadFile(segmentPath(t, start))
+		if errors.Is(err, fs.ErrNotExist) {
+			// Removed by retention since it was listed.
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read %s segment: %w", t.name, err)
+		}
+		records, _ := decodeRecords(data)
+		for _, rec := range records {
+			if gpuID != "" && rec.gpuID != gpuID {
+				continue
+			}
+			ts := rec.bucket.Timestamp
+			if (!from.IsZero() && ts.Before(from)) || (!to.IsZero() && ts.After(to)) {
+				continue
+			}
+			if err := fn(rec.gpuID, rec.bucket); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close writes the queued samples, then flushes and closes open segments.
+// In-progress rollups are not written; they are rebuilt from raw samples on
+// the next Open.
+func (s *Store) Close() error {
+	if !s.ro {
+		s.queueMu.Lock()
+		if !s.stopping {
+			s.stopping = true
+			close(s.queue)
+		}
+		s.queueMu.Unlock()
+		<-s.done
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	var errs []error
+	for i, w := range s.writers {
+		if w == nil {
+			continue
+		}
+		if err := w.close(); err != nil {
+			errs = append(errs, fmt.Errorf("close %s segment: %w", s.tiers[i].name, err))
+		}
+		s.writers[i] = nil
+	}
+	if err := s.root.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Store) pickTier(now, since time.Time, step time.Duration) int {
+	covers := func(t tier) bool {
+		return !since.IsZero() && !since.Before(now.Add(-t.retention))
+	}
+
+	chosen := -1
+	for i, t := range s.tiers {
+		if since.IsZero() || covers(t) {
+			if chosen == -1 || t.resolution <= step {
+				chosen = i
+			}
+		}
+	}
+	if chosen == -1 {
+		return len(s.tiers) - 1
+	}
+
+	return chosen
+}
+
+func (s *Store) rollupLocked(idx int, gpuID string, bucket sampler.HistoryBucket) error {
+	t := s.tiers[idx]
+	start := bucket.Timestamp.Truncate(t.resolution)
+
+	r, ok := s.rollups[idx][gpuID]
+	if ok && start.Before(r.start) {
+		// Samples from before the open bucket (e.g. after a clock step) only
+		// land in the raw tier.
+		return nil
+	}
+	if ok && start.After(r.start) {
+		if err := s.writeLocked(idx, gpuID, r.bucket()); err != nil {
+			return err
+		}
+		ok = false
+	}
+	if !ok {
+		r = &rollup{start: start, metrics: make(map[string]*metricAcc)}
+		s.rollups[idx][gpuID] = r
+	}
+	r.add(bucket)
+
+	return nil
+}
+
+func (s *Store) writeLocked(idx int, gpuID string, bucket sampler.HistoryBucket) error {
+	t := s.tiers[idx]
+	start := bucket.Timestamp.Truncate(t.span)
+
+	w := s.writers[idx]
+	if w == nil || start.After(w.start) {
+		if w != nil {
+			if err := w.close(); err != nil {
+				s.logger.Warn("failed to close segment", "tier", t.name, "err", err)
+			}
+			s.writers[idx] = nil
+		}
+		opened, err := s.openWriter(t, start)
+		if err != nil {
+			return err
+		}
+		s.writers[idx] = opened
+		w = opened
+	}
+
+	data := appendRecord(nil, gpuID, bucket)
+	if _, err := w.file.Write(data); err != nil {
+		// Drop the partial frame so later records stay readable.
+		if truncErr := w.file.Truncate(w.size); truncErr != nil {
+			s.logger.Warn("failed to truncate segment after write error", "tier", t.name, "err", truncErr)
+		}
+
+		return fmt.Errorf("write %s segment: %w", t.name, err)
+	}
+	w.size += int64(len(data))
+
+	return nil
+}
+
+func (s *Store) openWriter(t tier, start time.Time) (*segmentWriter, error) {
+	file, err := s.root.OpenFile(segmentPath(t, start), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("open %s segment: %w", t.name, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+
+		return nil, fmt.Errorf("stat %s segment: %w", t.name, err)
+	}
+
+	return &segmentWriter{file: file, start: start, size: info.Size()}, nil
+}
+
+// recover truncates torn tails and replays raw samples newer than the last
+// flushed rollup of each GPU.
+func (s *Store) recover() error {
+	lastFlushed := make([]map[string]time.Time, len(s.tiers))
+	for i, t := range s.tiers {
+		lastFlushed[i] = make(map[string]time.Time)
+
+		starts, err := s.segments(t)
+		if err != nil {
+			return err
+		}
+		if len(starts) == 0 {
+			continue
+		}
+		if err := s.truncateTorn(t, starts[len(starts)-1]); err != nil {
+			return err
+		}
+		if i == 0 {
+			continue
+		}
+		// Raw data only reaches back a day, and the newest two rollup
+		// segments always cover at least that.
+		for _, start := range starts[max(0, len(starts)-2):] {
+			data, err := s.root.ReadFile(segmentPath(t, start))
+			if err != nil {
+				return fmt.Errorf("read %s segment: %w", t.name, err)
+			}
+			records, _ := decodeRecords(data)
+			for _, rec := range records {
+				if rec.bucket.Timestamp.After(lastFlushed[i][rec.gpuID]) {
+					lastFlushed[i][rec.gpuID] = rec.bucket.Timestamp
+				}
+			}
+		}
+	}
+
+	starts, err := s.segments(s.tiers[0])
+	if err != nil {
+		return err
+	}
+	for _, start := range starts {
+		data, err := s.root.ReadFile(segmentPath(s.tiers[0], start))
+		if err != nil {
+			return fmt.Errorf("read raw segment: %w", err)
+		}
+		records, _ := decodeRecords(data)
+		for _, rec := range records {
+			for i := 1; i < len(s.tiers); i++ {
+				bucketStart := rec.bucket.Timestamp.Truncate(s.tiers[i].resolution)
+				if last, ok := lastFlushed[i][rec.gpuID]; ok && !bucketStart.After(last) {
+					continue
+				}
+				if err := s.rollupLocked(i, rec.gpuID, rec.bucket); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) truncateTorn(t tier, start time.Time) error {
+	name := segmentPath(t, start)
+	data, err := s.root.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("read %s segment: %w", t.name, err)
+	}
+	_, valid := decodeRecords(data)
+	if valid == len(data) {
+		return nil
+	}
+
+	s.logger.Warn("truncating torn segment", "tier", t.name, "segment", name, "valid_bytes", valid, "size", len(data))
+	file, err := s.root.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("open %s segment: %w", t.name, err)
+	}
+	defer file.Close()
+	if err := file.Truncate(int64(valid)); err != nil {
+		return fmt.Errorf("truncate %s segment: %w", t.name, err)
+	}
+
+	return file.Sync()
+}
+
+// applyRetention removes segments that ended before their tier's retention.
+// It only touches the filesystem, so it runs without the store lock.
+func (s *Store) applyRetention() {
+	now := s.now()
+	for _, t := range s.tiers {
+		starts, err := s.segments(t)
+		if err != nil {
+			s.logger.Warn("failed to list segments", "tier", t.name, "err", err)
+
+			continue
+		}
+		for _, start := range starts {
+			if !start.Add(t.span).Before(now.Add(-t.retention)) {
+				continue
+			}
+			if err := s.root.Remove(segmentPath(t, start)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				s.logger.Warn("failed to remove expired segment", "tier", t.name, "err", err)
+			}
+		}
+	}
+}
+
+// segments lists segment start times of a tier in ascending order.
+func (s *Store) segments(t tier) ([]time.Time, error) {
+	dir, err := s.root.Open(t.name)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Read-only stores may point at a data dir that was never written.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open %s tier: %w", t.name, err)
+	}
+	defer dir.Close()
+
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		return nil, fmt.Errorf("list %s tier: %w", t.name, err)
+	}
+
+	starts := make([]time.Time, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		unix, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		starts = append(starts, time.Unix(unix, 0))
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	return starts, nil
+}
+
+func segmentPath(t tier, start time.Time) string {
+	return path.Join(t.name, strconv.FormatInt(start.Unix(), 10)+segmentExt)
+}
+
+func (w *segmentWriter) close() error {
+	syncErr := w.file.Sync()
+	closeErr := w.file.Close()
+
+	return errors.Join(syncErr, closeErr)
+}
+
+func (r *rollup) add(bucket sampler.HistoryBucket) {
+	weight := max(bucket.Count, 1)
+	r.count += weight
+	for name, agg := range bucket.Metrics {
+		acc, ok := r.metrics[name]
+		if !ok {
+			r.metrics[name] = &metricAcc{min: agg.Min, max: agg.Max, sum: agg.Avg * float64(weight), n: weight}
+
+			continue
+		}
+		acc.min = min(acc.min, agg.Min)
+		acc.max = max(acc.max, agg.Max)
+		acc.sum += agg.Avg * float64(weight)
+		acc.n += weight
+	}
+}
+
+func (r *rollup) bucket() sampler.HistoryBucket {
+	bucket := sampler.HistoryBucket{
+		Timestamp: r.start,
+		Count:     r.count,
+		Metrics:   make(map[string]sampler.Aggregate, len(r.metrics)),
+	}
+	for name, acc := range r.metrics {
+		bucket.Metrics[name] = sampler.Aggregate{Min: acc.min, Avg: acc.sum / float64(acc.n), Max: acc.max}
+	}
+
+	return bucket
+}
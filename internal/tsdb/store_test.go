@@ -0,0 +1,268 @@
+package tsdb
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/sampler"
+)
+
+// testClock is read by the store's writer goroutine.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *testClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func openTestStore(t *testing.T, dir string, clock *testClock) *Store {
+	t.Helper()
+
+	store, err := Open(Options{
+		Dir:    dir,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Now:    clock.Now,
+	})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+
+	return store
+}
+
+func appendBusy(t *testing.T, store *Store, ts time.Time, busy float64) {
+	t.Helper()
+
+	value := busy
+	if err := store.Append(sampler.Sample{GPUId: "card0", Timestamp: ts, Metrics: sampler.Metrics{GPUBusyPct: &value}}); err != nil {
+		t.Fatalf("Append returned error: %v", err)
+	}
+}
+
+func flush(t *testing.T, store *Store) {
+	t.Helper()
+
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush returned error: %v", err)
+	}
+}
+
+// fillMinutes appends one sample every 20s for three minutes; each minute
+// averages to its index times ten plus ten.
+func fillMinutes(t *testing.T, store *Store, base time.Time) {
+	t.Helper()
+
+	for minute := range 3 {
+		for i, offset := range []float64{-5, 0, 5} {
+			ts := base.Add(time.Duration(minute)*time.Minute + time.Duration(i)*20*time.Second)
+			appendBusy(t, store, ts, float64(minute+1)*10+offset)
+		}
+	}
+}
+
+func TestStoreRawAndRollups(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := &testClock{now: base.Add(3 * time.Minute)}
+	store := openTestStore(t, t.TempDir(), clock)
+	t.Cleanup(func() { _ = store.Close() })
+
+	fillMinutes(t, store, base)
+	flush(t, store)
+
+	raw, err := store.Query("card0", base, time.Time{}, time.Second)
+	if err != nil {
+		t.Fatalf("Query raw returned error: %v", err)
+	}
+	if len(raw) != 9 || raw[0].Metrics["gpu_busy_pct"].Avg != 5 {
+		t.Fatalf("unexpected raw points: %+v", raw)
+	}
+
+	minutes, err := store.Query("card0", base, time.Time{}, time.Minute)
+	if err != nil {
+		t.Fatalf("Query 1m returned error: %v", err)
+	}
+	if len(minutes) != 3 {
+		t.Fatalf("expected 3 minute buckets (last in progress), got %+v", minutes)
+	}
+	for i, bucket := range minutes {
+		want := sampler.Aggregate{Min: float64(i+1)*10 - 5, Avg: float64(i+1) * 10, Max: float64(i+1)*10 + 5}
+		if bucket.Count != 3 || bucket.Metrics["gpu_busy_pct"] != want {
+			t.Fatalf("unexpected bucket %d: %+v", i, bucket)
+		}
+	}
+
+	hours, err := store.Query("card0", base, time.Time{}, 6*time.Hour)
+	if err != nil {
+		t.Fatalf("Query 1h returned error: %v", err)
+	}
+	if len(hours) != 1 || hours[0].Count != 9 || hours[0].Metrics["gpu_busy_pct"].Avg != 20 {
+		t.Fatalf("unexpected hour buckets: %+v", hours)
+	}
+
+	other, err := store.Query("card1", base, time.Time{}, time.Second)
+	if err != nil || len(other) != 0 {
+		t.Fatalf("expected no data for other gpu, got %+v (err %v)", other, err)
+	}
+}
+
+func TestStoreRecoversTornSegment(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := &testClock{now: base.Add(3 * time.Minute)}
+
+	store := openTestStore(t, dir, clock)
+	fillMinutes(t, store, base)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	// Simulate a crash halfway through writing a record.
+	segment := filepath.Join(dir, "raw", segmentName(base, time.Hour))
+	file, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open segment: %v", err)
+	}
+	if _, err := file.Write([]byte{42, 0, 0, 0, 1, 2}); err != nil {
+		t.Fatalf("write torn record: %v", err)
+	}
+	_ = file.Close()
+
+	store = openTestStore(t, dir, clock)
+	t.Cleanup(func() { _ = store.Close() })
+
+	appendBusy(t, store, base.Add(3*time.Minute), 99)
+	flush(t, store)
+
+	raw, err := store.Query("card0", base, time.Time{}, time.Second)
+	if err != nil {
+		t.Fatalf("Query raw returned error: %v", err)
+	}
+	if len(raw) != 10 || raw[9].Metrics["gpu_busy_pct"].Avg != 99 {
+		t.Fatalf("expected torn tail dropped and new sample readable, got %d points", len(raw))
+	}
+
+	// The third minute was still in progress at Close and must be rebuilt
+	// from raw samples, then flushed by the sample of the fourth minute.
+	minutes, err := store.Query("card0", base, time.Time{}, time.Minute)
+	if err != nil {
+		t.Fatalf("Query 1m returned error: %v", err)
+	}
+	if len(minutes) != 4 || minutes[2].Count != 3 || minutes[2].Metrics["gpu_busy_pct"].Avg != 30 {
+		t.Fatalf("unexpected minute buckets after recovery: %+v", minutes)
+	}
+}
+
+func TestStoreRetention(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := &testClock{now: base}
+
+	store := openTestStore(t, dir, clock)
+	t.Cleanup(func() { _ = store.Close() })
+	appendBusy(t, store, base, 10)
+
+	clock.Set(base.Add(48 * time.Hour))
+	appendBusy(t, store, clock.Now(), 20)
+	flush(t, store)
+
+	if _, err := os.Stat(filepath.Join(dir, "raw", segmentName(base, time.Hour))); !os.IsNotExist(err) {
+		t.Fatalf("expected expired raw segment to be removed, stat err %v", err)
+	}
+
+	// The minute rollup of the first sample outlives the raw tier.
+	minutes, err := store.Query("card0", base.Add(-time.Minute), time.Time{}, time.Minute)
+	if err != nil {
+		t.Fatalf("Query returned error: %v", err)
+	}
+	if len(minutes) != 2 || minutes[0].Metrics["gpu_busy_pct"].Avg != 10 {
+		t.Fatalf("unexpected minute buckets: %+v", minutes)
+	}
+}
+
+func TestDecodeRecordsStopsAtCorruption(t *testing.T) {
+	t.Parallel()
+
+	busy := 12.5
+	bucket := sampler.SampleBucket(sampler.Sample{Timestamp: time.Unix(100, 0), Metrics: sampler.Metrics{GPUBusyPct: &busy}})
+	data := appendRecord(nil, "card0", bucket)
+	good := len(data)
+	data = appendRecord(data, "card0", bucket)
+	data[len(data)-1] ^= 0xff
+
+	records, valid := decodeRecords(data)
+	if len(records) != 1 || valid != good {
+		t.Fatalf("expected one valid record of %d bytes, got %d records and %d bytes", good, len(records), valid)
+	}
+	if records[0].gpuID != "card0" || records[0].bucket.Metrics["gpu_busy_pct"].Avg != busy {
+		t.Fatalf("unexpected record %+v", records[0])
+	}
+}
+
+func segmentName(ts time.Time, span time.Duration) string {
+	return filepath.Base(segmentPath(tier{span: span}, ts.Truncate(span)))
+}
+
+func TestStoreSamplesReadOnly(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := &testClock{now: base.Add(3 * time.Minute)}
+
+	writer := openTestStore(t, dir, clock)
+	t.Cleanup(func() { _ = writer.Close() })
+	fillMinutes(t, writer, base)
+	temp := 50.0
+	if err := writer.Append(sampler.Sample{GPUId: "card1", Timestamp: base.Add(time.Minute), Metrics: sampler.Metrics{TempC: &temp}}); err != nil {
+		t.Fatalf("Append returned error: %v", err)
+	}
+	flush(t, writer)
+
+	reader, err := Open(Options{Dir: dir, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("Open read-only returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = reader.Close() })
+
+	if err := reader.Append(sampler.Sample{GPUId: "card0", Timestamp: base}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+
+	var gpus []string
+	err = reader.Samples("", base.Add(time.Minute), base.Add(2*time.Minute), func(gpuID string, _ sampler.HistoryBucket) error {
+		gpus = append(gpus, gpuID)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Samples returned error: %v", err)
+	}
+	// Three card0 samples of the second minute, the first of the third minute
+	// at exactly the upper bound, then card1 in append order.
+	if len(gpus) != 5 || gpus[4] != "card1" {
+		t.Fatalf("unexpected samples %v", gpus)
+	}
+}
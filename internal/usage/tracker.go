@@ -0,0 +1,367 @@
+// Package usage accumulates per-application, per-user and per-container GPU
+// usage over rolling time windows from process scanner snapshots.
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/procscan"
+)
+
+const (
+	minuteSlots = 60
+	hourSlots   = 7 * 24
+
+	// MaxWindow is the longest window that can be queried.
+	MaxWindow = hourSlots * time.Hour
+
+	hostContainer = "host"
+
+	// maxKeys bounds the entries of each grouping per GPU; the least
+	// recently seen entry makes room for a new one.
+	maxKeys = 1024
+)
+
+// Grouping selects the dimension usage is aggregated by.
+type Grouping string
+
+// Supported groupings.
+const (
+	ByApp       Grouping = "app"
+	ByUser      Grouping = "user"
+	ByContainer Grouping = "container"
+)
+
+// ParseGrouping validates a grouping name.
+func ParseGrouping(value string) (Grouping, error) {
+	switch Grouping(strings.ToLower(strings.TrimSpace(value))) {
+	case "", ByApp:
+		return ByApp, nil
+	case ByUser:
+		return ByUser, nil
+	case ByContainer:
+		return ByContainer, nil
+	default:
+		return "", fmt.Errorf("unsupported grouping %q", value)
+	}
+}
+
+// ParseWindow parses a window such as "15m", "1h", "1d" or "1w".
+func ParseWindow(value string) (time.Duration, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return time.Hour, nil
+	}
+
+	var window time.Duration
+	switch {
+	case strings.HasSuffix(value, "d"), strings.HasSuffix(value, "w"):
+		count, err := strconv.Atoi(value[:len(value)-1])
+		if err != nil {
+			return 0, fmt.Errorf("parse window %q: %w", value, err)
+		}
+		unit := 24 * time.Hour
+		if strings.HasSuffix(value, "w") {
+			unit *= 7
+		}
+		window = time.Duration(count) * unit
+	default:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("parse window %q: %w", value, err)
+		}
+		window = parsed
+	}
+
+	if window <= 0 {
+		return 0, fmt.Errorf("window must be > 0")
+	}
+	if window > MaxWindow {
+		return 0, fmt.Errorf("window must be <= %s", MaxWindow)
+	}
+
+	return window, nil
+}
+
+// Entry is a single leaderboard row.
+type Entry struct {
+	Key             string  `json:"key"`
+	Name            string  `json:"name,omitempty"`
+	User            string  `json:"user,omitempty"`
+	Cgroup          string  `json:"cgroup,omitempty"`
+	Container       string  `json:"container,omitempty"`
+	GPUTimeMS       float64 `json:"gpu_time_ms"`
+	VRAMByteSeconds float64 `json:"vram_byte_seconds"`
+	AvgVRAMBytes    float64 `json:"avg_vram_bytes"`
+}
+
+// Leaderboard lists the heaviest GPU users of a window, sorted by GPU time.
+type Leaderboard struct {
+	GPUId   string    `json:"gpu_id"`
+	By      Grouping  `json:"by"`
+	Window  string    `json:"window"`
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+	Entries []Entry   `json:"entries"`
+}
+
+// Tracker integrates GPU time and VRAM residency from process snapshots.
+type Tracker struct {
+	maxGap time.Duration
+
+	mu   sync.Mutex
+	gpus map[string]*gpuUsage
+}
+
+type gpuUsage struct {
+	lastTS time.Time
+	groups map[Grouping]map[string]*accumulator
+}
+
+type accumulator struct {
+	entry    Entry
+	lastSeen time.Time
+	minutes  [minuteSlots]bucket
+	hours    [hourSlots]bucket
+}
+
+type bucket struct {
+	index           int64
+	gpuMS           float64
+	vramByteSeconds float64
+}
+
+// NewTracker constructs a Tracker. Gaps between consecutive snapshots longer
+// than maxGap (e.g. while the scanner idles) are only credited up to maxGap.
+func NewTracker(maxGap time.Duration) *Tracker {
+	if maxGap <= 0 {
+		maxGap = time.Minute
+	}
+
+	return &Tracker{
+		maxGap: maxGap,
+		gpus:   make(map[string]*gpuUsage),
+	}
+}
+
+// Observe accumulates a process snapshot. It is safe for concurrent use and
+// is meant to be registered as a procscan listener.
+func (t *Tracker) Observe(snapshot procscan.Snapshot) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage, ok := t.gpus[snapshot.GPUId]
+	if !ok {
+		usage = &gpuUsage{groups: make(map[Grouping]map[string]*accumulator)}
+		t.gpus[snapshot.GPUId] = usage
+	}
+
+	prev := usage.lastTS
+	if !prev.IsZero() && !snapshot.Timestamp.After(prev) {
+		return
+	}
+	usage.lastTS = snapshot.Timestamp
+	if prev.IsZero() {
+		return
+	}
+
+	elapsed := snapshot.Timestamp.Sub(prev)
+	if elapsed > t.maxGap {
+		elapsed = t.maxGap
+	}
+	seconds := elapsed.Seconds()
+
+	for _, proc := range snapshot.Processes {
+		var gpuMS, vramByteSeconds float64
+		if proc.GPUTimeMSPerS != nil {
+			gpuMS = *proc.GPUTimeMSPerS * seconds
+		}
+		if proc.VRAMBytes != nil {
+			vramByteSeconds = float64(*proc.VRAMBytes) * seconds
+		}
+		if gpuMS == 0 && vramByteSeconds == 0 {
+			continue
+		}
+
+		container := proc.Container
+		if container == "" {
+			container = hostContainer
+		}
+
+		cgroup := unitCgroup(proc.Cgroup)
+		keys := map[Grouping]Entry{
+			ByApp:       {Key: proc.Name + "@" + cgroup, Name: proc.Name, Cgroup: cgroup, Container: proc.Container},
+			ByUser:      {Key: proc.User, User: proc.User},
+			ByContainer: {Key: container, Container: container},
+		}
+		for grouping, entry := range keys {
+			group, ok := usage.groups[grouping]
+			if !ok {
+				group = make(map[string]*accumulator)
+				usage.groups[grouping] = group
+			}
+			acc, ok := group[entry.Key]
+			if !ok {
+				if len(group) >= maxKeys {
+					evictOldest(group)
+				}
+				acc = &accumulator{entry: entry}
+				group[entry.Key] = acc
+			}
+			acc.add(snapshot.Timestamp, gpuMS, vramByteSeconds)
+		}
+	}
+
+	t.pruneLocked(usage, snapshot.Timestamp)
+}
+
+// Leaderboard summarises usage for the GPU over the window ending at now.
+func (t *Tracker) Leaderboard(gpuID string, by Grouping, window time.Duration, now time.Time) Leaderboard {
+	board := Leaderboard{
+		GPUId:   gpuID,
+		By:      by,
+		Window:  window.String(),
+		Since:   now.Add(-window).UTC(),
+		Until:   now.UTC(),
+		Entries: []Entry{},
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage, ok := t.gpus[gpuID]
+	if !ok {
+		return board
+	}
+
+	seconds := window.Seconds()
+	for _, acc := range usage.groups[by] {
+		gpuMS, vramByteSeconds := acc.sum(now, window)
+		if gpuMS == 0 && vramByteSeconds == 0 {
+			continue
+		}
+		entry := acc.entry
+		entry.GPUTimeMS = gpuMS
+		entry.VRAMByteSeconds = vramByteSeconds
+		entry.AvgVRAMBytes = vramByteSeconds / seconds
+		board.Entries = append(board.Entries, entry)
+	}
+
+	sort.Slice(board.Entries, func(i, j int) bool {
+		a, b := board.Entries[i], board.Entries[j]
+		if a.GPUTimeMS != b.GPUTimeMS {
+			return a.GPUTimeMS > b.GPUTimeMS
+		}
+		if a.VRAMByteSeconds != b.VRAMByteSeconds {
+			return a.VRAMByteSeconds > b.VRAMByteSeconds
+		}
+
+		return a.Key < b.Key
+	})
+
+	return board
+}
+
+// unitCgroup trims a cgroup path to the systemd slice or service it runs
+// under. systemd puts every launch of an application into a scope of its
+// own (e.g. app-firefox-1234.scope, session-3.scope), which would split one
+// application into a new entry per launch.
+func unitCgroup(cgroup string) string {
+	parts := strings.Split(cgroup, "/")
+	for i, part := range parts {
+		if strings.HasSuffix(part, ".scope") {
+			parts = parts[:i]
+
+			break
+		}
+	}
+	trimmed := strings.Join(parts, "/")
+	if trimmed == "" && cgroup != "" {
+		return "/"
+	}
+
+	return trimmed
+}
+
+func evictOldest(group map[string]*accumulator) {
+	var (
+		oldestKey string
+		oldest    time.Time
+		found     bool
+	)
+	for key, acc := range group {
+		if !found || acc.lastSeen.Before(oldest) {
+			oldestKey, oldest, found = key, acc.lastSeen, true
+		}
+	}
+	delete(group, oldestKey)
+}
+
+func (t *Tracker) pruneLocked(usage *gpuUsage, now time.Time) {
+	for _, group := range usage.groups {
+		for key, acc := range group {
+			if now.Sub(acc.lastSeen) > MaxWindow {
+				delete(group, key)
+			}
+		}
+	}
+}
+
+func (a *accumulator) add(ts time.Time, gpuMS, vramByteSeconds float64) {
+	a.lastSeen = ts
+	unix := ts.Unix()
+
+	minute := unix / 60
+	mb := &a.minutes[minute%minuteSlots]
+	if mb.index != minute {
+		*mb = bucket{index: minute}
+	}
+	mb.gpuMS += gpuMS
+	mb.vramByteSeconds += vramByteSeconds
+
+	hour := unix / 3600
+	hb := &a.hours[hour%hourSlots]
+	if hb.index != hour {
+		*hb = bucket{index: hour}
+	}
+	hb.gpuMS += gpuMS
+	hb.vramByteSeconds += vramByteSeconds
+}
+
+// sum totals the buckets covering the window. Windows up to an hour use
+// minute resolution; longer windows are rounded to whole hours.
+func (a *accumulator) sum(now time.Time, window time.Duration) (float64, float64) {
+	var gpuMS, vramByteSeconds float64
+	unix := now.Unix()
+
+	if window <= time.Hour {
+		current := unix / 60
+		oldest := (unix - int64(window.Seconds())) / 60
+		for i := range a.minutes {
+			b := a.minutes[i]
+			if b.index > oldest && b.index <= current {
+				gpuMS += b.gpuMS
+				vramByteSeconds += b.vramByteSeconds
+			}
+		}
+
+		return gpuMS, vramByteSeconds
+	}
+
+	current := unix / 3600
+	oldest := (unix - int64(window.Seconds())) / 3600
+	for i := range a.hours {
+		b := a.hours[i]
+		if b.index > oldest && b.index <= current {
+			gpuMS += b.gpuMS
+			vramByteSeconds += b.vramByteSeconds
+		}
+	}
+
+	return gpuMS, vramByteSeconds
+}
@@ -0,0 +1,173 @@
+package usage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/skobkin/amdgputop-web/internal/procscan"
+)
+
+func TestTrackerLeaderboard(t *testing.T) {
+	tracker := NewTracker(10 * time.Second)
+	base := time.Unix(1_700_000_000, 0)
+
+	vram := uint64(1 << 20)
+	busy := 100.0
+	idle := 10.0
+
+	snapshot := func(ts time.Time, pidA, pidB int) procscan.Snapshot {
+		return procscan.Snapshot{
+			GPUId:     "card0",
+			Timestamp: ts,
+			Processes: []procscan.Process{
+				{PID: pidA, User: "alice", Name: "blender", Cgroup: "/user.slice", VRAMBytes: &vram, GPUTimeMSPerS: &busy},
+				{PID: pidB, User: "bob", Name: "game", Cgroup: "/docker/abc", Container: "abc", GPUTimeMSPerS: &idle},
+			},
+		}
+	}
+
+	tracker.Observe(snapshot(base, 10, 20))
+	// PIDs change between scans; usage is keyed on name and cgroup instead.
+	tracker.Observe(snapshot(base.Add(2*time.Second), 11, 21))
+	// A long gap is only credited up to maxGap.
+	tracker.Observe(snapshot(base.Add(62*time.Second), 12, 22))
+
+	now := base.Add(62 * time.Second)
+
+	board := tracker.Leaderboard("card0", ByApp, time.Hour, now)
+	if len(board.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(board.Entries))
+	}
+	first := board.Entries[0]
+	if first.Name != "blender" {
+		t.Fatalf("expected blender to lead, got %q", first.Name)
+	}
+	if first.GPUTimeMS != 1200 {
+		t.Fatalf("unexpected gpu time: %v", first.GPUTimeMS)
+	}
+	if first.VRAMByteSeconds != float64(vram)*12 {
+		t.Fatalf("unexpected vram seconds: %v", first.VRAMByteSeconds)
+	}
+	if first.AvgVRAMBytes != float64(vram)*12/3600 {
+		t.Fatalf("unexpected avg vram: %v", first.AvgVRAMBytes)
+	}
+
+	users := tracker.Leaderboard("card0", ByUser, 24*time.Hour, now)
+	if len(users.Entries) != 2 || users.Entries[1].User != "bob" || users.Entries[1].GPUTimeMS != 120 {
+		t.Fatalf("unexpected user leaderboard: %+v", users.Entries)
+	}
+
+	containers := tracker.Leaderboard("card0", ByContainer, time.Hour, now)
+	if len(containers.Entries) != 2 || containers.Entries[0].Container != hostContainer || containers.Entries[1].Container != "abc" {
+		t.Fatalf("unexpected container leaderboard: %+v", containers.Entries)
+	}
+
+	later := tracker.Leaderboard("card0", ByApp, 15*time.Minute, now.Add(2*time.Hour))
+	if len(later.Entries) != 0 {
+		t.Fatalf("expected window to expire, got %+v", later.Entries)
+	}
+
+	if empty := tracker.Leaderboard("card1", ByApp, time.Hour, now); len(empty.Entries) != 0 {
+		t.Fatalf("expected no entries for unknown gpu")
+	}
+}
+
+func TestTrackerGroupsLaunchesByUnit(t *testing.T) {
+	tracker := NewTracker(10 * time.Second)
+	base := time.Unix(1_700_000_000, 0)
+	busy := 100.0
+
+	const app = "/user.slice/user-1000.slice/user@1000.service/app.slice"
+	for i := range 4 {
+		// Every launch lands in a scope of its own.
+		cgroup := app + "/app-firefox-" + strconv.Itoa(1000+i) + ".scope"
+		tracker.Observe(procscan.Snapshot{
+			GPUId:     "card0",
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Processes: []procscan.Process{{PID: 100 + i, User: "alice", Name: "firefox", Cgroup: cgroup, GPUTimeMSPerS: &busy}},
+		})
+	}
+
+	board := tracker.Leaderboard("card0", ByApp, time.Hour, base.Add(time.Minute))
+	if len(board.Entries) != 1 || board.Entries[0].Cgroup != app || board.Entries[0].GPUTimeMS != 300 {
+		t.Fatalf("expected one entry per unit, got %+v", board.Entries)
+	}
+}
+
+func TestTrackerCapsKeys(t *testing.T) {
+	tracker := NewTracker(10 * time.Second)
+	base := time.Unix(1_700_000_000, 0)
+	busy := 100.0
+
+	for i := range maxKeys + 2 {
+		tracker.Observe(procscan.Snapshot{
+			GPUId:     "card0",
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			Processes: []procscan.Process{{PID: i, User: "alice", Name: "job-" + strconv.Itoa(i), GPUTimeMSPerS: &busy}},
+		})
+	}
+
+	board := tracker.Leaderboard("card0", ByApp, 24*time.Hour, base.Add(time.Hour))
+	if len(board.Entries) != maxKeys {
+		t.Fatalf("expected %d entries, got %d", maxKeys, len(board.Entries))
+	}
+	for _, entry := range board.Entries {
+		if entry.Name == "job-1" {
+			t.Fatalf("expected the least recently seen entry to be evicted")
+		}
+	}
+}
+
+func TestUnitCgroup(t *testing.T) {
+	cases := map[string]string{
+		"/user.slice/user-1000.slice/session-3.scope":                         "/user.slice/user-1000.slice",
+		"/system.slice/docker-0123abcd.scope":                                 "/system.slice",
+		"/system.slice/ollama.service":                                        "/system.slice/ollama.service",
+		"/user.slice/user-1000.slice/user@1000.service/app.slice/a.scope/sub": "/user.slice/user-1000.slice/user@1000.service/app.slice",
+		"/docker/abc": "/docker/abc",
+		"/init.scope": "/",
+		"":            "",
+	}
+	for input, want := range cases {
+		if got := unitCgroup(input); got != want {
+			t.Fatalf("unitCgroup(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":    time.Hour,
+		"15m": 15 * time.Minute,
+		"1d":  24 * time.Hour,
+		"1w":  7 * 24 * time.Hour,
+	}
+	for input, want := range cases {
+		got, err := ParseWindow(input)
+		if err != nil {
+			t.Fatalf("ParseWindow(%q): %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("ParseWindow(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	for _, input := range []string{"0s", "-1h", "2w", "bogus"} {
+		if _, err := ParseWindow(input); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
+
+func TestParseGrouping(t *testing.T) {
+	if got, err := ParseGrouping(""); err != nil || got != ByApp {
+		t.Fatalf("expected default app grouping, got %q, %v", got, err)
+	}
+	if got, err := ParseGrouping("User"); err != nil || got != ByUser {
+		t.Fatalf("expected user grouping, got %q, %v", got, err)
+	}
+	if _, err := ParseGrouping("pid"); err == nil {
+		t.Fatalf("expected error for unsupported grouping")
+	}
+}
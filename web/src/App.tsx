@@ -35,6 +35,7 @@ const App = () => {
   const lastUpdatedTs = useAppStore((state) => state.lastUpdatedTs);
   const version = useAppStore((state) => state.version);
   const error = useAppStore((state) => state.error);
+  const alerts = useAppStore((state) => state.alerts);
   const relativeTimeRefreshMs = useAppStore((state) => state.relativeTimeRefreshMs);
   const setGPUs = useAppStore((state) => state.setGPUs);
   const setSelectedGpuId = useAppStore((state) => state.setSelectedGpuId);
@@ -44,7 +45,11 @@ const App = () => {
   const setChartsMaxPoints = useAppStore((state) => state.setChartsMaxPoints);
   const setChartWindowPoints = useAppStore((state) => state.setChartWindowPoints);
   const updateStats = useAppStore((state) => state.updateStats);
+  const loadHistory = useAppStore((state) => state.loadHistory);
   const updateProcs = useAppStore((state) => state.updateProcs);
+  const updateAlert = useAppStore((state) => state.updateAlert);
+  const clearAlerts = useAppStore((state) => state.clearAlerts);
+  const addEvent = useAppStore((state) => state.addEvent);
   const clearGpuData = useAppStore((state) => state.clearGpuData);
   const setVersion = useAppStore((state) => state.setVersion);
   const setError = useAppStore((state) => state.setError);
@@ -228,6 +233,8 @@ const App = () => {
             const message: ServerMessage = JSON.parse(event.data);
             switch (message.type) {
               case 'hello':
+                // Active alerts are re-sent after every hello.
+                clearAlerts();
                 setFeatures(message.features ?? {});
                 setSampleInterval(message.interval_ms);
                 if (typeof message.charts_max_points === 'number') {
@@ -238,9 +245,18 @@ const App = () => {
               case 'stats':
                 updateStats(message as StatsSample);
                 break;
+              case 'history':
+                loadHistory(message);
+                break;
               case 'procs':
                 updateProcs(message as ProcSnapshot);
                 break;
+              case 'alert':
+                updateAlert(message);
+                break;
+              case 'event':
+                addEvent(message);
+                break;
               case 'error':
                 setError(message.message);
                 break;
@@ -286,7 +302,7 @@ const App = () => {
       wsRef.current = null;
       socket?.close(1000, 'shutdown');
     };
-  }, [fetchVersionInfo, setConnection, setError, setFeatures, setGPUs, setSampleInterval, setVersion, updateProcs, updateStats]);
+  }, [addEvent, clearAlerts, fetchVersionInfo, loadHistory, setConnection, setError, setFeatures, setGPUs, setSampleInterval, setVersion, updateAlert, updateProcs, updateStats]);
 
   // Resubscribe whenever selection changes.
   useEffect(() => {
@@ -413,6 +429,25 @@ const App = () => {
         </div>
       )}
 
+      {Object.values(alerts)
+        .filter((alert) => alert.state === 'firing')
+        .map((alert) => (
+          <div
+            key={`${alert.rule}/${alert.gpu_id}`}
+            class={`status-banner ${alert.severity === 'critical' ? 'error' : 'warn'}`}
+            role="alert"
+          >
+            {alert.gpu_id}: {alert.rule} ({alert.expr}
+            {alert.value != null ? `, now ${Number(alert.value.toFixed(2))}` : ''})
+          </div>
+        ))}
+
+      {statsSample?.power_state === 'suspended' && (
+        <div class="status-banner info" role="status">
+          GPU is runtime-suspended; metrics resume when it wakes up.
+        </div>
+      )}
+
       <StatsTiles sample={statsSample} nowMs={nowMs} />
       <MemoryBars sample={statsSample} />
       {features.procs ? <ProcTable snapshot={procSnapshot} nowMs={nowMs} /> : null}
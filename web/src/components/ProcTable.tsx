@@ -119,7 +119,8 @@ const ProcTable: FunctionalComponent<Props> = ({ snapshot, nowMs }) => {
                   <td>{proc.user || '—'}</td>
                   <td class="name-cell">
                     <div class="proc-name" title={proc.cmdTooltip || proc.name || undefined}>
-                      <strong>{proc.name || '—'}</strong>
+                      <strong>{proc.display_name || proc.name || '—'}</strong>
+                      {proc.category ? <small class="muted"> · {proc.category}</small> : null}
                     </div>
                   </td>
                   <td>{formatBytes(proc.vram_bytes)}</td>
@@ -133,7 +134,12 @@ const ProcTable: FunctionalComponent<Props> = ({ snapshot, nowMs }) => {
         </div>
       )}
       <small class="muted">
-        Capabilities: VRAM/GTT {snapshot.capabilities.vram_gtt_from_fdinfo ? '✓' : '—'},
+        Capabilities: VRAM/GTT{' '}
+        {snapshot.capabilities.vram_gtt_from_fdinfo
+          ? '✓'
+          : snapshot.capabilities.vram_gtt_from_gem_info
+            ? '✓ (gem_info)'
+            : '—'},
         GPU time {snapshot.capabilities.engine_time_from_fdinfo ? '✓' : '—'}
       </small>
     </section>
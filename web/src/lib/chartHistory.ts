@@ -1,4 +1,4 @@
-import type { StatsSample } from '@/types';
+import type { HistoryMessage, StatsSample } from '@/types';
 
 export type ChartMetricKey =
   | 'gpuLoad'
@@ -47,9 +47,17 @@ export function appendChartSample(
   sample: StatsSample,
   capacity: number
 ): ChartHistory {
+  const ts = Date.parse(sample.ts);
+  if (history && history.capacity === capacity && history.size > 0) {
+    const last = history.timestamps[(history.cursor - 1 + history.capacity) % history.capacity];
+    if (last != null && ts <= last) {
+      // Already covered by the history backfill.
+      return history;
+    }
+  }
   const next = history && history.capacity === capacity ? { ...history } : createChartHistory(capacity);
   const index = next.cursor;
-  next.timestamps[index] = Date.parse(sample.ts);
+  next.timestamps[index] = ts;
   next.gpuLoad[index] = sample.metrics.gpu_busy_pct ?? null;
   next.vramUsed[index] = sample.metrics.vram_used_bytes ?? null;
   next.gttUsed[index] = sample.metrics.gtt_used_bytes ?? null;
@@ -63,6 +71,27 @@ export function appendChartSample(
   return next;
 }
 
+export function chartHistoryFromBackfill(message: HistoryMessage, capacity: number): ChartHistory {
+  const next = createChartHistory(capacity);
+  const start = Math.max(0, message.ts_ms.length - capacity);
+  const column = (key: keyof HistoryMessage['metrics'], i: number) => message.metrics[key]?.[i] ?? null;
+  for (let i = start; i < message.ts_ms.length; i += 1) {
+    const index = next.cursor;
+    next.timestamps[index] = message.ts_ms[i];
+    next.gpuLoad[index] = column('gpu_busy_pct', i);
+    next.vramUsed[index] = column('vram_used_bytes', i);
+    next.gttUsed[index] = column('gtt_used_bytes', i);
+    next.sclk[index] = column('sclk_mhz', i);
+    next.mclk[index] = column('mclk_mhz', i);
+    next.temp[index] = column('temp_c', i);
+    next.power[index] = column('power_w', i);
+    next.fan[index] = column('fan_rpm', i);
+    next.cursor = (index + 1) % next.capacity;
+    next.size = Math.min(next.size + 1, next.capacity);
+  }
+  return next;
+}
+
 export function buildChartSeries(
   history: ChartHistory,
   windowPoints: number,
@@ -1,12 +1,15 @@
 import { create } from 'zustand';
 import type {
+  AlertMessage,
   ConnectionStatus,
+  GPUEvent,
   GPUInfo,
+  HistoryMessage,
   ProcSnapshot,
   StatsSample,
   VersionInfo
 } from './types';
-import { appendChartSample, type ChartHistory } from './lib/chartHistory';
+import { appendChartSample, chartHistoryFromBackfill, type ChartHistory } from './lib/chartHistory';
 
 type FeatureMap = Record<string, boolean>;
 
@@ -19,6 +22,7 @@ const SELECTED_GPU_STORAGE_KEY = 'amdgputop-web:selected-gpu-id';
 const RELATIVE_TIME_REFRESH_STORAGE_KEY = 'amdgputop-web:relative-time-refresh-ms';
 
 const DEFAULT_CHART_WINDOW_POINTS = 300;
+const MAX_EVENTS = 500;
 export const RELATIVE_TIME_REFRESH_OPTIONS = [5000, 10000, 30000, 60000] as const;
 const DEFAULT_RELATIVE_TIME_REFRESH_MS = 10000;
 
@@ -136,6 +140,9 @@ interface AppState {
   statsByGpu: Record<string, StatsSample>;
   procsByGpu: Record<string, ProcSnapshot>;
   chartHistoryByGpu: Record<string, ChartHistory>;
+  alerts: Record<string, AlertMessage>;
+  // Recent timeline events (newest last), e.g. for chart markers.
+  events: GPUEvent[];
   lastUpdatedTs: number | null;
   version: VersionInfo | null;
   error: string | null;
@@ -150,7 +157,11 @@ interface AppState {
   setChartWindowPoints: (points: number) => void;
   setChartsCollapsed: (collapsed: boolean) => void;
   updateStats: (sample: StatsSample) => void;
+  loadHistory: (message: HistoryMessage) => void;
   updateProcs: (snapshot: ProcSnapshot) => void;
+  updateAlert: (alert: AlertMessage) => void;
+  clearAlerts: () => void;
+  addEvent: (event: GPUEvent) => void;
   clearGpuData: (gpuId: string) => void;
   setVersion: (info: VersionInfo) => void;
   setError: (message: string | null) => void;
@@ -170,6 +181,8 @@ export const useAppStore = create<AppState>((set) => ({
   statsByGpu: {},
   procsByGpu: {},
   chartHistoryByGpu: {},
+  alerts: {},
+  events: [],
   lastUpdatedTs: null,
   version: null,
   error: null,
@@ -264,11 +277,41 @@ export const useAppStore = create<AppState>((set) => ({
         : state.chartHistoryByGpu,
       lastUpdatedTs: Date.now()
     })),
+  loadHistory: (message) =>
+    set((state) =>
+      state.features.charts
+        ? {
+            chartHistoryByGpu: {
+              ...state.chartHistoryByGpu,
+              [message.gpu_id]: chartHistoryFromBackfill(message, state.chartsMaxPoints)
+            }
+          }
+        : {}
+    ),
   updateProcs: (snapshot) =>
     set((state) => ({
       procsByGpu: { ...state.procsByGpu, [snapshot.gpu_id]: snapshot },
       lastUpdatedTs: Date.now()
     })),
+  updateAlert: (alert) =>
+    set((state) => {
+      const key = `${alert.rule}/${alert.gpu_id}`;
+      const next = { ...state.alerts };
+      if (alert.state === 'resolved') {
+        delete next[key];
+      } else {
+        next[key] = alert;
+      }
+      return { alerts: next };
+    }),
+  clearAlerts: () => set({ alerts: {} }),
+  addEvent: (event) =>
+    set((state) => {
+      if (state.events.some((existing) => existing.id === event.id)) {
+        return {};
+      }
+      return { events: [...state.events, event].slice(-MAX_EVENTS) };
+    }),
   clearGpuData: (gpuId) =>
     set((state) => {
       const nextStats = { ...state.statsByGpu };
@@ -20,16 +20,43 @@ export interface Metrics {
   gtt_total_bytes: number | null;
 }
 
+export interface GPUState {
+  perf_level?: string;
+  power_profile?: string;
+  link_speed?: string;
+  link_width?: string;
+  max_link_speed?: string;
+  max_link_width?: string;
+  throttle_status?: number;
+}
+
 export interface StatsSample {
   type: 'stats';
   gpu_id: string;
   ts: string;
   metrics: Metrics;
+  state?: GPUState;
+  // Runtime PM status; a 'suspended' GPU is not read and has null metrics.
+  power_state?: string;
+  // Set while the GPU's reader is hung; metrics are null and the sample is
+  // not kept in history.
+  quarantined?: boolean;
+  // Min/avg/max of the sub-sampled metrics with APP_SAMPLE_SUBINTERVAL; over
+  // the WebSocket only when subscribed with sub_stats.
+  stats?: Partial<Record<keyof Metrics, { min: number; avg: number; max: number }>>;
+  sub_samples?: number;
 }
 
 export interface ProcScannerCapabilities {
   vram_gtt_from_fdinfo: boolean;
   engine_time_from_fdinfo: boolean;
+  vram_gtt_from_gem_info: boolean;
+}
+
+export interface BOBucket {
+  max_bytes?: number;
+  count: number;
+  bytes: number;
 }
 
 export interface ProcInfo {
@@ -39,9 +66,18 @@ export interface ProcInfo {
   name: string;
   cmd: string;
   render_node: string;
+  cgroup?: string;
+  container?: string;
+  display_name?: string;
+  category?: string;
+  steam_app_id?: string;
+  bo_histogram?: BOBucket[];
   vram_bytes: number | null;
   gtt_bytes: number | null;
   gpu_time_ms_per_s: number | null;
+  power_w?: number;
+  energy_j?: number;
+  energy_wh?: number;
 }
 
 export interface ProcSnapshot {
@@ -52,12 +88,72 @@ export interface ProcSnapshot {
   processes: ProcInfo[];
 }
 
+export interface ProcHistoryPoint {
+  ts: string;
+  vram_bytes: number | null;
+  gtt_bytes: number | null;
+  gpu_time_ms_per_s: number | null;
+}
+
+export interface ProcHistory {
+  gpu_id: string;
+  pid: number;
+  name: string;
+  points: ProcHistoryPoint[];
+}
+
+export interface ProcHistoryMessage {
+  type: 'proc_history';
+  gpu_id: string;
+  processes: ProcHistory[];
+}
+
+export interface HistoryMessage {
+  type: 'history';
+  gpu_id: string;
+  ts_ms: number[];
+  metrics: Partial<Record<keyof Metrics, Array<number | null>>>;
+}
+
 export interface HelloMessage {
   type: 'hello';
   interval_ms: number;
   gpus: GPUInfo[];
   features: Record<string, boolean>;
   charts_max_points?: number;
+  capabilities?: Record<string, CapabilitySummary>;
+}
+
+export interface CapabilitySummary {
+  available: string[];
+  missing?: Record<string, string>;
+}
+
+export interface AlertMessage {
+  type: 'alert';
+  rule: string;
+  gpu_id: string;
+  state: 'pending' | 'firing' | 'resolved';
+  severity: string;
+  expr: string;
+  value: number | null;
+  active_since: string;
+  fired_at?: string;
+  resolved_at?: string;
+  silenced: boolean;
+}
+
+export interface GPUEvent {
+  id: number;
+  gpu_id?: string;
+  ts: string;
+  kind: string;
+  message: string;
+  attrs?: Record<string, string>;
+}
+
+export interface EventMessage extends GPUEvent {
+  type: 'event';
 }
 
 export interface ErrorMessage {
@@ -69,7 +165,16 @@ export interface PongMessage {
   type: 'pong';
 }
 
-export type ServerMessage = HelloMessage | StatsSample | ProcSnapshot | ErrorMessage | PongMessage;
+export type ServerMessage =
+  | HelloMessage
+  | StatsSample
+  | ProcSnapshot
+  | ProcHistoryMessage
+  | HistoryMessage
+  | AlertMessage
+  | EventMessage
+  | ErrorMessage
+  | PongMessage;
 
 export type ConnectionStatus = 'idle' | 'connecting' | 'open' | 'closed' | 'error';
 